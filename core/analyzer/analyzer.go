@@ -1,11 +1,14 @@
 package analyzer
 
 import (
+	"bytes"
 	base "core/base"
 	middleware "core/middleware"
 	"errors"
 	"fmt"
+	"io"
 	"logging"
+	"net/http"
 	"net/url"
 )
 
@@ -63,6 +66,12 @@ func (analyzer *mk_analyzer) Analyze(
 	dataList = make([]base.MKData, 0)
 	errorList = make([]error, 0)
 
+	// 先读出响应体，使每个解析器都能完整地读取它
+	body, err := bufferBody(httpResponse)
+	if err != nil {
+		return dataList, append(errorList, err)
+	}
+
 	for i, parse := range parsers {
 
 		if parse == nil {
@@ -71,6 +80,7 @@ func (analyzer *mk_analyzer) Analyze(
 			continue
 		}
 
+		httpResponse.Body = io.NopCloser(bytes.NewReader(body))
 		pDataList, pErrorList := parse(httpResponse, depth)
 		if pDataList != nil {
 			for _, data := range pDataList {
@@ -99,6 +109,13 @@ func appendDataList(dataList []base.MKData, data base.MKData, depth uint32) []ba
 		return append(dataList, data)
 	}
 
+	if request.KeepDepth() {
+		if request.Depth() != depth {
+			request = base.NewPaginationRequest(request.Request(), depth)
+		}
+		return append(dataList, request)
+	}
+
 	newDepth := depth + 1
 	if request.Depth() != newDepth {
 		request = base.NewRequest(request.Request(), newDepth)
@@ -107,6 +124,16 @@ func appendDataList(dataList []base.MKData, data base.MKData, depth uint32) []ba
 	return append(dataList, request)
 }
 
+// 读出并关闭响应体
+func bufferBody(httpResponse *http.Response) ([]byte, error) {
+	if httpResponse.Body == nil {
+		return nil, nil
+	}
+	defer httpResponse.Body.Close()
+
+	return io.ReadAll(httpResponse.Body)
+}
+
 func appendErrorList(errorList []error, err error) []error {
 	if err == nil {
 		return errorList
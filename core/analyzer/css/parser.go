@@ -0,0 +1,519 @@
+package css
+
+import (
+	"code.google.com/p/go.net/html"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// 选择器解析器
+type parser struct {
+	input string // 选择器源文本
+	pos   int    // 当前位置
+}
+
+// 解析选择器组
+func (p *parser) parseGroup() ([]*complex, error) {
+	group := make([]*complex, 0)
+
+	for {
+		c, err := p.parseComplex()
+		if err != nil {
+			return nil, err
+		}
+		group = append(group, c)
+
+		p.skipSpace()
+		if p.eof() {
+			return group, nil
+		}
+
+		if p.peek() != ',' {
+			return nil, p.errorf("意外的字符%q", p.peek())
+		}
+		p.pos++
+	}
+}
+
+// 解析复杂选择器
+func (p *parser) parseComplex() (*complex, error) {
+	p.skipSpace()
+
+	c := &complex{}
+	next := combinatorNone
+
+	for {
+		part, err := p.parseCompound()
+		if err != nil {
+			return nil, err
+		}
+
+		c.compounds = append(c.compounds, part)
+		c.combinators = append(c.combinators, next)
+
+		hadSpace := p.skipSpace()
+		if p.eof() || p.peek() == ',' || p.peek() == ')' {
+			return c, nil
+		}
+
+		switch p.peek() {
+		case '>', '+', '~':
+			next = combinator(p.peek())
+			p.pos++
+			p.skipSpace()
+		default:
+			if !hadSpace {
+				return nil, p.errorf("意外的字符%q", p.peek())
+			}
+			next = combinatorDescendant
+		}
+	}
+}
+
+// 解析复合选择器
+func (p *parser) parseCompound() (*compound, error) {
+	c := &compound{}
+	start := p.pos
+
+	if !p.eof() && p.peek() == '*' {
+		p.pos++
+	} else if !p.eof() && isNameStart(p.peekRune()) {
+		c.tag = strings.ToLower(p.parseName())
+	}
+
+	for !p.eof() {
+		var filter nodeFilter
+		var err error
+
+		switch p.peek() {
+		case '#':
+			p.pos++
+			id := p.parseName()
+			if id == "" {
+				return nil, p.errorf("#之后缺少ID")
+			}
+			filter = func(node *html.Node) bool {
+				value, ok := attr(node, "id")
+				return ok && value == id
+			}
+
+		case '.':
+			p.pos++
+			class := p.parseName()
+			if class == "" {
+				return nil, p.errorf(".之后缺少类名")
+			}
+			filter = func(node *html.Node) bool {
+				value, _ := attr(node, "class")
+				return containsWord(value, class)
+			}
+
+		case '[':
+			filter, err = p.parseAttribute()
+
+		case ':':
+			filter, err = p.parsePseudo()
+
+		default:
+			if p.pos == start {
+				return nil, p.errorf("意外的字符%q", p.peek())
+			}
+			return c, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		c.filters = append(c.filters, filter)
+	}
+
+	if p.pos == start {
+		return nil, p.errorf("缺少选择器")
+	}
+
+	return c, nil
+}
+
+// 解析属性选择器
+func (p *parser) parseAttribute() (nodeFilter, error) {
+	p.pos++ // [
+	p.skipSpace()
+
+	name := strings.ToLower(p.parseName())
+	if name == "" {
+		return nil, p.errorf("缺少属性名")
+	}
+
+	p.skipSpace()
+	if p.eof() {
+		return nil, p.errorf("属性选择器没有结束")
+	}
+
+	if p.peek() == ']' {
+		p.pos++
+		return func(node *html.Node) bool {
+			_, ok := attr(node, name)
+			return ok
+		}, nil
+	}
+
+	operator := ""
+	if p.peek() == '=' {
+		operator = "="
+		p.pos++
+	} else if p.pos+1 < len(p.input) && p.input[p.pos+1] == '=' && strings.IndexByte("~|^$*", p.peek()) >= 0 {
+		operator = p.input[p.pos : p.pos+2]
+		p.pos += 2
+	} else {
+		return nil, p.errorf("无效的属性运算符")
+	}
+
+	p.skipSpace()
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if p.eof() || p.peek() != ']' {
+		return nil, p.errorf("属性选择器没有结束")
+	}
+	p.pos++
+
+	var test func(actual string) bool
+	switch operator {
+	case "=":
+		test = func(actual string) bool { return actual == value }
+	case "~=":
+		test = func(actual string) bool { return containsWord(actual, value) }
+	case "|=":
+		test = func(actual string) bool { return actual == value || strings.HasPrefix(actual, value+"-") }
+	case "^=":
+		test = func(actual string) bool { return value != "" && strings.HasPrefix(actual, value) }
+	case "$=":
+		test = func(actual string) bool { return value != "" && strings.HasSuffix(actual, value) }
+	case "*=":
+		test = func(actual string) bool { return value != "" && strings.Contains(actual, value) }
+	}
+
+	return func(node *html.Node) bool {
+		actual, ok := attr(node, name)
+		return ok && test(actual)
+	}, nil
+}
+
+// 解析伪类
+func (p *parser) parsePseudo() (nodeFilter, error) {
+	p.pos++ // :
+	name := strings.ToLower(p.parseName())
+	if name == "" {
+		return nil, p.errorf(":之后缺少伪类名")
+	}
+
+	switch name {
+	case "first-child":
+		return nthFilter(0, 1, false, false), nil
+	case "last-child":
+		return nthFilter(0, 1, true, false), nil
+	case "only-child":
+		return func(node *html.Node) bool {
+			return previousElement(node) == nil && nextElement(node) == nil
+		}, nil
+	case "first-of-type":
+		return nthFilter(0, 1, false, true), nil
+	case "last-of-type":
+		return nthFilter(0, 1, true, true), nil
+	case "empty":
+		return func(node *html.Node) bool {
+			for child := node.FirstChild; child != nil; child = child.NextSibling {
+				if child.Type == html.ElementNode || (child.Type == html.TextNode && child.Data != "") {
+					return false
+				}
+			}
+			return true
+		}, nil
+	}
+
+	if !functionalPseudos[name] {
+		return nil, p.errorf("不支持的伪类:%s", name)
+	}
+
+	argument, err := p.parseArgument(name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch name {
+	case "nth-child", "nth-last-child", "nth-of-type", "nth-last-of-type":
+		a, b, err := parseNth(argument)
+		if err != nil {
+			return nil, p.errorf("%s", err)
+		}
+		last := strings.HasPrefix(name, "nth-last")
+		ofType := strings.HasSuffix(name, "of-type")
+		return nthFilter(a, b, last, ofType), nil
+
+	case "not":
+		inner := &parser{input: argument}
+		c, err := inner.parseCompound()
+		if err == nil && !inner.eof() {
+			err = inner.errorf("意外的字符%q", inner.peek())
+		}
+		if err != nil {
+			return nil, p.errorf(":not()的参数无效: %s", err)
+		}
+		return func(node *html.Node) bool { return !c.match(node) }, nil
+
+	case "contains":
+		text := unquote(strings.TrimSpace(argument))
+		return func(node *html.Node) bool { return strings.Contains(Text(node), text) }, nil
+	}
+
+	return nil, p.errorf("不支持的伪类:%s", name)
+}
+
+// 支持的带参数的伪类
+var functionalPseudos = map[string]bool{
+	"nth-child":        true,
+	"nth-last-child":   true,
+	"nth-of-type":      true,
+	"nth-last-of-type": true,
+	"not":              true,
+	"contains":         true,
+}
+
+// 解析伪类的参数（括号中的内容）
+func (p *parser) parseArgument(name string) (string, error) {
+	if p.eof() || p.peek() != '(' {
+		return "", p.errorf("伪类:%s缺少参数", name)
+	}
+	p.pos++
+
+	start := p.pos
+	level := 1
+	var quote byte
+	for ; !p.eof(); p.pos++ {
+		ch := p.peek()
+		switch {
+		case quote != 0:
+			if ch == '\\' {
+				p.pos++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '(':
+			level++
+		case ch == ')':
+			level--
+			if level == 0 {
+				argument := p.input[start:p.pos]
+				p.pos++
+				return argument, nil
+			}
+		}
+	}
+
+	return "", p.errorf("伪类:%s的参数没有结束", name)
+}
+
+// 解析属性值（带引号的字符串或标识符）
+func (p *parser) parseValue() (string, error) {
+	if p.eof() {
+		return "", p.errorf("缺少属性值")
+	}
+
+	quote := p.peek()
+	if quote != '"' && quote != '\'' {
+		value := p.parseName()
+		if value == "" {
+			return "", p.errorf("缺少属性值")
+		}
+		return value, nil
+	}
+
+	p.pos++
+	var builder strings.Builder
+	for !p.eof() {
+		ch := p.peek()
+		p.pos++
+		if ch == '\\' && !p.eof() {
+			builder.WriteByte(p.peek())
+			p.pos++
+			continue
+		}
+		if ch == quote {
+			return builder.String(), nil
+		}
+		builder.WriteByte(ch)
+	}
+
+	return "", p.errorf("字符串没有结束")
+}
+
+// 解析标识符
+func (p *parser) parseName() string {
+	var builder strings.Builder
+	for !p.eof() {
+		r := p.peekRune()
+		if r == '\\' && p.pos+1 < len(p.input) {
+			p.pos++
+			r = p.peekRune()
+		} else if !isNameChar(r) {
+			break
+		}
+
+		builder.WriteRune(r)
+		p.pos += utf8.RuneLen(r)
+	}
+
+	return builder.String()
+}
+
+// 跳过空白，返回是否跳过了字符
+func (p *parser) skipSpace() bool {
+	start := p.pos
+	for !p.eof() && strings.IndexByte(" \t\r\n\f", p.peek()) >= 0 {
+		p.pos++
+	}
+
+	return p.pos > start
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *parser) peek() byte {
+	return p.input[p.pos]
+}
+
+func (p *parser) peekRune() rune {
+	r, _ := utf8.DecodeRuneInString(p.input[p.pos:])
+	return r
+}
+
+// 生成带位置信息的错误
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return errors.New(fmt.Sprintf("位置%d: %s", p.pos, fmt.Sprintf(format, args...)))
+}
+
+// 判断字符能否作为标识符的开头
+func isNameStart(r rune) bool {
+	return r == '_' || r == '-' || r == '\\' || r >= 0x80 ||
+		(r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+// 判断字符能否出现在标识符中
+func isNameChar(r rune) bool {
+	return isNameStart(r) || (r >= '0' && r <= '9')
+}
+
+// 判断以空白分隔的值中是否包含给定的词
+func containsWord(value string, word string) bool {
+	if word == "" {
+		return false
+	}
+
+	for _, field := range strings.Fields(value) {
+		if field == word {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 去掉字符串两端的引号
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+
+	return value
+}
+
+// 解析an+b形式的表达式
+func parseNth(expression string) (int, int, error) {
+	expression = strings.ToLower(strings.Join(strings.Fields(expression), ""))
+
+	switch expression {
+	case "odd":
+		return 2, 1, nil
+	case "even":
+		return 2, 0, nil
+	case "":
+		return 0, 0, errors.New("缺少an+b表达式")
+	}
+
+	index := strings.IndexByte(expression, 'n')
+	if index < 0 {
+		b, err := strconv.Atoi(expression)
+		if err != nil {
+			return 0, 0, errors.New(fmt.Sprintf("无效的an+b表达式【%s】", expression))
+		}
+		return 0, b, nil
+	}
+
+	var a, b int
+	switch coefficient := expression[:index]; coefficient {
+	case "", "+":
+		a = 1
+	case "-":
+		a = -1
+	default:
+		var err error
+		if a, err = strconv.Atoi(coefficient); err != nil {
+			return 0, 0, errors.New(fmt.Sprintf("无效的an+b表达式【%s】", expression))
+		}
+	}
+
+	if rest := expression[index+1:]; rest != "" {
+		var err error
+		if b, err = strconv.Atoi(strings.TrimPrefix(rest, "+")); err != nil || (rest[0] != '+' && rest[0] != '-') {
+			return 0, 0, errors.New(fmt.Sprintf("无效的an+b表达式【%s】", expression))
+		}
+	}
+
+	return a, b, nil
+}
+
+// 生成按位置匹配的过滤器。
+// 元素的位置（从1开始）需满足 position = a*n + b（n为非负整数）
+func nthFilter(a int, b int, fromLast bool, ofType bool) nodeFilter {
+	return func(node *html.Node) bool {
+		if node.Parent == nil {
+			return false
+		}
+
+		position := 1
+		sibling := node
+		for {
+			if fromLast {
+				sibling = nextElement(sibling)
+			} else {
+				sibling = previousElement(sibling)
+			}
+
+			if sibling == nil {
+				break
+			}
+
+			if !ofType || sibling.Data == node.Data {
+				position++
+			}
+		}
+
+		if a == 0 {
+			return position == b
+		}
+
+		n := position - b
+		return n%a == 0 && n/a >= 0
+	}
+}
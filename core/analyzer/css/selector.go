@@ -0,0 +1,251 @@
+package css
+
+import (
+	"code.google.com/p/go.net/html"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CSS选择器接口
+type MKSelector interface {
+	// 获取根节点之下（不含根节点本身）所有匹配的元素，按文档顺序排列
+	MatchAll(root *html.Node) []*html.Node
+
+	// 获取根节点之下第一个匹配的元素，没有匹配的元素时返回nil
+	MatchFirst(root *html.Node) *html.Node
+
+	// 判断给定元素是否匹配
+	Match(node *html.Node) bool
+
+	// 获取选择器的字符串表现形式
+	String() string
+}
+
+// 编译CSS选择器。
+// 支持的语法：
+//   - 类型选择器、通配选择器、#id、.class
+//   - 属性选择器：[attr]、[attr=v]、[attr~=v]、[attr|=v]、[attr^=v]、[attr$=v]、[attr*=v]
+//   - 伪类：:first-child、:last-child、:only-child、:nth-child()、:nth-last-child()、
+//     :first-of-type、:last-of-type、:nth-of-type()、:empty、:not()、:contains()
+//   - 组合器：后代（空白）、>、+、~，以及以逗号分隔的选择器组
+func Compile(selector string) (MKSelector, error) {
+	p := &parser{input: selector}
+
+	group, err := p.parseGroup()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("无效的CSS选择器【%s】: %s", selector, err))
+	}
+
+	return &mk_selector{source: selector, group: group}, nil
+}
+
+// 编译CSS选择器，失败时引发运行时恐慌。仅适用于常量选择器
+func MustCompile(selector string) MKSelector {
+	compiled, err := Compile(selector)
+	if err != nil {
+		panic(err)
+	}
+
+	return compiled
+}
+
+// CSS选择器的实现类型
+type mk_selector struct {
+	source string     // 选择器源文本
+	group  []*complex // 选择器组
+}
+
+func (selector *mk_selector) MatchAll(root *html.Node) []*html.Node {
+	nodes := make([]*html.Node, 0)
+	if root == nil {
+		return nodes
+	}
+
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == html.ElementNode && selector.Match(child) {
+				nodes = append(nodes, child)
+			}
+
+			walk(child)
+		}
+	}
+	walk(root)
+
+	return nodes
+}
+
+func (selector *mk_selector) MatchFirst(root *html.Node) *html.Node {
+	if root == nil {
+		return nil
+	}
+
+	for child := root.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && selector.Match(child) {
+			return child
+		}
+
+		if found := selector.MatchFirst(child); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+func (selector *mk_selector) Match(node *html.Node) bool {
+	if node == nil || node.Type != html.ElementNode {
+		return false
+	}
+
+	for _, c := range selector.group {
+		if c.match(node, len(c.compounds)-1) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (selector *mk_selector) String() string {
+	return selector.source
+}
+
+// 组合器
+type combinator byte
+
+const (
+	combinatorNone       combinator = 0   // 无（第一个复合选择器）
+	combinatorDescendant combinator = ' ' // 后代
+	combinatorChild      combinator = '>' // 子元素
+	combinatorAdjacent   combinator = '+' // 相邻兄弟
+	combinatorSibling    combinator = '~' // 后续兄弟
+)
+
+// 复杂选择器，由组合器连接的复合选择器序列
+type complex struct {
+	compounds   []*compound  // 复合选择器
+	combinators []combinator // combinators[i]表示compounds[i]与compounds[i-1]之间的关系
+}
+
+// 从右向左匹配第i个复合选择器
+func (c *complex) match(node *html.Node, i int) bool {
+	if !c.compounds[i].match(node) {
+		return false
+	}
+
+	if i == 0 {
+		return true
+	}
+
+	switch c.combinators[i] {
+	case combinatorDescendant:
+		for parent := node.Parent; parent != nil && parent.Type == html.ElementNode; parent = parent.Parent {
+			if c.match(parent, i-1) {
+				return true
+			}
+		}
+
+	case combinatorChild:
+		parent := node.Parent
+		return parent != nil && parent.Type == html.ElementNode && c.match(parent, i-1)
+
+	case combinatorAdjacent:
+		sibling := previousElement(node)
+		return sibling != nil && c.match(sibling, i-1)
+
+	case combinatorSibling:
+		for sibling := previousElement(node); sibling != nil; sibling = previousElement(sibling) {
+			if c.match(sibling, i-1) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// 复合选择器，由同时作用于一个元素的简单选择器组成
+type compound struct {
+	tag     string       // 标签名，空字符串表示通配
+	filters []nodeFilter // 其他简单选择器
+}
+
+// 简单选择器的匹配函数
+type nodeFilter func(node *html.Node) bool
+
+func (c *compound) match(node *html.Node) bool {
+	if c.tag != "" && node.Data != c.tag {
+		return false
+	}
+
+	for _, filter := range c.filters {
+		if !filter(node) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// 获取前一个兄弟元素
+func previousElement(node *html.Node) *html.Node {
+	for sibling := node.PrevSibling; sibling != nil; sibling = sibling.PrevSibling {
+		if sibling.Type == html.ElementNode {
+			return sibling
+		}
+	}
+
+	return nil
+}
+
+// 获取后一个兄弟元素
+func nextElement(node *html.Node) *html.Node {
+	for sibling := node.NextSibling; sibling != nil; sibling = sibling.NextSibling {
+		if sibling.Type == html.ElementNode {
+			return sibling
+		}
+	}
+
+	return nil
+}
+
+// 获取元素的属性值
+func attr(node *html.Node, name string) (string, bool) {
+	for _, a := range node.Attr {
+		if a.Namespace == "" && a.Key == name {
+			return a.Val, true
+		}
+	}
+
+	return "", false
+}
+
+// 获取节点的全部文本内容
+func Text(node *html.Node) string {
+	if node == nil {
+		return ""
+	}
+
+	if node.Type == html.TextNode {
+		return node.Data
+	}
+
+	var builder strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			switch child.Type {
+			case html.TextNode:
+				builder.WriteString(child.Data)
+			case html.ElementNode, html.DocumentNode:
+				walk(child)
+			}
+		}
+	}
+	walk(node)
+
+	return builder.String()
+}
@@ -0,0 +1,120 @@
+package css
+
+import (
+	"code.google.com/p/go.net/html"
+	"strings"
+	"testing"
+)
+
+var testDocument = `<html><body>
+<div id="main" class="content archive">
+	<h1 class="title">博客归档</h1>
+	<ul class="posts">
+		<li class="post first"><a href="/p/1" rel="bookmark">第一篇</a><span class="date">2014-01-01</span></li>
+		<li class="post"><a href="/p/2">第二篇</a><span class="date">2014-02-01</span></li>
+		<li class="post"><a href="http://other.com/p/3" data-id="x-3">第三篇</a></li>
+		<li class="post last"><a href="/p/4" lang="zh-CN">第四篇</a><em></em></li>
+	</ul>
+	<p>说明</p>
+	<p class="note">下一页</p>
+</div>
+</body></html>`
+
+func parseTestDocument(t *testing.T) *html.Node {
+	root, err := html.Parse(strings.NewReader(testDocument))
+	if err != nil {
+		t.Fatalf("无法解析测试文档: %s", err)
+	}
+
+	return root
+}
+
+func TestSelectorMatches(t *testing.T) {
+	root := parseTestDocument(t)
+
+	cases := []struct {
+		selector string
+		expected []string
+	}{
+		{"h1", []string{"博客归档"}},
+		{"#main > h1.title", []string{"博客归档"}},
+		{"ul.posts li a", []string{"第一篇", "第二篇", "第三篇", "第四篇"}},
+		{"li:first-child > a", []string{"第一篇"}},
+		{"li:last-child a", []string{"第四篇"}},
+		{"li:nth-child(2n) a", []string{"第二篇", "第四篇"}},
+		{"li:nth-child(odd) > a", []string{"第一篇", "第三篇"}},
+		{"li:nth-last-child(-n+2) a", []string{"第三篇", "第四篇"}},
+		{"li:not(.first):not(.last) a", []string{"第二篇", "第三篇"}},
+		{"a[href^='http']", []string{"第三篇"}},
+		{"a[href$=\"/2\"]", []string{"第二篇"}},
+		{"a[data-id*=\"-\"]", []string{"第三篇"}},
+		{"a[rel~=bookmark]", []string{"第一篇"}},
+		{"a[lang|=zh]", []string{"第四篇"}},
+		{"a + span.date", []string{"2014-01-01", "2014-02-01"}},
+		{"ul ~ p", []string{"说明", "下一页"}},
+		{"ul + p", []string{"说明"}},
+		{"p:contains('下一页')", []string{"下一页"}},
+		{"p:last-of-type, h1", []string{"博客归档", "下一页"}},
+		{"li > em:empty", []string{""}},
+		{"div.content.archive > p:first-of-type", []string{"说明"}},
+	}
+
+	for _, c := range cases {
+		selector, err := Compile(c.selector)
+		if err != nil {
+			t.Errorf("编译选择器【%s】失败: %s", c.selector, err)
+			continue
+		}
+
+		nodes := selector.MatchAll(root)
+		actual := make([]string, 0, len(nodes))
+		for _, node := range nodes {
+			actual = append(actual, Text(node))
+		}
+
+		if strings.Join(actual, "|") != strings.Join(c.expected, "|") {
+			t.Errorf("选择器【%s】的结果为%q，期望为%q", c.selector, actual, c.expected)
+		}
+	}
+}
+
+func TestSelectorMatchFirst(t *testing.T) {
+	root := parseTestDocument(t)
+
+	node := MustCompile("li.post a").MatchFirst(root)
+	if node == nil || Text(node) != "第一篇" {
+		t.Fatalf("MatchFirst的结果错误: %v", node)
+	}
+
+	if MustCompile("table").MatchFirst(root) != nil {
+		t.Fatalf("不存在的元素不应该被匹配")
+	}
+}
+
+func TestInvalidSelectors(t *testing.T) {
+	invalid := []string{"", "div >", "a[href", "a[href=]", "li:nth-child(x)", "p:unknown", ".", "div, ", "a[href!=x]"}
+
+	for _, selector := range invalid {
+		if _, err := Compile(selector); err == nil {
+			t.Errorf("选择器【%s】应该编译失败", selector)
+		}
+	}
+}
+
+func TestUnsupportedPseudoClasses(t *testing.T) {
+	cases := map[string]string{
+		"html:root":      "不支持的伪类:root",
+		"input:checked":  "不支持的伪类:checked",
+		"p:lang(zh)":     "不支持的伪类:lang",
+		"li:nth-child":   "伪类:nth-child缺少参数",
+		"p:not(.a":       "伪类:not的参数没有结束",
+		"a:first-child:": ":之后缺少伪类名",
+	}
+
+	for selector, expected := range cases {
+		_, err := Compile(selector)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("选择器【%s】的错误应该包含%q，实际为%v", selector, expected, err)
+		}
+	}
+}
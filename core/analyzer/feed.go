@@ -0,0 +1,366 @@
+package analyzer
+
+import (
+	"bufio"
+	"code.google.com/p/go.net/html/charset"
+	base "core/base"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 订阅源条目的字段名称
+const (
+	FEED_FIELD_ID         = "id"         // 条目的唯一标识（RSS的guid或Atom的id）
+	FEED_FIELD_TITLE      = "title"      // 标题
+	FEED_FIELD_LINK       = "link"       // 链接（绝对URL）
+	FEED_FIELD_PUBLISHED  = "published"  // 发布时间，能识别时为RFC 3339格式
+	FEED_FIELD_AUTHOR     = "author"     // 作者
+	FEED_FIELD_SUMMARY    = "summary"    // 摘要
+	FEED_FIELD_CONTENT    = "content"    // 全文
+	FEED_FIELD_CATEGORIES = "categories" // 分类（字符串切片）
+)
+
+// 订阅源中常见的时间格式
+var feedTimeLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	time.RFC822Z,
+	time.RFC822,
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// 订阅源解析参数的描述模板
+var feedArgumentsTemplate string = "{ follow links: %v }"
+
+// 订阅源解析参数的容器
+type FeedArguments struct {
+	FollowLinks bool   `xml:"followLinks"` // 是否为每个条目的链接生成请求，以便抓取全文
+	description string // 描述
+}
+
+func (arguments *FeedArguments) Check() error {
+	return nil
+}
+
+func (arguments *FeedArguments) String() string {
+	if arguments.description == "" {
+		arguments.description = fmt.Sprintf(feedArgumentsTemplate, arguments.FollowLinks)
+	}
+
+	return arguments.description
+}
+
+// RSS 2.0和Atom文档。两者的根元素不同，解码后根据根元素的名称区分
+type feedDocument struct {
+	XMLName xml.Name
+	Items   []rssItem    `xml:"channel>item"` // RSS条目
+	Entries []atomEntry  `xml:"entry"`        // Atom条目
+	Authors []atomPerson `xml:"author"`       // Atom订阅源的作者，条目没有作者时使用
+}
+
+// RSS条目
+type rssItem struct {
+	GUID        string     `xml:"guid"`
+	Title       string     `xml:"title"`
+	Links       []feedLink `xml:"link"`
+	PubDate     string     `xml:"pubDate"`
+	Date        string     `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Author      string     `xml:"author"`
+	Creator     string     `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Description string     `xml:"description"`
+	Content     string     `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Categories  []string   `xml:"category"`
+}
+
+// 链接。RSS的链接是元素的文本，Atom的链接是href属性
+type feedLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+	Text string `xml:",chardata"`
+}
+
+// Atom条目
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      atomText       `xml:"title"`
+	Links      []feedLink     `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Authors    []atomPerson   `xml:"author"`
+	Summary    atomText       `xml:"summary"`
+	Content    atomText       `xml:"content"`
+	Categories []atomCategory `xml:"category"`
+}
+
+// Atom文本构造。type为xhtml时内容是XHTML片段
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+// Atom人员构造
+type atomPerson struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email"`
+}
+
+// Atom分类
+type atomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr"`
+}
+
+// 获取文本构造的值
+func (text atomText) value() string {
+	if text.Type == "xhtml" {
+		return strings.TrimSpace(text.Inner)
+	}
+
+	return strings.TrimSpace(text.Text)
+}
+
+// 创建订阅源解析器。
+// 订阅源解析器解析RSS 2.0和Atom订阅源，每个条目产生一个条目，
+// 字段见FEED_FIELD_*常量。参数arguments为nil时使用默认参数
+func NewFeedParser(arguments *FeedArguments) (MKParseResponse, error) {
+	if arguments == nil {
+		arguments = &FeedArguments{}
+	}
+
+	if err := arguments.Check(); err != nil {
+		return nil, err
+	}
+
+	followLinks := arguments.FollowLinks
+
+	parse := func(httpResponse *http.Response, depth uint32) ([]base.MKData, []error) {
+		body, ok := feedBody(httpResponse)
+		if !ok {
+			return nil, nil
+		}
+
+		var document feedDocument
+		decoder := xml.NewDecoder(body)
+		decoder.Strict = false
+		decoder.CharsetReader = charset.NewReaderLabel
+		if err := decoder.Decode(&document); err != nil {
+			return nil, []error{errors.New(fmt.Sprintf("无法解析订阅源: %s", err))}
+		}
+
+		var items []base.MKItem
+		switch document.XMLName.Local {
+		case "rss":
+			items = rssItems(document.Items)
+		case "feed":
+			items = atomItems(document.Entries, document.Authors)
+		default:
+			// 不是订阅源
+			return nil, nil
+		}
+
+		pageURL := responseURL(httpResponse)
+		dataList := make([]base.MKData, 0, len(items))
+		errorList := make([]error, 0)
+		seen := make(map[string]bool)
+		for _, item := range items {
+			link, _ := item[FEED_FIELD_LINK].(string)
+			linkURL, ok := resolveLink(pageURL, link)
+			if ok {
+				item[FEED_FIELD_LINK] = linkURL.String()
+			}
+
+			dataList = append(dataList, item)
+
+			if !followLinks || !ok || seen[linkURL.String()] {
+				continue
+			}
+			seen[linkURL.String()] = true
+
+			httpRequest, err := http.NewRequest("GET", linkURL.String(), nil)
+			if err != nil {
+				errorList = append(errorList, err)
+				continue
+			}
+			dataList = append(dataList, base.NewRequest(httpRequest, depth))
+		}
+
+		return dataList, errorList
+	}
+
+	return parse, nil
+}
+
+// 把RSS条目转换为条目
+func rssItems(rssItems []rssItem) []base.MKItem {
+	items := make([]base.MKItem, 0, len(rssItems))
+	for _, rss := range rssItems {
+		published := rss.PubDate
+		if published == "" {
+			published = rss.Date
+		}
+
+		author := rss.Author
+		if author == "" {
+			author = rss.Creator
+		}
+
+		link := ""
+		for _, l := range rss.Links {
+			if text := strings.TrimSpace(l.Text); text != "" {
+				link = text
+				break
+			}
+		}
+
+		items = append(items, base.MKItem{
+			FEED_FIELD_ID:         strings.TrimSpace(rss.GUID),
+			FEED_FIELD_TITLE:      strings.TrimSpace(rss.Title),
+			FEED_FIELD_LINK:       link,
+			FEED_FIELD_PUBLISHED:  normalizeFeedTime(published),
+			FEED_FIELD_AUTHOR:     strings.TrimSpace(author),
+			FEED_FIELD_SUMMARY:    strings.TrimSpace(rss.Description),
+			FEED_FIELD_CONTENT:    strings.TrimSpace(rss.Content),
+			FEED_FIELD_CATEGORIES: trimAll(rss.Categories),
+		})
+	}
+
+	return items
+}
+
+// 把Atom条目转换为条目
+func atomItems(entries []atomEntry, feedAuthors []atomPerson) []base.MKItem {
+	items := make([]base.MKItem, 0, len(entries))
+	for _, entry := range entries {
+		published := entry.Published
+		if published == "" {
+			published = entry.Updated
+		}
+
+		authors := entry.Authors
+		if len(authors) == 0 {
+			authors = feedAuthors
+		}
+
+		names := make([]string, 0, len(authors))
+		for _, author := range authors {
+			if name := strings.TrimSpace(author.Name); name != "" {
+				names = append(names, name)
+			}
+		}
+
+		// 优先使用rel为alternate（或省略rel）的链接
+		link := ""
+		for _, l := range entry.Links {
+			if l.Rel == "" || l.Rel == "alternate" {
+				link = strings.TrimSpace(l.Href)
+				break
+			}
+		}
+
+		categories := make([]string, 0, len(entry.Categories))
+		for _, category := range entry.Categories {
+			if category.Term != "" {
+				categories = append(categories, category.Term)
+			} else if category.Label != "" {
+				categories = append(categories, category.Label)
+			}
+		}
+
+		summary := entry.Summary.value()
+		content := entry.Content.value()
+		if summary == "" {
+			summary = content
+		}
+
+		items = append(items, base.MKItem{
+			FEED_FIELD_ID:         strings.TrimSpace(entry.ID),
+			FEED_FIELD_TITLE:      entry.Title.value(),
+			FEED_FIELD_LINK:       link,
+			FEED_FIELD_PUBLISHED:  normalizeFeedTime(published),
+			FEED_FIELD_AUTHOR:     strings.Join(names, ", "),
+			FEED_FIELD_SUMMARY:    summary,
+			FEED_FIELD_CONTENT:    content,
+			FEED_FIELD_CATEGORIES: trimAll(categories),
+		})
+	}
+
+	return items
+}
+
+// 把订阅源中的时间转换为RFC 3339格式，无法识别时原样返回
+func normalizeFeedTime(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+
+	for _, layout := range feedTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format(time.RFC3339)
+		}
+	}
+
+	return value
+}
+
+// 去掉每个字符串两端的空白，并去掉空字符串
+func trimAll(values []string) []string {
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			result = append(result, value)
+		}
+	}
+
+	return result
+}
+
+// 探测订阅源时读取的响应体开头的长度
+const feedSniffLength = 1024
+
+// 响应体开头的订阅源根元素
+var feedRootPattern = regexp.MustCompile(`<(rss|feed)[\s>]`)
+
+// 获取可能为订阅源的响应体，由根元素最终确定是否为订阅源。
+// 未声明内容类型以及声明为任何XML类型（如application/x-rss+xml）的响应都可能是订阅源；
+// 配置不当的服务器常把订阅源声明为HTML、纯文本或二进制数据，这时只有开头出现rss或feed元素才按订阅源处理
+func feedBody(httpResponse *http.Response) (io.Reader, bool) {
+	contentType := httpResponse.Header.Get("Content-Type")
+	if contentType == "" {
+		return httpResponse.Body, true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+
+	if strings.HasSuffix(mediaType, "/xml") || strings.HasSuffix(mediaType, "+xml") {
+		return httpResponse.Body, true
+	}
+
+	switch mediaType {
+	case "text/html", "text/plain", "application/octet-stream":
+		reader := bufio.NewReaderSize(httpResponse.Body, feedSniffLength)
+		head, _ := reader.Peek(feedSniffLength)
+		return reader, feedRootPattern.Match(head)
+	}
+
+	return nil, false
+}
@@ -0,0 +1,184 @@
+package analyzer
+
+import (
+	base "core/base"
+	"reflect"
+	"testing"
+)
+
+var rssFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:content="http://purl.org/rss/1.0/modules/content/">
+<channel>
+	<title>唐巧的技术博客</title>
+	<link>http://blog.devtang.com/</link>
+	<item>
+		<guid isPermaLink="false">post-1</guid>
+		<title> 第一篇 </title>
+		<link>/2014/01/01/first/</link>
+		<pubDate>Wed, 01 Jan 2014 08:00:00 +0800</pubDate>
+		<author>tang@devtang.com (唐巧)</author>
+		<description><![CDATA[<p>摘要</p>]]></description>
+		<content:encoded><![CDATA[<p>全文</p>]]></content:encoded>
+		<category>iOS</category>
+		<category> </category>
+		<category>随笔</category>
+	</item>
+	<item>
+		<title>第二篇</title>
+		<link>http://blog.devtang.com/2014/01/01/first/</link>
+		<dc:date>2014-02-01T10:00:00Z</dc:date>
+		<dc:creator>唐巧</dc:creator>
+		<description>没有全文</description>
+	</item>
+</channel>
+</rss>`
+
+var atomFixture = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<title>唐巧的技术博客</title>
+	<author><name>唐巧</name></author>
+	<entry>
+		<id>tag:blog.devtang.com,2014:1</id>
+		<title type="html">第一篇</title>
+		<link rel="edit" href="/api/posts/1"/>
+		<link rel="alternate" href="/2014/01/01/first/"/>
+		<published>2014-01-01T08:00:00+08:00</published>
+		<updated>2014-01-02T08:00:00+08:00</updated>
+		<summary>摘要</summary>
+		<content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>全文</p></div></content>
+		<category term="ios"/>
+		<category label="随笔"/>
+	</entry>
+	<entry>
+		<id>tag:blog.devtang.com,2014:2</id>
+		<title>第二篇</title>
+		<link href="http://other.com/2/"/>
+		<updated>2014-02-01 10:00:00</updated>
+		<author><name>客座作者</name></author>
+		<author><name>唐巧</name></author>
+		<content>只有全文</content>
+	</entry>
+</feed>`
+
+// 只有标题的订阅源对应的条目
+var minimalFeedItems = []base.MKItem{
+	{
+		FEED_FIELD_ID:         "",
+		FEED_FIELD_TITLE:      "x",
+		FEED_FIELD_LINK:       "",
+		FEED_FIELD_PUBLISHED:  "",
+		FEED_FIELD_AUTHOR:     "",
+		FEED_FIELD_SUMMARY:    "",
+		FEED_FIELD_CONTENT:    "",
+		FEED_FIELD_CATEGORIES: []string{},
+	},
+}
+
+func TestFeedParser(t *testing.T) {
+	cases := []struct {
+		name        string
+		contentType string
+		document    string
+		expected    []base.MKItem
+	}{
+		{"RSS", "application/rss+xml", rssFixture, []base.MKItem{
+			{
+				FEED_FIELD_ID:         "post-1",
+				FEED_FIELD_TITLE:      "第一篇",
+				FEED_FIELD_LINK:       "http://blog.devtang.com/2014/01/01/first/",
+				FEED_FIELD_PUBLISHED:  "2014-01-01T08:00:00+08:00",
+				FEED_FIELD_AUTHOR:     "tang@devtang.com (唐巧)",
+				FEED_FIELD_SUMMARY:    "<p>摘要</p>",
+				FEED_FIELD_CONTENT:    "<p>全文</p>",
+				FEED_FIELD_CATEGORIES: []string{"iOS", "随笔"},
+			},
+			{
+				FEED_FIELD_ID:         "",
+				FEED_FIELD_TITLE:      "第二篇",
+				FEED_FIELD_LINK:       "http://blog.devtang.com/2014/01/01/first/",
+				FEED_FIELD_PUBLISHED:  "2014-02-01T10:00:00Z",
+				FEED_FIELD_AUTHOR:     "唐巧",
+				FEED_FIELD_SUMMARY:    "没有全文",
+				FEED_FIELD_CONTENT:    "",
+				FEED_FIELD_CATEGORIES: []string{},
+			},
+		}},
+		{"Atom", "application/atom+xml; charset=utf-8", atomFixture, []base.MKItem{
+			{
+				FEED_FIELD_ID:         "tag:blog.devtang.com,2014:1",
+				FEED_FIELD_TITLE:      "第一篇",
+				FEED_FIELD_LINK:       "http://blog.devtang.com/2014/01/01/first/",
+				FEED_FIELD_PUBLISHED:  "2014-01-01T08:00:00+08:00",
+				FEED_FIELD_AUTHOR:     "唐巧",
+				FEED_FIELD_SUMMARY:    "摘要",
+				FEED_FIELD_CONTENT:    `<div xmlns="http://www.w3.org/1999/xhtml"><p>全文</p></div>`,
+				FEED_FIELD_CATEGORIES: []string{"ios", "随笔"},
+			},
+			{
+				FEED_FIELD_ID:         "tag:blog.devtang.com,2014:2",
+				FEED_FIELD_TITLE:      "第二篇",
+				FEED_FIELD_LINK:       "http://other.com/2/",
+				FEED_FIELD_PUBLISHED:  "2014-02-01T10:00:00Z",
+				FEED_FIELD_AUTHOR:     "客座作者, 唐巧",
+				FEED_FIELD_SUMMARY:    "只有全文",
+				FEED_FIELD_CONTENT:    "只有全文",
+				FEED_FIELD_CATEGORIES: []string{},
+			},
+		}},
+		// 未声明内容类型时由根元素决定
+		{"未声明类型的Atom", "", `<feed xmlns="http://www.w3.org/2005/Atom"><entry><title>x</title></entry></feed>`, minimalFeedItems},
+		{"非标准的XML类型", "application/x-rss+xml", `<rss><channel><item><title>x</title></item></channel></rss>`, minimalFeedItems},
+		{"声明为HTML的RSS", "text/html; charset=utf-8", `<?xml version="1.0"?>
+<rss version="2.0"><channel><item><title>x</title></item></channel></rss>`, minimalFeedItems},
+		{"声明为二进制数据的Atom", "application/octet-stream", `<feed xmlns="http://www.w3.org/2005/Atom"><entry><title>x</title></entry></feed>`, minimalFeedItems},
+		{"不是订阅源的XML", "application/xml", `<urlset><url><loc>http://blog.devtang.com/</loc></url></urlset>`, []base.MKItem{}},
+		{"HTML", "text/html", `<html><body><p>正文</p></body></html>`, []base.MKItem{}},
+		{"引用订阅源的HTML", "text/html", `<html><body><rss></rss></body></html>`, []base.MKItem{}},
+		{"图片", "image/png", `<rss><channel><item><title>x</title></item></channel></rss>`, []base.MKItem{}},
+	}
+
+	parse, err := NewFeedParser(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range cases {
+		response := newTestResponse(t, "http://blog.devtang.com/atom.xml", c.contentType, c.document)
+		dataList, errorList := parse(response, 0)
+		if len(errorList) > 0 {
+			t.Errorf("%s: 意外的错误: %v", c.name, errorList)
+		}
+
+		urls, items := splitData(dataList)
+		if len(urls) > 0 {
+			t.Errorf("%s: 默认不应该跟随链接，实际为%v", c.name, urls)
+		}
+		if !reflect.DeepEqual(items, c.expected) {
+			t.Errorf("%s:\n期望%v\n实际为%v", c.name, c.expected, items)
+		}
+	}
+}
+
+func TestFeedParserFollowLinks(t *testing.T) {
+	parse, _ := NewFeedParser(&FeedArguments{FollowLinks: true})
+
+	// 两个RSS条目的链接相同，只生成一个请求
+	dataList, _ := parse(newTestResponse(t, "http://blog.devtang.com/rss.xml", "text/xml", rssFixture), 2)
+	urls, items := splitData(dataList)
+	if len(items) != 2 || !reflect.DeepEqual(urls, []string{"http://blog.devtang.com/2014/01/01/first/"}) {
+		t.Errorf("期望2个条目和1个请求，实际为%d个条目和%v", len(items), urls)
+	}
+
+	for _, data := range dataList {
+		if request, ok := data.(*base.MKRequest); ok && request.Depth() != 2 {
+			t.Errorf("请求的深度应该为2，实际为%d", request.Depth())
+		}
+	}
+}
+
+func TestFeedParserMalformed(t *testing.T) {
+	parse, _ := NewFeedParser(nil)
+	if _, errorList := parse(newTestResponse(t, "http://blog.devtang.com/rss.xml", "application/rss+xml", "<rss><channel>"), 0); len(errorList) != 1 {
+		t.Errorf("不完整的订阅源应该返回1个错误，实际为%v", errorList)
+	}
+}
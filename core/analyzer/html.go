@@ -0,0 +1,133 @@
+package analyzer
+
+import (
+	"code.google.com/p/go.net/html"
+	"code.google.com/p/go.net/html/charset"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 可以被跟随的URL协议
+var followableSchemes = map[string]bool{
+	"http":  true,
+	"https": true,
+}
+
+// 判断响应是否为HTML文档。未声明内容类型时按HTML处理
+func isHTMLResponse(httpResponse *http.Response) bool {
+	contentType := httpResponse.Header.Get("Content-Type")
+	if contentType == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// 获取被转换为UTF-8编码的响应体。
+// 编码依次根据Content-Type、BOM和<meta>标签确定，因此GBK等编码的中文页面也能被正确解析
+func utf8Body(httpResponse *http.Response) (io.Reader, error) {
+	return charset.NewReader(httpResponse.Body, httpResponse.Header.Get("Content-Type"))
+}
+
+// 把响应体解析为HTML文档树
+func parseHTML(httpResponse *http.Response) (*html.Node, error) {
+	body, err := utf8Body(httpResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	return html.Parse(body)
+}
+
+// 获取文档的基础URL，即<base href>与最终URL共同确定的URL
+func documentBaseURL(root *html.Node, pageURL *url.URL) *url.URL {
+	var find func(node *html.Node) *html.Node
+	find = func(node *html.Node) *html.Node {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == html.ElementNode && child.Data == "base" {
+				if _, ok := nodeAttr(child, "href"); ok {
+					return child
+				}
+			}
+
+			if found := find(child); found != nil {
+				return found
+			}
+		}
+
+		return nil
+	}
+
+	baseNode := find(root)
+	if baseNode == nil {
+		return pageURL
+	}
+
+	href, _ := nodeAttr(baseNode, "href")
+	hrefURL, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return pageURL
+	}
+
+	if pageURL != nil {
+		hrefURL = pageURL.ResolveReference(hrefURL)
+	}
+
+	return hrefURL
+}
+
+// 获取元素的属性值
+func nodeAttr(node *html.Node, name string) (string, bool) {
+	for _, attr := range node.Attr {
+		if attr.Namespace == "" && attr.Key == name {
+			return attr.Val, true
+		}
+	}
+
+	return "", false
+}
+
+// 获取响应的最终URL（即跟随重定向之后的URL）
+func responseURL(httpResponse *http.Response) *url.URL {
+	if httpResponse.Request == nil {
+		return nil
+	}
+
+	return httpResponse.Request.URL
+}
+
+// 以基础URL为参照解析链接。
+// 只有可以被跟随的链接才会被返回，javascript:、mailto:等链接会被忽略。
+// 结果中不包含片段（#之后的部分）
+func resolveLink(baseURL *url.URL, link string) (*url.URL, bool) {
+	link = strings.TrimSpace(link)
+	if link == "" || strings.HasPrefix(link, "#") {
+		return nil, false
+	}
+
+	linkURL, err := url.Parse(link)
+	if err != nil {
+		return nil, false
+	}
+
+	if baseURL != nil {
+		linkURL = baseURL.ResolveReference(linkURL)
+	}
+
+	if !followableSchemes[strings.ToLower(linkURL.Scheme)] || linkURL.Host == "" {
+		return nil, false
+	}
+
+	linkURL.Fragment = ""
+	linkURL.RawFragment = ""
+
+	return linkURL, true
+}
@@ -0,0 +1,351 @@
+package analyzer
+
+import (
+	jsonpath "core/analyzer/jsonpath"
+	base "core/base"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// JSON规则的描述模板
+var jsonRuleTemplate string = "{ items: %s, fields: %d, pagination: %s }"
+
+// JSON字段规则
+type JSONField struct {
+	Name     string `xml:"name,attr"`     // 字段名称
+	Path     string `xml:"path,attr"`     // 相对于条目的JSONPath表达式，为空时取与字段名称同名的成员
+	Multiple bool   `xml:"multiple,attr"` // 是否提取所有匹配的值（结果为切片）
+	Default  string `xml:"default,attr"`  // 没有匹配的值时使用的默认值
+}
+
+// JSON分页规则。
+// 下一页URL、游标和页码（查询参数或URL模板）几种方式必须且只能指定一种
+type JSONPagination struct {
+	NextURL      string `xml:"nextUrl,attr"`      // 下一页URL的JSONPath表达式，相对URL以当前页面的URL为参照
+	Cursor       string `xml:"cursor,attr"`       // 下一页游标的JSONPath表达式
+	CursorParam  string `xml:"cursorParam,attr"`  // 游标所在的查询参数名
+	PageParam    string `xml:"pageParam,attr"`    // 页码所在的查询参数名
+	PageTemplate string `xml:"pageTemplate,attr"` // 包含{page}占位符的URL模板
+	FirstPage    uint32 `xml:"firstPage,attr"`    // 第一页的页码，默认为1
+	MaxPages     uint32 `xml:"maxPages,attr"`     // 按页码翻页时最多的页数，0表示不限
+}
+
+func (pagination *JSONPagination) Check() error {
+	_, err := compilePagination(pagination)
+	return err
+}
+
+func (pagination *JSONPagination) String() string {
+	switch {
+	case pagination.NextURL != "":
+		return "nextUrl " + pagination.NextURL
+	case pagination.Cursor != "":
+		return fmt.Sprintf("cursor %s -> %s", pagination.Cursor, pagination.CursorParam)
+	case pagination.PageParam != "":
+		return "page param " + pagination.PageParam
+	}
+
+	return "page template " + pagination.PageTemplate
+}
+
+// JSON规则。
+// 条目表达式选出的每个值都会产生一个条目；
+// 没有字段规则时，对象的所有成员都成为条目的字段
+type JSONRule struct {
+	Items       string          `xml:"items,attr"` // 选出条目的JSONPath表达式，默认为$
+	Fields      []JSONField     `xml:"field"`      // 字段规则
+	Pagination  *JSONPagination `xml:"pagination"` // 分页规则，为nil时不翻页
+	description string          // 描述
+}
+
+func (rule *JSONRule) Check() error {
+	_, err := compileJSONRule(rule)
+	return err
+}
+
+func (rule *JSONRule) String() string {
+	if rule.description == "" {
+		pagination := "none"
+		if rule.Pagination != nil {
+			pagination = rule.Pagination.String()
+		}
+		rule.description = fmt.Sprintf(jsonRuleTemplate, rule.itemsPath(), len(rule.Fields), pagination)
+	}
+
+	return rule.description
+}
+
+// 获取选出条目的JSONPath表达式
+func (rule *JSONRule) itemsPath() string {
+	if rule.Items == "" {
+		return "$"
+	}
+
+	return rule.Items
+}
+
+// 编译后的JSON字段规则
+type compiledJSONField struct {
+	field JSONField       // 字段规则
+	path  jsonpath.MKPath // JSONPath表达式
+}
+
+// 编译后的分页规则
+type compiledPagination struct {
+	pagination JSONPagination  // 分页规则
+	nextURL    jsonpath.MKPath // 下一页URL的表达式
+	cursor     jsonpath.MKPath // 游标的表达式
+	numbering  *pageNumbering  // 页码翻页方式
+}
+
+// 编译后的JSON规则
+type compiledJSONRule struct {
+	items      jsonpath.MKPath      // 选出条目的表达式
+	fields     []*compiledJSONField // 字段规则
+	pagination *compiledPagination  // 分页规则
+}
+
+// 编译JSON规则
+func compileJSONRule(rule *JSONRule) (*compiledJSONRule, error) {
+	if rule == nil {
+		return nil, errors.New("JSON规则无效！")
+	}
+
+	items, err := jsonpath.Compile(rule.itemsPath())
+	if err != nil {
+		return nil, err
+	}
+
+	compiled := &compiledJSONRule{items: items}
+
+	names := make(map[string]bool)
+	for i, field := range rule.Fields {
+		if field.Name == "" {
+			return nil, errors.New(fmt.Sprintf("JSON字段规则[%d]的名称不能为空！", i))
+		}
+
+		if names[field.Name] {
+			return nil, errors.New(fmt.Sprintf("字段名称重复【%s】！", field.Name))
+		}
+		names[field.Name] = true
+
+		path := field.Path
+		if path == "" {
+			path = "['" + strings.Replace(field.Name, "'", "\\'", -1) + "']"
+		}
+
+		compiledPath, err := jsonpath.Compile(path)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("字段【%s】: %s", field.Name, err))
+		}
+
+		compiled.fields = append(compiled.fields, &compiledJSONField{field: field, path: compiledPath})
+	}
+
+	if rule.Pagination != nil {
+		if compiled.pagination, err = compilePagination(rule.Pagination); err != nil {
+			return nil, err
+		}
+	}
+
+	return compiled, nil
+}
+
+// 编译分页规则
+func compilePagination(pagination *JSONPagination) (*compiledPagination, error) {
+	modes := 0
+	for _, value := range []string{pagination.NextURL, pagination.Cursor, pagination.PageParam, pagination.PageTemplate} {
+		if value != "" {
+			modes++
+		}
+	}
+
+	if modes != 1 {
+		return nil, errors.New("分页规则中必须且只能指定nextUrl、cursor、pageParam和pageTemplate中的一个！")
+	}
+
+	compiled := &compiledPagination{pagination: *pagination}
+
+	var err error
+	switch {
+	case pagination.NextURL != "":
+		compiled.nextURL, err = jsonpath.Compile(pagination.NextURL)
+
+	case pagination.Cursor != "":
+		if pagination.CursorParam == "" {
+			return nil, errors.New("按游标翻页时必须指定游标所在的查询参数名（cursorParam）！")
+		}
+		compiled.cursor, err = jsonpath.Compile(pagination.Cursor)
+
+	default:
+		compiled.numbering, err = newPageNumbering(pagination.PageParam, pagination.PageTemplate, pagination.FirstPage)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return compiled, nil
+}
+
+// 创建JSON解析器。
+// JSON解析器按照JSON规则从JSON响应体中提取条目，并根据分页规则生成下一页的分页请求。
+// 分页请求会沿用当前请求的头部，其深度与当前页面相同
+func NewJSONParser(rule *JSONRule) (MKParseResponse, error) {
+	compiled, err := compileJSONRule(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	parse := func(httpResponse *http.Response, depth uint32) ([]base.MKData, []error) {
+		if !isJSONResponse(httpResponse) {
+			return nil, nil
+		}
+
+		var document interface{}
+		decoder := json.NewDecoder(httpResponse.Body)
+		decoder.UseNumber()
+		if err := decoder.Decode(&document); err != nil {
+			return nil, []error{errors.New(fmt.Sprintf("无法解析JSON文档: %s", err))}
+		}
+
+		items := compiled.extract(document)
+		dataList := make([]base.MKData, 0, len(items)+1)
+		for _, item := range items {
+			dataList = append(dataList, item)
+		}
+
+		if compiled.pagination == nil || httpResponse.Request == nil {
+			return dataList, nil
+		}
+
+		nextURL, ok := compiled.pagination.next(document, httpResponse.Request.URL, len(items))
+		if !ok {
+			return dataList, nil
+		}
+
+		httpRequest, err := http.NewRequest("GET", nextURL.String(), nil)
+		if err != nil {
+			return dataList, []error{err}
+		}
+
+		for key, values := range httpResponse.Request.Header {
+			httpRequest.Header[key] = append([]string{}, values...)
+		}
+
+		return append(dataList, base.NewPaginationRequest(httpRequest, depth)), nil
+	}
+
+	return parse, nil
+}
+
+// 从JSON文档中提取条目
+func (rule *compiledJSONRule) extract(document interface{}) []base.MKItem {
+	values := rule.items.Select(document)
+
+	items := make([]base.MKItem, 0, len(values))
+	for _, value := range values {
+		if len(rule.fields) == 0 {
+			if object, ok := value.(map[string]interface{}); ok {
+				item := base.MKItem{}
+				for key, member := range object {
+					item[key] = member
+				}
+				items = append(items, item)
+			}
+			continue
+		}
+
+		item := base.MKItem{}
+		for _, field := range rule.fields {
+			item[field.field.Name] = field.extract(value)
+		}
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// 提取字段的值
+func (field *compiledJSONField) extract(value interface{}) interface{} {
+	values := field.path.Select(value)
+
+	if len(values) == 0 && field.field.Default != "" {
+		values = append(values, field.field.Default)
+	}
+
+	if field.field.Multiple {
+		return values
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	return values[0]
+}
+
+// 获取下一页的URL。第二个结果值为false时表示没有下一页
+func (pagination *compiledPagination) next(document interface{}, pageURL *url.URL, itemCount int) (*url.URL, bool) {
+	switch {
+	case pagination.nextURL != nil:
+		link := firstString(pagination.nextURL.Select(document))
+		if link == "" {
+			return nil, false
+		}
+		return resolveLink(pageURL, link)
+
+	case pagination.cursor != nil:
+		param := pagination.pagination.CursorParam
+		cursor := firstString(pagination.cursor.Select(document))
+		// 游标没有变化时停止翻页，以免陷入循环
+		if cursor == "" || cursor == pageURL.Query().Get(param) {
+			return nil, false
+		}
+		return withQueryParam(pageURL, param, cursor), true
+	}
+
+	// 按页码翻页时，当前页没有条目即表示已到最后一页
+	if itemCount == 0 {
+		return nil, false
+	}
+
+	page, _ := pagination.numbering.current(pageURL)
+	nextPage := page + 1
+	if maxPages := pagination.pagination.MaxPages; maxPages > 0 &&
+		pagination.numbering.count(nextPage) > maxPages {
+		return nil, false
+	}
+
+	return pagination.numbering.url(pageURL, nextPage)
+}
+
+// 获取第一个值的字符串形式
+func firstString(values []interface{}) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	return strings.TrimSpace(jsonpath.String(values[0]))
+}
+
+// 判断响应是否为JSON文档。未声明内容类型时按JSON处理
+func isJSONResponse(httpResponse *http.Response) bool {
+	contentType := httpResponse.Header.Get("Content-Type")
+	if contentType == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "application/json" || mediaType == "text/json" ||
+		strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,360 @@
+package jsonpath
+
+import (
+	base "core/base"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// JSONPath表达式接口
+type MKPath interface {
+	// 从JSON文档中选出所有匹配的值，按文档顺序排列（对象的成员按名称排序）。
+	// JSON文档应是encoding/json解码得到的值，即map[string]interface{}、[]interface{}、
+	// string、float64（或json.Number）、bool和nil的组合
+	Select(document interface{}) []interface{}
+
+	// 获取表达式的字符串表现形式
+	String() string
+}
+
+// 编译JSONPath表达式。
+// 支持的语法：
+//   - 根节点$、当前节点@（也可以省略，此时表达式相对于根节点）
+//   - 成员：.name、['name']、["name"]，以及通配符.*、[*]
+//   - 数组下标（负数表示从末尾开始）、切片[start:end:step]、联合['a','b']、[0,2]
+//   - 递归下降：..name、..*、..[0]
+//   - 过滤器：[?(@.price < 10)]、[?(@.tags)]，运算符为==、!=、<、<=、>、>=
+func Compile(path string) (MKPath, error) {
+	p := &parser{input: path}
+
+	segments, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+
+	return &mk_path{source: path, segments: segments}, nil
+}
+
+// 编译JSONPath表达式，失败时引发运行时恐慌。仅适用于常量表达式
+func MustCompile(path string) MKPath {
+	compiled, err := Compile(path)
+	if err != nil {
+		panic(err)
+	}
+
+	return compiled
+}
+
+// JSONPath表达式的实现类型
+type mk_path struct {
+	source   string     // 表达式源文本
+	segments []*segment // 路径段
+}
+
+func (path *mk_path) Select(document interface{}) []interface{} {
+	return selectSegments(path.segments, document)
+}
+
+func (path *mk_path) String() string {
+	return path.source
+}
+
+// 路径段
+type segment struct {
+	recursive bool       // 是否为递归下降
+	selectors []selector // 选择器，结果按顺序合并
+}
+
+// 选择器，从一个值中选出若干子值
+type selector interface {
+	apply(value interface{}) []interface{}
+}
+
+// 依次应用路径段
+func selectSegments(segments []*segment, value interface{}) []interface{} {
+	current := []interface{}{value}
+
+	for _, seg := range segments {
+		next := make([]interface{}, 0)
+		for _, v := range current {
+			targets := []interface{}{v}
+			if seg.recursive {
+				targets = descendantsOrSelf(targets[:0], v)
+			}
+
+			for _, target := range targets {
+				for _, s := range seg.selectors {
+					next = append(next, s.apply(target)...)
+				}
+			}
+		}
+		current = next
+	}
+
+	return current
+}
+
+// 按文档顺序追加值本身及其所有后代
+func descendantsOrSelf(values []interface{}, value interface{}) []interface{} {
+	values = append(values, value)
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			values = descendantsOrSelf(values, v[key])
+		}
+	case []interface{}:
+		for _, child := range v {
+			values = descendantsOrSelf(values, child)
+		}
+	}
+
+	return values
+}
+
+// 获取排序后的对象成员名称
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// 成员选择器
+type nameSelector string
+
+func (s nameSelector) apply(value interface{}) []interface{} {
+	if object, ok := value.(map[string]interface{}); ok {
+		if child, ok := object[string(s)]; ok {
+			return []interface{}{child}
+		}
+	}
+
+	return nil
+}
+
+// 通配选择器
+type wildcardSelector struct{}
+
+func (s wildcardSelector) apply(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		children := make([]interface{}, 0, len(v))
+		for _, key := range sortedKeys(v) {
+			children = append(children, v[key])
+		}
+		return children
+	case []interface{}:
+		return append([]interface{}{}, v...)
+	}
+
+	return nil
+}
+
+// 数组下标选择器
+type indexSelector int
+
+func (s indexSelector) apply(value interface{}) []interface{} {
+	array, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	index := int(s)
+	if index < 0 {
+		index += len(array)
+	}
+
+	if index < 0 || index >= len(array) {
+		return nil
+	}
+
+	return []interface{}{array[index]}
+}
+
+// 数组切片选择器
+type sliceSelector struct {
+	start, end, step *int // 起点、终点和步长，为nil时表示省略
+}
+
+func (s sliceSelector) apply(value interface{}) []interface{} {
+	array, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	length := len(array)
+	step := 1
+	if s.step != nil {
+		step = *s.step
+	}
+
+	if step == 0 {
+		return nil
+	}
+
+	normalize := func(index *int, defaultValue int) int {
+		if index == nil {
+			return defaultValue
+		}
+
+		i := *index
+		if i < 0 {
+			i += length
+		}
+
+		if i < -1 {
+			i = -1
+		}
+		if i > length {
+			i = length
+		}
+
+		return i
+	}
+
+	result := make([]interface{}, 0)
+	if step > 0 {
+		start, end := normalize(s.start, 0), normalize(s.end, length)
+		if start < 0 {
+			start = 0
+		}
+		for i := start; i < end; i += step {
+			result = append(result, array[i])
+		}
+	} else {
+		start, end := normalize(s.start, length-1), normalize(s.end, -1)
+		if start >= length {
+			start = length - 1
+		}
+		for i := start; i > end; i += step {
+			result = append(result, array[i])
+		}
+	}
+
+	return result
+}
+
+// 过滤器选择器，选出满足条件的子值
+type filterSelector struct {
+	path    []*segment  // 相对于@的路径
+	op      string      // 比较运算符，为空时表示判断路径是否存在
+	operand interface{} // 比较的字面量
+}
+
+func (s filterSelector) apply(value interface{}) []interface{} {
+	children := wildcardSelector{}.apply(value)
+
+	result := make([]interface{}, 0, len(children))
+	for _, child := range children {
+		if s.match(child) {
+			result = append(result, child)
+		}
+	}
+
+	return result
+}
+
+// 判断值是否满足过滤条件
+func (s filterSelector) match(value interface{}) bool {
+	selected := selectSegments(s.path, value)
+	if s.op == "" {
+		return len(selected) > 0
+	}
+
+	for _, v := range selected {
+		if compare(s.op, v, s.operand) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 比较两个JSON值。数字之间按数值比较，字符串之间按字典序比较，其他值只能判断是否相等
+func compare(op string, left interface{}, right interface{}) bool {
+	if l, ok := toNumber(left); ok {
+		if r, ok := toNumber(right); ok {
+			switch op {
+			case "==":
+				return l == r
+			case "!=":
+				return l != r
+			case "<":
+				return l < r
+			case "<=":
+				return l <= r
+			case ">":
+				return l > r
+			case ">=":
+				return l >= r
+			}
+			return false
+		}
+	}
+
+	if l, ok := left.(string); ok {
+		if r, ok := right.(string); ok {
+			switch op {
+			case "==":
+				return l == r
+			case "!=":
+				return l != r
+			case "<":
+				return l < r
+			case "<=":
+				return l <= r
+			case ">":
+				return l > r
+			case ">=":
+				return l >= r
+			}
+			return false
+		}
+	}
+
+	switch op {
+	case "==":
+		return fmt.Sprint(left) == fmt.Sprint(right) && sameKind(left, right)
+	case "!=":
+		return !(fmt.Sprint(left) == fmt.Sprint(right) && sameKind(left, right))
+	}
+
+	return false
+}
+
+// 判断两个非数字、非字符串的值是否为同一种JSON类型
+func sameKind(left interface{}, right interface{}) bool {
+	switch left.(type) {
+	case nil:
+		return right == nil
+	case bool:
+		_, ok := right.(bool)
+		return ok
+	}
+
+	return false
+}
+
+// 创建语法错误
+func newSyntaxError(path string, pos int, format string, args ...interface{}) error {
+	message := fmt.Sprintf("无效的JSONPath表达式【%s】（位置%d）: %s",
+		path, pos, fmt.Sprintf(format, args...))
+	return base.NewError(base.ERR_DOMAIN_ANALYZER, base.ERR_CODE_INVALID_EXPRESSION, message)
+}
+
+// 把值转换为字符串。字符串原样返回，其他值以JSON文本表示
+func String(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+
+	return strings.TrimSpace(jsonText(value))
+}
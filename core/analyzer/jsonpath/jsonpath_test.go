@@ -0,0 +1,91 @@
+package jsonpath
+
+import (
+	base "core/base"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var testDocument = `{
+	"store": {
+		"book": [
+			{"category": "reference", "author": "Nigel Rees", "title": "Sayings of the Century", "price": 8.95},
+			{"category": "fiction", "author": "Evelyn Waugh", "title": "Sword of Honour", "price": 12.99},
+			{"category": "fiction", "author": "Herman Melville", "title": "Moby Dick", "isbn": "0-553-21311-3", "price": 8.99},
+			{"category": "fiction", "author": "J. R. R. Tolkien", "title": "The Lord of the Rings", "isbn": "0-395-19395-8", "price": 22.99}
+		],
+		"bicycle": {"color": "red", "price": 19.95}
+	},
+	"next-page": "/api/books?cursor=abc"
+}`
+
+func decodeTestDocument(t *testing.T) interface{} {
+	var document interface{}
+	decoder := json.NewDecoder(strings.NewReader(testDocument))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		t.Fatalf("无法解析测试文档: %s", err)
+	}
+
+	return document
+}
+
+func TestSelect(t *testing.T) {
+	document := decodeTestDocument(t)
+
+	cases := []struct {
+		path     string
+		expected []string
+	}{
+		{"$.store.book[*].author", []string{"Nigel Rees", "Evelyn Waugh", "Herman Melville", "J. R. R. Tolkien"}},
+		{"store.book[0].title", []string{"Sayings of the Century"}},
+		{"$['store']['bicycle']['color']", []string{"red"}},
+		{"$.store.book[-1].title", []string{"The Lord of the Rings"}},
+		{"$.store.book[1:3].price", []string{"12.99", "8.99"}},
+		{"$.store.book[::-2].price", []string{"22.99", "12.99"}},
+		{"$.store.book[0,2].author", []string{"Nigel Rees", "Herman Melville"}},
+		{"$..isbn", []string{"0-553-21311-3", "0-395-19395-8"}},
+		{"$.store..price", []string{"19.95", "8.95", "12.99", "8.99", "22.99"}},
+		{"$.store.book[?(@.price < 10)].title", []string{"Sayings of the Century", "Moby Dick"}},
+		{"$.store.book[?(@.category == 'fiction' )].price", []string{"12.99", "8.99", "22.99"}},
+		{"$.store.book[?(@.isbn)].author", []string{"Herman Melville", "J. R. R. Tolkien"}},
+		{"$.next-page", []string{"/api/books?cursor=abc"}},
+		{"$.store.bicycle.size", []string{}},
+		{"$.store.bicycle", []string{`{"color":"red","price":19.95}`}},
+	}
+
+	for _, c := range cases {
+		path, err := Compile(c.path)
+		if err != nil {
+			t.Errorf("无法编译表达式【%s】: %s", c.path, err)
+			continue
+		}
+
+		actual := make([]string, 0)
+		for _, value := range path.Select(document) {
+			actual = append(actual, String(value))
+		}
+
+		if strings.Join(actual, "|") != strings.Join(c.expected, "|") {
+			t.Errorf("表达式【%s】: 期望%v，实际为%v", c.path, c.expected, actual)
+		}
+	}
+}
+
+func TestInvalidPath(t *testing.T) {
+	invalid := []string{"", "$.", "$[", "$['a'", "$[?(@.a ==)]", "$.a b", "$[1:2:3:4]"}
+
+	for _, path := range invalid {
+		_, err := Compile(path)
+		if err == nil {
+			t.Errorf("表达式【%s】应该是无效的", path)
+			continue
+		}
+
+		mkErr, ok := err.(base.MKError)
+		if !ok || mkErr.Domain() != base.ERR_DOMAIN_ANALYZER || mkErr.Code() != base.ERR_CODE_INVALID_EXPRESSION {
+			t.Errorf("表达式【%s】的错误类型不正确: %s", path, err)
+		}
+	}
+}
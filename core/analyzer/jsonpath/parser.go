@@ -0,0 +1,375 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// 表达式解析器
+type parser struct {
+	input string // 表达式
+	pos   int    // 当前位置
+}
+
+// 解析完整的表达式
+func (p *parser) parsePath() ([]*segment, error) {
+	p.skipSpace()
+	if p.eof() {
+		return nil, newSyntaxError(p.input, p.pos, "表达式为空")
+	}
+
+	segments, err := p.parseSegments()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if !p.eof() {
+		return nil, newSyntaxError(p.input, p.pos, "意外的字符%q", p.input[p.pos])
+	}
+
+	return segments, nil
+}
+
+// 解析路径段，遇到不能作为路径段开头的字符时结束
+func (p *parser) parseSegments() ([]*segment, error) {
+	segments := make([]*segment, 0)
+
+	// 路径可以以$或@开头，也可以直接以成员名开头
+	switch {
+	case p.peek() == '$' || p.peek() == '@':
+		p.pos++
+	case isNameStart(p.peek()):
+		segments = append(segments, &segment{selectors: []selector{nameSelector(p.scanName())}})
+	}
+
+	for !p.eof() {
+		switch {
+		case strings.HasPrefix(p.input[p.pos:], ".."):
+			p.pos += 2
+			seg, err := p.parseMember(true)
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, seg)
+
+		case p.peek() == '.':
+			p.pos++
+			seg, err := p.parseMember(false)
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, seg)
+
+		case p.peek() == '[':
+			selectors, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, &segment{selectors: selectors})
+
+		default:
+			return segments, nil
+		}
+	}
+
+	return segments, nil
+}
+
+// 解析.或..之后的成员名、通配符或方括号
+func (p *parser) parseMember(recursive bool) (*segment, error) {
+	switch {
+	case p.peek() == '*':
+		p.pos++
+		return &segment{recursive: recursive, selectors: []selector{wildcardSelector{}}}, nil
+
+	case recursive && p.peek() == '[':
+		selectors, err := p.parseBracket()
+		if err != nil {
+			return nil, err
+		}
+		return &segment{recursive: true, selectors: selectors}, nil
+
+	case isNameStart(p.peek()):
+		return &segment{recursive: recursive, selectors: []selector{nameSelector(p.scanName())}}, nil
+	}
+
+	return nil, newSyntaxError(p.input, p.pos, "缺少成员名")
+}
+
+// 解析方括号中的选择器
+func (p *parser) parseBracket() ([]selector, error) {
+	p.pos++ // [
+	selectors := make([]selector, 0)
+
+	for {
+		p.skipSpace()
+
+		var s selector
+		var err error
+		switch c := p.peek(); {
+		case c == '*':
+			p.pos++
+			s = wildcardSelector{}
+		case c == '\'' || c == '"':
+			var name string
+			if name, err = p.scanString(); err == nil {
+				s = nameSelector(name)
+			}
+		case c == '?':
+			s, err = p.parseFilter()
+		case c == '-' || c == ':' || isDigit(c):
+			s, err = p.parseIndexOrSlice()
+		default:
+			err = newSyntaxError(p.input, p.pos, "无效的选择器")
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, s)
+
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return selectors, nil
+		default:
+			return nil, newSyntaxError(p.input, p.pos, "缺少]")
+		}
+	}
+}
+
+// 解析数组下标或切片
+func (p *parser) parseIndexOrSlice() (selector, error) {
+	parts := make([]*int, 0, 3)
+	for {
+		p.skipSpace()
+
+		var part *int
+		if p.peek() == '-' || isDigit(p.peek()) {
+			n, err := p.scanInt()
+			if err != nil {
+				return nil, err
+			}
+			part = &n
+		}
+		parts = append(parts, part)
+
+		p.skipSpace()
+		if p.peek() != ':' {
+			break
+		}
+		if len(parts) == 3 {
+			return nil, newSyntaxError(p.input, p.pos, "切片最多有三个部分")
+		}
+		p.pos++
+	}
+
+	if len(parts) == 1 {
+		if parts[0] == nil {
+			return nil, newSyntaxError(p.input, p.pos, "缺少数组下标")
+		}
+		return indexSelector(*parts[0]), nil
+	}
+
+	s := sliceSelector{start: parts[0], end: parts[1]}
+	if len(parts) == 3 {
+		s.step = parts[2]
+	}
+
+	return s, nil
+}
+
+// 解析过滤器 ?(@.path op literal)
+func (p *parser) parseFilter() (selector, error) {
+	p.pos++ // ?
+	p.skipSpace()
+	if p.peek() != '(' {
+		return nil, newSyntaxError(p.input, p.pos, "过滤器缺少(")
+	}
+	p.pos++
+
+	p.skipSpace()
+	if p.peek() != '@' {
+		return nil, newSyntaxError(p.input, p.pos, "过滤器必须以@开头")
+	}
+
+	path, err := p.parseSegments()
+	if err != nil {
+		return nil, err
+	}
+
+	s := filterSelector{path: path}
+
+	p.skipSpace()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if strings.HasPrefix(p.input[p.pos:], op) {
+			p.pos += len(op)
+			s.op = op
+			break
+		}
+	}
+
+	if s.op != "" {
+		p.skipSpace()
+		if s.operand, err = p.scanLiteral(); err != nil {
+			return nil, err
+		}
+	}
+
+	p.skipSpace()
+	if p.peek() != ')' {
+		return nil, newSyntaxError(p.input, p.pos, "过滤器缺少)")
+	}
+	p.pos++
+
+	return s, nil
+}
+
+// 扫描字面量：字符串、数字、true、false或null
+func (p *parser) scanLiteral() (interface{}, error) {
+	c := p.peek()
+	switch {
+	case c == '\'' || c == '"':
+		return p.scanString()
+	case c == '-' || isDigit(c):
+		start := p.pos
+		p.pos++
+		for !p.eof() && (isDigit(p.peek()) || strings.IndexByte(".eE+-", p.peek()) >= 0) {
+			p.pos++
+		}
+		value, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+		if err != nil {
+			return nil, newSyntaxError(p.input, start, "无效的数字")
+		}
+		return value, nil
+	}
+
+	for _, word := range []string{"true", "false", "null"} {
+		if strings.HasPrefix(p.input[p.pos:], word) {
+			p.pos += len(word)
+			switch word {
+			case "true":
+				return true, nil
+			case "false":
+				return false, nil
+			}
+			return nil, nil
+		}
+	}
+
+	return nil, newSyntaxError(p.input, p.pos, "缺少字面量")
+}
+
+// 扫描以单引号或双引号括起的字符串，支持反斜杠转义
+func (p *parser) scanString() (string, error) {
+	start := p.pos
+	quote := p.input[p.pos]
+	p.pos++
+
+	var builder strings.Builder
+	for !p.eof() {
+		c := p.input[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return builder.String(), nil
+		case c == '\\' && p.pos+1 < len(p.input):
+			builder.WriteByte(p.input[p.pos+1])
+			p.pos += 2
+		default:
+			builder.WriteByte(c)
+			p.pos++
+		}
+	}
+
+	return "", newSyntaxError(p.input, start, "字符串没有结束")
+}
+
+// 扫描整数
+func (p *parser) scanInt() (int, error) {
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+	}
+	for !p.eof() && isDigit(p.peek()) {
+		p.pos++
+	}
+
+	n, err := strconv.Atoi(p.input[start:p.pos])
+	if err != nil {
+		return 0, newSyntaxError(p.input, start, "无效的整数")
+	}
+
+	return n, nil
+}
+
+// 扫描成员名
+func (p *parser) scanName() string {
+	start := p.pos
+	for !p.eof() && isNameChar(p.peek()) {
+		p.pos++
+	}
+
+	return p.input[start:p.pos]
+}
+
+func (p *parser) skipSpace() {
+	for !p.eof() && strings.IndexByte(" \t\r\n", p.input[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+
+	return p.input[p.pos]
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.input)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// 成员名可以包含非ASCII字符
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || isDigit(c) || c == '-'
+}
+
+// 把值转换为数字
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+
+	return 0, false
+}
+
+// 获取值的JSON文本
+func jsonText(value interface{}) string {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+
+	return string(content)
+}
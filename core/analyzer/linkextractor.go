@@ -0,0 +1,330 @@
+package analyzer
+
+import (
+	"code.google.com/p/go.net/html"
+	base "core/base"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 可以提取链接的标签及其链接属性
+var linkTagAttributes = map[string]string{
+	"a":      "href",
+	"area":   "href",
+	"link":   "href",
+	"iframe": "src",
+	"frame":  "src",
+}
+
+// 默认提取链接的标签
+var defaultLinkTags = []string{"a", "area"}
+
+// 链接规则默认的链接属性
+var defaultLinkRuleAttr = "href"
+
+// 链接提取参数的描述模板
+var linkExtractorArgumentsTemplate string = "{ tags: %v, rules: %d, follow nofollow: %v }"
+
+// 链接规则，以CSS选择器或XPath表达式选出链接
+type LinkRule struct {
+	Selector string `xml:"selector,attr"` // CSS选择器
+	XPath    string `xml:"xpath,attr"`    // XPath表达式，与CSS选择器必须且只能指定一个
+	Attr     string `xml:"attr,attr"`     // 链接所在的属性名，默认为href。XPath选中的不是元素时直接使用其字符串值
+}
+
+// 链接提取参数的容器
+type LinkExtractorArguments struct {
+	Tags           []string   `xml:"tag"`            // 提取链接的标签，默认为a和area
+	Rules          []LinkRule `xml:"rule"`           // 链接规则。指定了链接规则时只按规则提取链接，不再按标签提取
+	FollowNofollow bool       `xml:"followNofollow"` // 是否跟随带有rel="nofollow"的链接
+	description    string     // 描述
+}
+
+func (arguments *LinkExtractorArguments) Check() error {
+	for _, tag := range arguments.Tags {
+		if _, ok := linkTagAttributes[strings.ToLower(tag)]; !ok {
+			return errors.New(fmt.Sprintf("不支持从标签<%s>中提取链接！\n", tag))
+		}
+	}
+
+	_, err := compileLinkRules(arguments.Rules)
+	return err
+}
+
+func (arguments *LinkExtractorArguments) String() string {
+	if arguments.description == "" {
+		arguments.description =
+			fmt.Sprintf(linkExtractorArgumentsTemplate,
+				arguments.tags(),
+				len(arguments.Rules),
+				arguments.FollowNofollow)
+	}
+
+	return arguments.description
+}
+
+// 获取提取链接的标签
+func (arguments *LinkExtractorArguments) tags() []string {
+	if len(arguments.Tags) == 0 {
+		return defaultLinkTags
+	}
+
+	return arguments.Tags
+}
+
+// 创建链接提取器。
+// 链接提取器会扫描HTML文档，以最终URL和<base href>为参照解析相对链接，
+// 并为每个不重复的链接生成一个请求。
+// 参数arguments为nil时使用默认参数
+func NewLinkExtractor(arguments *LinkExtractorArguments) (MKParseResponse, error) {
+	if arguments == nil {
+		arguments = &LinkExtractorArguments{}
+	}
+
+	if err := arguments.Check(); err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string]string)
+	for _, tag := range arguments.tags() {
+		tag = strings.ToLower(tag)
+		tags[tag] = linkTagAttributes[tag]
+	}
+
+	rules, err := compileLinkRules(arguments.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	followNofollow := arguments.FollowNofollow
+
+	parse := func(httpResponse *http.Response, depth uint32) ([]base.MKData, []error) {
+		if !isHTMLResponse(httpResponse) {
+			return nil, nil
+		}
+
+		var links []*url.URL
+		errorList := make([]error, 0)
+		if len(rules) > 0 {
+			root, err := parseHTML(httpResponse)
+			if err != nil {
+				return nil, []error{err}
+			}
+
+			baseURL := documentBaseURL(root, responseURL(httpResponse))
+			links, errorList = extractRuleLinks(root, baseURL, rules, followNofollow)
+		} else {
+			body, err := utf8Body(httpResponse)
+			if err != nil {
+				return nil, []error{err}
+			}
+
+			if links, err = extractLinks(body, responseURL(httpResponse), tags, followNofollow); err != nil {
+				return nil, []error{err}
+			}
+		}
+
+		dataList := make([]base.MKData, 0, len(links))
+		for _, link := range links {
+			httpRequest, err := http.NewRequest("GET", link.String(), nil)
+			if err != nil {
+				errorList = append(errorList, err)
+				continue
+			}
+
+			dataList = append(dataList, base.NewRequest(httpRequest, depth))
+		}
+
+		return dataList, errorList
+	}
+
+	return parse, nil
+}
+
+// 从HTML文档中提取链接。
+// 参数tags代表需要提取链接的标签及其链接属性
+func extractLinks(
+	body io.Reader,
+	pageURL *url.URL,
+	tags map[string]string,
+	followNofollow bool) ([]*url.URL, error) {
+
+	baseURL := pageURL
+	baseFound := false
+	seen := make(map[string]bool)
+	links := make([]*url.URL, 0)
+
+	tokenizer := html.NewTokenizer(body)
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			if tokenizer.Err() == io.EOF {
+				return links, nil
+			}
+
+			return links, tokenizer.Err()
+		}
+
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+
+		token := tokenizer.Token()
+
+		// 只有第一个<base href>有效
+		if token.Data == "base" {
+			if href, ok := tokenAttr(token, "href"); ok && !baseFound {
+				if hrefURL, err := url.Parse(strings.TrimSpace(href)); err == nil {
+					if pageURL != nil {
+						hrefURL = pageURL.ResolveReference(hrefURL)
+					}
+					baseURL = hrefURL
+					baseFound = true
+				}
+			}
+			continue
+		}
+
+		attrName, ok := tags[token.Data]
+		if !ok {
+			continue
+		}
+
+		if !followNofollow {
+			if rel, ok := tokenAttr(token, "rel"); ok && hasToken(rel, "nofollow") {
+				continue
+			}
+		}
+
+		link, ok := tokenAttr(token, attrName)
+		if !ok {
+			continue
+		}
+
+		linkURL, ok := resolveLink(baseURL, link)
+		if !ok {
+			continue
+		}
+
+		key := linkURL.String()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		links = append(links, linkURL)
+	}
+}
+
+// 编译后的链接规则
+type compiledLinkRule struct {
+	selector nodeSelector // 节点选择器
+	attr     string       // 链接所在的属性名
+}
+
+// 编译链接规则
+func compileLinkRules(rules []LinkRule) ([]*compiledLinkRule, error) {
+	compiled := make([]*compiledLinkRule, 0, len(rules))
+	for i, rule := range rules {
+		selector, err := compileNodeSelector(rule.Selector, rule.XPath)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("链接规则[%d]: %s", i, err))
+		}
+
+		if selector == nil {
+			return nil, errors.New(fmt.Sprintf("链接规则[%d]中必须指定CSS选择器或XPath表达式！", i))
+		}
+
+		attr := rule.Attr
+		if attr == "" {
+			attr = defaultLinkRuleAttr
+		}
+
+		compiled = append(compiled, &compiledLinkRule{selector: selector, attr: attr})
+	}
+
+	return compiled, nil
+}
+
+// 按链接规则从HTML文档树中提取链接
+func extractRuleLinks(
+	root *html.Node,
+	baseURL *url.URL,
+	rules []*compiledLinkRule,
+	followNofollow bool) ([]*url.URL, []error) {
+
+	seen := make(map[string]bool)
+	links := make([]*url.URL, 0)
+	errorList := make([]error, 0)
+
+	for _, rule := range rules {
+		selections, err := rule.selector.selectNodes(root)
+		if err != nil {
+			errorList = append(errorList, err)
+			continue
+		}
+
+		for _, selection := range selections {
+			// 属性节点按其所属元素判断是否为nofollow链接
+			owner := selection.element
+			if owner == nil {
+				owner = selection.owner
+			}
+
+			if owner != nil && !followNofollow {
+				if rel, ok := nodeAttr(owner, "rel"); ok && hasToken(rel, "nofollow") {
+					continue
+				}
+			}
+
+			link := selection.value
+			if node := selection.element; node != nil {
+				var ok bool
+				if link, ok = nodeAttr(node, rule.attr); !ok {
+					continue
+				}
+			}
+
+			linkURL, ok := resolveLink(baseURL, link)
+			if !ok {
+				continue
+			}
+
+			key := linkURL.String()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			links = append(links, linkURL)
+		}
+	}
+
+	return links, errorList
+}
+
+// 获取标签中的属性值
+func tokenAttr(token html.Token, name string) (string, bool) {
+	for _, attr := range token.Attr {
+		if attr.Namespace == "" && attr.Key == name {
+			return attr.Val, true
+		}
+	}
+
+	return "", false
+}
+
+// 判断以空白分隔的属性值中是否包含给定的词（不区分大小写）
+func hasToken(value string, word string) bool {
+	for _, field := range strings.Fields(value) {
+		if strings.EqualFold(field, word) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,143 @@
+package analyzer
+
+import (
+	base "core/base"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 创建一个以rawURL为最终URL的测试响应
+func newTestResponse(t *testing.T, rawURL string, contentType string, body string) *http.Response {
+	httpRequest, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    httpRequest,
+	}
+}
+
+// 分别取出分析结果中请求的URL和条目
+func splitData(dataList []base.MKData) ([]string, []base.MKItem) {
+	urls := make([]string, 0)
+	items := make([]base.MKItem, 0)
+	for _, data := range dataList {
+		switch value := data.(type) {
+		case *base.MKRequest:
+			urls = append(urls, value.Request().URL.String())
+		case base.MKItem:
+			items = append(items, value)
+		}
+	}
+
+	return urls, items
+}
+
+var linkTestPage = `<html><head><title>归档</title></head><body>
+<div id="posts">
+	<a href="/p/1">第一篇</a>
+	<a href="p/2#comments">第二篇</a>
+	<a href="/p/1">第一篇（重复）</a>
+	<a href="http://other.com/p/3" rel="external nofollow">第三篇</a>
+</div>
+<a href="javascript:void(0)">脚本</a>
+<a href="mailto:tang@devtang.com">邮件</a>
+<a href="#top">顶部</a>
+<map><area href="/about"></map>
+<link rel="next" href="/archives/2">
+<iframe src="/widget"></iframe>
+</body></html>`
+
+func TestLinkExtractor(t *testing.T) {
+	cases := []struct {
+		name      string
+		arguments *LinkExtractorArguments
+		page      string
+		expected  []string
+	}{
+		{"默认标签", nil, linkTestPage, []string{
+			"http://blog.devtang.com/p/1",
+			"http://blog.devtang.com/archives/p/2",
+			"http://blog.devtang.com/about",
+		}},
+		{"跟随nofollow", &LinkExtractorArguments{Tags: []string{"a"}, FollowNofollow: true}, linkTestPage, []string{
+			"http://blog.devtang.com/p/1",
+			"http://blog.devtang.com/archives/p/2",
+			"http://other.com/p/3",
+		}},
+		{"指定标签", &LinkExtractorArguments{Tags: []string{"link", "iframe"}}, linkTestPage, []string{
+			"http://blog.devtang.com/archives/2",
+			"http://blog.devtang.com/widget",
+		}},
+		{"base href", nil, `<html><head><base href="/mirror/"><base href="/ignored/"></head>
+			<body><a href="p/1">相对</a><a href="/p/2">绝对路径</a></body></html>`, []string{
+			"http://blog.devtang.com/mirror/p/1",
+			"http://blog.devtang.com/p/2",
+		}},
+		{"CSS规则", &LinkExtractorArguments{Rules: []LinkRule{{Selector: "#posts a"}}}, linkTestPage, []string{
+			"http://blog.devtang.com/p/1",
+			"http://blog.devtang.com/archives/p/2",
+		}},
+		{"XPath规则", &LinkExtractorArguments{Rules: []LinkRule{{XPath: "//div[@id='posts']/a[2]/@href"}, {XPath: "//link/@href"}}}, linkTestPage, []string{
+			"http://blog.devtang.com/archives/p/2",
+			"http://blog.devtang.com/archives/2",
+		}},
+		{"规则与base href", &LinkExtractorArguments{Rules: []LinkRule{{Selector: "a"}}}, `<html><head><base href="http://cdn.devtang.com/"></head>
+			<body><a href="p/1">相对</a></body></html>`, []string{
+			"http://cdn.devtang.com/p/1",
+		}},
+	}
+
+	for _, c := range cases {
+		parse, err := NewLinkExtractor(c.arguments)
+		if err != nil {
+			t.Fatalf("%s: 无法创建链接提取器: %s", c.name, err)
+		}
+
+		response := newTestResponse(t, "http://blog.devtang.com/archives/", "text/html; charset=utf-8", c.page)
+		dataList, errorList := parse(response, 1)
+		if len(errorList) > 0 {
+			t.Errorf("%s: 意外的错误: %v", c.name, errorList)
+		}
+
+		urls, _ := splitData(dataList)
+		if !reflect.DeepEqual(urls, c.expected) {
+			t.Errorf("%s: 期望%v，实际为%v", c.name, c.expected, urls)
+		}
+	}
+}
+
+func TestLinkExtractorSkipsNonHTML(t *testing.T) {
+	parse, _ := NewLinkExtractor(nil)
+	response := newTestResponse(t, "http://blog.devtang.com/feed", "application/rss+xml", `<a href="/p/1">x</a>`)
+	if dataList, _ := parse(response, 0); len(dataList) != 0 {
+		t.Errorf("非HTML响应不应该提取链接，实际为%v", dataList)
+	}
+}
+
+func TestLinkExtractorArgumentsCheck(t *testing.T) {
+	invalid := []*LinkExtractorArguments{
+		{Tags: []string{"img"}},
+		{Rules: []LinkRule{{}}},
+		{Rules: []LinkRule{{Selector: "a", XPath: "//a"}}},
+		{Rules: []LinkRule{{Selector: "a["}}},
+	}
+
+	for i, arguments := range invalid {
+		if err := arguments.Check(); err == nil {
+			t.Errorf("[%d] %s: 应该无效", i, arguments.String())
+		}
+	}
+}
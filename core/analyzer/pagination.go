@@ -0,0 +1,462 @@
+package analyzer
+
+import (
+	"code.google.com/p/go.net/html"
+	base "core/base"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 页码模板中表示页码的占位符
+const PAGE_PLACEHOLDER = "{page}"
+
+// 默认的下一页链接文本
+var defaultNextTexts = []string{"下一页", "下页", "后一页", "Next", "Next Page", "Older Posts", "Older"}
+
+// 比较链接文本时去掉的箭头等字符
+const linkTextDecorations = " \t\r\n<>«»‹›→←[]【】()（）"
+
+// 分页规则的描述模板
+var paginationRuleTemplate string = "{ mode: %s, match: %s, max pages: %d, items: %s }"
+
+// 分页规则。
+// 下一页链接（CSS选择器、XPath表达式或链接文本）和页码（查询参数或URL模板）几种方式必须且只能指定一种。
+// 按页码翻页时，URL中带有页码的页面总是可以翻页，没有页码的页面只有匹配列表页面的正则表达式时才视为第一页，
+// 以免为文章等其他页面生成页码URL
+type PaginationRule struct {
+	Selector     string `xml:"selector,attr"`     // 下一页链接的CSS选择器
+	XPath        string `xml:"xpath,attr"`        // 下一页链接的XPath表达式
+	Text         string `xml:"text,attr"`         // 下一页链接的文本，多个文本以|分隔，比较时忽略大小写和箭头。为default时使用常见的文本
+	PageParam    string `xml:"pageParam,attr"`    // 页码所在的查询参数名
+	PageTemplate string `xml:"pageTemplate,attr"` // 包含{page}占位符的URL模板
+	FirstPage    uint32 `xml:"firstPage,attr"`    // 第一页的页码，默认为1
+	LastPage     uint32 `xml:"lastPage,attr"`     // 最后一页的页码，0表示不限。仅页码方式有效
+	MaxPages     uint32 `xml:"maxPages,attr"`     // 最多翻页的页数（包括第一页），0表示不限
+	ItemSelector string `xml:"itemSelector,attr"` // 页面中条目的CSS选择器。指定时，页面中没有新条目即停止翻页
+	ItemXPath    string `xml:"itemXpath,attr"`    // 页面中条目的XPath表达式，与条目的CSS选择器至多指定一个
+	Match        string `xml:"match,attr"`        // 列表页面URL的正则表达式。通过链接翻页时只在匹配的页面中查找下一页，按页码翻页时匹配的页面可以作为第一页
+	description  string // 描述
+}
+
+func (rule *PaginationRule) Check() error {
+	_, err := compilePaginationRule(rule)
+	return err
+}
+
+func (rule *PaginationRule) String() string {
+	if rule.description == "" {
+		mode := ""
+		switch {
+		case rule.Selector != "":
+			mode = "selector " + rule.Selector
+		case rule.XPath != "":
+			mode = "xpath " + rule.XPath
+		case rule.Text != "":
+			mode = "text " + rule.Text
+		case rule.PageParam != "":
+			mode = "page param " + rule.PageParam
+		default:
+			mode = "page template " + rule.PageTemplate
+		}
+
+		items := rule.ItemSelector
+		if items == "" {
+			items = rule.ItemXPath
+		}
+
+		rule.description = fmt.Sprintf(paginationRuleTemplate, mode, rule.Match, rule.MaxPages, items)
+	}
+
+	return rule.description
+}
+
+// 页码翻页方式，页码位于查询参数或URL模板中
+type pageNumbering struct {
+	param    string         // 页码所在的查询参数名
+	template string         // 包含{page}占位符的URL模板
+	pattern  *regexp.Regexp // 从URL中识别页码的正则表达式
+	first    uint32         // 第一页的页码
+}
+
+// 创建页码翻页方式。参数param和template必须且只能指定一个，参数first为0时第一页的页码为1
+func newPageNumbering(param string, template string, first uint32) (*pageNumbering, error) {
+	if (param == "") == (template == "") {
+		return nil, errors.New("页码所在的查询参数名和URL模板必须且只能指定一个！")
+	}
+
+	if first == 0 {
+		first = 1
+	}
+
+	numbering := &pageNumbering{param: param, template: template, first: first}
+	if template != "" {
+		if strings.Count(template, PAGE_PLACEHOLDER) != 1 {
+			return nil, errors.New(fmt.Sprintf("页码模板【%s】中必须有且只有一个%s！", template, PAGE_PLACEHOLDER))
+		}
+
+		// 模板可以是相对URL，因此只要求当前URL以模板结尾
+		pattern := strings.Replace(regexp.QuoteMeta(template), regexp.QuoteMeta(PAGE_PLACEHOLDER), `(\d+)`, 1)
+		var err error
+		if numbering.pattern, err = regexp.Compile(pattern + "$"); err != nil {
+			return nil, err
+		}
+	}
+
+	return numbering, nil
+}
+
+// 获取页面的页码。第二个结果值表示URL中是否带有页码，无法从URL中识别页码时视为第一页
+func (numbering *pageNumbering) current(pageURL *url.URL) (uint32, bool) {
+	var text string
+	if numbering.pattern != nil {
+		if match := numbering.pattern.FindStringSubmatch(pageURL.String()); match != nil {
+			text = match[1]
+		}
+	} else {
+		text = pageURL.Query().Get(numbering.param)
+	}
+
+	page, err := strconv.ParseUint(text, 10, 32)
+	if err != nil || uint32(page) < numbering.first {
+		return numbering.first, false
+	}
+
+	return uint32(page), true
+}
+
+// 获取从第一页到给定页的页数
+func (numbering *pageNumbering) count(page uint32) uint32 {
+	return page - numbering.first + 1
+}
+
+// 生成给定页的URL
+func (numbering *pageNumbering) url(pageURL *url.URL, page uint32) (*url.URL, bool) {
+	number := strconv.FormatUint(uint64(page), 10)
+	if numbering.pattern == nil {
+		return withQueryParam(pageURL, numbering.param, number), true
+	}
+
+	return resolveLink(pageURL, strings.Replace(numbering.template, PAGE_PLACEHOLDER, number, 1))
+}
+
+// 生成设置了查询参数的URL
+func withQueryParam(pageURL *url.URL, name string, value string) *url.URL {
+	nextURL := *pageURL
+	query := nextURL.Query()
+	query.Set(name, value)
+	nextURL.RawQuery = query.Encode()
+	nextURL.Fragment = ""
+
+	return &nextURL
+}
+
+// 编译后的分页规则
+type compiledPaginationRule struct {
+	rule      PaginationRule // 分页规则
+	next      nodeSelector   // 下一页链接的选择器
+	texts     []string       // 下一页链接的文本
+	numbering *pageNumbering // 页码翻页方式
+	items     nodeSelector   // 条目选择器
+	match     *regexp.Regexp // 列表页面URL的正则表达式
+}
+
+// 编译分页规则
+func compilePaginationRule(rule *PaginationRule) (*compiledPaginationRule, error) {
+	if rule == nil {
+		return nil, errors.New("分页规则无效！")
+	}
+
+	modes := 0
+	for _, value := range []string{rule.Selector, rule.XPath, rule.Text, rule.PageParam, rule.PageTemplate} {
+		if value != "" {
+			modes++
+		}
+	}
+
+	if modes != 1 {
+		return nil, errors.New("分页规则中必须且只能指定selector、xpath、text、pageParam和pageTemplate中的一个！")
+	}
+
+	compiled := &compiledPaginationRule{rule: *rule}
+
+	var err error
+	switch {
+	case rule.Selector != "" || rule.XPath != "":
+		compiled.next, err = compileNodeSelector(rule.Selector, rule.XPath)
+	case rule.Text != "":
+		texts := defaultNextTexts
+		if rule.Text != "default" {
+			texts = strings.Split(rule.Text, "|")
+		}
+		for _, text := range texts {
+			if text = normalizeLinkText(text); text != "" {
+				compiled.texts = append(compiled.texts, text)
+			}
+		}
+	default:
+		if rule.LastPage > 0 && rule.LastPage < rule.FirstPage {
+			return nil, errors.New(fmt.Sprintf("最后一页的页码（%d）不能小于第一页的页码（%d）！", rule.LastPage, rule.FirstPage))
+		}
+		compiled.numbering, err = newPageNumbering(rule.PageParam, rule.PageTemplate, rule.FirstPage)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if compiled.items, err = compileNodeSelector(rule.ItemSelector, rule.ItemXPath); err != nil {
+		return nil, err
+	}
+
+	if rule.Match != "" {
+		if compiled.match, err = regexp.Compile(rule.Match); err != nil {
+			return nil, errors.New(fmt.Sprintf("无效的列表页面正则表达式【%s】: %s", rule.Match, err))
+		}
+	}
+
+	return compiled, nil
+}
+
+// 分页状态，在同一个分页跟随器处理的各个页面之间共享
+type paginationState struct {
+	pages map[string]uint32 // 已生成的下一页URL与其页数（从第一页起计算）
+	items map[uint64]bool   // 已见过的条目的指纹
+	mutex sync.Mutex        // 互斥锁
+}
+
+// 创建分页跟随器。
+// 分页跟随器按照分页规则找出HTML页面的下一页，并生成分页请求。
+// 分页请求的深度与当前页面相同，因此翻页不会消耗爬取深度。
+// 翻页在以下情况下停止：找不到下一页、超过最后一页或最多页数、
+// 指定了条目选择器时页面中没有新的条目，以及页面不是列表页面
+func NewPaginationFollower(rule *PaginationRule) (MKParseResponse, error) {
+	compiled, err := compilePaginationRule(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &paginationState{
+		pages: make(map[string]uint32),
+		items: make(map[uint64]bool),
+	}
+
+	parse := func(httpResponse *http.Response, depth uint32) ([]base.MKData, []error) {
+		if !isHTMLResponse(httpResponse) || httpResponse.Request == nil {
+			return nil, nil
+		}
+
+		root, err := parseHTML(httpResponse)
+		if err != nil {
+			return nil, []error{err}
+		}
+
+		pageURL := httpResponse.Request.URL
+		if !compiled.paginates(pageURL) {
+			return nil, nil
+		}
+		baseURL := documentBaseURL(root, pageURL)
+
+		if compiled.items != nil {
+			fresh, err := state.markItems(compiled.items, root)
+			if err != nil {
+				return nil, []error{err}
+			}
+			if !fresh {
+				return nil, nil
+			}
+		}
+
+		nextURL, ok, err := compiled.nextPage(root, pageURL, baseURL, state)
+		if err != nil {
+			return nil, []error{err}
+		}
+		if !ok {
+			return nil, nil
+		}
+
+		httpRequest, err := http.NewRequest("GET", nextURL.String(), nil)
+		if err != nil {
+			return nil, []error{err}
+		}
+
+		for key, values := range httpResponse.Request.Header {
+			httpRequest.Header[key] = append([]string{}, values...)
+		}
+
+		return []base.MKData{base.NewPaginationRequest(httpRequest, depth)}, nil
+	}
+
+	return parse, nil
+}
+
+// 判断是否在页面中翻页。
+// 按页码翻页时，URL中没有页码的页面必须匹配列表页面的正则表达式；
+// 通过链接翻页时，只有指定了正则表达式才检查页面的URL
+func (rule *compiledPaginationRule) paginates(pageURL *url.URL) bool {
+	if rule.numbering != nil {
+		if _, ok := rule.numbering.current(pageURL); ok {
+			return true
+		}
+		return rule.match != nil && rule.match.MatchString(pageURL.String())
+	}
+
+	return rule.match == nil || rule.match.MatchString(pageURL.String())
+}
+
+// 获取下一页的URL。第二个结果值为false时表示没有下一页
+func (rule *compiledPaginationRule) nextPage(
+	root *html.Node,
+	pageURL *url.URL,
+	baseURL *url.URL,
+	state *paginationState) (*url.URL, bool, error) {
+
+	if rule.numbering != nil {
+		page, _ := rule.numbering.current(pageURL)
+		nextPage := page + 1
+		if rule.rule.LastPage > 0 && nextPage > rule.rule.LastPage {
+			return nil, false, nil
+		}
+
+		if rule.rule.MaxPages > 0 && rule.numbering.count(nextPage) > rule.rule.MaxPages {
+			return nil, false, nil
+		}
+
+		nextURL, ok := rule.numbering.url(pageURL, nextPage)
+		return nextURL, ok, nil
+	}
+
+	link, err := rule.nextLink(root)
+	if err != nil || link == "" {
+		return nil, false, err
+	}
+
+	nextURL, ok := resolveLink(baseURL, link)
+	if !ok || nextURL.String() == pageURL.String() {
+		return nil, false, nil
+	}
+
+	// 通过链接翻页时无法从URL中得知页码，因此记录每个下一页URL的页数
+	if !state.advance(pageURL, nextURL, rule.rule.MaxPages) {
+		return nil, false, nil
+	}
+
+	return nextURL, true, nil
+}
+
+// 获取下一页链接
+func (rule *compiledPaginationRule) nextLink(root *html.Node) (string, error) {
+	if rule.next != nil {
+		selections, err := rule.next.selectNodes(root)
+		if err != nil {
+			return "", err
+		}
+
+		for _, selection := range selections {
+			if selection.element == nil {
+				return selection.value, nil
+			}
+
+			if href, ok := nodeAttr(selection.element, "href"); ok {
+				return href, nil
+			}
+		}
+
+		return "", nil
+	}
+
+	link := ""
+	walkElements(root, func(node *html.Node) bool {
+		if link != "" {
+			return false
+		}
+
+		if node.Data != "a" {
+			return true
+		}
+
+		href, ok := nodeAttr(node, "href")
+		if !ok {
+			return false
+		}
+
+		text := normalizeLinkText(textContent(node))
+		if text == "" {
+			text = normalizeLinkText(attrValue(node, "title"))
+		}
+
+		for _, expected := range rule.texts {
+			if strings.EqualFold(text, expected) {
+				link = href
+				break
+			}
+		}
+
+		return false
+	})
+
+	return link, nil
+}
+
+// 规范化链接文本：合并空白并去掉两端的箭头等字符
+func normalizeLinkText(text string) string {
+	return strings.Trim(collapseSpace(text), linkTextDecorations)
+}
+
+// 记录页面中的条目，返回是否有新的条目
+func (state *paginationState) markItems(items nodeSelector, root *html.Node) (bool, error) {
+	selections, err := items.selectNodes(root)
+	if err != nil {
+		return false, err
+	}
+
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	fresh := false
+	for _, selection := range selections {
+		content := selection.value
+		if selection.element != nil {
+			content = collapseSpace(textContent(selection.element))
+		}
+
+		hash := fnv.New64a()
+		hash.Write([]byte(content))
+		fingerprint := hash.Sum64()
+
+		if !state.items[fingerprint] {
+			state.items[fingerprint] = true
+			fresh = true
+		}
+	}
+
+	return fresh, nil
+}
+
+// 记录从当前页到下一页的翻页，返回是否还可以翻页
+func (state *paginationState) advance(pageURL *url.URL, nextURL *url.URL, maxPages uint32) bool {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	// 没有记录的页面视为第一页
+	page, ok := state.pages[pageURL.String()]
+	if !ok {
+		page = 1
+	}
+
+	if maxPages > 0 && page+1 > maxPages {
+		return false
+	}
+
+	if _, ok := state.pages[nextURL.String()]; !ok {
+		state.pages[nextURL.String()] = page + 1
+	}
+
+	return true
+}
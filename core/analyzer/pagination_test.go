@@ -0,0 +1,136 @@
+package analyzer
+
+import (
+	base "core/base"
+	"reflect"
+	"testing"
+)
+
+var archivePage = `<html><body>
+<ul class="posts"><li>第一篇</li><li>第二篇</li></ul>
+<div class="pager">
+	<a href="/archives/">« 上一页</a>
+	<a class="next" href="/archives/page/3/" title="Next">下一页 »</a>
+</div>
+</body></html>`
+
+func TestPaginationFollower(t *testing.T) {
+	cases := []struct {
+		name     string
+		rule     PaginationRule
+		pageURL  string
+		page     string
+		expected []string
+	}{
+		{"CSS选择器", PaginationRule{Selector: "a.next"}, "http://blog.devtang.com/archives/page/2/", archivePage,
+			[]string{"http://blog.devtang.com/archives/page/3/"}},
+		{"XPath表达式", PaginationRule{XPath: "//div[@class='pager']/a[last()]/@href"}, "http://blog.devtang.com/archives/page/2/", archivePage,
+			[]string{"http://blog.devtang.com/archives/page/3/"}},
+		{"默认的链接文本", PaginationRule{Text: "default"}, "http://blog.devtang.com/archives/page/2/", archivePage,
+			[]string{"http://blog.devtang.com/archives/page/3/"}},
+		{"链接标题", PaginationRule{Text: "next"}, "http://blog.devtang.com/archives/page/2/",
+			`<html><body><a href="/archives/page/3/" title="Next"><img src="arrow.png"></a></body></html>`,
+			[]string{"http://blog.devtang.com/archives/page/3/"}},
+		{"没有匹配的链接文本", PaginationRule{Text: "后一页|Older"}, "http://blog.devtang.com/archives/page/2/", archivePage, []string{}},
+		{"指向自身的链接", PaginationRule{Selector: "a.next"}, "http://blog.devtang.com/archives/page/3/", archivePage, []string{}},
+		{"匹配的列表页面", PaginationRule{Selector: "a.next", Match: `/archives/`}, "http://blog.devtang.com/archives/page/2/", archivePage,
+			[]string{"http://blog.devtang.com/archives/page/3/"}},
+		{"不匹配的页面", PaginationRule{Selector: "a.next", Match: `/archives/`}, "http://blog.devtang.com/2014/01/01/first/", archivePage, []string{}},
+		{"查询参数", PaginationRule{PageParam: "page"}, "http://blog.devtang.com/list?page=2&tag=ios#top", archivePage,
+			[]string{"http://blog.devtang.com/list?page=3&tag=ios"}},
+		{"没有页码的页面", PaginationRule{PageParam: "page"}, "http://blog.devtang.com/2014/01/01/first/", archivePage, []string{}},
+		{"匹配的第一页", PaginationRule{PageParam: "page", Match: `/list$`}, "http://blog.devtang.com/list", archivePage,
+			[]string{"http://blog.devtang.com/list?page=2"}},
+		{"不匹配的第一页", PaginationRule{PageParam: "page", Match: `/list$`}, "http://blog.devtang.com/about", archivePage, []string{}},
+		{"URL模板", PaginationRule{PageTemplate: "/archives/page/{page}/"}, "http://blog.devtang.com/archives/page/2/", archivePage,
+			[]string{"http://blog.devtang.com/archives/page/3/"}},
+		{"URL模板的第一页", PaginationRule{PageTemplate: "/archives/page/{page}/", Match: `/archives/$`}, "http://blog.devtang.com/archives/", archivePage,
+			[]string{"http://blog.devtang.com/archives/page/2/"}},
+		{"第一页的页码", PaginationRule{PageParam: "p", FirstPage: 0, Match: `/list`}, "http://blog.devtang.com/list?p=0", archivePage,
+			[]string{"http://blog.devtang.com/list?p=2"}},
+		{"最后一页", PaginationRule{PageParam: "page", LastPage: 3}, "http://blog.devtang.com/list?page=3", archivePage, []string{}},
+		{"最多页数", PaginationRule{PageParam: "page", FirstPage: 0, MaxPages: 3}, "http://blog.devtang.com/list?page=3", archivePage, []string{}},
+		{"没有条目", PaginationRule{PageParam: "page", ItemSelector: "ul.posts li"}, "http://blog.devtang.com/list?page=2",
+			`<html><body><ul class="posts"></ul></body></html>`, []string{}},
+		{"非HTML响应", PaginationRule{PageParam: "page"}, "http://blog.devtang.com/list.json?page=2", "", []string{}},
+	}
+
+	for _, c := range cases {
+		parse, err := NewPaginationFollower(&c.rule)
+		if err != nil {
+			t.Fatalf("%s: 无法创建分页跟随器: %s", c.name, err)
+		}
+
+		contentType := "text/html"
+		if c.page == "" {
+			contentType = "application/json"
+		}
+
+		dataList, errorList := parse(newTestResponse(t, c.pageURL, contentType, c.page), 2)
+		if len(errorList) > 0 {
+			t.Errorf("%s: 意外的错误: %v", c.name, errorList)
+		}
+
+		urls, _ := splitData(dataList)
+		if !reflect.DeepEqual(urls, c.expected) {
+			t.Errorf("%s: 期望%v，实际为%v", c.name, c.expected, urls)
+		}
+	}
+}
+
+func TestPaginationFollowerStops(t *testing.T) {
+	page := func(items string, next string) string {
+		return `<html><body><ul class="posts">` + items + `</ul><a class="next" href="` + next + `">下一页</a></body></html>`
+	}
+
+	// 通过链接翻页时，页数记录在分页跟随器中
+	parse, _ := NewPaginationFollower(&PaginationRule{Selector: "a.next", MaxPages: 3, ItemSelector: "ul.posts li"})
+	steps := []struct {
+		pageURL  string
+		page     string
+		expected []string
+	}{
+		{"http://blog.devtang.com/", page("<li>1</li>", "/p/2"), []string{"http://blog.devtang.com/p/2"}},
+		{"http://blog.devtang.com/p/2", page("<li>2</li>", "/p/3"), []string{"http://blog.devtang.com/p/3"}},
+		{"http://blog.devtang.com/p/3", page("<li>3</li>", "/p/4"), []string{}},
+		// 另一个入口页面重新计算页数，但条目都已经见过
+		{"http://blog.devtang.com/tags/ios/", page("<li>1</li><li>2</li>", "/tags/ios/2"), []string{}},
+		{"http://blog.devtang.com/tags/go/", page("<li>2</li><li>4</li>", "/tags/go/2"), []string{"http://blog.devtang.com/tags/go/2"}},
+	}
+
+	for i, step := range steps {
+		dataList, errorList := parse(newTestResponse(t, step.pageURL, "text/html", step.page), 1)
+		if len(errorList) > 0 {
+			t.Errorf("[%d] 意外的错误: %v", i, errorList)
+		}
+
+		urls, _ := splitData(dataList)
+		if !reflect.DeepEqual(urls, step.expected) {
+			t.Errorf("[%d] %s: 期望%v，实际为%v", i, step.pageURL, step.expected, urls)
+		}
+
+		for _, data := range dataList {
+			if request, ok := data.(*base.MKRequest); ok && request.Depth() != 1 {
+				t.Errorf("[%d] 分页请求的深度应该与当前页面相同，实际为%d", i, request.Depth())
+			}
+		}
+	}
+}
+
+func TestPaginationRuleCheck(t *testing.T) {
+	invalid := []*PaginationRule{
+		{},
+		{Selector: "a.next", PageParam: "page"},
+		{PageTemplate: "/archives/page/"},
+		{PageTemplate: "/{page}/{page}/"},
+		{PageParam: "page", FirstPage: 3, LastPage: 2},
+		{Selector: "a["},
+		{Text: "default", Match: "("},
+	}
+
+	for i, rule := range invalid {
+		if err := rule.Check(); err == nil {
+			t.Errorf("[%d] %s: 应该无效", i, rule.String())
+		}
+	}
+}
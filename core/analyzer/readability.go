@@ -0,0 +1,667 @@
+package analyzer
+
+import (
+	"bytes"
+	"code.google.com/p/go.net/html"
+	base "core/base"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 正文条目的字段名称
+const (
+	READABILITY_FIELD_URL       = "url"       // 页面URL
+	READABILITY_FIELD_TITLE     = "title"     // 标题
+	READABILITY_FIELD_BYLINE    = "byline"    // 作者署名
+	READABILITY_FIELD_PUBLISHED = "published" // 发布时间，按原文返回
+	READABILITY_FIELD_CONTENT   = "content"   // 清理后的正文HTML
+	READABILITY_FIELD_TEXT      = "text"      // 正文纯文本，段落之间以换行分隔
+	READABILITY_FIELD_LENGTH    = "length"    // 正文的字符数
+)
+
+// 默认的正文最少字符数
+const defaultReadabilityMinLength = 140
+
+// 不可能包含正文的标签
+var unlikelyTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true,
+	"iframe": true, "form": true, "nav": true, "header": true, "footer": true,
+	"aside": true, "button": true, "input": true, "select": true, "textarea": true,
+	"svg": true, "canvas": true, "object": true, "embed": true, "link": true, "meta": true,
+}
+
+// 块级元素，提取纯文本时在其前后换行
+var blockTags = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "pre": true, "blockquote": true,
+	"ul": true, "ol": true, "li": true, "table": true, "tr": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true, "br": true, "hr": true, "figure": true,
+	"figcaption": true, "dl": true, "dt": true, "dd": true,
+}
+
+// 清理后的正文HTML中保留的属性
+var keptAttributes = map[string]bool{
+	"href": true, "src": true, "alt": true, "title": true, "colspan": true, "rowspan": true, "datetime": true,
+}
+
+var (
+	// class或id表明不可能是正文的元素
+	unlikelyPattern = regexp.MustCompile(`(?i)comment|reply|discuss|sidebar|side-bar|widget|footer|header|menu|nav|breadcrumb|share|social|sponsor|banner|advert|\bads?\b|ad-|popup|modal|related|recommend|pagination|pager|copyright|login|subscribe|tags?-?cloud`)
+	// class或id表明可能是正文的元素
+	positivePattern = regexp.MustCompile(`(?i)article|content|entry|post|main|body|text|blog|story|markdown|prose|detail`)
+	// class或id表明是作者署名的元素
+	bylinePattern = regexp.MustCompile(`(?i)byline|author|writer|poster`)
+	// class或id表明是发布时间的元素
+	datePattern = regexp.MustCompile(`(?i)date|time|publish|posted|meta`)
+	// 文本中的日期，如2014-01-02、2014/1/2、2014年1月2日
+	dateTextPattern = regexp.MustCompile(`\d{4}\s*[-/.年]\s*\d{1,2}\s*[-/.月]\s*\d{1,2}\s*日?(?:\s+\d{1,2}:\d{2}(?::\d{2})?)?`)
+	// 标题中站点名称之前的分隔符
+	titleSeparatorPattern = regexp.MustCompile(`\s+[|\-–—_»·]\s+|\s*[|_»]\s*`)
+)
+
+// 正文提取参数的描述模板
+var readabilityArgumentsTemplate string = "{ min length: %d }"
+
+// 正文提取参数的容器
+type ReadabilityArguments struct {
+	MinLength   uint32 `xml:"minLength"` // 正文的最少字符数，不足时不产生条目。为0时使用默认值140
+	description string // 描述
+}
+
+func (arguments *ReadabilityArguments) Check() error {
+	return nil
+}
+
+func (arguments *ReadabilityArguments) String() string {
+	if arguments.description == "" {
+		arguments.description = fmt.Sprintf(readabilityArgumentsTemplate, arguments.minLength())
+	}
+
+	return arguments.description
+}
+
+// 获取正文的最少字符数
+func (arguments *ReadabilityArguments) minLength() int {
+	if arguments.MinLength == 0 {
+		return defaultReadabilityMinLength
+	}
+
+	return int(arguments.MinLength)
+}
+
+// 创建正文提取器。
+// 正文提取器按照文本密度和链接密度为页面中的块打分，选出正文所在的块，
+// 去掉导航、广告和评论等内容后产生一个条目（字段见READABILITY_FIELD_*常量）。
+// 文本长度以字符而不是单词计算，标点也包括中文标点，因此同样适用于中文页面。
+// 参数arguments为nil时使用默认参数
+func NewReadabilityParser(arguments *ReadabilityArguments) (MKParseResponse, error) {
+	if arguments == nil {
+		arguments = &ReadabilityArguments{}
+	}
+
+	if err := arguments.Check(); err != nil {
+		return nil, err
+	}
+
+	minLength := arguments.minLength()
+
+	parse := func(httpResponse *http.Response, depth uint32) ([]base.MKData, []error) {
+		if !isHTMLResponse(httpResponse) {
+			return nil, nil
+		}
+
+		root, err := parseHTML(httpResponse)
+		if err != nil {
+			return nil, []error{err}
+		}
+
+		baseURL := documentBaseURL(root, responseURL(httpResponse))
+		item := extractArticle(root, baseURL, minLength)
+		if item == nil {
+			return nil, nil
+		}
+
+		return []base.MKData{item}, nil
+	}
+
+	return parse, nil
+}
+
+// 从文档中提取正文。正文过短时返回nil
+func extractArticle(root *html.Node, baseURL *url.URL, minLength int) base.MKItem {
+	metas := extractMetaTags(root)
+
+	// 署名和发布时间所在的元素可能会在清理时被去掉，所以要先提取
+	title := articleTitle(root)
+	byline := articleByline(root, metas)
+	published := articlePublished(root, metas)
+
+	removeUnlikely(root)
+
+	nodes := articleNodes(root)
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	var content bytes.Buffer
+	var text strings.Builder
+	for _, node := range nodes {
+		cleanArticle(node)
+		renderClean(&content, node, baseURL)
+		writeText(&text, node)
+	}
+
+	articleText := normalizeParagraphs(text.String())
+	length := utf8.RuneCountInString(articleText)
+	if length < minLength {
+		return nil
+	}
+
+	item := base.MKItem{
+		READABILITY_FIELD_TITLE:     title,
+		READABILITY_FIELD_BYLINE:    byline,
+		READABILITY_FIELD_PUBLISHED: published,
+		READABILITY_FIELD_CONTENT:   strings.TrimSpace(content.String()),
+		READABILITY_FIELD_TEXT:      articleText,
+		READABILITY_FIELD_LENGTH:    length,
+	}
+
+	if baseURL != nil {
+		item[READABILITY_FIELD_URL] = baseURL.String()
+	}
+
+	return item
+}
+
+// 去掉不可能包含正文的元素
+func removeUnlikely(node *html.Node) {
+	for child := node.FirstChild; child != nil; {
+		next := child.NextSibling
+
+		switch child.Type {
+		case html.CommentNode:
+			node.RemoveChild(child)
+		case html.ElementNode:
+			if isUnlikely(child) {
+				node.RemoveChild(child)
+			} else {
+				removeUnlikely(child)
+			}
+		}
+
+		child = next
+	}
+}
+
+// 判断元素是否不可能包含正文
+func isUnlikely(node *html.Node) bool {
+	if unlikelyTags[node.Data] {
+		return true
+	}
+
+	if node.Data == "body" || node.Data == "html" || node.Data == "article" || node.Data == "main" {
+		return false
+	}
+
+	if hasAttr(node, "hidden") {
+		return true
+	}
+
+	if style, ok := nodeAttr(node, "style"); ok {
+		style = strings.Replace(strings.ToLower(style), " ", "", -1)
+		if strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden") {
+			return true
+		}
+	}
+
+	if role, ok := nodeAttr(node, "role"); ok {
+		switch role {
+		case "navigation", "banner", "complementary", "contentinfo", "dialog", "menu", "menubar":
+			return true
+		}
+	}
+
+	names := classAndID(node)
+	return unlikelyPattern.MatchString(names) && !positivePattern.MatchString(names)
+}
+
+// 获取元素的class和id
+func classAndID(node *html.Node) string {
+	class, _ := nodeAttr(node, "class")
+	id, _ := nodeAttr(node, "id")
+
+	return class + " " + id
+}
+
+// 为元素打分并选出正文所在的元素
+func articleNodes(root *html.Node) []*html.Node {
+	scores := make(map[*html.Node]float64)
+
+	initialize := func(node *html.Node) {
+		if _, ok := scores[node]; ok {
+			return
+		}
+
+		score := classWeight(node)
+		switch node.Data {
+		case "article":
+			score += 10
+		case "div", "section", "main":
+			score += 5
+		case "pre", "td", "blockquote":
+			score += 3
+		case "ol", "ul", "dl", "dd", "dt", "li", "form":
+			score -= 3
+		case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+			score -= 5
+		}
+		scores[node] = score
+	}
+
+	// 以段落为单位打分，分数累加到父元素和祖父元素上
+	walkElements(root, func(node *html.Node) bool {
+		if !isParagraph(node) {
+			return true
+		}
+
+		text := strings.TrimSpace(collapseSpace(textContent(node)))
+		length := utf8.RuneCountInString(text)
+		if length < 25 {
+			return true
+		}
+
+		score := 1 + float64(countPunctuation(text)) + math.Min(float64(length)/100, 3)
+
+		parent := node.Parent
+		for level := 0; parent != nil && parent.Type == html.ElementNode && level < 3; level++ {
+			initialize(parent)
+			switch level {
+			case 0:
+				scores[parent] += score
+			case 1:
+				scores[parent] += score / 2
+			default:
+				scores[parent] += score / 6
+			}
+			parent = parent.Parent
+		}
+
+		return true
+	})
+
+	if len(scores) == 0 {
+		return nil
+	}
+
+	// 链接越多的元素越不可能是正文。候选元素按文档顺序排列，得分相同时靠前的优先
+	candidates := make([]*html.Node, 0, len(scores))
+	walkElements(root, func(node *html.Node) bool {
+		if _, ok := scores[node]; ok {
+			scores[node] *= 1 - linkDensity(node)
+			candidates = append(candidates, node)
+		}
+		return true
+	})
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return scores[candidates[i]] > scores[candidates[j]]
+	})
+
+	top := candidates[0]
+	if top.Parent == nil {
+		return []*html.Node{top}
+	}
+
+	// 得分接近的兄弟元素以及较长的段落也属于正文
+	threshold := math.Max(10, scores[top]*0.2)
+	nodes := make([]*html.Node, 0)
+	for sibling := top.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+		if sibling.Type != html.ElementNode {
+			continue
+		}
+
+		if sibling == top {
+			nodes = append(nodes, sibling)
+			continue
+		}
+
+		if score, ok := scores[sibling]; ok && score >= threshold {
+			nodes = append(nodes, sibling)
+			continue
+		}
+
+		if sibling.Data == "p" {
+			text := collapseSpace(textContent(sibling))
+			length := utf8.RuneCountInString(text)
+			density := linkDensity(sibling)
+			if (length > 80 && density < 0.25) || (length > 0 && density == 0 && strings.ContainsAny(text, ".。")) {
+				nodes = append(nodes, sibling)
+			}
+		}
+	}
+
+	return nodes
+}
+
+// 判断元素是否为段落。没有块级子元素的div也视为段落
+func isParagraph(node *html.Node) bool {
+	switch node.Data {
+	case "p", "pre", "td", "blockquote", "li", "dd":
+		return true
+	case "div", "section":
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == html.ElementNode && blockTags[child.Data] && child.Data != "br" {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// 根据class和id计算权重
+func classWeight(node *html.Node) float64 {
+	names := classAndID(node)
+	if strings.TrimSpace(names) == "" {
+		return 0
+	}
+
+	weight := 0.0
+	if positivePattern.MatchString(names) {
+		weight += 25
+	}
+
+	if unlikelyPattern.MatchString(names) {
+		weight -= 25
+	}
+
+	return weight
+}
+
+// 计算链接密度，即链接文本占全部文本的比例
+func linkDensity(node *html.Node) float64 {
+	length := utf8.RuneCountInString(collapseSpace(textContent(node)))
+	if length == 0 {
+		return 0
+	}
+
+	linkLength := 0
+	walkElements(node, func(child *html.Node) bool {
+		if child.Data == "a" {
+			linkLength += utf8.RuneCountInString(collapseSpace(textContent(child)))
+			return false
+		}
+		return true
+	})
+
+	return float64(linkLength) / float64(length)
+}
+
+// 统计标点的个数。中文文本没有单词间的空格，因此用标点衡量句子的数量
+func countPunctuation(text string) int {
+	count := 0
+	for _, r := range text {
+		switch r {
+		case ',', '，', '。', '、', '；', '！', '？', ';':
+			count++
+		}
+	}
+
+	return count
+}
+
+// 清理正文元素：去掉链接密度高、文本少的块以及空的块
+func cleanArticle(node *html.Node) {
+	for child := node.FirstChild; child != nil; {
+		next := child.NextSibling
+
+		if child.Type == html.ElementNode {
+			cleanArticle(child)
+
+			if isClutter(child) {
+				node.RemoveChild(child)
+			}
+		}
+
+		child = next
+	}
+}
+
+// 判断块是否为杂乱内容
+func isClutter(node *html.Node) bool {
+	switch node.Data {
+	case "div", "section", "ul", "ol", "table", "p", "dl", "span":
+	default:
+		return false
+	}
+
+	text := collapseSpace(textContent(node))
+	length := utf8.RuneCountInString(strings.TrimSpace(text))
+
+	// 包含图片等媒体的块即使没有文本也要保留
+	hasMedia := false
+	walkElements(node, func(child *html.Node) bool {
+		switch child.Data {
+		case "img", "video", "audio", "picture", "pre", "code":
+			hasMedia = true
+		}
+		return !hasMedia
+	})
+
+	if length == 0 {
+		return !hasMedia
+	}
+
+	if hasMedia || node.Data == "p" || node.Data == "span" {
+		return false
+	}
+
+	density := linkDensity(node)
+	return (density > 0.5 && length < 200) || classWeight(node) < 0 && length < 100
+}
+
+// 输出清理后的HTML：只保留必要的属性，链接和图片地址转换为绝对URL
+func renderClean(buffer *bytes.Buffer, node *html.Node, baseURL *url.URL) {
+	clone := cloneClean(node, baseURL)
+	html.Render(buffer, clone)
+}
+
+// 复制元素及其后代，并清理属性
+func cloneClean(node *html.Node, baseURL *url.URL) *html.Node {
+	clone := &html.Node{
+		Type:      node.Type,
+		DataAtom:  node.DataAtom,
+		Data:      node.Data,
+		Namespace: node.Namespace,
+	}
+
+	for _, attr := range node.Attr {
+		if !keptAttributes[attr.Key] {
+			continue
+		}
+
+		if (attr.Key == "href" || attr.Key == "src") && baseURL != nil {
+			if linkURL, err := url.Parse(strings.TrimSpace(attr.Val)); err == nil {
+				attr.Val = baseURL.ResolveReference(linkURL).String()
+			}
+		}
+
+		clone.Attr = append(clone.Attr, attr)
+	}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		clone.AppendChild(cloneClean(child, baseURL))
+	}
+
+	return clone
+}
+
+// 输出纯文本，块级元素前后换行
+func writeText(builder *strings.Builder, node *html.Node) {
+	switch node.Type {
+	case html.TextNode:
+		builder.WriteString(node.Data)
+		return
+	case html.ElementNode:
+		if blockTags[node.Data] {
+			builder.WriteString("\n")
+			defer builder.WriteString("\n")
+		}
+	}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		writeText(builder, child)
+	}
+}
+
+// 整理段落：合并每行中的空白，去掉空行
+func normalizeParagraphs(text string) string {
+	lines := strings.Split(text, "\n")
+	paragraphs := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if line = strings.TrimSpace(collapseSpace(line)); line != "" {
+			paragraphs = append(paragraphs, line)
+		}
+	}
+
+	return strings.Join(paragraphs, "\n")
+}
+
+// 把连续的空白合并为一个空格
+func collapseSpace(text string) string {
+	var builder strings.Builder
+	space := false
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+
+		if space && builder.Len() > 0 {
+			builder.WriteByte(' ')
+		}
+		space = false
+		builder.WriteRune(r)
+	}
+
+	return builder.String()
+}
+
+// 获取元素的文本内容
+func textContent(node *html.Node) string {
+	var builder strings.Builder
+	writeText(&builder, node)
+
+	return builder.String()
+}
+
+// 获取文章标题。
+// 页面中唯一的<h1>与<title>一致时使用<h1>，否则去掉<title>中的站点名称
+func articleTitle(root *html.Node) string {
+	title := documentTitle(root)
+
+	headings := make([]string, 0)
+	walkElements(root, func(node *html.Node) bool {
+		if node.Data == "h1" {
+			headings = append(headings, strings.TrimSpace(collapseSpace(textContent(node))))
+			return false
+		}
+		return true
+	})
+
+	if len(headings) == 1 && headings[0] != "" && (title == "" || strings.Contains(title, headings[0])) {
+		return headings[0]
+	}
+
+	if locations := titleSeparatorPattern.FindAllStringIndex(title, -1); len(locations) > 0 {
+		last := locations[len(locations)-1]
+		if candidate := strings.TrimSpace(title[:last[0]]); utf8.RuneCountInString(candidate) >= 4 {
+			return candidate
+		}
+	}
+
+	return title
+}
+
+// 获取作者署名
+func articleByline(root *html.Node, metas *metaTags) string {
+	if author := metas.values["author"]; author != "" {
+		return author
+	}
+
+	if author := metas.values["article:author"]; author != "" && !strings.HasPrefix(author, "http") {
+		return author
+	}
+
+	byline := ""
+	walkElements(root, func(node *html.Node) bool {
+		if byline != "" {
+			return false
+		}
+
+		rel, _ := nodeAttr(node, "rel")
+		itemprop, _ := nodeAttr(node, "itemprop")
+		if hasToken(rel, "author") || hasToken(itemprop, "author") || bylinePattern.MatchString(classAndID(node)) {
+			text := strings.TrimSpace(collapseSpace(textContent(node)))
+			if length := utf8.RuneCountInString(text); length > 0 && length < 100 {
+				byline = text
+				return false
+			}
+		}
+
+		return true
+	})
+
+	return byline
+}
+
+// 获取发布时间
+func articlePublished(root *html.Node, metas *metaTags) string {
+	for _, name := range []string{"article:published_time", "pubdate", "publishdate", "date", "dc.date", "dcterms.created"} {
+		if value := metas.values[name]; value != "" {
+			return value
+		}
+	}
+
+	published := ""
+	walkElements(root, func(node *html.Node) bool {
+		if published != "" {
+			return false
+		}
+
+		if itemprop, _ := nodeAttr(node, "itemprop"); hasToken(itemprop, "datePublished") {
+			published = firstNonEmpty(attrValue(node, "datetime"), attrValue(node, "content"),
+				strings.TrimSpace(collapseSpace(textContent(node))))
+			return false
+		}
+
+		if node.Data == "time" {
+			published = firstNonEmpty(attrValue(node, "datetime"), strings.TrimSpace(textContent(node)))
+			return false
+		}
+
+		if datePattern.MatchString(classAndID(node)) {
+			if match := dateTextPattern.FindString(textContent(node)); match != "" {
+				published = match
+				return false
+			}
+		}
+
+		return true
+	})
+
+	return published
+}
+
+// 获取属性值，不存在时返回空字符串
+func attrValue(node *html.Node, name string) string {
+	value, _ := nodeAttr(node, name)
+	return strings.TrimSpace(value)
+}
@@ -0,0 +1,153 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+)
+
+// 中文博客文章：正文前后有导航、侧栏、评论和广告
+var chineseArticlePage = `<!DOCTYPE html>
+<html><head>
+<meta charset="utf-8">
+<title>iOS开发的一些经验 | 唐巧的技术博客</title>
+<script>var tracker = "不应该出现在正文中";</script>
+</head><body>
+<header><h1><a href="/">唐巧的技术博客</a></h1></header>
+<nav class="menu"><a href="/">首页</a> <a href="/archives/">归档</a> <a href="/about/">关于</a></nav>
+<div id="main">
+	<article class="post">
+		<h1 class="entry-title">iOS开发的一些经验</h1>
+		<div class="post-meta">发表于 2014年1月2日 作者 <span class="author">唐巧</span></div>
+		<div class="entry-content">
+			<p>做iOS开发已经有好几年了，这篇文章总结一下我在工作中积累的一些经验，希望对大家有所帮助。</p>
+			<p>第一，尽量使用系统提供的控件。系统控件经过了充分的测试，行为也符合用户的习惯，自己实现往往得不偿失。</p>
+			<p><img src="/images/2014/xcode.png" alt="Xcode"></p>
+			<p>第二，重视代码审查。团队里的每一次提交都应该有人审查，这样既能发现问题，也能让大家互相学习。</p>
+			<p>第三，多写单元测试，特别是数据层和网络层的代码。详情见<a href="/2013/12/unit-test/">这篇文章</a>。</p>
+			<div class="share"><a href="#">微博</a> <a href="#">微信</a></div>
+		</div>
+	</article>
+	<div id="comments" class="comments">
+		<p>评论：写得很好，学习了！这是一条很长很长很长的评论，用来确认评论区不会被当作正文。</p>
+	</div>
+</div>
+<aside class="sidebar"><h3>最新文章</h3><ul><li><a href="/p/1">第一篇</a></li><li><a href="/p/2">第二篇</a></li></ul></aside>
+<div class="advert">广告位招租，联系方式：某某某，这段广告文字也比较长，但不是正文。</div>
+<footer>Copyright © 2014 唐巧</footer>
+</body></html>`
+
+var englishArticlePage = `<html><head>
+<title>Understanding Go Interfaces - Tech Blog</title>
+<meta name="author" content="Jane Doe">
+<meta property="article:published_time" content="2014-03-04T05:06:07Z">
+</head><body>
+<div class="content">
+	<p>Interfaces in Go are satisfied implicitly, which means a type never declares which interfaces it implements.</p>
+	<p>This makes it easy to define small interfaces close to where they are used, and to adapt existing types.</p>
+	<p>In this post we look at a few patterns, including embedding, type assertions and the empty interface.</p>
+</div>
+</body></html>`
+
+func TestReadabilityParser(t *testing.T) {
+	cases := []struct {
+		name       string
+		minLength  uint32
+		page       string
+		expected   map[string]string // 需要检查的字段，nil表示没有条目
+		contains   []string          // 正文纯文本中应该包含的内容
+		excludes   []string          // 正文纯文本中不应该包含的内容
+		contentHas []string          // 正文HTML中应该包含的内容
+	}{
+		{
+			name: "中文文章",
+			page: chineseArticlePage,
+			expected: map[string]string{
+				READABILITY_FIELD_URL:       "http://blog.devtang.com/2014/01/02/ios-tips/",
+				READABILITY_FIELD_TITLE:     "iOS开发的一些经验",
+				READABILITY_FIELD_BYLINE:    "唐巧",
+				READABILITY_FIELD_PUBLISHED: "2014年1月2日",
+			},
+			contains: []string{"做iOS开发已经有好几年了", "第二，重视代码审查。", "第三，多写单元测试"},
+			excludes: []string{"首页", "最新文章", "评论：", "广告位招租", "Copyright", "tracker", "微博"},
+			contentHas: []string{
+				`<img src="http://blog.devtang.com/images/2014/xcode.png" alt="Xcode"/>`,
+				`<a href="http://blog.devtang.com/2013/12/unit-test/">这篇文章</a>`,
+			},
+		},
+		{
+			name: "英文文章",
+			page: englishArticlePage,
+			expected: map[string]string{
+				READABILITY_FIELD_TITLE:     "Understanding Go Interfaces",
+				READABILITY_FIELD_BYLINE:    "Jane Doe",
+				READABILITY_FIELD_PUBLISHED: "2014-03-04T05:06:07Z",
+			},
+			contains: []string{"Interfaces in Go are satisfied implicitly", "the empty interface."},
+		},
+		{
+			name:      "正文过短",
+			minLength: 1000,
+			page:      chineseArticlePage,
+		},
+		{
+			name: "没有正文",
+			page: `<html><body><nav><a href="/">首页</a></nav><p>短句。</p></body></html>`,
+		},
+	}
+
+	for _, c := range cases {
+		parse, err := NewReadabilityParser(&ReadabilityArguments{MinLength: c.minLength})
+		if err != nil {
+			t.Fatalf("%s: 无法创建解析器: %s", c.name, err)
+		}
+
+		response := newTestResponse(t, "http://blog.devtang.com/2014/01/02/ios-tips/", "text/html; charset=utf-8", c.page)
+		dataList, errorList := parse(response, 0)
+		if len(errorList) > 0 {
+			t.Errorf("%s: 意外的错误: %v", c.name, errorList)
+		}
+
+		_, items := splitData(dataList)
+		if c.expected == nil {
+			if len(items) != 0 {
+				t.Errorf("%s: 不应该产生条目，实际为%v", c.name, items)
+			}
+			continue
+		}
+
+		if len(items) != 1 {
+			t.Errorf("%s: 应该产生1个条目，实际为%d个", c.name, len(items))
+			continue
+		}
+		item := items[0]
+
+		for field, expected := range c.expected {
+			if value := item[field]; value != expected {
+				t.Errorf("%s: 字段%s期望%q，实际为%q", c.name, field, expected, value)
+			}
+		}
+
+		text, _ := item[READABILITY_FIELD_TEXT].(string)
+		for _, expected := range c.contains {
+			if !strings.Contains(text, expected) {
+				t.Errorf("%s: 正文中应该包含%q，实际为%q", c.name, expected, text)
+			}
+		}
+		for _, unexpected := range c.excludes {
+			if strings.Contains(text, unexpected) {
+				t.Errorf("%s: 正文中不应该包含%q，实际为%q", c.name, unexpected, text)
+			}
+		}
+
+		content, _ := item[READABILITY_FIELD_CONTENT].(string)
+		for _, expected := range c.contentHas {
+			if !strings.Contains(content, expected) {
+				t.Errorf("%s: 正文HTML中应该包含%q，实际为%q", c.name, expected, content)
+			}
+		}
+
+		if length, _ := item[READABILITY_FIELD_LENGTH].(int); length != len([]rune(text)) {
+			t.Errorf("%s: 长度应该按字符计算，期望%d，实际为%d", c.name, len([]rune(text)), length)
+		}
+	}
+}
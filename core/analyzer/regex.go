@@ -0,0 +1,185 @@
+package analyzer
+
+import (
+	css "core/analyzer/css"
+	base "core/base"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// 正则规则的描述模板
+var regexRuleTemplate string = "{ pattern: %s, source: %s, multiple: %v }"
+
+// 正则规则，以带命名分组的正则表达式从文本中提取条目。
+// 每个命名分组对应条目的一个字段，未命名的分组会被忽略。
+// 若指定了CSS选择器或XPath表达式，则匹配选中节点的文本，否则匹配整个响应体
+type RegexRule struct {
+	Pattern     string `xml:"pattern,attr"`  // 正则表达式（RE2语法），至少包含一个命名分组，如(?P<price>\d+)
+	Selector    string `xml:"selector,attr"` // 选出待匹配节点的CSS选择器
+	XPath       string `xml:"xpath,attr"`    // 选出待匹配节点的XPath表达式，与CSS选择器至多指定一个
+	Multiple    bool   `xml:"multiple,attr"` // 是否每个匹配都产生一个条目。否则每段文本只取第一个匹配
+	Trim        bool   `xml:"trim,attr"`     // 是否去掉字段值两端的空白
+	description string // 描述
+}
+
+func (rule *RegexRule) Check() error {
+	_, err := compileRegexRule(rule)
+	return err
+}
+
+func (rule *RegexRule) String() string {
+	if rule.description == "" {
+		source := "body"
+		if rule.Selector != "" {
+			source = rule.Selector
+		} else if rule.XPath != "" {
+			source = rule.XPath
+		}
+		rule.description = fmt.Sprintf(regexRuleTemplate, rule.Pattern, source, rule.Multiple)
+	}
+
+	return rule.description
+}
+
+// 编译后的正则规则
+type compiledRegexRule struct {
+	rule     RegexRule      // 正则规则
+	pattern  *regexp.Regexp // 正则表达式
+	selector nodeSelector   // 节点选择器，为nil时表示匹配整个响应体
+}
+
+// 编译正则规则
+func compileRegexRule(rule *RegexRule) (*compiledRegexRule, error) {
+	if rule == nil {
+		return nil, errors.New("正则规则无效！")
+	}
+
+	pattern, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("无效的正则表达式【%s】: %s", rule.Pattern, err))
+	}
+
+	named := false
+	for _, name := range pattern.SubexpNames() {
+		if name != "" {
+			named = true
+			break
+		}
+	}
+
+	if !named {
+		return nil, errors.New(fmt.Sprintf("正则表达式【%s】中至少要有一个命名分组！", rule.Pattern))
+	}
+
+	selector, err := compileNodeSelector(rule.Selector, rule.XPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &compiledRegexRule{rule: *rule, pattern: pattern, selector: selector}, nil
+}
+
+// 创建正则提取器。
+// 正则提取器把正则表达式的命名分组映射为条目的字段。
+// 匹配整个响应体时不限制响应的内容类型，因此也适用于脚本、JSON等文本
+func NewRegexExtractor(rule *RegexRule) (MKParseResponse, error) {
+	compiled, err := compileRegexRule(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	parse := func(httpResponse *http.Response, depth uint32) ([]base.MKData, []error) {
+		texts, err := compiled.texts(httpResponse)
+		if err != nil {
+			return nil, []error{err}
+		}
+
+		dataList := make([]base.MKData, 0)
+		for _, text := range texts {
+			for _, item := range compiled.extract(text) {
+				dataList = append(dataList, item)
+			}
+		}
+
+		return dataList, nil
+	}
+
+	return parse, nil
+}
+
+// 获取待匹配的文本
+func (rule *compiledRegexRule) texts(httpResponse *http.Response) ([]string, error) {
+	if rule.selector == nil {
+		body, err := utf8Body(httpResponse)
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := io.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+
+		return []string{string(content)}, nil
+	}
+
+	if !isHTMLResponse(httpResponse) {
+		return nil, nil
+	}
+
+	root, err := parseHTML(httpResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	selections, err := rule.selector.selectNodes(root)
+	if err != nil {
+		return nil, err
+	}
+
+	texts := make([]string, 0, len(selections))
+	for _, selection := range selections {
+		if selection.element != nil {
+			texts = append(texts, css.Text(selection.element))
+		} else {
+			texts = append(texts, selection.value)
+		}
+	}
+
+	return texts, nil
+}
+
+// 从文本中提取条目
+func (rule *compiledRegexRule) extract(text string) []base.MKItem {
+	limit := 1
+	if rule.rule.Multiple {
+		limit = -1
+	}
+
+	names := rule.pattern.SubexpNames()
+	matches := rule.pattern.FindAllStringSubmatch(text, limit)
+
+	items := make([]base.MKItem, 0, len(matches))
+	for _, match := range matches {
+		item := base.MKItem{}
+		for i, name := range names {
+			if name == "" {
+				continue
+			}
+
+			value := match[i]
+			if rule.rule.Trim {
+				value = strings.TrimSpace(value)
+			}
+			item[name] = value
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
@@ -0,0 +1,107 @@
+package analyzer
+
+import (
+	base "core/base"
+	"reflect"
+	"testing"
+)
+
+var pricePage = `<html><body>
+<ul class="prices">
+	<li>机械键盘 售价 399 元</li>
+	<li>无线鼠标 售价 129 元</li>
+	<li>暂无报价</li>
+</ul>
+<script>var sku = "SKU-1001"; var sku = "SKU-1002";</script>
+</body></html>`
+
+func TestRegexExtractor(t *testing.T) {
+	cases := []struct {
+		name     string
+		rule     RegexRule
+		expected []base.MKItem
+	}{
+		{"命名分组对应字段", RegexRule{Pattern: `(\S+) 售价 (?P<price>\d+) 元`, Selector: "li"}, []base.MKItem{
+			{"price": "399"},
+			{"price": "129"},
+		}},
+		{"多个命名分组", RegexRule{Pattern: `(?P<name>\S+) 售价 (?P<price>\d+)`, XPath: "//li"}, []base.MKItem{
+			{"name": "机械键盘", "price": "399"},
+			{"name": "无线鼠标", "price": "129"},
+		}},
+		{"整个响应体只取第一个匹配", RegexRule{Pattern: `sku = "(?P<sku>[^"]+)"`}, []base.MKItem{
+			{"sku": "SKU-1001"},
+		}},
+		{"整个响应体的多个匹配", RegexRule{Pattern: `sku = "(?P<sku>[^"]+)"`, Multiple: true}, []base.MKItem{
+			{"sku": "SKU-1001"},
+			{"sku": "SKU-1002"},
+		}},
+		{"选中节点的多个匹配", RegexRule{Pattern: `(?P<price>\d+) 元`, Selector: "ul.prices", Multiple: true}, []base.MKItem{
+			{"price": "399"},
+			{"price": "129"},
+		}},
+		{"去掉空白", RegexRule{Pattern: `(?P<name>[^售]+)售价`, Selector: "li", Trim: true}, []base.MKItem{
+			{"name": "机械键盘"},
+			{"name": "无线鼠标"},
+		}},
+		{"没有匹配", RegexRule{Pattern: `(?P<stock>库存 \d+)`, Selector: "li"}, []base.MKItem{}},
+	}
+
+	for _, c := range cases {
+		parse, err := NewRegexExtractor(&c.rule)
+		if err != nil {
+			t.Fatalf("%s: 无法创建正则提取器: %s", c.name, err)
+		}
+
+		dataList, errorList := parse(newTestResponse(t, "http://shop.devtang.com/list", "text/html", pricePage), 0)
+		if len(errorList) > 0 {
+			t.Errorf("%s: 意外的错误: %v", c.name, errorList)
+		}
+
+		_, items := splitData(dataList)
+		if !reflect.DeepEqual(items, c.expected) {
+			t.Errorf("%s: 期望%v，实际为%v", c.name, c.expected, items)
+		}
+	}
+}
+
+func TestRegexExtractorNonHTML(t *testing.T) {
+	// 匹配整个响应体时不限制内容类型，指定了选择器时只处理HTML
+	body := `{"sku": "SKU-2001"}`
+
+	parse, _ := NewRegexExtractor(&RegexRule{Pattern: `"sku": "(?P<sku>[^"]+)"`})
+	dataList, _ := parse(newTestResponse(t, "http://shop.devtang.com/api", "application/json", body), 0)
+	if _, items := splitData(dataList); len(items) != 1 || items[0]["sku"] != "SKU-2001" {
+		t.Errorf("应该从JSON响应体中提取条目，实际为%v", items)
+	}
+
+	parse, _ = NewRegexExtractor(&RegexRule{Pattern: `"sku": "(?P<sku>[^"]+)"`, Selector: "body"})
+	dataList, _ = parse(newTestResponse(t, "http://shop.devtang.com/api", "application/json", body), 0)
+	if _, items := splitData(dataList); len(items) != 0 {
+		t.Errorf("指定了选择器时不应该处理非HTML响应，实际为%v", items)
+	}
+}
+
+func TestRegexRuleCheck(t *testing.T) {
+	invalid := []*RegexRule{
+		{Pattern: `(?P<price>\d+`},
+		{Pattern: `(\d+) 元`},
+		{Pattern: `(?P<1-price>\d+)`},
+		{Pattern: `(?P<>\d+)`},
+		{Pattern: `(?P<price>\d+)`, Selector: "li["},
+		{Pattern: `(?P<price>\d+)`, Selector: "li", XPath: "//li"},
+	}
+
+	for i, rule := range invalid {
+		if _, err := compileRegexRule(rule); err == nil {
+			t.Errorf("[%d] %s: 应该无效", i, rule.String())
+		}
+		if _, err := NewRegexExtractor(rule); err == nil {
+			t.Errorf("[%d] %s: 不应该创建正则提取器", i, rule.String())
+		}
+	}
+
+	if _, err := compileRegexRule(nil); err == nil {
+		t.Error("空的正则规则应该无效")
+	}
+}
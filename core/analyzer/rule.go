@@ -0,0 +1,326 @@
+package analyzer
+
+import (
+	"bytes"
+	"code.google.com/p/go.net/html"
+	css "core/analyzer/css"
+	base "core/base"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 字段规则中表示取文本内容和取内部HTML的特殊属性名
+const (
+	FIELD_ATTR_TEXT = "text" // 文本内容（默认）
+	FIELD_ATTR_HTML = "html" // 内部HTML
+)
+
+// 条目规则的描述模板
+var itemRuleTemplate string = "{ scope: %s, fields: %d }"
+
+// 字段规则，描述如何从文档中提取条目的一个字段
+type FieldRule struct {
+	Name     string `xml:"name,attr"`     // 字段名称
+	Selector string `xml:"selector,attr"` // CSS选择器
+	XPath    string `xml:"xpath,attr"`    // XPath表达式，与CSS选择器至多指定一个。都为空时表示条目范围元素本身
+	Attr     string `xml:"attr,attr"`     // 取值的属性名，text表示文本内容，html表示内部HTML
+	Multiple bool   `xml:"multiple,attr"` // 是否提取所有匹配的值（结果为字符串切片）
+	Trim     bool   `xml:"trim,attr"`     // 是否去掉值两端的空白
+	Absolute bool   `xml:"absolute,attr"` // 是否把值解析为绝对URL（适用于href、src等属性）
+	Default  string `xml:"default,attr"`  // 没有匹配的值时使用的默认值
+	Pattern  string `xml:"pattern,attr"`  // 进一步提取值的正则表达式（RE2语法），不匹配的值会被忽略
+	Group    string `xml:"group,attr"`    // 取值的分组（名称或序号），为空时取第一个分组，没有分组时取整个匹配
+}
+
+// 条目规则。
+// 若指定了范围选择器（或范围XPath表达式），则每个匹配的元素都会产生一个条目，
+// 否则每个文档产生一个条目
+type ItemRule struct {
+	Scope       string      `xml:"scope,attr"`      // 条目范围的CSS选择器
+	ScopeXPath  string      `xml:"scopeXpath,attr"` // 条目范围的XPath表达式，与CSS选择器至多指定一个
+	Fields      []FieldRule `xml:"field"`           // 字段规则
+	description string      // 描述
+}
+
+func (rule *ItemRule) Check() error {
+	_, err := compileItemRule(rule)
+	return err
+}
+
+func (rule *ItemRule) String() string {
+	if rule.description == "" {
+		scope := rule.Scope
+		if scope == "" {
+			scope = rule.ScopeXPath
+		}
+		rule.description = fmt.Sprintf(itemRuleTemplate, scope, len(rule.Fields))
+	}
+
+	return rule.description
+}
+
+// 编译后的字段规则
+type compiledField struct {
+	rule     FieldRule      // 字段规则
+	selector nodeSelector   // 节点选择器，为nil时表示范围元素本身
+	pattern  *regexp.Regexp // 正则表达式，为nil时直接取节点的值
+	group    int            // 取值的分组序号
+}
+
+// 编译后的条目规则
+type compiledItemRule struct {
+	scope  nodeSelector     // 条目范围选择器，为nil时表示整个文档
+	fields []*compiledField // 字段规则
+}
+
+// 编译条目规则
+func compileItemRule(rule *ItemRule) (*compiledItemRule, error) {
+	if rule == nil {
+		return nil, errors.New("条目规则无效！")
+	}
+
+	if len(rule.Fields) == 0 {
+		return nil, errors.New("条目规则中至少要有一个字段规则！")
+	}
+
+	compiled := &compiledItemRule{}
+
+	scope, err := compileNodeSelector(rule.Scope, rule.ScopeXPath)
+	if err != nil {
+		return nil, err
+	}
+	compiled.scope = scope
+
+	names := make(map[string]bool)
+	for i, fieldRule := range rule.Fields {
+		if fieldRule.Name == "" {
+			return nil, errors.New(fmt.Sprintf("字段规则[%d]的名称不能为空！", i))
+		}
+
+		if names[fieldRule.Name] {
+			return nil, errors.New(fmt.Sprintf("字段名称重复【%s】！", fieldRule.Name))
+		}
+		names[fieldRule.Name] = true
+
+		selector, err := compileNodeSelector(fieldRule.Selector, fieldRule.XPath)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("字段【%s】: %s", fieldRule.Name, err))
+		}
+
+		field := &compiledField{rule: fieldRule, selector: selector}
+		if err := field.compilePattern(); err != nil {
+			return nil, errors.New(fmt.Sprintf("字段【%s】: %s", fieldRule.Name, err))
+		}
+		compiled.fields = append(compiled.fields, field)
+	}
+
+	return compiled, nil
+}
+
+// 编译字段规则中的正则表达式，并确定取值的分组
+func (field *compiledField) compilePattern() error {
+	if field.rule.Pattern == "" {
+		if field.rule.Group != "" {
+			return errors.New("指定分组时必须同时指定正则表达式！")
+		}
+		return nil
+	}
+
+	pattern, err := regexp.Compile(field.rule.Pattern)
+	if err != nil {
+		return errors.New(fmt.Sprintf("无效的正则表达式【%s】: %s", field.rule.Pattern, err))
+	}
+	field.pattern = pattern
+
+	switch {
+	case field.rule.Group == "":
+		if pattern.NumSubexp() > 0 {
+			field.group = 1
+		}
+	case pattern.SubexpIndex(field.rule.Group) >= 0:
+		field.group = pattern.SubexpIndex(field.rule.Group)
+	default:
+		group, err := strconv.Atoi(field.rule.Group)
+		if err != nil || group < 0 || group > pattern.NumSubexp() {
+			return errors.New(fmt.Sprintf("正则表达式【%s】中没有分组【%s】！", field.rule.Pattern, field.rule.Group))
+		}
+		field.group = group
+	}
+
+	return nil
+}
+
+// 创建条目提取器。
+// 条目提取器按照条目规则从HTML文档中提取字段，并生成条目
+func NewItemExtractor(rule *ItemRule) (MKParseResponse, error) {
+	compiled, err := compileItemRule(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	parse := func(httpResponse *http.Response, depth uint32) ([]base.MKData, []error) {
+		if !isHTMLResponse(httpResponse) {
+			return nil, nil
+		}
+
+		root, err := parseHTML(httpResponse)
+		if err != nil {
+			return nil, []error{err}
+		}
+
+		baseURL := documentBaseURL(root, responseURL(httpResponse))
+
+		items, errorList := compiled.extract(root, baseURL)
+		dataList := make([]base.MKData, 0, len(items))
+		for _, item := range items {
+			dataList = append(dataList, item)
+		}
+
+		return dataList, errorList
+	}
+
+	return parse, nil
+}
+
+// 从文档中提取条目
+func (rule *compiledItemRule) extract(root *html.Node, baseURL *url.URL) ([]base.MKItem, []error) {
+	scopes := []*html.Node{root}
+	if rule.scope != nil {
+		selections, err := rule.scope.selectNodes(root)
+		if err != nil {
+			return nil, []error{err}
+		}
+
+		// 只有元素可以作为条目范围
+		scopes = make([]*html.Node, 0, len(selections))
+		for _, selection := range selections {
+			if selection.element != nil {
+				scopes = append(scopes, selection.element)
+			}
+		}
+	}
+
+	items := make([]base.MKItem, 0, len(scopes))
+	errorList := make([]error, 0)
+	for _, scope := range scopes {
+		item := base.MKItem{}
+		matched := false
+
+		for _, field := range rule.fields {
+			value, ok, err := field.extract(scope, baseURL)
+			if err != nil {
+				errorList = append(errorList, err)
+				continue
+			}
+			matched = matched || ok
+			item[field.rule.Name] = value
+		}
+
+		// 没有任何字段匹配的范围不产生条目
+		if matched {
+			items = append(items, item)
+		}
+	}
+
+	return items, errorList
+}
+
+// 提取字段的值。第二个结果值表示是否有匹配的节点
+func (field *compiledField) extract(scope *html.Node, baseURL *url.URL) (interface{}, bool, error) {
+	selections := []selection{{element: scope}}
+	if field.selector != nil {
+		var err error
+		if selections, err = field.selector.selectNodes(scope); err != nil {
+			return nil, false, errors.New(fmt.Sprintf("字段【%s】: %s", field.rule.Name, err))
+		}
+	}
+
+	values := make([]string, 0, len(selections))
+	for _, selection := range selections {
+		value, ok := field.value(selection, baseURL)
+		if !ok {
+			continue
+		}
+
+		values = append(values, value)
+		if !field.rule.Multiple {
+			break
+		}
+	}
+
+	matched := len(values) > 0
+	if !matched && field.rule.Default != "" {
+		values = append(values, field.rule.Default)
+	}
+
+	if field.rule.Multiple {
+		return values, matched, nil
+	}
+
+	if len(values) == 0 {
+		return "", false, nil
+	}
+
+	return values[0], matched, nil
+}
+
+// 获取选中节点的取值。非元素节点直接取其字符串值，不考虑取值的属性名
+func (field *compiledField) value(selected selection, baseURL *url.URL) (string, bool) {
+	node := selected.element
+	value := selected.value
+
+	if node != nil {
+		switch strings.ToLower(field.rule.Attr) {
+		case "", FIELD_ATTR_TEXT:
+			value = css.Text(node)
+		case FIELD_ATTR_HTML:
+			value = innerHTML(node)
+		default:
+			var ok bool
+			if value, ok = nodeAttr(node, field.rule.Attr); !ok {
+				return "", false
+			}
+		}
+	}
+
+	if field.pattern != nil {
+		match := field.pattern.FindStringSubmatchIndex(value)
+		if match == nil || match[2*field.group] < 0 {
+			return "", false
+		}
+		value = value[match[2*field.group]:match[2*field.group+1]]
+	}
+
+	if field.rule.Trim {
+		value = strings.TrimSpace(value)
+	}
+
+	if field.rule.Absolute {
+		linkURL, err := url.Parse(strings.TrimSpace(value))
+		if err != nil {
+			return "", false
+		}
+
+		if baseURL != nil {
+			linkURL = baseURL.ResolveReference(linkURL)
+		}
+		value = linkURL.String()
+	}
+
+	return value, true
+}
+
+// 获取元素的内部HTML
+func innerHTML(node *html.Node) string {
+	var buffer bytes.Buffer
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		html.Render(&buffer, child)
+	}
+
+	return buffer.String()
+}
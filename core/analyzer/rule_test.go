@@ -0,0 +1,92 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+)
+
+var productPage = `<html><body>
+<ul id="products">
+	<li class="product" data-sku="SKU-1001">
+		<a class="name" href="/p/1001">机械键盘</a>
+		<span class="price">售价：¥ 399.00 元</span>
+		<span class="stock">库存 12 件</span>
+		<img src="/img/1001.png?w=200">
+	</li>
+	<li class="product" data-sku="1002">
+		<a class="name" href="/p/1002">无线鼠标</a>
+		<span class="price">暂无报价</span>
+		<span class="stock">缺货</span>
+		<img src="/img/1002.png?w=200">
+	</li>
+</ul>
+</body></html>`
+
+func TestItemExtractorPatterns(t *testing.T) {
+	cases := []struct {
+		name     string
+		field    FieldRule
+		expected []interface{} // 每个条目中该字段的值
+	}{
+		{"第一个分组", FieldRule{Selector: ".price", Pattern: `¥\s*([\d.]+)`}, []interface{}{"399.00", ""}},
+		{"命名分组", FieldRule{Selector: ".price", Pattern: `¥\s*(?P<yuan>\d+)\.(?P<fen>\d+)`, Group: "fen"}, []interface{}{"00", ""}},
+		{"分组序号", FieldRule{Selector: ".price", Pattern: `(¥)\s*(\d+)`, Group: "2"}, []interface{}{"399", ""}},
+		{"整个匹配", FieldRule{Selector: ".price", Pattern: `¥\s*\d+`, Group: "0"}, []interface{}{"¥ 399", ""}},
+		{"没有分组时取整个匹配", FieldRule{Selector: ".stock", Pattern: `\d+`}, []interface{}{"12", ""}},
+		{"不匹配时使用默认值", FieldRule{Selector: ".stock", Pattern: `\d+`, Default: "0"}, []interface{}{"12", "0"}},
+		{"属性值", FieldRule{XPath: "@data-sku", Pattern: `\d+$`}, []interface{}{"1001", "1002"}},
+		{"先匹配再解析URL", FieldRule{Selector: "img", Attr: "src", Pattern: `^[^?]+`, Absolute: true}, []interface{}{
+			"http://shop.devtang.com/img/1001.png",
+			"http://shop.devtang.com/img/1002.png",
+		}},
+		{"匹配后去掉空白", FieldRule{Selector: ".price", Pattern: `：([^元]+)`, Trim: true}, []interface{}{"¥ 399.00", ""}},
+		{"多个值", FieldRule{Selector: "span", Pattern: `\d+`, Multiple: true}, []interface{}{
+			[]string{"399", "12"},
+			[]string{},
+		}},
+	}
+
+	for _, c := range cases {
+		c.field.Name = "value"
+		rule := &ItemRule{
+			Scope:  "li.product",
+			Fields: []FieldRule{{Name: "title", Selector: ".name"}, c.field},
+		}
+
+		parse, err := NewItemExtractor(rule)
+		if err != nil {
+			t.Fatalf("%s: 无法创建条目提取器: %s", c.name, err)
+		}
+
+		dataList, errorList := parse(newTestResponse(t, "http://shop.devtang.com/list", "text/html", productPage), 0)
+		if len(errorList) > 0 {
+			t.Errorf("%s: 意外的错误: %v", c.name, errorList)
+		}
+
+		_, items := splitData(dataList)
+		values := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			values = append(values, item["value"])
+		}
+		if !reflect.DeepEqual(values, c.expected) {
+			t.Errorf("%s: 期望%v，实际为%v", c.name, c.expected, values)
+		}
+	}
+}
+
+func TestItemRuleCheckPatterns(t *testing.T) {
+	invalid := []FieldRule{
+		{Name: "price", Selector: ".price", Pattern: `(`},
+		{Name: "price", Selector: ".price", Group: "1"},
+		{Name: "price", Selector: ".price", Pattern: `(\d+)`, Group: "2"},
+		{Name: "price", Selector: ".price", Pattern: `(\d+)`, Group: "-1"},
+		{Name: "price", Selector: ".price", Pattern: `(?P<yuan>\d+)`, Group: "fen"},
+	}
+
+	for i, field := range invalid {
+		rule := &ItemRule{Fields: []FieldRule{field}}
+		if err := rule.Check(); err == nil {
+			t.Errorf("[%d] %v: 应该无效", i, field)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package analyzer
+
+import (
+	"code.google.com/p/go.net/html"
+	css "core/analyzer/css"
+	xpath "core/analyzer/xpath"
+	"errors"
+	"fmt"
+)
+
+// 选中的节点。
+// CSS选择器只会选中元素，XPath表达式还可能选中属性节点、文本节点，
+// 或者得到字符串、数字等标量值，这些情况下直接使用其字符串值
+type selection struct {
+	element *html.Node // 选中的元素，非元素时为nil
+	owner   *html.Node // 选中属性节点时为其所属元素，否则为nil
+	value   string     // 非元素时的字符串值
+}
+
+// 节点选择器，从给定节点之下选出若干节点
+type nodeSelector interface {
+	selectNodes(context *html.Node) ([]selection, error)
+}
+
+// 编译节点选择器。参数selector和expression分别为CSS选择器和XPath表达式，至多只能指定一个。
+// 两者都为空时返回nil
+func compileNodeSelector(selector string, expression string) (nodeSelector, error) {
+	switch {
+	case selector != "" && expression != "":
+		return nil, errors.New(fmt.Sprintf("CSS选择器【%s】和XPath表达式【%s】不能同时指定！", selector, expression))
+	case selector != "":
+		compiled, err := css.Compile(selector)
+		if err != nil {
+			return nil, err
+		}
+		return &cssSelector{compiled}, nil
+	case expression != "":
+		compiled, err := xpath.Compile(expression)
+		if err != nil {
+			return nil, err
+		}
+		return &xpathSelector{compiled}, nil
+	}
+
+	return nil, nil
+}
+
+// 基于CSS选择器的节点选择器
+type cssSelector struct {
+	selector css.MKSelector
+}
+
+func (s *cssSelector) selectNodes(context *html.Node) ([]selection, error) {
+	nodes := s.selector.MatchAll(context)
+
+	selections := make([]selection, 0, len(nodes))
+	for _, node := range nodes {
+		selections = append(selections, selection{element: node})
+	}
+
+	return selections, nil
+}
+
+// 基于XPath表达式的节点选择器
+type xpathSelector struct {
+	expression xpath.MKExpression
+}
+
+func (s *xpathSelector) selectNodes(context *html.Node) ([]selection, error) {
+	value, err := s.expression.Evaluate(context)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, ok := value.([]xpath.Node)
+	if !ok {
+		return []selection{{value: xpath.String(value)}}, nil
+	}
+
+	selections := make([]selection, 0, len(nodes))
+	for _, node := range nodes {
+		if node.IsElement() {
+			selections = append(selections, selection{element: node.HTML})
+		} else if node.IsAttr() {
+			selections = append(selections, selection{owner: node.HTML, value: node.Value()})
+		} else {
+			selections = append(selections, selection{value: node.Value()})
+		}
+	}
+
+	return selections, nil
+}
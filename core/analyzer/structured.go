@@ -0,0 +1,671 @@
+package analyzer
+
+import (
+	"code.google.com/p/go.net/html"
+	css "core/analyzer/css"
+	base "core/base"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 结构化数据的来源
+const (
+	STRUCTURED_SOURCE_JSONLD    = "jsonld"    // schema.org JSON-LD
+	STRUCTURED_SOURCE_MICRODATA = "microdata" // HTML微数据
+	STRUCTURED_SOURCE_OPENGRAPH = "opengraph" // OpenGraph元数据
+	STRUCTURED_SOURCE_TWITTER   = "twitter"   // Twitter卡片元数据
+)
+
+// 结构化数据条目的字段名称。
+// 前几个字段是从各来源归一化得到的，优先级依次为JSON-LD、微数据、OpenGraph、Twitter卡片和普通HTML标签；
+// 与来源同名的字段保存该来源的原始数据
+const (
+	STRUCTURED_FIELD_URL         = "url"         // 页面的规范URL
+	STRUCTURED_FIELD_TYPE        = "type"        // 类型，如Article、BlogPosting
+	STRUCTURED_FIELD_TITLE       = "title"       // 标题
+	STRUCTURED_FIELD_DESCRIPTION = "description" // 描述
+	STRUCTURED_FIELD_AUTHOR      = "author"      // 作者，多个作者以逗号分隔
+	STRUCTURED_FIELD_PUBLISHED   = "published"   // 发布时间
+	STRUCTURED_FIELD_MODIFIED    = "modified"    // 修改时间
+	STRUCTURED_FIELD_IMAGE       = "image"       // 图片的绝对URL
+	STRUCTURED_FIELD_SITE_NAME   = "siteName"    // 网站名称
+)
+
+// 所有的结构化数据来源
+var structuredSources = []string{
+	STRUCTURED_SOURCE_JSONLD,
+	STRUCTURED_SOURCE_MICRODATA,
+	STRUCTURED_SOURCE_OPENGRAPH,
+	STRUCTURED_SOURCE_TWITTER,
+}
+
+// 优先作为主体的schema.org类型
+var primarySchemaTypes = map[string]bool{
+	"Article":             true,
+	"NewsArticle":         true,
+	"BlogPosting":         true,
+	"TechArticle":         true,
+	"Report":              true,
+	"Product":             true,
+	"Recipe":              true,
+	"Event":               true,
+	"VideoObject":         true,
+	"Review":              true,
+	"QAPage":              true,
+	"ScholarlyArticle":    true,
+	"SocialMediaPosting":  true,
+	"DiscussionForumPost": true,
+}
+
+// 结构化数据解析参数的描述模板
+var structuredDataArgumentsTemplate string = "{ sources: %v }"
+
+// 结构化数据解析参数的容器
+type StructuredDataArguments struct {
+	Sources     []string `xml:"source"` // 使用的来源，默认为全部来源
+	description string   // 描述
+}
+
+func (arguments *StructuredDataArguments) Check() error {
+	for _, source := range arguments.Sources {
+		if !containsString(structuredSources, strings.ToLower(source)) {
+			return errors.New(fmt.Sprintf("不支持的结构化数据来源【%s】！\n", source))
+		}
+	}
+
+	return nil
+}
+
+func (arguments *StructuredDataArguments) String() string {
+	if arguments.description == "" {
+		arguments.description = fmt.Sprintf(structuredDataArgumentsTemplate, arguments.sources())
+	}
+
+	return arguments.description
+}
+
+// 获取使用的来源
+func (arguments *StructuredDataArguments) sources() []string {
+	if len(arguments.Sources) == 0 {
+		return structuredSources
+	}
+
+	return arguments.Sources
+}
+
+// 创建结构化数据解析器。
+// 结构化数据解析器从每个HTML页面中提取JSON-LD、微数据、OpenGraph和Twitter卡片，
+// 并产生一个包含归一化字段（见STRUCTURED_FIELD_*常量）和各来源原始数据的条目。
+// 参数arguments为nil时使用默认参数
+func NewStructuredDataParser(arguments *StructuredDataArguments) (MKParseResponse, error) {
+	if arguments == nil {
+		arguments = &StructuredDataArguments{}
+	}
+
+	if err := arguments.Check(); err != nil {
+		return nil, err
+	}
+
+	sources := make(map[string]bool)
+	for _, source := range arguments.sources() {
+		sources[strings.ToLower(source)] = true
+	}
+
+	parse := func(httpResponse *http.Response, depth uint32) ([]base.MKData, []error) {
+		if !isHTMLResponse(httpResponse) {
+			return nil, nil
+		}
+
+		root, err := parseHTML(httpResponse)
+		if err != nil {
+			return nil, []error{err}
+		}
+
+		baseURL := documentBaseURL(root, responseURL(httpResponse))
+		item, errorList := extractStructuredData(root, baseURL, sources)
+		if item == nil {
+			return nil, errorList
+		}
+
+		return []base.MKData{item}, errorList
+	}
+
+	return parse, nil
+}
+
+// 从文档中提取结构化数据。没有任何可用数据时返回nil
+func extractStructuredData(root *html.Node, baseURL *url.URL, sources map[string]bool) (base.MKItem, []error) {
+	item := base.MKItem{}
+	errorList := make([]error, 0)
+
+	// 按优先级排列的候选对象，均为schema.org风格的对象
+	candidates := make([]map[string]interface{}, 0)
+
+	if sources[STRUCTURED_SOURCE_JSONLD] {
+		objects, errs := extractJSONLD(root)
+		errorList = append(errorList, errs...)
+		if len(objects) > 0 {
+			raw := make([]interface{}, 0, len(objects))
+			for _, object := range objects {
+				raw = append(raw, object)
+			}
+			item[STRUCTURED_SOURCE_JSONLD] = raw
+			candidates = append(candidates, primaryObject(resolveReferences(objects)))
+		}
+	}
+
+	if sources[STRUCTURED_SOURCE_MICRODATA] {
+		objects := extractMicrodata(root)
+		if len(objects) > 0 {
+			raw := make([]interface{}, 0, len(objects))
+			for _, object := range objects {
+				raw = append(raw, object)
+			}
+			item[STRUCTURED_SOURCE_MICRODATA] = raw
+			candidates = append(candidates, primaryObject(objects))
+		}
+	}
+
+	metas := extractMetaTags(root)
+
+	if sources[STRUCTURED_SOURCE_OPENGRAPH] {
+		if og := metas.withPrefix("og:", "article:", "profile:", "book:"); len(og) > 0 {
+			item[STRUCTURED_SOURCE_OPENGRAPH] = og
+			candidates = append(candidates, map[string]interface{}{
+				"@type":         og["og:type"],
+				"headline":      og["og:title"],
+				"description":   og["og:description"],
+				"author":        og["article:author"],
+				"datePublished": og["article:published_time"],
+				"dateModified":  firstNonEmpty(og["article:modified_time"], og["og:updated_time"]),
+				"image":         firstNonEmpty(og["og:image:secure_url"], og["og:image"], og["og:image:url"]),
+				"url":           og["og:url"],
+				"siteName":      og["og:site_name"],
+			})
+		}
+	}
+
+	if sources[STRUCTURED_SOURCE_TWITTER] {
+		if twitter := metas.withPrefix("twitter:"); len(twitter) > 0 {
+			item[STRUCTURED_SOURCE_TWITTER] = twitter
+			candidates = append(candidates, map[string]interface{}{
+				"headline":    twitter["twitter:title"],
+				"description": twitter["twitter:description"],
+				"author":      twitter["twitter:creator"],
+				"image":       firstNonEmpty(twitter["twitter:image"], twitter["twitter:image:src"]),
+				"siteName":    twitter["twitter:site"],
+			})
+		}
+	}
+
+	// 普通HTML标签作为最后的候选
+	candidates = append(candidates, map[string]interface{}{
+		"headline":      documentTitle(root),
+		"description":   metas.values["description"],
+		"author":        metas.values["author"],
+		"url":           canonicalLink(root),
+		"datePublished": metas.values["date"],
+	})
+
+	fields := []struct {
+		name string                                     // 字段名称
+		get  func(object map[string]interface{}) string // 从候选对象中获取字段的值
+	}{
+		{STRUCTURED_FIELD_URL, func(o map[string]interface{}) string { return schemaString(o["url"]) }},
+		{STRUCTURED_FIELD_TYPE, func(o map[string]interface{}) string { return schemaType(o["@type"]) }},
+		{STRUCTURED_FIELD_TITLE, func(o map[string]interface{}) string {
+			return firstNonEmpty(schemaString(o["headline"]), schemaString(o["name"]))
+		}},
+		{STRUCTURED_FIELD_DESCRIPTION, func(o map[string]interface{}) string { return schemaString(o["description"]) }},
+		{STRUCTURED_FIELD_AUTHOR, func(o map[string]interface{}) string {
+			return firstNonEmpty(schemaNames(o["author"]), schemaNames(o["creator"]))
+		}},
+		{STRUCTURED_FIELD_PUBLISHED, func(o map[string]interface{}) string {
+			return firstNonEmpty(schemaString(o["datePublished"]), schemaString(o["dateCreated"]))
+		}},
+		{STRUCTURED_FIELD_MODIFIED, func(o map[string]interface{}) string { return schemaString(o["dateModified"]) }},
+		{STRUCTURED_FIELD_IMAGE, func(o map[string]interface{}) string {
+			return firstNonEmpty(schemaURL(o["image"]), schemaURL(o["thumbnailUrl"]))
+		}},
+		{STRUCTURED_FIELD_SITE_NAME, func(o map[string]interface{}) string {
+			return firstNonEmpty(schemaString(o["siteName"]), schemaNames(o["publisher"]))
+		}},
+	}
+
+	found := false
+	for _, field := range fields {
+		value := ""
+		for _, candidate := range candidates {
+			if value = strings.TrimSpace(field.get(candidate)); value != "" {
+				break
+			}
+		}
+
+		if value != "" && (field.name == STRUCTURED_FIELD_URL || field.name == STRUCTURED_FIELD_IMAGE) {
+			if linkURL, ok := resolveLink(baseURL, value); ok {
+				value = linkURL.String()
+			}
+		}
+
+		if value != "" {
+			found = true
+		}
+		item[field.name] = value
+	}
+
+	if !found {
+		return nil, errorList
+	}
+
+	if item[STRUCTURED_FIELD_URL] == "" && baseURL != nil {
+		item[STRUCTURED_FIELD_URL] = baseURL.String()
+	}
+
+	return item, errorList
+}
+
+// 提取JSON-LD对象。数组和@graph中的对象会被展开
+func extractJSONLD(root *html.Node) ([]map[string]interface{}, []error) {
+	objects := make([]map[string]interface{}, 0)
+	errorList := make([]error, 0)
+
+	var flatten func(value interface{})
+	flatten = func(value interface{}) {
+		switch v := value.(type) {
+		case []interface{}:
+			for _, element := range v {
+				flatten(element)
+			}
+		case map[string]interface{}:
+			if graph, ok := v["@graph"]; ok {
+				flatten(graph)
+				return
+			}
+			objects = append(objects, v)
+		}
+	}
+
+	walkElements(root, func(node *html.Node) bool {
+		if node.Data != "script" {
+			return true
+		}
+
+		scriptType, _ := nodeAttr(node, "type")
+		if !strings.EqualFold(strings.TrimSpace(scriptType), "application/ld+json") {
+			return false
+		}
+
+		var value interface{}
+		if err := json.Unmarshal([]byte(css.Text(node)), &value); err != nil {
+			errorList = append(errorList, errors.New(fmt.Sprintf("无法解析JSON-LD: %s", err)))
+			return false
+		}
+		flatten(value)
+
+		return false
+	})
+
+	return objects, errorList
+}
+
+// 把只有@id的引用替换为同一文档中被引用的对象。
+// @graph中的文章通常以{"@id": ...}引用作者、发布者和图片，替换后才能取到它们的名称和URL。
+// 只替换一层，以免循环引用
+func resolveReferences(objects []map[string]interface{}) []map[string]interface{} {
+	index := make(map[string]map[string]interface{})
+	for _, object := range objects {
+		if id, ok := object["@id"].(string); ok && id != "" {
+			index[id] = object
+		}
+	}
+
+	resolve := func(value interface{}) interface{} {
+		reference, ok := value.(map[string]interface{})
+		if !ok || len(reference) != 1 {
+			return value
+		}
+
+		id, _ := reference["@id"].(string)
+		if target, ok := index[id]; ok {
+			return target
+		}
+
+		return value
+	}
+
+	resolved := make([]map[string]interface{}, 0, len(objects))
+	for _, object := range objects {
+		copied := make(map[string]interface{}, len(object))
+		for name, value := range object {
+			if values, ok := value.([]interface{}); ok {
+				elements := make([]interface{}, 0, len(values))
+				for _, element := range values {
+					elements = append(elements, resolve(element))
+				}
+				copied[name] = elements
+				continue
+			}
+
+			copied[name] = resolve(value)
+		}
+		resolved = append(resolved, copied)
+	}
+
+	return resolved
+}
+
+// 提取顶层的微数据条目。
+// 每个条目被转换为与JSON-LD相同结构的对象：@type为类型，属性值为字符串、对象或切片
+func extractMicrodata(root *html.Node) []map[string]interface{} {
+	objects := make([]map[string]interface{}, 0)
+
+	walkElements(root, func(node *html.Node) bool {
+		if !hasAttr(node, "itemscope") {
+			return true
+		}
+
+		// 带itemprop的条目是其他条目的属性，由所属条目处理
+		if !hasAttr(node, "itemprop") {
+			objects = append(objects, microdataItem(node))
+		}
+
+		return true
+	})
+
+	return objects
+}
+
+// 把带itemscope的元素转换为对象
+func microdataItem(scope *html.Node) map[string]interface{} {
+	object := make(map[string]interface{})
+	if itemType, ok := nodeAttr(scope, "itemtype"); ok {
+		object["@type"] = strings.TrimSpace(itemType)
+	}
+
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+
+			props, hasProp := nodeAttr(child, "itemprop")
+			nested := hasAttr(child, "itemscope")
+
+			if hasProp {
+				var value interface{}
+				if nested {
+					value = microdataItem(child)
+				} else {
+					value = microdataValue(child)
+				}
+
+				for _, prop := range strings.Fields(props) {
+					appendProperty(object, prop, value)
+				}
+			}
+
+			// 嵌套条目的属性属于嵌套条目
+			if !nested {
+				walk(child)
+			}
+		}
+	}
+	walk(scope)
+
+	return object
+}
+
+// 获取微数据属性的值
+func microdataValue(node *html.Node) string {
+	attr := ""
+	switch node.Data {
+	case "meta":
+		attr = "content"
+	case "a", "area", "link":
+		attr = "href"
+	case "img", "audio", "video", "source", "embed", "iframe", "track":
+		attr = "src"
+	case "object":
+		attr = "data"
+	case "time":
+		attr = "datetime"
+	case "data", "meter":
+		attr = "value"
+	}
+
+	if attr != "" {
+		if value, ok := nodeAttr(node, attr); ok {
+			return strings.TrimSpace(value)
+		}
+	}
+
+	return strings.Join(strings.Fields(css.Text(node)), " ")
+}
+
+// 为对象追加属性值，同名属性出现多次时值为切片
+func appendProperty(object map[string]interface{}, name string, value interface{}) {
+	existing, ok := object[name]
+	if !ok {
+		object[name] = value
+		return
+	}
+
+	if values, ok := existing.([]interface{}); ok {
+		object[name] = append(values, value)
+		return
+	}
+
+	object[name] = []interface{}{existing, value}
+}
+
+// 元数据标签
+type metaTags struct {
+	values map[string]string // 以小写的name或property为键的内容，同名标签只保留第一个
+}
+
+// 提取<meta>标签
+func extractMetaTags(root *html.Node) *metaTags {
+	metas := &metaTags{values: make(map[string]string)}
+
+	walkElements(root, func(node *html.Node) bool {
+		if node.Data != "meta" {
+			return true
+		}
+
+		content, ok := nodeAttr(node, "content")
+		if !ok {
+			return false
+		}
+
+		for _, key := range []string{"property", "name"} {
+			if name, ok := nodeAttr(node, key); ok {
+				name = strings.ToLower(strings.TrimSpace(name))
+				if _, exists := metas.values[name]; !exists && name != "" {
+					metas.values[name] = strings.TrimSpace(content)
+				}
+			}
+		}
+
+		return false
+	})
+
+	return metas
+}
+
+// 获取以给定前缀开头的元数据
+func (metas *metaTags) withPrefix(prefixes ...string) map[string]interface{} {
+	result := make(map[string]interface{})
+	for name, content := range metas.values {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(name, prefix) {
+				result[name] = content
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+// 在候选对象中选出主体对象：优先选择文章、商品等类型，否则选择第一个
+func primaryObject(objects []map[string]interface{}) map[string]interface{} {
+	for _, object := range objects {
+		if primarySchemaTypes[schemaType(object["@type"])] {
+			return object
+		}
+	}
+
+	return objects[0]
+}
+
+// 获取schema.org类型的短名称，如http://schema.org/BlogPosting的短名称为BlogPosting
+func schemaType(value interface{}) string {
+	typeName := schemaString(value)
+	if index := strings.LastIndexAny(typeName, "/#"); index >= 0 {
+		typeName = typeName[index+1:]
+	}
+
+	return typeName
+}
+
+// 获取值的字符串形式。切片取第一个元素，对象取其@value或@id
+func schemaString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []interface{}:
+		for _, element := range v {
+			if s := schemaString(element); s != "" {
+				return s
+			}
+		}
+	case map[string]interface{}:
+		return firstNonEmpty(schemaString(v["@value"]), schemaString(v["@id"]))
+	case float64, bool, json.Number:
+		return fmt.Sprint(v)
+	}
+
+	return ""
+}
+
+// 获取人员或组织的名称，多个名称以逗号分隔
+func schemaNames(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []interface{}:
+		names := make([]string, 0, len(v))
+		for _, element := range v {
+			if name := strings.TrimSpace(schemaNames(element)); name != "" {
+				names = append(names, name)
+			}
+		}
+		return strings.Join(names, ", ")
+	case map[string]interface{}:
+		return schemaString(v["name"])
+	}
+
+	return ""
+}
+
+// 获取图片等资源的URL
+func schemaURL(value interface{}) string {
+	switch v := value.(type) {
+	case []interface{}:
+		for _, element := range v {
+			if s := schemaURL(element); s != "" {
+				return s
+			}
+		}
+		return ""
+	case map[string]interface{}:
+		return firstNonEmpty(schemaString(v["url"]), schemaString(v["contentUrl"]), schemaString(v["@id"]))
+	}
+
+	return schemaString(value)
+}
+
+// 获取文档的<title>
+func documentTitle(root *html.Node) string {
+	title := ""
+	walkElements(root, func(node *html.Node) bool {
+		if title != "" {
+			return false
+		}
+
+		if node.Data == "title" {
+			title = strings.Join(strings.Fields(css.Text(node)), " ")
+			return false
+		}
+
+		// <body>中的<title>属于SVG等内容，不是文档标题
+		return node.Data != "body" && node.Data != "svg"
+	})
+
+	return title
+}
+
+// 获取<link rel="canonical">的链接
+func canonicalLink(root *html.Node) string {
+	link := ""
+	walkElements(root, func(node *html.Node) bool {
+		if link != "" {
+			return false
+		}
+
+		if node.Data == "link" {
+			if rel, ok := nodeAttr(node, "rel"); ok && hasToken(rel, "canonical") {
+				link, _ = nodeAttr(node, "href")
+			}
+			return false
+		}
+
+		return true
+	})
+
+	return link
+}
+
+// 按文档顺序遍历元素。函数visit返回false时不再遍历该元素的后代
+func walkElements(node *html.Node, visit func(node *html.Node) bool) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && !visit(child) {
+			continue
+		}
+
+		walkElements(child, visit)
+	}
+}
+
+// 判断元素是否有给定的属性
+func hasAttr(node *html.Node, name string) bool {
+	_, ok := nodeAttr(node, name)
+	return ok
+}
+
+// 获取第一个非空的字符串
+func firstNonEmpty(values ...interface{}) string {
+	for _, value := range values {
+		if s, ok := value.(string); ok && strings.TrimSpace(s) != "" {
+			return s
+		}
+	}
+
+	return ""
+}
+
+// 判断字符串切片中是否包含给定的字符串
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,168 @@
+package analyzer
+
+import (
+	"testing"
+)
+
+// 常见的SEO插件生成的JSON-LD：所有对象放在@graph中，互相以@id引用
+var jsonLDGraphPage = `<html><head>
+<title>页面标题 - 唐巧的技术博客</title>
+<meta property="og:title" content="OpenGraph标题">
+<script type="application/ld+json">{
+	"@context": "https://schema.org",
+	"@graph": [
+		{"@type": "WebSite", "@id": "http://blog.devtang.com/#website", "name": "唐巧的技术博客"},
+		{"@type": "WebPage", "@id": "http://blog.devtang.com/first/#webpage", "name": "页面"},
+		{"@type": "ImageObject", "@id": "http://blog.devtang.com/first/#image", "url": "/images/cover.png"},
+		{
+			"@type": "BlogPosting",
+			"@id": "http://blog.devtang.com/first/#article",
+			"headline": "第一篇",
+			"url": "/first/",
+			"datePublished": "2014-01-01T08:00:00+08:00",
+			"author": [{"@id": "http://blog.devtang.com/#person"}],
+			"publisher": {"@id": "http://blog.devtang.com/#website"},
+			"image": {"@id": "http://blog.devtang.com/first/#image"}
+		},
+		{"@type": "Person", "@id": "http://blog.devtang.com/#person", "name": "唐巧"}
+	]
+}</script>
+</head><body></body></html>`
+
+var microdataPage = `<html><body>
+<article itemscope itemtype="http://schema.org/BlogPosting">
+	<h1 itemprop="headline">微数据标题</h1>
+	<time itemprop="datePublished" datetime="2014-02-01">2月1日</time>
+	<div itemprop="author" itemscope itemtype="http://schema.org/Person">
+		<span itemprop="name">唐巧</span>
+	</div>
+	<div itemprop="author" itemscope itemtype="http://schema.org/Person">
+		<span itemprop="name">客座作者</span>
+	</div>
+	<img itemprop="image" src="/images/a.png">
+</article>
+</body></html>`
+
+var socialPage = `<html><head>
+<meta property="og:type" content="article">
+<meta property="og:title" content="OpenGraph标题">
+<meta property="og:site_name" content="唐巧的技术博客">
+<meta property="article:published_time" content="2014-03-01">
+<meta name="twitter:title" content="Twitter标题">
+<meta name="twitter:description" content="Twitter描述">
+<meta name="twitter:image" content="https://img.devtang.com/t.png">
+</head><body></body></html>`
+
+var plainPage = `<html><head>
+<title> 普通页面 </title>
+<meta name="description" content="页面描述">
+<meta name="author" content="唐巧">
+<link rel="canonical" href="/plain/">
+</head><body><svg><title>图标</title></svg></body></html>`
+
+func TestStructuredDataParser(t *testing.T) {
+	cases := []struct {
+		name      string
+		arguments *StructuredDataArguments
+		page      string
+		expected  map[string]string // 需要检查的归一化字段，nil表示没有条目
+		errors    int
+	}{
+		{"JSON-LD @graph", nil, jsonLDGraphPage, map[string]string{
+			STRUCTURED_FIELD_TYPE:      "BlogPosting",
+			STRUCTURED_FIELD_TITLE:     "第一篇",
+			STRUCTURED_FIELD_URL:       "http://blog.devtang.com/first/",
+			STRUCTURED_FIELD_AUTHOR:    "唐巧",
+			STRUCTURED_FIELD_PUBLISHED: "2014-01-01T08:00:00+08:00",
+			STRUCTURED_FIELD_IMAGE:     "http://blog.devtang.com/images/cover.png",
+			STRUCTURED_FIELD_SITE_NAME: "唐巧的技术博客",
+		}, 0},
+		{"只使用OpenGraph", &StructuredDataArguments{Sources: []string{"opengraph"}}, jsonLDGraphPage, map[string]string{
+			STRUCTURED_FIELD_TITLE: "OpenGraph标题",
+			STRUCTURED_FIELD_URL:   "http://blog.devtang.com/first/index.html",
+		}, 0},
+		{"微数据", nil, microdataPage, map[string]string{
+			STRUCTURED_FIELD_TYPE:      "BlogPosting",
+			STRUCTURED_FIELD_TITLE:     "微数据标题",
+			STRUCTURED_FIELD_AUTHOR:    "唐巧, 客座作者",
+			STRUCTURED_FIELD_PUBLISHED: "2014-02-01",
+			STRUCTURED_FIELD_IMAGE:     "http://blog.devtang.com/images/a.png",
+		}, 0},
+		{"OpenGraph优先于Twitter卡片", nil, socialPage, map[string]string{
+			STRUCTURED_FIELD_TYPE:        "article",
+			STRUCTURED_FIELD_TITLE:       "OpenGraph标题",
+			STRUCTURED_FIELD_DESCRIPTION: "Twitter描述",
+			STRUCTURED_FIELD_PUBLISHED:   "2014-03-01",
+			STRUCTURED_FIELD_IMAGE:       "https://img.devtang.com/t.png",
+			STRUCTURED_FIELD_SITE_NAME:   "唐巧的技术博客",
+		}, 0},
+		{"普通HTML标签", nil, plainPage, map[string]string{
+			STRUCTURED_FIELD_TITLE:       "普通页面",
+			STRUCTURED_FIELD_DESCRIPTION: "页面描述",
+			STRUCTURED_FIELD_AUTHOR:      "唐巧",
+			STRUCTURED_FIELD_URL:         "http://blog.devtang.com/plain/",
+		}, 0},
+		{"无效的JSON-LD", nil, `<html><head><title>标题</title><script type="application/ld+json">{"@type": </script></head></html>`, map[string]string{
+			STRUCTURED_FIELD_TITLE: "标题",
+		}, 1},
+		{"没有结构化数据", nil, `<html><body><p>正文</p></body></html>`, nil, 0},
+	}
+
+	for _, c := range cases {
+		parse, err := NewStructuredDataParser(c.arguments)
+		if err != nil {
+			t.Fatalf("%s: 无法创建解析器: %s", c.name, err)
+		}
+
+		response := newTestResponse(t, "http://blog.devtang.com/first/index.html", "text/html", c.page)
+		dataList, errorList := parse(response, 0)
+		if len(errorList) != c.errors {
+			t.Errorf("%s: 期望%d个错误，实际为%v", c.name, c.errors, errorList)
+		}
+
+		_, items := splitData(dataList)
+		if c.expected == nil {
+			if len(items) != 0 {
+				t.Errorf("%s: 不应该产生条目，实际为%v", c.name, items)
+			}
+			continue
+		}
+
+		if len(items) != 1 {
+			t.Errorf("%s: 应该产生1个条目，实际为%d个", c.name, len(items))
+			continue
+		}
+
+		for field, expected := range c.expected {
+			if value := items[0][field]; value != expected {
+				t.Errorf("%s: 字段%s期望%q，实际为%q", c.name, field, expected, value)
+			}
+		}
+	}
+}
+
+func TestStructuredDataRawSources(t *testing.T) {
+	parse, _ := NewStructuredDataParser(nil)
+	dataList, _ := parse(newTestResponse(t, "http://blog.devtang.com/first/", "text/html", jsonLDGraphPage), 0)
+	_, items := splitData(dataList)
+	if len(items) != 1 {
+		t.Fatalf("应该产生1个条目，实际为%d个", len(items))
+	}
+
+	// @graph被展开，原始数据中的引用保持不变
+	raw, _ := items[0][STRUCTURED_SOURCE_JSONLD].([]interface{})
+	if len(raw) != 5 {
+		t.Fatalf("应该展开出5个JSON-LD对象，实际为%d个", len(raw))
+	}
+	article, _ := raw[3].(map[string]interface{})
+	if publisher, _ := article["publisher"].(map[string]interface{}); len(publisher) != 1 {
+		t.Errorf("原始数据中的引用不应该被替换，实际为%v", article["publisher"])
+	}
+
+	if _, ok := items[0][STRUCTURED_SOURCE_OPENGRAPH].(map[string]interface{}); !ok {
+		t.Errorf("应该保存OpenGraph的原始数据，实际为%v", items[0][STRUCTURED_SOURCE_OPENGRAPH])
+	}
+	if _, ok := items[0][STRUCTURED_SOURCE_TWITTER]; ok {
+		t.Error("没有Twitter卡片时不应该有对应的字段")
+	}
+}
@@ -0,0 +1,563 @@
+package xpath
+
+import (
+	"code.google.com/p/go.net/html"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 求值上下文
+type evalContext struct {
+	node     Node      // 上下文节点
+	position int       // 上下文位置（从1开始）
+	size     int       // 上下文大小
+	doc      *document // 文档
+}
+
+// 以新的上下文节点派生上下文
+func (ctx *evalContext) with(node Node, position int, size int) *evalContext {
+	return &evalContext{node: node, position: position, size: size, doc: ctx.doc}
+}
+
+func (e *literalExpr) eval(ctx *evalContext) (interface{}, error) {
+	return e.value, nil
+}
+
+func (e *numberExpr) eval(ctx *evalContext) (interface{}, error) {
+	return e.value, nil
+}
+
+func (e *negateExpr) eval(ctx *evalContext) (interface{}, error) {
+	value, err := e.operand.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return -toNumber(value), nil
+}
+
+func (e *unionExpr) eval(ctx *evalContext) (interface{}, error) {
+	left, err := evalNodeSet(e.left, ctx, "|")
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := evalNodeSet(e.right, ctx, "|")
+	if err != nil {
+		return nil, err
+	}
+
+	return ctx.doc.sortUnique(append(append([]Node{}, left...), right...)), nil
+}
+
+func (e *functionExpr) eval(ctx *evalContext) (interface{}, error) {
+	return e.fn.call(ctx, e.args)
+}
+
+func (e *binaryExpr) eval(ctx *evalContext) (interface{}, error) {
+	left, err := e.left.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// and、or需要短路求值
+	switch e.op {
+	case "and":
+		if !toBoolean(left) {
+			return false, nil
+		}
+		right, err := e.right.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return toBoolean(right), nil
+
+	case "or":
+		if toBoolean(left) {
+			return true, nil
+		}
+		right, err := e.right.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return toBoolean(right), nil
+	}
+
+	right, err := e.right.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case "=", "!=", "<", "<=", ">", ">=":
+		return compare(e.op, left, right), nil
+	case "+":
+		return toNumber(left) + toNumber(right), nil
+	case "-":
+		return toNumber(left) - toNumber(right), nil
+	case "*":
+		return toNumber(left) * toNumber(right), nil
+	case "div":
+		return toNumber(left) / toNumber(right), nil
+	case "mod":
+		return math.Mod(toNumber(left), toNumber(right)), nil
+	}
+
+	return nil, newEvaluationError("未知的运算符【%s】", e.op)
+}
+
+func (e *filterExpr) eval(ctx *evalContext) (interface{}, error) {
+	nodes, err := evalNodeSet(e.primary, ctx, "[]")
+	if err != nil {
+		return nil, err
+	}
+
+	// 过滤表达式的谓词按文档顺序确定位置
+	for _, predicate := range e.predicates {
+		if nodes, err = applyPredicate(ctx, nodes, predicate); err != nil {
+			return nil, err
+		}
+	}
+
+	return nodes, nil
+}
+
+func (e *pathExpr) eval(ctx *evalContext) (interface{}, error) {
+	var nodes []Node
+
+	switch {
+	case e.filter != nil:
+		var err error
+		if nodes, err = evalNodeSet(e.filter, ctx, "/"); err != nil {
+			return nil, err
+		}
+	case e.absolute:
+		nodes = []Node{newNode(ctx.node.root())}
+	default:
+		nodes = []Node{ctx.node}
+	}
+
+	for _, s := range e.steps {
+		result := make([]Node, 0)
+		for _, node := range nodes {
+			selected, err := s.evaluate(ctx, node)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, selected...)
+		}
+
+		nodes = ctx.doc.sortUnique(result)
+	}
+
+	return nodes, nil
+}
+
+// 对一个上下文节点执行定位步
+func (s *step) evaluate(ctx *evalContext, node Node) ([]Node, error) {
+	candidates := axisNodes(s.axis, node)
+
+	selected := make([]Node, 0, len(candidates))
+	for _, candidate := range candidates {
+		if s.test.match(candidate, s.axis == axisAttribute) {
+			selected = append(selected, candidate)
+		}
+	}
+
+	// 定位步的谓词按轴的方向确定位置
+	var err error
+	for _, predicate := range s.predicates {
+		if selected, err = applyPredicate(ctx, selected, predicate); err != nil {
+			return nil, err
+		}
+	}
+
+	return selected, nil
+}
+
+// 对节点序列应用谓词
+func applyPredicate(ctx *evalContext, nodes []Node, predicate expr) ([]Node, error) {
+	result := make([]Node, 0, len(nodes))
+
+	for i, node := range nodes {
+		value, err := predicate.eval(ctx.with(node, i+1, len(nodes)))
+		if err != nil {
+			return nil, err
+		}
+
+		keep := false
+		if number, ok := value.(float64); ok {
+			keep = number == float64(i+1)
+		} else {
+			keep = toBoolean(value)
+		}
+
+		if keep {
+			result = append(result, node)
+		}
+	}
+
+	return result, nil
+}
+
+// 求值并要求结果为节点集
+func evalNodeSet(e expr, ctx *evalContext, operator string) ([]Node, error) {
+	value, err := e.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, ok := value.([]Node)
+	if !ok {
+		return nil, newEvaluationError("运算符%s的操作数必须是节点集", operator)
+	}
+
+	return nodes, nil
+}
+
+// 获取轴上的节点，按轴的方向排列（反向轴按文档逆序）
+func axisNodes(a axis, node Node) []Node {
+	nodes := make([]Node, 0)
+
+	switch a {
+	case axisSelf:
+		nodes = append(nodes, node)
+
+	case axisChild:
+		if !node.IsAttr() {
+			nodes = appendChildren(nodes, node.HTML)
+		}
+
+	case axisDescendant, axisDescendantOrSelf:
+		if a == axisDescendantOrSelf {
+			nodes = append(nodes, node)
+		}
+		if !node.IsAttr() {
+			nodes = appendDescendants(nodes, node.HTML)
+		}
+
+	case axisParent:
+		if parent, ok := node.parent(); ok {
+			nodes = append(nodes, parent)
+		}
+
+	case axisAncestor, axisAncestorOrSelf:
+		if a == axisAncestorOrSelf {
+			nodes = append(nodes, node)
+		}
+		for parent, ok := node.parent(); ok; parent, ok = parent.parent() {
+			nodes = append(nodes, parent)
+		}
+
+	case axisFollowingSibling:
+		if !node.IsAttr() {
+			for sibling := node.HTML.NextSibling; sibling != nil; sibling = sibling.NextSibling {
+				if visible(sibling) {
+					nodes = append(nodes, newNode(sibling))
+				}
+			}
+		}
+
+	case axisPrecedingSibling:
+		if !node.IsAttr() {
+			for sibling := node.HTML.PrevSibling; sibling != nil; sibling = sibling.PrevSibling {
+				if visible(sibling) {
+					nodes = append(nodes, newNode(sibling))
+				}
+			}
+		}
+
+	case axisFollowing:
+		// 属性节点之后的节点从其所属元素的子节点开始
+		start := node.HTML
+		if node.IsAttr() {
+			nodes = appendDescendants(nodes, start)
+		}
+		for n := start; n != nil; n = n.Parent {
+			for sibling := n.NextSibling; sibling != nil; sibling = sibling.NextSibling {
+				if visible(sibling) {
+					nodes = append(nodes, newNode(sibling))
+					nodes = appendDescendants(nodes, sibling)
+				}
+			}
+		}
+
+	case axisPreceding:
+		// 不包括祖先节点，按文档逆序排列
+		for n := node.HTML; n != nil; n = n.Parent {
+			for sibling := n.PrevSibling; sibling != nil; sibling = sibling.PrevSibling {
+				if visible(sibling) {
+					nodes = appendReverseDescendants(nodes, sibling)
+					nodes = append(nodes, newNode(sibling))
+				}
+			}
+		}
+
+	case axisAttribute:
+		if node.IsElement() {
+			for i := range node.HTML.Attr {
+				nodes = append(nodes, Node{HTML: node.HTML, AttrIndex: i})
+			}
+		}
+	}
+
+	return nodes
+}
+
+// 追加可见的子节点
+func appendChildren(nodes []Node, parent *html.Node) []Node {
+	for child := parent.FirstChild; child != nil; child = child.NextSibling {
+		if visible(child) {
+			nodes = append(nodes, newNode(child))
+		}
+	}
+
+	return nodes
+}
+
+// 按文档顺序追加可见的后代节点
+func appendDescendants(nodes []Node, parent *html.Node) []Node {
+	for child := parent.FirstChild; child != nil; child = child.NextSibling {
+		if visible(child) {
+			nodes = append(nodes, newNode(child))
+			nodes = appendDescendants(nodes, child)
+		}
+	}
+
+	return nodes
+}
+
+// 按文档逆序追加可见的后代节点
+func appendReverseDescendants(nodes []Node, parent *html.Node) []Node {
+	for child := parent.LastChild; child != nil; child = child.PrevSibling {
+		if visible(child) {
+			nodes = appendReverseDescendants(nodes, child)
+			nodes = append(nodes, newNode(child))
+		}
+	}
+
+	return nodes
+}
+
+// 按文档顺序排序并去重
+func (doc *document) sortUnique(nodes []Node) []Node {
+	if len(nodes) < 2 {
+		return nodes
+	}
+
+	seen := make(map[orderKey]bool, len(nodes))
+	unique := make([]Node, 0, len(nodes))
+	for _, node := range nodes {
+		key := doc.key(node)
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, node)
+		}
+	}
+
+	sort.SliceStable(unique, func(i, j int) bool {
+		return doc.before(unique[i], unique[j])
+	})
+
+	return unique
+}
+
+// 比较两个值（XPath 1.0规范第3.4节）
+func compare(op string, left interface{}, right interface{}) bool {
+	leftNodes, leftIsNodes := left.([]Node)
+	rightNodes, rightIsNodes := right.([]Node)
+
+	switch {
+	case leftIsNodes && rightIsNodes:
+		for _, l := range leftNodes {
+			for _, r := range rightNodes {
+				if compareAtomic(op, l.Value(), r.Value()) {
+					return true
+				}
+			}
+		}
+		return false
+
+	case leftIsNodes:
+		return compareNodeSet(op, leftNodes, right, false)
+
+	case rightIsNodes:
+		return compareNodeSet(op, rightNodes, left, true)
+	}
+
+	return compareAtomic(op, left, right)
+}
+
+// 比较节点集与非节点集的值。参数swapped表示节点集位于运算符右侧
+func compareNodeSet(op string, nodes []Node, other interface{}, swapped bool) bool {
+	if b, ok := other.(bool); ok {
+		if swapped {
+			return compareAtomic(op, b, len(nodes) > 0)
+		}
+		return compareAtomic(op, len(nodes) > 0, b)
+	}
+
+	for _, node := range nodes {
+		var value interface{} = node.Value()
+		if _, ok := other.(float64); ok {
+			value = toNumber(value)
+		}
+
+		var result bool
+		if swapped {
+			result = compareAtomic(op, other, value)
+		} else {
+			result = compareAtomic(op, value, other)
+		}
+
+		if result {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 比较两个非节点集的值
+func compareAtomic(op string, left interface{}, right interface{}) bool {
+	if op == "=" || op == "!=" {
+		var equal bool
+
+		_, leftIsBool := left.(bool)
+		_, rightIsBool := right.(bool)
+		_, leftIsNumber := left.(float64)
+		_, rightIsNumber := right.(float64)
+
+		switch {
+		case leftIsBool || rightIsBool:
+			equal = toBoolean(left) == toBoolean(right)
+		case leftIsNumber || rightIsNumber:
+			equal = toNumber(left) == toNumber(right)
+		default:
+			equal = toString(left) == toString(right)
+		}
+
+		if op == "=" {
+			return equal
+		}
+		return !equal
+	}
+
+	l, r := toNumber(left), toNumber(right)
+	switch op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	case ">=":
+		return l >= r
+	}
+
+	return false
+}
+
+// 转换为字符串（string()函数的语义）
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case float64:
+		return formatNumber(v)
+	case []Node:
+		if len(v) == 0 {
+			return ""
+		}
+		return v[0].Value()
+	}
+
+	return ""
+}
+
+// 转换为数字（number()函数的语义）
+func toNumber(value interface{}) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	case string:
+		return parseNumber(v)
+	case []Node:
+		return parseNumber(toString(v))
+	}
+
+	return math.NaN()
+}
+
+// 转换为布尔值（boolean()函数的语义）
+func toBoolean(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case string:
+		return v != ""
+	case []Node:
+		return len(v) > 0
+	}
+
+	return false
+}
+
+// 按XPath的Number语法解析字符串，不符合语法时返回NaN
+func parseNumber(s string) float64 {
+	s = strings.Trim(s, " \t\r\n")
+	digits := strings.TrimPrefix(s, "-")
+	if digits == "" || digits == "." {
+		return math.NaN()
+	}
+
+	dots := 0
+	for i := 0; i < len(digits); i++ {
+		if digits[i] == '.' {
+			dots++
+		} else if !isDigit(digits[i]) {
+			return math.NaN()
+		}
+	}
+
+	if dots > 1 {
+		return math.NaN()
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return math.NaN()
+	}
+
+	return value
+}
+
+// 把数字格式化为字符串
+func formatNumber(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "Infinity"
+	case math.IsInf(value, -1):
+		return "-Infinity"
+	case value == 0:
+		return "0"
+	}
+
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
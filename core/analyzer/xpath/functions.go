@@ -0,0 +1,451 @@
+package xpath
+
+import (
+	"code.google.com/p/go.net/html"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// 核心函数库中的函数
+type function struct {
+	minArgs int                                                      // 最少参数个数
+	maxArgs int                                                      // 最多参数个数，-1表示不限
+	call    func(ctx *evalContext, args []expr) (interface{}, error) // 实现
+}
+
+// 核心函数库（XPath 1.0规范第4节）
+var functions map[string]*function
+
+func init() {
+	functions = map[string]*function{
+		// 节点集函数
+		"last":          {0, 0, fnLast},
+		"position":      {0, 0, fnPosition},
+		"count":         {1, 1, fnCount},
+		"id":            {1, 1, fnID},
+		"local-name":    {0, 1, fnLocalName},
+		"name":          {0, 1, fnLocalName},
+		"namespace-uri": {0, 1, fnNamespaceURI},
+
+		// 字符串函数
+		"string":           {0, 1, fnString},
+		"concat":           {2, -1, fnConcat},
+		"starts-with":      {2, 2, fnStartsWith},
+		"contains":         {2, 2, fnContains},
+		"substring-before": {2, 2, fnSubstringBefore},
+		"substring-after":  {2, 2, fnSubstringAfter},
+		"substring":        {2, 3, fnSubstring},
+		"string-length":    {0, 1, fnStringLength},
+		"normalize-space":  {0, 1, fnNormalizeSpace},
+		"translate":        {3, 3, fnTranslate},
+
+		// 布尔函数
+		"boolean": {1, 1, fnBoolean},
+		"not":     {1, 1, fnNot},
+		"true":    {0, 0, fnTrue},
+		"false":   {0, 0, fnFalse},
+		"lang":    {1, 1, fnLang},
+
+		// 数字函数
+		"number":  {0, 1, fnNumber},
+		"sum":     {1, 1, fnSum},
+		"floor":   {1, 1, fnFloor},
+		"ceiling": {1, 1, fnCeiling},
+		"round":   {1, 1, fnRound},
+	}
+}
+
+// 对参数求值并转换为字符串
+func stringArg(ctx *evalContext, args []expr, i int) (string, error) {
+	value, err := args[i].eval(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return toString(value), nil
+}
+
+// 对参数求值并转换为数字
+func numberArg(ctx *evalContext, args []expr, i int) (float64, error) {
+	value, err := args[i].eval(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return toNumber(value), nil
+}
+
+// 获取可选的字符串参数，省略时使用上下文节点的字符串值
+func optionalStringArg(ctx *evalContext, args []expr) (string, error) {
+	if len(args) == 0 {
+		return ctx.node.Value(), nil
+	}
+
+	return stringArg(ctx, args, 0)
+}
+
+// 获取可选的节点集参数中的第一个节点，省略时使用上下文节点
+func optionalNodeArg(ctx *evalContext, args []expr, name string) (*Node, error) {
+	if len(args) == 0 {
+		return &ctx.node, nil
+	}
+
+	nodes, err := evalNodeSet(args[0], ctx, name+"()")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+
+	return &nodes[0], nil
+}
+
+func fnLast(ctx *evalContext, args []expr) (interface{}, error) {
+	return float64(ctx.size), nil
+}
+
+func fnPosition(ctx *evalContext, args []expr) (interface{}, error) {
+	return float64(ctx.position), nil
+}
+
+func fnCount(ctx *evalContext, args []expr) (interface{}, error) {
+	nodes, err := evalNodeSet(args[0], ctx, "count()")
+	if err != nil {
+		return nil, err
+	}
+
+	return float64(len(nodes)), nil
+}
+
+func fnID(ctx *evalContext, args []expr) (interface{}, error) {
+	value, err := args[0].eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]bool)
+	if nodes, ok := value.([]Node); ok {
+		for _, node := range nodes {
+			for _, id := range strings.Fields(node.Value()) {
+				ids[id] = true
+			}
+		}
+	} else {
+		for _, id := range strings.Fields(toString(value)) {
+			ids[id] = true
+		}
+	}
+
+	result := make([]Node, 0)
+	for _, node := range appendDescendants(nil, ctx.node.root()) {
+		if !node.IsElement() {
+			continue
+		}
+
+		for _, attr := range node.HTML.Attr {
+			if attr.Key == "id" && ids[attr.Val] {
+				result = append(result, node)
+				break
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func fnLocalName(ctx *evalContext, args []expr) (interface{}, error) {
+	node, err := optionalNodeArg(ctx, args, "local-name")
+	if err != nil || node == nil {
+		return "", err
+	}
+
+	return node.Name(), nil
+}
+
+func fnNamespaceURI(ctx *evalContext, args []expr) (interface{}, error) {
+	node, err := optionalNodeArg(ctx, args, "namespace-uri")
+	if err != nil || node == nil {
+		return "", err
+	}
+
+	if node.IsAttr() {
+		return node.Attr().Namespace, nil
+	}
+
+	if node.IsElement() {
+		return node.HTML.Namespace, nil
+	}
+
+	return "", nil
+}
+
+func fnString(ctx *evalContext, args []expr) (interface{}, error) {
+	return optionalStringArg(ctx, args)
+}
+
+func fnConcat(ctx *evalContext, args []expr) (interface{}, error) {
+	var builder strings.Builder
+	for i := range args {
+		s, err := stringArg(ctx, args, i)
+		if err != nil {
+			return nil, err
+		}
+		builder.WriteString(s)
+	}
+
+	return builder.String(), nil
+}
+
+// 对两个字符串参数求值
+func twoStringArgs(ctx *evalContext, args []expr) (string, string, error) {
+	a, err := stringArg(ctx, args, 0)
+	if err != nil {
+		return "", "", err
+	}
+
+	b, err := stringArg(ctx, args, 1)
+	if err != nil {
+		return "", "", err
+	}
+
+	return a, b, nil
+}
+
+func fnStartsWith(ctx *evalContext, args []expr) (interface{}, error) {
+	a, b, err := twoStringArgs(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.HasPrefix(a, b), nil
+}
+
+func fnContains(ctx *evalContext, args []expr) (interface{}, error) {
+	a, b, err := twoStringArgs(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Contains(a, b), nil
+}
+
+func fnSubstringBefore(ctx *evalContext, args []expr) (interface{}, error) {
+	a, b, err := twoStringArgs(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if index := strings.Index(a, b); index >= 0 {
+		return a[:index], nil
+	}
+
+	return "", nil
+}
+
+func fnSubstringAfter(ctx *evalContext, args []expr) (interface{}, error) {
+	a, b, err := twoStringArgs(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if index := strings.Index(a, b); index >= 0 {
+		return a[index+len(b):], nil
+	}
+
+	return "", nil
+}
+
+func fnSubstring(ctx *evalContext, args []expr) (interface{}, error) {
+	s, err := stringArg(ctx, args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	start, err := numberArg(ctx, args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// 字符位置p需满足 round(start) <= p < round(start) + round(length)
+	first := xpathRound(start)
+	end := math.Inf(1)
+	if len(args) == 3 {
+		length, err := numberArg(ctx, args, 2)
+		if err != nil {
+			return nil, err
+		}
+		end = first + xpathRound(length)
+	}
+
+	var builder strings.Builder
+	position := 1.0
+	for _, r := range s {
+		if position >= first && position < end {
+			builder.WriteRune(r)
+		}
+		position++
+	}
+
+	return builder.String(), nil
+}
+
+func fnStringLength(ctx *evalContext, args []expr) (interface{}, error) {
+	s, err := optionalStringArg(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return float64(utf8.RuneCountInString(s)), nil
+}
+
+func fnNormalizeSpace(ctx *evalContext, args []expr) (interface{}, error) {
+	s, err := optionalStringArg(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Join(strings.Fields(s), " "), nil
+}
+
+func fnTranslate(ctx *evalContext, args []expr) (interface{}, error) {
+	s, from, err := twoStringArgs(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := stringArg(ctx, args, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	fromRunes, toRunes := []rune(from), []rune(to)
+	mapping := make(map[rune]int)
+	for i, r := range fromRunes {
+		if _, ok := mapping[r]; !ok {
+			mapping[r] = i
+		}
+	}
+
+	var builder strings.Builder
+	for _, r := range s {
+		index, ok := mapping[r]
+		if !ok {
+			builder.WriteRune(r)
+		} else if index < len(toRunes) {
+			builder.WriteRune(toRunes[index])
+		}
+	}
+
+	return builder.String(), nil
+}
+
+func fnBoolean(ctx *evalContext, args []expr) (interface{}, error) {
+	value, err := args[0].eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return toBoolean(value), nil
+}
+
+func fnNot(ctx *evalContext, args []expr) (interface{}, error) {
+	value, err := args[0].eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return !toBoolean(value), nil
+}
+
+func fnTrue(ctx *evalContext, args []expr) (interface{}, error) {
+	return true, nil
+}
+
+func fnFalse(ctx *evalContext, args []expr) (interface{}, error) {
+	return false, nil
+}
+
+func fnLang(ctx *evalContext, args []expr) (interface{}, error) {
+	lang, err := stringArg(ctx, args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// HTML文档使用lang属性，XHTML文档使用xml:lang属性
+	for node := ctx.node.HTML; node != nil; node = node.Parent {
+		if node.Type != html.ElementNode {
+			continue
+		}
+
+		for _, attr := range node.Attr {
+			if attr.Key == "lang" || attr.Key == "xml:lang" {
+				value := strings.ToLower(attr.Val)
+				lang = strings.ToLower(lang)
+				return value == lang || strings.HasPrefix(value, lang+"-"), nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+func fnNumber(ctx *evalContext, args []expr) (interface{}, error) {
+	if len(args) == 0 {
+		return parseNumber(ctx.node.Value()), nil
+	}
+
+	return numberArg(ctx, args, 0)
+}
+
+func fnSum(ctx *evalContext, args []expr) (interface{}, error) {
+	nodes, err := evalNodeSet(args[0], ctx, "sum()")
+	if err != nil {
+		return nil, err
+	}
+
+	sum := 0.0
+	for _, node := range nodes {
+		sum += parseNumber(node.Value())
+	}
+
+	return sum, nil
+}
+
+func fnFloor(ctx *evalContext, args []expr) (interface{}, error) {
+	value, err := numberArg(ctx, args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return math.Floor(value), nil
+}
+
+func fnCeiling(ctx *evalContext, args []expr) (interface{}, error) {
+	value, err := numberArg(ctx, args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return math.Ceil(value), nil
+}
+
+func fnRound(ctx *evalContext, args []expr) (interface{}, error) {
+	value, err := numberArg(ctx, args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return xpathRound(value), nil
+}
+
+// 按XPath的规则取整：最接近的整数，两个整数同样接近时取较大者
+func xpathRound(value float64) float64 {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return value
+	}
+
+	return math.Floor(value + 0.5)
+}
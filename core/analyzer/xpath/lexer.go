@@ -0,0 +1,187 @@
+package xpath
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 记号类型
+type tokenKind uint8
+
+const (
+	tokenEOF          tokenKind = iota // 结束
+	tokenName                          // 名称（NCName、QName或prefix:*）
+	tokenStar                          // 作为名称测试的*
+	tokenNumber                        // 数字
+	tokenString                        // 字符串字面量
+	tokenOperator                      // 运算符（包括and、or、div、mod以及作为乘号的*）
+	tokenLeftParen                     // (
+	tokenRightParen                    // )
+	tokenLeftBracket                   // [
+	tokenRightBracket                  // ]
+	tokenDot                           // .
+	tokenDotDot                        // ..
+	tokenAt                            // @
+	tokenComma                         // ,
+	tokenColonColon                    // ::
+	tokenSlash                         // /
+	tokenDoubleSlash                   // //
+	tokenPipe                          // |
+	tokenDollar                        // $
+)
+
+// 记号
+type token struct {
+	kind tokenKind // 类型
+	text string    // 文本
+	pos  int       // 在表达式中的位置
+}
+
+// 把表达式切分为记号
+func tokenize(expression string) ([]token, error) {
+	tokens := make([]token, 0)
+	pos := 0
+
+	for {
+		for pos < len(expression) && strings.IndexByte(" \t\r\n", expression[pos]) >= 0 {
+			pos++
+		}
+
+		if pos >= len(expression) {
+			tokens = append(tokens, token{kind: tokenEOF, pos: pos})
+			return tokens, nil
+		}
+
+		start := pos
+		ch := expression[pos]
+		next := byte(0)
+		if pos+1 < len(expression) {
+			next = expression[pos+1]
+		}
+
+		var t token
+		switch {
+		case ch == '(':
+			t, pos = token{kind: tokenLeftParen, text: "("}, pos+1
+		case ch == ')':
+			t, pos = token{kind: tokenRightParen, text: ")"}, pos+1
+		case ch == '[':
+			t, pos = token{kind: tokenLeftBracket, text: "["}, pos+1
+		case ch == ']':
+			t, pos = token{kind: tokenRightBracket, text: "]"}, pos+1
+		case ch == '@':
+			t, pos = token{kind: tokenAt, text: "@"}, pos+1
+		case ch == ',':
+			t, pos = token{kind: tokenComma, text: ","}, pos+1
+		case ch == '|':
+			t, pos = token{kind: tokenPipe, text: "|"}, pos+1
+		case ch == '$':
+			t, pos = token{kind: tokenDollar, text: "$"}, pos+1
+		case ch == ':' && next == ':':
+			t, pos = token{kind: tokenColonColon, text: "::"}, pos+2
+		case ch == '/' && next == '/':
+			t, pos = token{kind: tokenDoubleSlash, text: "//"}, pos+2
+		case ch == '/':
+			t, pos = token{kind: tokenSlash, text: "/"}, pos+1
+		case ch == '.' && next == '.':
+			t, pos = token{kind: tokenDotDot, text: ".."}, pos+2
+		case ch == '.' && !isDigit(next):
+			t, pos = token{kind: tokenDot, text: "."}, pos+1
+		case ch == '!' && next == '=', ch == '<' && next == '=', ch == '>' && next == '=':
+			t, pos = token{kind: tokenOperator, text: expression[pos : pos+2]}, pos+2
+		case ch == '=' || ch == '<' || ch == '>' || ch == '+' || ch == '-':
+			t, pos = token{kind: tokenOperator, text: string(ch)}, pos+1
+		case ch == '*':
+			t, pos = token{kind: tokenStar, text: "*"}, pos+1
+		case ch == '"' || ch == '\'':
+			end := strings.IndexByte(expression[pos+1:], ch)
+			if end < 0 {
+				return nil, newSyntaxError(expression, pos, "字符串没有结束")
+			}
+			t = token{kind: tokenString, text: expression[pos+1 : pos+1+end]}
+			pos += end + 2
+		case isDigit(ch) || ch == '.':
+			for pos < len(expression) && isDigit(expression[pos]) {
+				pos++
+			}
+			if pos < len(expression) && expression[pos] == '.' {
+				pos++
+				for pos < len(expression) && isDigit(expression[pos]) {
+					pos++
+				}
+			}
+			t = token{kind: tokenNumber, text: expression[start:pos]}
+		default:
+			r, _ := utf8.DecodeRuneInString(expression[pos:])
+			if !isNameStart(r) {
+				return nil, newSyntaxError(expression, pos, "意外的字符%q", r)
+			}
+
+			pos = scanName(expression, pos)
+			// QName或prefix:*
+			if pos+1 < len(expression) && expression[pos] == ':' && expression[pos+1] != ':' {
+				if expression[pos+1] == '*' {
+					pos += 2
+				} else if r, _ := utf8.DecodeRuneInString(expression[pos+1:]); isNameStart(r) {
+					pos = scanName(expression, pos+1)
+				}
+			}
+			t = token{kind: tokenName, text: expression[start:pos]}
+		}
+
+		t.pos = start
+		tokens = append(tokens, disambiguate(tokens, t))
+	}
+}
+
+// 按照XPath 1.0规范第3.7节消除歧义：
+// 若前一个记号存在，且不是@、::、(、[、,或运算符，
+// 则*应被识别为乘号，名称应被识别为运算符名称
+func disambiguate(tokens []token, t token) token {
+	if len(tokens) == 0 {
+		return t
+	}
+
+	switch tokens[len(tokens)-1].kind {
+	case tokenAt, tokenColonColon, tokenLeftParen, tokenLeftBracket, tokenComma,
+		tokenOperator, tokenSlash, tokenDoubleSlash, tokenPipe:
+		return t
+	}
+
+	if t.kind == tokenStar {
+		t.kind = tokenOperator
+	} else if t.kind == tokenName {
+		switch t.text {
+		case "and", "or", "div", "mod":
+			t.kind = tokenOperator
+		}
+	}
+
+	return t
+}
+
+// 扫描NCName，返回名称之后的位置
+func scanName(expression string, pos int) int {
+	for pos < len(expression) {
+		r, size := utf8.DecodeRuneInString(expression[pos:])
+		if !isNameChar(r) {
+			break
+		}
+		pos += size
+	}
+
+	return pos
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isNameStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isNameChar(r rune) bool {
+	return isNameStart(r) || r == '-' || r == '.' || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
@@ -0,0 +1,155 @@
+package xpath
+
+import (
+	"code.google.com/p/go.net/html"
+	"strings"
+)
+
+// XPath数据模型中的节点。
+// HTML文档树中没有属性节点，所以属性节点由其所属元素和属性下标共同表示
+type Node struct {
+	HTML      *html.Node // 对应的HTML节点。属性节点时为其所属元素
+	AttrIndex int        // 属性在HTML.Attr中的下标。非属性节点时为-1
+}
+
+// 创建非属性节点
+func newNode(node *html.Node) Node {
+	return Node{HTML: node, AttrIndex: -1}
+}
+
+// 判断是否为属性节点
+func (node Node) IsAttr() bool {
+	return node.AttrIndex >= 0
+}
+
+// 判断是否为元素节点
+func (node Node) IsElement() bool {
+	return !node.IsAttr() && node.HTML.Type == html.ElementNode
+}
+
+// 获取属性节点对应的属性
+func (node Node) Attr() *html.Attribute {
+	if !node.IsAttr() {
+		return nil
+	}
+
+	return &node.HTML.Attr[node.AttrIndex]
+}
+
+// 获取节点名称
+func (node Node) Name() string {
+	if node.IsAttr() {
+		return node.Attr().Key
+	}
+
+	if node.HTML.Type == html.ElementNode {
+		return node.HTML.Data
+	}
+
+	return ""
+}
+
+// 获取节点的字符串值
+func (node Node) Value() string {
+	if node.IsAttr() {
+		return node.Attr().Val
+	}
+
+	switch node.HTML.Type {
+	case html.TextNode, html.CommentNode:
+		return node.HTML.Data
+	}
+
+	var builder strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			switch child.Type {
+			case html.TextNode:
+				builder.WriteString(child.Data)
+			case html.ElementNode:
+				walk(child)
+			}
+		}
+	}
+	walk(node.HTML)
+
+	return builder.String()
+}
+
+// 判断HTML节点在XPath数据模型中是否可见
+func visible(node *html.Node) bool {
+	switch node.Type {
+	case html.DocumentNode, html.ElementNode, html.TextNode, html.CommentNode:
+		return true
+	}
+
+	return false
+}
+
+// 获取节点的父节点。属性节点的父节点是其所属元素
+func (node Node) parent() (Node, bool) {
+	if node.IsAttr() {
+		return newNode(node.HTML), true
+	}
+
+	if node.HTML.Parent == nil {
+		return Node{}, false
+	}
+
+	return newNode(node.HTML.Parent), true
+}
+
+// 获取文档的根节点
+func (node Node) root() *html.Node {
+	root := node.HTML
+	for root.Parent != nil {
+		root = root.Parent
+	}
+
+	return root
+}
+
+// 文档顺序的键
+type orderKey struct {
+	index int // HTML节点在文档中的序号
+	attr  int // 属性下标加一，非属性节点为0
+}
+
+// 文档，记录各节点的文档顺序
+type document struct {
+	order map[*html.Node]int // HTML节点的序号
+}
+
+// 为根节点所在的文档建立顺序表
+func newDocument(root *html.Node) *document {
+	doc := &document{order: make(map[*html.Node]int)}
+
+	index := 0
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		doc.order[n] = index
+		index++
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(root)
+
+	return doc
+}
+
+// 获取节点的文档顺序键
+func (doc *document) key(node Node) orderKey {
+	return orderKey{index: doc.order[node.HTML], attr: node.AttrIndex + 1}
+}
+
+// 判断节点a是否在节点b之前
+func (doc *document) before(a Node, b Node) bool {
+	keyA, keyB := doc.key(a), doc.key(b)
+	if keyA.index != keyB.index {
+		return keyA.index < keyB.index
+	}
+
+	return keyA.attr < keyB.attr
+}
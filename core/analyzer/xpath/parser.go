@@ -0,0 +1,590 @@
+package xpath
+
+import (
+	"code.google.com/p/go.net/html"
+	"strconv"
+	"strings"
+)
+
+// 轴
+type axis uint8
+
+const (
+	axisChild axis = iota
+	axisDescendant
+	axisDescendantOrSelf
+	axisSelf
+	axisParent
+	axisAncestor
+	axisAncestorOrSelf
+	axisFollowingSibling
+	axisPrecedingSibling
+	axisFollowing
+	axisPreceding
+	axisAttribute
+)
+
+// 轴名称与轴的映射关系
+var axisMap = map[string]axis{
+	"child":              axisChild,
+	"descendant":         axisDescendant,
+	"descendant-or-self": axisDescendantOrSelf,
+	"self":               axisSelf,
+	"parent":             axisParent,
+	"ancestor":           axisAncestor,
+	"ancestor-or-self":   axisAncestorOrSelf,
+	"following-sibling":  axisFollowingSibling,
+	"preceding-sibling":  axisPrecedingSibling,
+	"following":          axisFollowing,
+	"preceding":          axisPreceding,
+	"attribute":          axisAttribute,
+}
+
+// 节点类型测试的名称
+var nodeTypeNames = map[string]bool{
+	"node":                   true,
+	"text":                   true,
+	"comment":                true,
+	"processing-instruction": true,
+}
+
+// 表达式语法树的节点
+type expr interface {
+	eval(ctx *evalContext) (interface{}, error)
+}
+
+// 二元运算
+type binaryExpr struct {
+	op    string
+	left  expr
+	right expr
+}
+
+// 取负
+type negateExpr struct {
+	operand expr
+}
+
+// 并集
+type unionExpr struct {
+	left  expr
+	right expr
+}
+
+// 字符串字面量
+type literalExpr struct {
+	value string
+}
+
+// 数字字面量
+type numberExpr struct {
+	value float64
+}
+
+// 函数调用
+type functionExpr struct {
+	name string
+	fn   *function
+	args []expr
+}
+
+// 过滤表达式，即带谓词的基本表达式
+type filterExpr struct {
+	primary    expr
+	predicates []expr
+}
+
+// 路径表达式
+type pathExpr struct {
+	filter   expr    // 起点表达式，为nil时表示从上下文节点（或根节点）开始
+	absolute bool    // 是否从根节点开始
+	steps    []*step // 定位步
+}
+
+// 节点测试
+type nodeTest struct {
+	nodeType string // 节点类型测试（node、text等），为空时表示名称测试
+	name     string // 名称测试，*表示任意名称
+}
+
+// 定位步
+type step struct {
+	axis       axis
+	test       nodeTest
+	predicates []expr
+}
+
+// 表达式解析器
+type parser struct {
+	expression string
+	tokens     []token
+	pos        int
+}
+
+// 解析表达式
+func parse(expression string) (expr, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{expression: expression, tokens: tokens}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().kind != tokenEOF {
+		return nil, p.errorf("意外的记号【%s】", p.peek().text)
+	}
+
+	return e, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) peekAt(offset int) token {
+	if p.pos+offset >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+
+	return p.tokens[p.pos+offset]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+// 判断下一个记号是否为给定的运算符
+func (p *parser) isOperator(operators ...string) bool {
+	t := p.peek()
+	if t.kind != tokenOperator {
+		return false
+	}
+
+	for _, operator := range operators {
+		if t.text == operator {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *parser) expect(kind tokenKind, text string) error {
+	if p.peek().kind != kind {
+		return p.errorf("缺少【%s】", text)
+	}
+
+	p.next()
+
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return newSyntaxError(p.expression, p.peek().pos, format, args...)
+}
+
+// 解析左结合的二元运算
+func (p *parser) parseBinary(operand func() (expr, error), operators ...string) (expr, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOperator(operators...) {
+		op := p.next().text
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binaryExpr{op: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseOr() (expr, error) {
+	return p.parseBinary(p.parseAnd, "or")
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	return p.parseBinary(p.parseEquality, "and")
+}
+
+func (p *parser) parseEquality() (expr, error) {
+	return p.parseBinary(p.parseRelational, "=", "!=")
+}
+
+func (p *parser) parseRelational() (expr, error) {
+	return p.parseBinary(p.parseAdditive, "<", "<=", ">", ">=")
+}
+
+func (p *parser) parseAdditive() (expr, error) {
+	return p.parseBinary(p.parseMultiplicative, "+", "-")
+}
+
+func (p *parser) parseMultiplicative() (expr, error) {
+	return p.parseBinary(p.parseUnary, "*", "div", "mod")
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	if p.isOperator("-") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return &negateExpr{operand: operand}, nil
+	}
+
+	return p.parseUnion()
+}
+
+func (p *parser) parseUnion() (expr, error) {
+	left, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == tokenPipe {
+		p.next()
+		right, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &unionExpr{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+// 解析路径表达式
+func (p *parser) parsePath() (expr, error) {
+	t := p.peek()
+
+	switch t.kind {
+	case tokenSlash:
+		p.next()
+		path := &pathExpr{absolute: true}
+		// 单独的“/”表示根节点
+		if p.startsStep() {
+			if err := p.parseRelativePath(path); err != nil {
+				return nil, err
+			}
+		}
+		return path, nil
+
+	case tokenDoubleSlash:
+		p.next()
+		path := &pathExpr{absolute: true}
+		path.steps = append(path.steps, descendantOrSelfStep())
+		if err := p.parseRelativePath(path); err != nil {
+			return nil, err
+		}
+		return path, nil
+	}
+
+	if p.startsStep() {
+		path := &pathExpr{}
+		if err := p.parseRelativePath(path); err != nil {
+			return nil, err
+		}
+		return path, nil
+	}
+
+	filter, err := p.parseFilter()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().kind != tokenSlash && p.peek().kind != tokenDoubleSlash {
+		return filter, nil
+	}
+
+	path := &pathExpr{filter: filter}
+	if p.next().kind == tokenDoubleSlash {
+		path.steps = append(path.steps, descendantOrSelfStep())
+	}
+
+	if err := p.parseRelativePath(path); err != nil {
+		return nil, err
+	}
+
+	return path, nil
+}
+
+// 判断下一个记号是否为定位步的开始
+func (p *parser) startsStep() bool {
+	t := p.peek()
+
+	switch t.kind {
+	case tokenDot, tokenDotDot, tokenAt, tokenStar:
+		return true
+	case tokenName:
+		next := p.peekAt(1)
+		if next.kind == tokenColonColon {
+			return true
+		}
+		// 后面跟着“(”的名称是函数调用，除非它是节点类型测试
+		return next.kind != tokenLeftParen || nodeTypeNames[t.text]
+	}
+
+	return false
+}
+
+// 解析相对定位路径，把定位步追加到路径表达式中
+func (p *parser) parseRelativePath(path *pathExpr) error {
+	for {
+		s, err := p.parseStep()
+		if err != nil {
+			return err
+		}
+		path.steps = append(path.steps, s)
+
+		switch p.peek().kind {
+		case tokenSlash:
+			p.next()
+		case tokenDoubleSlash:
+			p.next()
+			path.steps = append(path.steps, descendantOrSelfStep())
+		default:
+			return nil
+		}
+	}
+}
+
+// 解析定位步
+func (p *parser) parseStep() (*step, error) {
+	switch p.peek().kind {
+	case tokenDot:
+		p.next()
+		return &step{axis: axisSelf, test: nodeTest{nodeType: "node"}}, nil
+	case tokenDotDot:
+		p.next()
+		return &step{axis: axisParent, test: nodeTest{nodeType: "node"}}, nil
+	}
+
+	s := &step{axis: axisChild}
+
+	if p.peek().kind == tokenAt {
+		p.next()
+		s.axis = axisAttribute
+	} else if p.peek().kind == tokenName && p.peekAt(1).kind == tokenColonColon {
+		name := p.next().text
+		a, ok := axisMap[name]
+		if !ok {
+			if name == "namespace" {
+				return nil, p.errorf("不支持namespace轴")
+			}
+			return nil, p.errorf("未知的轴【%s】", name)
+		}
+		p.next()
+		s.axis = a
+	}
+
+	t := p.next()
+	switch t.kind {
+	case tokenStar:
+		s.test = nodeTest{name: "*"}
+	case tokenName:
+		if nodeTypeNames[t.text] && p.peek().kind == tokenLeftParen {
+			p.next()
+			// processing-instruction()可以带一个字面量参数
+			if t.text == "processing-instruction" && p.peek().kind == tokenString {
+				p.next()
+			}
+			if err := p.expect(tokenRightParen, ")"); err != nil {
+				return nil, err
+			}
+			s.test = nodeTest{nodeType: t.text}
+		} else {
+			s.test = nodeTest{name: t.text}
+		}
+	default:
+		p.pos--
+		return nil, p.errorf("缺少节点测试")
+	}
+
+	predicates, err := p.parsePredicates()
+	if err != nil {
+		return nil, err
+	}
+	s.predicates = predicates
+
+	return s, nil
+}
+
+// 解析谓词序列
+func (p *parser) parsePredicates() ([]expr, error) {
+	predicates := make([]expr, 0)
+
+	for p.peek().kind == tokenLeftBracket {
+		p.next()
+		predicate, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expect(tokenRightBracket, "]"); err != nil {
+			return nil, err
+		}
+
+		predicates = append(predicates, predicate)
+	}
+
+	return predicates, nil
+}
+
+// 解析过滤表达式
+func (p *parser) parseFilter() (expr, error) {
+	primary, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	predicates, err := p.parsePredicates()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(predicates) == 0 {
+		return primary, nil
+	}
+
+	return &filterExpr{primary: primary, predicates: predicates}, nil
+}
+
+// 解析基本表达式
+func (p *parser) parsePrimary() (expr, error) {
+	t := p.peek()
+
+	switch t.kind {
+	case tokenString:
+		p.next()
+		return &literalExpr{value: t.text}, nil
+
+	case tokenNumber:
+		p.next()
+		value, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf("无效的数字【%s】", t.text)
+		}
+		return &numberExpr{value: value}, nil
+
+	case tokenLeftParen:
+		p.next()
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenRightParen, ")"); err != nil {
+			return nil, err
+		}
+		return e, nil
+
+	case tokenDollar:
+		return nil, p.errorf("不支持变量引用")
+
+	case tokenName:
+		if p.peekAt(1).kind == tokenLeftParen {
+			return p.parseFunctionCall()
+		}
+	}
+
+	if t.kind == tokenEOF {
+		return nil, p.errorf("表达式不完整")
+	}
+
+	return nil, p.errorf("意外的记号【%s】", t.text)
+}
+
+// 解析函数调用
+func (p *parser) parseFunctionCall() (expr, error) {
+	name := p.next().text
+	p.next() // (
+
+	fn, ok := functions[name]
+	if !ok {
+		p.pos -= 2
+		return nil, p.errorf("未知的函数【%s】", name)
+	}
+
+	args := make([]expr, 0)
+	if p.peek().kind != tokenRightParen {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+
+			if p.peek().kind != tokenComma {
+				break
+			}
+			p.next()
+		}
+	}
+
+	if err := p.expect(tokenRightParen, ")"); err != nil {
+		return nil, err
+	}
+
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, p.errorf("函数%s()的参数个数错误【%d】", name, len(args))
+	}
+
+	return &functionExpr{name: name, fn: fn, args: args}, nil
+}
+
+// “//”对应的定位步
+func descendantOrSelfStep() *step {
+	return &step{axis: axisDescendantOrSelf, test: nodeTest{nodeType: "node"}}
+}
+
+// 判断名称测试是否匹配节点
+func (test nodeTest) match(node Node, principalAttr bool) bool {
+	switch test.nodeType {
+	case "node":
+		return true
+	case "text":
+		return !node.IsAttr() && node.HTML.Type == html.TextNode
+	case "comment":
+		return !node.IsAttr() && node.HTML.Type == html.CommentNode
+	case "processing-instruction":
+		return false
+	}
+
+	// 名称测试只匹配主节点类型的节点
+	if principalAttr != node.IsAttr() {
+		return false
+	}
+	if !node.IsAttr() && !node.IsElement() {
+		return false
+	}
+
+	if test.name == "*" {
+		return true
+	}
+
+	name := test.name
+	if index := strings.IndexByte(name, ':'); index >= 0 {
+		// HTML文档没有命名空间，忽略前缀
+		if name[index+1:] == "*" {
+			return true
+		}
+		name = name[index+1:]
+	}
+
+	return strings.EqualFold(node.Name(), name)
+}
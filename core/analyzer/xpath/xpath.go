@@ -0,0 +1,106 @@
+package xpath
+
+import (
+	"code.google.com/p/go.net/html"
+	base "core/base"
+	"fmt"
+	"strings"
+)
+
+// XPath表达式接口
+type MKExpression interface {
+	// 以给定节点为上下文节点对表达式求值。
+	// 结果的类型为[]Node（节点集）、string、float64或bool之一
+	Evaluate(context *html.Node) (interface{}, error)
+
+	// 以给定节点为上下文节点对表达式求值，结果必须是节点集。
+	// 节点按文档顺序排列
+	Select(context *html.Node) ([]Node, error)
+
+	// 获取表达式的字符串表现形式
+	String() string
+}
+
+// 编译XPath 1.0表达式。
+// 支持全部13个轴、节点测试（名称、*、node()、text()、comment()、processing-instruction()）、
+// 谓词、运算符以及核心函数库。不支持变量引用和名称空间前缀
+func Compile(expression string) (MKExpression, error) {
+	if strings.TrimSpace(expression) == "" {
+		return nil, newSyntaxError(expression, 0, "表达式为空")
+	}
+
+	e, err := parse(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mk_expression{source: expression, root: e}, nil
+}
+
+// 编译XPath表达式，失败时引发运行时恐慌。仅适用于常量表达式
+func MustCompile(expression string) MKExpression {
+	compiled, err := Compile(expression)
+	if err != nil {
+		panic(err)
+	}
+
+	return compiled
+}
+
+// XPath表达式的实现类型
+type mk_expression struct {
+	source string // 表达式源文本
+	root   expr   // 语法树
+}
+
+func (expression *mk_expression) Evaluate(context *html.Node) (interface{}, error) {
+	if context == nil {
+		return nil, newEvaluationError("上下文节点为空")
+	}
+
+	node := newNode(context)
+	ctx := &evalContext{
+		node:     node,
+		position: 1,
+		size:     1,
+		doc:      newDocument(node.root()),
+	}
+
+	return expression.root.eval(ctx)
+}
+
+func (expression *mk_expression) Select(context *html.Node) ([]Node, error) {
+	value, err := expression.Evaluate(context)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, ok := value.([]Node)
+	if !ok {
+		return nil, newEvaluationError("表达式【%s】的结果不是节点集", expression.source)
+	}
+
+	return nodes, nil
+}
+
+func (expression *mk_expression) String() string {
+	return expression.source
+}
+
+// 将求值结果转换为字符串（XPath的string()函数）
+func String(value interface{}) string {
+	return toString(value)
+}
+
+// 创建语法错误
+func newSyntaxError(expression string, pos int, format string, args ...interface{}) error {
+	message := fmt.Sprintf("无效的XPath表达式【%s】（位置%d）: %s",
+		expression, pos, fmt.Sprintf(format, args...))
+	return base.NewError(base.ERR_DOMAIN_ANALYZER, base.ERR_CODE_INVALID_EXPRESSION, message)
+}
+
+// 创建求值错误
+func newEvaluationError(format string, args ...interface{}) error {
+	message := "XPath求值失败: " + fmt.Sprintf(format, args...)
+	return base.NewError(base.ERR_DOMAIN_ANALYZER, base.ERR_CODE_EVALUATION, message)
+}
@@ -0,0 +1,160 @@
+package xpath
+
+import (
+	"code.google.com/p/go.net/html"
+	base "core/base"
+	"strings"
+	"testing"
+)
+
+var testDocument = `<html><body>
+<div id="main" class="content archive">
+	<h1 class="title">博客归档</h1>
+	<ul class="posts">
+		<li class="post first"><a href="/p/1" rel="bookmark">第一篇</a><span class="date">2014-01-01</span></li>
+		<li class="post"><a href="/p/2">第二篇</a><span class="date">2014-02-01</span></li>
+		<li class="post"><a href="http://other.com/p/3" data-id="x-3">第三篇</a></li>
+		<li class="post last" lang="zh-CN"><a href="/p/4">第四篇</a><em>12.5</em></li>
+	</ul>
+	<p>说明</p>
+	<p class="note">下一页</p>
+</div>
+</body></html>`
+
+func parseTestDocument(t *testing.T) *html.Node {
+	root, err := html.Parse(strings.NewReader(testDocument))
+	if err != nil {
+		t.Fatalf("无法解析测试文档: %s", err)
+	}
+
+	return root
+}
+
+func TestSelect(t *testing.T) {
+	root := parseTestDocument(t)
+
+	cases := []struct {
+		expression string
+		expected   []string
+	}{
+		{"//h1", []string{"博客归档"}},
+		{"/html/body/div/h1/text()", []string{"博客归档"}},
+		{"//li[1]/a", []string{"第一篇"}},
+		{"//li[last()]/a", []string{"第四篇"}},
+		{"(//a)[position() > 2]", []string{"第三篇", "第四篇"}},
+		{"//li[@class='post']/a", []string{"第二篇", "第三篇"}},
+		{"//li[contains(@class, 'first')]/a/@href", []string{"/p/1"}},
+		{"//a[starts-with(@href, 'http')]", []string{"第三篇"}},
+		{"//a[@data-id]/@data-id", []string{"x-3"}},
+		{"//span[@class='date']/preceding-sibling::a", []string{"第一篇", "第二篇"}},
+		{"//h1/following-sibling::p[2]", []string{"下一页"}},
+		{"//a[. = '第二篇']/ancestor::div/@id", []string{"main"}},
+		{"//li[not(span)]/a", []string{"第三篇", "第四篇"}},
+		{"//em/parent::li/a | //h1", []string{"博客归档", "第四篇"}},
+		{"//li[count(*) = 2][lang('zh')]/a", []string{"第四篇"}},
+		{"id('main')/ul/li[3]/a", []string{"第三篇"}},
+		{"//ul/li[2]/following::a[1]", []string{"第三篇"}},
+		{"//p[last()]/preceding::a[1]", []string{"第四篇"}},
+	}
+
+	for _, c := range cases {
+		expression, err := Compile(c.expression)
+		if err != nil {
+			t.Errorf("无法编译表达式【%s】: %s", c.expression, err)
+			continue
+		}
+
+		nodes, err := expression.Select(root)
+		if err != nil {
+			t.Errorf("表达式【%s】求值失败: %s", c.expression, err)
+			continue
+		}
+
+		actual := make([]string, 0, len(nodes))
+		for _, node := range nodes {
+			actual = append(actual, strings.TrimSpace(node.Value()))
+		}
+
+		if strings.Join(actual, "|") != strings.Join(c.expected, "|") {
+			t.Errorf("表达式【%s】: 期望%v，实际为%v", c.expression, c.expected, actual)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	root := parseTestDocument(t)
+
+	cases := []struct {
+		expression string
+		expected   interface{}
+	}{
+		{"count(//li)", 4.0},
+		{"count(//li) * 2 + 1 div 2", 8.5},
+		{"7 mod 3 - -1", 2.0},
+		{"sum(//em) + 0.5", 13.0},
+		{"round(2.5) + floor(-1.5) + ceiling(1.2)", 3.0},
+		{"string(//li[2]/a/@href)", "/p/2"},
+		{"normalize-space(concat('  a ', ' b', 'c  '))", "a bc"},
+		{"substring('12345', 1.5, 2.6)", "234"},
+		{"substring-before(//span, '-')", "2014"},
+		{"substring-after((//span)[2], '2014-')", "02-01"},
+		{"translate('bar', 'abc', 'AB')", "BAr"},
+		{"string-length(//h1)", 4.0},
+		{"local-name(//*[@id])", "div"},
+		{"//li = '第三篇'", true},
+		{"//em > 12 and //em < 13", true},
+		{"boolean(//table) or false()", false},
+		{"number('abc') = number('abc')", false},
+	}
+
+	for _, c := range cases {
+		expression, err := Compile(c.expression)
+		if err != nil {
+			t.Errorf("无法编译表达式【%s】: %s", c.expression, err)
+			continue
+		}
+
+		value, err := expression.Evaluate(root)
+		if err != nil {
+			t.Errorf("表达式【%s】求值失败: %s", c.expression, err)
+			continue
+		}
+
+		if value != c.expected {
+			t.Errorf("表达式【%s】: 期望%v，实际为%v", c.expression, c.expected, value)
+		}
+	}
+}
+
+func TestInvalidExpression(t *testing.T) {
+	invalid := []string{
+		"",
+		"//",
+		"//li[",
+		"//a[@href='x]",
+		"foo(1)",
+		"count()",
+		"$var",
+		"//li]",
+		"child::",
+		"unknown::li",
+	}
+
+	for _, expression := range invalid {
+		_, err := Compile(expression)
+		if err == nil {
+			t.Errorf("表达式【%s】应该是无效的", expression)
+			continue
+		}
+
+		mkErr, ok := err.(base.MKError)
+		if !ok || mkErr.Domain() != base.ERR_DOMAIN_ANALYZER || mkErr.Code() != base.ERR_CODE_INVALID_EXPRESSION {
+			t.Errorf("表达式【%s】的错误类型不正确: %s", expression, err)
+		}
+	}
+
+	expression := MustCompile("count(1)")
+	if _, err := expression.Evaluate(parseTestDocument(t)); err == nil {
+		t.Errorf("对非节点集调用count()应该失败")
+	}
+}
@@ -13,8 +13,9 @@ type MKData interface {
  * 请求
  */
 type MKRequest struct {
-	request *http.Request // HTTP请求指针
-	depth   uint32        // 请求深度
+	request   *http.Request // HTTP请求指针
+	depth     uint32        // 请求深度
+	keepDepth bool          // 是否保持与所在页面相同的深度
 }
 
 // 创建新的请求
@@ -25,6 +26,16 @@ func NewRequest(request *http.Request, depth uint32) *MKRequest {
 	}
 }
 
+// 创建新的分页请求。
+// 分页请求（如下一页）被视为所在页面的延续，分析器不会增加它的深度
+func NewPaginationRequest(request *http.Request, depth uint32) *MKRequest {
+	return &MKRequest{
+		request:   request,
+		depth:     depth,
+		keepDepth: true,
+	}
+}
+
 // 获取HTTP请求
 func (request *MKRequest) Request() *http.Request {
 	return request.request
@@ -35,6 +46,11 @@ func (request *MKRequest) Depth() uint32 {
 	return request.depth
 }
 
+// 是否保持与所在页面相同的深度
+func (request *MKRequest) KeepDepth() bool {
+	return request.keepDepth
+}
+
 // 数据是否有效
 func (request *MKRequest) Valid() bool {
 	return request.request != nil && request.request.URL != nil
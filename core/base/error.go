@@ -20,7 +20,10 @@ const (
 
 // 错误编码常量
 const (
-	ERR_CODE_NONE ErrorCode = 0 // 无错误
+	ERR_CODE_NONE               ErrorCode = 0 // 无错误
+	ERR_CODE_INVALID_EXPRESSION ErrorCode = 1 // 无效的表达式（如XPath表达式）
+	ERR_CODE_EVALUATION         ErrorCode = 2 // 表达式求值失败
+	ERR_CODE_ITEM_DROPPED       ErrorCode = 3 // 条目被丢弃（不是真正的错误）
 )
 
 // 错误接口
@@ -74,7 +77,7 @@ func (err *mk_error) generateFullErrorMessage() {
 
 	if err.domain != "" && err.code != ERR_CODE_NONE {
 		buffer.WriteString(string(err.domain))
-		buffer.WriteString("[%d]", err.code)
+		buffer.WriteString(fmt.Sprintf("[%d]", err.code))
 		buffer.WriteString(": ")
 	}
 
@@ -0,0 +1,70 @@
+package config
+
+import (
+	analyzer "core/analyzer"
+	downloader "core/downloader"
+	itempipeline "core/itempipeline"
+	registry "core/registry"
+)
+
+// 组件目录的接口类型。
+// 校验配置时通过组件目录确认站点类型、解析器、条目处理器和网页下载器是否存在，以及参数是否有效
+type MKCatalog interface {
+	// 判断站点类型代码是否已知
+	HasSiteType(code uint32) bool
+
+	// 检查解析器是否存在以及参数是否有效
+	CheckParser(name string, params map[string]string) error
+
+	// 检查条目处理器是否存在以及参数是否有效
+	CheckProcessor(name string, params map[string]string) error
+
+	// 检查网页下载器是否存在以及参数是否有效
+	CheckDownloader(name string, params map[string]string) error
+
+	// 判断网页下载器是否使用站点的环境（Cookie容器、登录等）
+	DownloaderUsesEnv(name string) bool
+}
+
+// 获取以站点类型注册表和组件注册表为准的组件目录
+func DefaultCatalog() MKCatalog {
+	return &mk_registryCatalog{}
+}
+
+type mk_registryCatalog struct{}
+
+func (catalog *mk_registryCatalog) HasSiteType(code uint32) bool {
+	_, ok := LookupSiteType(code)
+	return ok
+}
+
+func (catalog *mk_registryCatalog) CheckParser(name string, params map[string]string) error {
+	return registry.CheckParser(name, params)
+}
+
+func (catalog *mk_registryCatalog) CheckProcessor(name string, params map[string]string) error {
+	return registry.CheckProcessor(name, params)
+}
+
+func (catalog *mk_registryCatalog) CheckDownloader(name string, params map[string]string) error {
+	return registry.CheckDownloader(name, params)
+}
+
+func (catalog *mk_registryCatalog) DownloaderUsesEnv(name string) bool {
+	return registry.DownloaderUsesEnv(name)
+}
+
+// 按名称创建解析器
+func newNamedParser(component *Component) (analyzer.MKParseResponse, error) {
+	return registry.NewParser(component.Name, component.ParamMap())
+}
+
+// 按名称创建条目处理流程的一个阶段，阶段为条目输出时同时返回条目输出
+func newNamedStage(component *Component) (itempipeline.MKProcessItem, itempipeline.MKItemSink, error) {
+	return registry.NewStage(component.Name, component.ParamMap())
+}
+
+// 按名称在给定的环境中创建网页下载器
+func newNamedDownloader(component *Component, env *registry.DownloaderEnv) (downloader.MKPageDownloader, error) {
+	return registry.NewDownloader(component.Name, component.ParamMap(), env)
+}
@@ -0,0 +1,472 @@
+package config
+
+import (
+	base "core/base"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// 配置值的来源，优先级从低到高
+const (
+	SOURCE_DEFAULT = "default" // 默认值
+	SOURCE_FILE    = "file"    // 配置文件
+	SOURCE_ENV     = "env"     // 环境变量
+	SOURCE_FLAG    = "flag"    // 命令行参数
+)
+
+// 环境变量的前缀。配置项channel.request对应的环境变量为MKCRAWLER_CHANNEL_REQUEST
+const ENV_PREFIX = "MKCRAWLER_"
+
+// 指定配置文件路径的命令行参数名，对应的环境变量为MKCRAWLER_CONFIG
+const CONFIG_FLAG = "config"
+
+// 配置项的定义
+type settingDefinition struct {
+	key          string             // 配置项的名称
+	defaultValue string             // 默认值
+	usage        string             // 说明
+	check        func(string) error // 检查值的函数，为nil时不检查
+}
+
+// 所有配置项的定义
+var settingDefinitions = []settingDefinition{
+	{"websites", "config/website.xml", "站点配置文件的路径", nil},
+	{"plugins", "", "Go插件（.so）所在的目录，为空时不加载插件", nil},
+	{"cookies", "", "保存各站点Cookie的目录，为空时Cookie只保存在内存中", nil},
+	{"hosts", "", "主机覆盖表，形如host=ip[:port]，多项以逗号分隔，优先于站点配置文件中的<resolver>", checkHosts},
+	{"channel.request", fmt.Sprint(DEFAULT_REQUEST_CHANNEL_LENGTH), "请求通道的长度", checkPositive},
+	{"channel.response", fmt.Sprint(DEFAULT_RESPONSE_CHANNEL_LENGTH), "响应通道的长度", checkPositive},
+	{"channel.item", fmt.Sprint(DEFAULT_ITEM_CHANNEL_LENGTH), "条目通道的长度", checkPositive},
+	{"channel.error", fmt.Sprint(DEFAULT_ERROR_CHANNEL_LENGTH), "错误通道的长度", checkPositive},
+	{"pool.downloader", fmt.Sprint(DEFAULT_PAGE_DOWNLOADER_POOL_SIZE), "网页下载器池的尺寸", checkPositive},
+	{"pool.analyzer", fmt.Sprint(DEFAULT_ANALYZER_POOL_SIZE), "分析器池的尺寸", checkPositive},
+}
+
+// 检查值是否为正整数
+func checkPositive(value string) error {
+	number, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || number == 0 {
+		return errors.New(fmt.Sprintf("【%s】不是有效的正整数！", value))
+	}
+
+	return nil
+}
+
+// 检查主机覆盖表
+func checkHosts(value string) error {
+	_, err := parseHosts(value)
+	return err
+}
+
+// 查找配置项的定义
+func findSetting(key string) (*settingDefinition, bool) {
+	for i := range settingDefinitions {
+		if settingDefinitions[i].key == key {
+			return &settingDefinitions[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// 获取配置项对应的环境变量名
+func settingEnvName(key string) string {
+	return ENV_PREFIX + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+// 配置值
+type SettingValue struct {
+	Key    string // 配置项的名称
+	Value  string // 值
+	Source string // 来源，见SOURCE_*常量
+	Origin string // 具体出处，如配置文件的路径、环境变量名或命令行参数名
+}
+
+// 分层配置。
+// 每个配置项的值依次由默认值、配置文件（XML、JSON或YAML）、MKCRAWLER_*环境变量和命令行参数决定，
+// 后者覆盖前者，并记录每个值的来源
+type Settings struct {
+	values      map[string]*SettingValue // 配置项的名称与值的映射
+	flags       map[string]*string       // 配置项的名称与命令行参数值的映射
+	configPath  *string                  // 命令行参数中的配置文件路径
+	description string                   // 描述
+}
+
+// 创建只包含默认值的分层配置
+func NewSettings() *Settings {
+	settings := &Settings{values: make(map[string]*SettingValue)}
+	for _, definition := range settingDefinitions {
+		settings.values[definition.key] = &SettingValue{
+			Key:    definition.key,
+			Value:  definition.defaultValue,
+			Source: SOURCE_DEFAULT,
+		}
+	}
+
+	return settings
+}
+
+// 从默认值、配置文件、环境变量和命令行参数解析分层配置。
+// 参数flags中必须已经通过RegisterFlags注册了命令行参数并完成了解析；
+// 配置文件的路径由命令行参数-config或环境变量MKCRAWLER_CONFIG指定，都没有指定时不读取配置文件
+func ResolveSettings(settings *Settings, flags *flag.FlagSet, environ []string) error {
+	configPath := lookupEnv(environ, ENV_PREFIX+strings.ToUpper(CONFIG_FLAG))
+	if settings.configPath != nil && *settings.configPath != "" {
+		configPath = *settings.configPath
+	}
+
+	if configPath != "" {
+		if err := settings.LoadFile(configPath); err != nil {
+			return err
+		}
+	}
+
+	if err := settings.LoadEnv(environ); err != nil {
+		return err
+	}
+
+	if flags != nil {
+		if err := settings.ApplyFlags(flags); err != nil {
+			return err
+		}
+	}
+
+	return settings.Check()
+}
+
+func (settings *Settings) Check() error {
+	for _, definition := range settingDefinitions {
+		if definition.check == nil {
+			continue
+		}
+
+		value := settings.values[definition.key]
+		if err := definition.check(value.Value); err != nil {
+			return errors.New(fmt.Sprintf("配置项【%s】（来自%s）无效: %s", definition.key, value.describeSource(), err))
+		}
+	}
+
+	return nil
+}
+
+func (settings *Settings) String() string {
+	if settings.description == "" {
+		pairs := make([]string, 0, len(settingDefinitions))
+		for _, value := range settings.Values() {
+			pairs = append(pairs, fmt.Sprintf("%s: %s", value.Key, value.Value))
+		}
+		settings.description = "{ " + strings.Join(pairs, ", ") + " }"
+	}
+
+	return settings.description
+}
+
+// 设置配置项的值
+func (settings *Settings) set(key string, value string, source string, origin string) error {
+	definition, ok := findSetting(key)
+	if !ok {
+		return errors.New(fmt.Sprintf("未知的配置项【%s】（来自%s %s）！", key, source, origin))
+	}
+
+	settings.values[definition.key] = &SettingValue{
+		Key:    definition.key,
+		Value:  value,
+		Source: source,
+		Origin: origin,
+	}
+	settings.description = ""
+
+	return nil
+}
+
+// 读取配置文件。文件格式由扩展名决定：.xml、.json、.yaml或.yml。
+// 嵌套的元素（或对象）以点号连接成配置项的名称，例如channel.request；
+// XML中的属性与子元素等价，根元素的名称不计入配置项的名称，<site>元素会被忽略，
+// 因此站点配置文件本身也可以作为配置文件
+func (settings *Settings) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var values map[string]string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xml":
+		values, err = flattenXML(data)
+	case ".json":
+		values, err = flattenJSON(data)
+	case ".yaml", ".yml":
+		values, err = flattenYAML(data)
+	default:
+		return errors.New(fmt.Sprintf("不支持的配置文件格式【%s】！", path))
+	}
+
+	if err != nil {
+		return errors.New(fmt.Sprintf("无法解析配置文件【%s】: %s", path, err))
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := settings.set(key, values[key], SOURCE_FILE, path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 读取MKCRAWLER_*环境变量。参数environ的格式与os.Environ()的结果相同
+func (settings *Settings) LoadEnv(environ []string) error {
+	for _, definition := range settingDefinitions {
+		name := settingEnvName(definition.key)
+		if value, ok := lookupEnvOk(environ, name); ok {
+			if err := settings.set(definition.key, value, SOURCE_ENV, name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// 在命令行参数集中注册所有配置项以及-config参数
+func (settings *Settings) RegisterFlags(flags *flag.FlagSet) {
+	settings.flags = make(map[string]*string)
+	for _, definition := range settingDefinitions {
+		usage := fmt.Sprintf("%s（环境变量%s，默认为%q）", definition.usage, settingEnvName(definition.key), definition.defaultValue)
+		settings.flags[definition.key] = flags.String(definition.key, "", usage)
+	}
+
+	settings.configPath = flags.String(CONFIG_FLAG, "",
+		fmt.Sprintf("配置文件的路径，支持XML、JSON和YAML（环境变量%sCONFIG）", ENV_PREFIX))
+}
+
+// 应用命令行中出现过的配置项
+func (settings *Settings) ApplyFlags(flags *flag.FlagSet) error {
+	var err error
+	flags.Visit(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+		if _, ok := settings.flags[f.Name]; ok {
+			err = settings.set(f.Name, f.Value.String(), SOURCE_FLAG, "-"+f.Name)
+		}
+	})
+
+	return err
+}
+
+// 用站点配置中的通道参数和池基本参数覆盖仍为默认值的配置项。
+// 站点配置文件视为配置文件一层，因此不会覆盖来自配置文件、环境变量和命令行参数的值
+func (settings *Settings) ApplyWebsites(websites *Websites, path string) {
+	values := map[string]uint64{
+		"pool.downloader": uint64(websites.Pool.PageDownloaderPoolSize),
+		"pool.analyzer":   uint64(websites.Pool.AnalyzerPoolSize),
+	}
+	for _, length := range websites.Channel.lengths() {
+		if length.value != nil {
+			values[length.key] = uint64(*length.value)
+		}
+	}
+
+	for key, value := range values {
+		if value == 0 || settings.values[key].Source != SOURCE_DEFAULT {
+			continue
+		}
+		settings.set(key, strconv.FormatUint(value, 10), SOURCE_FILE, path)
+	}
+}
+
+// 获取配置项的值
+func (settings *Settings) Get(key string) string {
+	if value, ok := settings.values[key]; ok {
+		return value.Value
+	}
+
+	return ""
+}
+
+// 获取无符号整数配置项的值，无效时返回0
+func (settings *Settings) Uint(key string) uint64 {
+	number, _ := strconv.ParseUint(settings.Get(key), 10, 32)
+	return number
+}
+
+// 按定义的顺序获取所有配置值
+func (settings *Settings) Values() []*SettingValue {
+	values := make([]*SettingValue, 0, len(settingDefinitions))
+	for _, definition := range settingDefinitions {
+		values = append(values, settings.values[definition.key])
+	}
+
+	return values
+}
+
+// 生成通道参数的容器
+func (settings *Settings) ChannelArguments() base.ChannelArguments {
+	return base.NewChannelArguments(
+		uint(settings.Uint("channel.request")),
+		uint(settings.Uint("channel.response")),
+		uint(settings.Uint("channel.item")),
+		uint(settings.Uint("channel.error")))
+}
+
+// 生成池基本参数的容器
+func (settings *Settings) PoolArguments() base.PoolArguments {
+	return base.NewPoolArguments(
+		uint32(settings.Uint("pool.downloader")),
+		uint32(settings.Uint("pool.analyzer")))
+}
+
+// 输出生效的配置以及每个值的来源
+func (settings *Settings) Print(writer io.Writer) error {
+	table := tabwriter.NewWriter(writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "KEY\tVALUE\tSOURCE")
+	for _, value := range settings.Values() {
+		fmt.Fprintf(table, "%s\t%s\t%s\n", value.Key, value.Value, value.describeSource())
+	}
+
+	return table.Flush()
+}
+
+// 获取来源的描述，例如env MKCRAWLER_POOL_ANALYZER
+func (value *SettingValue) describeSource() string {
+	if value.Origin == "" {
+		return value.Source
+	}
+
+	return value.Source + " " + value.Origin
+}
+
+// 查找环境变量的值
+func lookupEnv(environ []string, name string) string {
+	value, _ := lookupEnvOk(environ, name)
+	return value
+}
+
+// 查找环境变量的值，并返回该环境变量是否存在。同名的环境变量以最后一个为准
+func lookupEnvOk(environ []string, name string) (string, bool) {
+	value, found := "", false
+	for _, entry := range environ {
+		if strings.HasPrefix(entry, name+"=") {
+			value, found = entry[len(name)+1:], true
+		}
+	}
+
+	return value, found
+}
+
+// 站点配置文件中不属于配置项的顶层元素，展开时跳过
+var siteElements = map[string]bool{"site": true, "resolver": true, "transports": true}
+
+// 把XML文档展开为配置项
+func flattenXML(data []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+
+	var path []string
+	var text strings.Builder
+	skip := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			if skip > 0 || (len(path) == 1 && siteElements[element.Name.Local]) {
+				skip++
+				continue
+			}
+
+			path = append(path, element.Name.Local)
+			text.Reset()
+
+			prefix := strings.Join(path[1:], ".")
+			for _, attr := range element.Attr {
+				key := attr.Name.Local
+				if prefix != "" {
+					key = prefix + "." + key
+				}
+				values[key] = strings.TrimSpace(attr.Value)
+			}
+		case xml.CharData:
+			if skip == 0 {
+				text.Write(element)
+			}
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+
+			if value := strings.TrimSpace(text.String()); value != "" && len(path) > 1 {
+				values[strings.Join(path[1:], ".")] = value
+			}
+			text.Reset()
+			path = path[:len(path)-1]
+		}
+	}
+
+	return values, nil
+}
+
+// 把JSON文档展开为配置项
+func flattenJSON(data []byte) (map[string]string, error) {
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+
+	var document map[string]interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string)
+	if err := flattenValue("", document, values); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// 把嵌套的映射展开为配置项
+func flattenValue(prefix string, value interface{}, values map[string]string) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, member := range v {
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			if err := flattenValue(key, member, values); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		return errors.New(fmt.Sprintf("配置项【%s】不能是列表！", prefix))
+	case nil:
+		values[prefix] = ""
+	default:
+		values[prefix] = fmt.Sprint(v)
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSettingsPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mkcrawler.yaml")
+	yaml := "# 测试配置\nchannel:\n  request: 20\n  response: \"30\"\npool:\n  downloader: 4 # 下载器\n"
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := NewSettings()
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	settings.RegisterFlags(flags)
+	if err := flags.Parse([]string{"-config", path, "-pool.downloader", "8"}); err != nil {
+		t.Fatal(err)
+	}
+
+	environ := []string{"MKCRAWLER_CHANNEL_RESPONSE=40", "MKCRAWLER_POOL_DOWNLOADER=6"}
+	if err := ResolveSettings(settings, flags, environ); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][2]string{
+		"channel.request":  {"20", SOURCE_FILE},
+		"channel.response": {"40", SOURCE_ENV},
+		"channel.item":     {"10", SOURCE_DEFAULT},
+		"pool.downloader":  {"8", SOURCE_FLAG},
+	}
+	for _, value := range settings.Values() {
+		if want, ok := expected[value.Key]; ok && (value.Value != want[0] || value.Source != want[1]) {
+			t.Errorf("%s: expected %s from %s, got %s from %s", value.Key, want[0], want[1], value.Value, value.Source)
+		}
+	}
+
+	arguments := settings.PoolArguments()
+	if arguments.PageDownloaderPoolSize() != 8 {
+		t.Errorf("unexpected pool arguments: %s", arguments.String())
+	}
+}
+
+func TestSettingsFileFormats(t *testing.T) {
+	files := map[string]string{
+		"settings.json": `{"channel": {"item": 50}, "websites": "sites.xml"}`,
+		"settings.xml":  `<mkcrawler websites="sites.xml"><channel item="50"/></mkcrawler>`,
+		"website.xml":   `<websites><websites>sites.xml</websites><channel><item>50</item></channel><resolver><ttl>1m</ttl></resolver><transports><transport name="api"><timeout>5s</timeout></transport></transports><site><name>x</name></site></websites>`,
+	}
+
+	for name, content := range files {
+		path := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		settings := NewSettings()
+		if err := settings.LoadFile(path); err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+
+		if settings.Get("channel.item") != "50" || settings.Get("websites") != "sites.xml" {
+			t.Errorf("%s: unexpected settings %s", name, settings.String())
+		}
+	}
+}
+
+func TestSettingsUnknownKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.yml")
+	if err := os.WriteFile(path, []byte("pool:\n  downloaders: 3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewSettings().LoadFile(path); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+}
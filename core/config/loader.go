@@ -0,0 +1,349 @@
+package config
+
+import (
+	"bytes"
+	registry "core/registry"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// 配置校验错误，带有出错元素所在的文件和行号
+type ValidationError struct {
+	File    string // 文件名
+	Line    int    // 行号，0表示未知
+	Message string // 错误信息
+}
+
+func (err *ValidationError) Error() string {
+	if err.Line == 0 {
+		return fmt.Sprintf("%s: %s", err.File, err.Message)
+	}
+
+	return fmt.Sprintf("%s:%d: %s", err.File, err.Line, err.Message)
+}
+
+// 配置校验错误的列表
+type ValidationErrors []*ValidationError
+
+func (errs ValidationErrors) Error() string {
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// 加载并校验站点配置文件。参数catalog为nil时使用默认的组件目录。
+// 校验未通过时返回的错误值为ValidationErrors，其中包含所有发现的问题
+func LoadWebsites(path string, catalog MKCatalog) (*Websites, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeWebsites(data, path, catalog)
+}
+
+// 解析并校验站点配置。参数filename只用于错误信息
+func DecodeWebsites(data []byte, filename string, catalog MKCatalog) (*Websites, error) {
+	if catalog == nil {
+		catalog = DefaultCatalog()
+	}
+
+	// 先记录行号，同时可以得到XML语法错误所在的行号
+	lines, err := indexLines(data)
+	if err != nil {
+		return nil, ValidationErrors{{File: filename, Line: syntaxErrorLine(err), Message: err.Error()}}
+	}
+
+	websites, err := ReadWebsites(bytes.NewReader(data))
+	if err != nil {
+		return nil, ValidationErrors{{File: filename, Message: err.Error()}}
+	}
+
+	validator := &validator{filename: filename, lines: lines, catalog: catalog}
+	validator.validate(websites)
+	if len(validator.errors) > 0 {
+		return nil, validator.errors
+	}
+
+	return websites, nil
+}
+
+// 获取XML语法错误的行号
+func syntaxErrorLine(err error) int {
+	if syntaxError, ok := err.(*xml.SyntaxError); ok {
+		return syntaxError.Line
+	}
+
+	return 0
+}
+
+// 记录每个元素所在的行号。
+// 元素的路径形如/websites[0]/site[1]/crawler[0]，方括号中为元素在同名兄弟元素中的序号
+func indexLines(data []byte) (map[string]int, error) {
+	type frame struct {
+		path   string         // 元素的路径
+		counts map[string]int // 各个名称的子元素的数量
+	}
+
+	lines := make(map[string]int)
+	stack := []*frame{{counts: make(map[string]int)}}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	line, offset := 1, int64(0)
+	for {
+		start := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			line += bytes.Count(data[offset:start], []byte("\n"))
+			offset = start
+
+			parent := stack[len(stack)-1]
+			name := element.Name.Local
+			path := elementPath(parent.path, name, parent.counts[name])
+			parent.counts[name]++
+
+			lines[path] = line
+			stack = append(stack, &frame{path: path, counts: make(map[string]int)})
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	return lines, nil
+}
+
+// 生成子元素的路径
+func elementPath(parent string, name string, index int) string {
+	return fmt.Sprintf("%s/%s[%d]", parent, name, index)
+}
+
+// 配置校验器
+type validator struct {
+	filename string           // 文件名
+	lines    map[string]int   // 元素路径与行号的映射
+	catalog  MKCatalog        // 组件目录
+	errors   ValidationErrors // 发现的问题
+}
+
+// 记录问题。元素本身没有行号时（例如以属性形式给出）使用最近的上级元素的行号
+func (v *validator) report(path string, format string, args ...interface{}) {
+	line := 0
+	for path != "" {
+		if l, ok := v.lines[path]; ok {
+			line = l
+			break
+		}
+		path = path[:strings.LastIndex(path, "/")]
+	}
+
+	v.errors = append(v.errors, &ValidationError{
+		File:    v.filename,
+		Line:    line,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// 校验站点配置
+func (v *validator) validate(websites *Websites) {
+	root := elementPath("", "websites", 0)
+
+	if err := websites.Channel.Check(); err != nil {
+		v.report(elementPath(root, "channel", 0), "通道参数无效: %s", err)
+	}
+
+	if err := websites.Pool.Check(); err != nil {
+		v.report(elementPath(root, "pool", 0), "池基本参数无效: %s", err)
+	}
+
+	if websites.Resolver != nil {
+		if err := websites.Resolver.Check(); err != nil {
+			v.report(elementPath(root, "resolver", 0), "域名解析器参数无效: %s", err)
+		}
+	}
+
+	if err := websites.Transports.Check(); err != nil {
+		v.report(elementPath(root, "transports", 0), "传输配置无效: %s", err)
+	}
+
+	if len(websites.Sites) == 0 {
+		v.report(root, "没有配置任何站点！")
+	}
+
+	names := make(map[string]bool)
+	for i := range websites.Sites {
+		site := &websites.Sites[i]
+		path := elementPath(root, "site", i)
+
+		name := strings.TrimSpace(site.Name)
+		if name == "" {
+			v.report(path, "站点[%d]的名称不能为空！", i)
+			name = fmt.Sprintf("#%d", i)
+		} else if names[name] {
+			v.report(elementPath(path, "name", 0), "站点名称重复【%s】！", name)
+		}
+		names[name] = true
+
+		v.validateSite(site, name, path)
+
+		effective := site.EffectiveCrawler()
+		if _, err := websites.Transports.Find(effective.Transport); err != nil {
+			v.report(elementPath(elementPath(path, "crawler", 0), "transport", 0), "站点【%s】: %s！", name, err)
+		}
+	}
+}
+
+// 校验站点
+func (v *validator) validateSite(site *Site, name string, path string) {
+	if err := checkURL(site.URL); err != nil {
+		v.report(elementPath(path, "url", 0), "站点【%s】的URL无效: %s", name, err)
+	}
+
+	if !v.catalog.HasSiteType(site.Type) {
+		v.report(elementPath(path, "type", 0), "站点【%s】的类型代码未知【%d】，已知的类型代码: %s",
+			name, site.Type, strings.Join(knownSiteTypes(), ", "))
+	}
+
+	crawler := &site.Crawler
+	path = elementPath(path, "crawler", 0)
+
+	seedsPath := elementPath(path, "seeds", 0)
+	for i, seed := range crawler.Seeds {
+		if err := checkURL(seed); err != nil {
+			v.report(elementPath(seedsPath, "seed", i), "站点【%s】的种子[%d]无效: %s", name, i, err)
+		}
+	}
+
+	if err := crawler.Scope.Check(); err != nil {
+		v.report(elementPath(path, "scope", 0), "站点【%s】的爬取范围无效: %s", name, err)
+	}
+
+	if crawler.Links != nil {
+		if err := crawler.Links.Check(); err != nil {
+			v.report(elementPath(path, "links", 0), "站点【%s】的链接规则无效: %s", name, err)
+		}
+	}
+
+	itemsPath := elementPath(path, "items", 0)
+	for i := range crawler.Items {
+		if err := crawler.Items[i].Check(); err != nil {
+			v.report(elementPath(itemsPath, "item", i), "站点【%s】的条目规则[%d]无效: %s", name, i, err)
+		}
+	}
+
+	for i := range crawler.Regexes {
+		if err := crawler.Regexes[i].Check(); err != nil {
+			v.report(elementPath(itemsPath, "regex", i), "站点【%s】的正则表达式规则[%d]无效: %s", name, i, err)
+		}
+	}
+
+	for i := range crawler.JSON {
+		if err := crawler.JSON[i].Check(); err != nil {
+			v.report(elementPath(itemsPath, "json", i), "站点【%s】的JSON规则[%d]无效: %s", name, i, err)
+		}
+	}
+
+	for i := range crawler.Pagination {
+		if err := crawler.Pagination[i].Check(); err != nil {
+			v.report(elementPath(path, "pagination", i), "站点【%s】的分页规则[%d]无效: %s", name, i, err)
+		}
+	}
+
+	parsersPath := elementPath(path, "parsers", 0)
+	for i := range crawler.Named {
+		component := &crawler.Named[i]
+		componentPath := elementPath(parsersPath, "parser", i)
+		if err := component.Check(); err != nil {
+			v.report(componentPath, "站点【%s】的解析器[%d]无效: %s", name, i, err)
+			continue
+		}
+
+		if err := v.catalog.CheckParser(component.Name, component.ParamMap()); err != nil {
+			v.report(componentPath, "站点【%s】: %s", name, err)
+		}
+	}
+
+	pipelinePath := elementPath(path, "pipeline", 0)
+	for i := range crawler.Pipeline {
+		component := &crawler.Pipeline[i]
+		componentPath := elementPath(pipelinePath, "stage", i)
+		if err := component.Check(); err != nil {
+			v.report(componentPath, "站点【%s】的条目处理阶段[%d]无效: %s", name, i, err)
+			continue
+		}
+
+		if err := v.catalog.CheckProcessor(component.Name, component.ParamMap()); err != nil {
+			v.report(componentPath, "站点【%s】: %s", name, err)
+		}
+	}
+
+	if component := crawler.Downloader; component != nil {
+		componentPath := elementPath(path, "downloader", 0)
+		if err := component.Check(); err != nil {
+			v.report(componentPath, "站点【%s】的网页下载器无效: %s", name, err)
+		} else if err := v.catalog.CheckDownloader(component.Name, component.ParamMap()); err != nil {
+			v.report(componentPath, "站点【%s】: %s", name, err)
+		}
+	}
+
+	if crawler.Login != nil {
+		if err := crawler.Login.Check(); err != nil {
+			v.report(elementPath(path, "login", 0), "站点【%s】的登录参数无效: %s", name, err)
+		}
+	}
+
+	if crawler.Auth != nil {
+		if err := crawler.Auth.Check(); err != nil {
+			v.report(elementPath(path, "auth", 0), "站点【%s】的认证参数无效: %s", name, err)
+		}
+	}
+
+	effective := site.EffectiveCrawler()
+	if !v.catalog.DownloaderUsesEnv(effective.downloaderName()) {
+		for _, setting := range effective.envSettings() {
+			v.report(elementPath(path, setting[0], 0), "站点【%s】的网页下载器【%s】不支持%s设置！",
+				name, effective.downloaderName(), setting[1])
+		}
+	}
+
+	if !effective.hasParsers() {
+		v.report(path, "站点【%s】没有任何解析器，站点类型%d的模板也没有提供解析器！", name, site.Type)
+	}
+
+	// 默认的http网页下载器不能读取file URL
+	if effective.downloaderName() == registry.DEFAULT_DOWNLOADER {
+		for i, seed := range site.Seeds() {
+			if isFileURL(seed) {
+				v.report(seedPath(path, crawler, i), "站点【%s】的种子【%s】是file URL，需要使用file网页下载器！", name, seed)
+			}
+		}
+	}
+}
+
+// 获取种子所在元素的路径。爬虫定义中没有种子时，唯一的种子来自站点的<url>
+func seedPath(crawlerPath string, crawler *Crawler, index int) string {
+	if len(crawler.Seeds) == 0 {
+		return elementPath(crawlerPath[:strings.LastIndex(crawlerPath, "/")], "url", 0)
+	}
+
+	return elementPath(elementPath(crawlerPath, "seeds", 0), "seed", index)
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+const testWebsites = `<websites>
+	<pool downloader="5"/>
+	<site>
+		<name>blog</name>
+		<url>http://blog.example.com/archives/</url>
+		<type>0</type>
+		<crawler>
+			<items>
+				<item scope="article">
+					<field name="title" selector="h1"/>
+				</item>
+			</items>
+			<parsers>
+				<parser name="readability">
+					<param name="minLength">200</param>
+				</parser>
+			</parsers>
+		</crawler>
+	</site>
+</websites>`
+
+func TestDecodeWebsites(t *testing.T) {
+	websites, err := DecodeWebsites([]byte(testWebsites), "website.xml", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := websites.Pool.Arguments()
+	if pool.PageDownloaderPoolSize() != 5 || pool.AnalyzerPoolSize() != DEFAULT_ANALYZER_POOL_SIZE {
+		t.Errorf("unexpected pool arguments: %s", pool.String())
+	}
+
+	// 条目规则和readability，加上博客归档模板中的链接提取器和分页规则
+	parsers, err := websites.Sites[0].Parsers()
+	if err != nil || len(parsers) != 4 {
+		t.Errorf("expected 4 parsers, got %d (%v)", len(parsers), err)
+	}
+}
+
+func TestValidationErrorLines(t *testing.T) {
+	data := strings.NewReplacer(
+		"<type>0</type>", "<type>99</type>",
+		`<field name="title" selector="h1"/>`, `<field name="title" selector="h1["/>`,
+		`<parser name="readability">`, `<parser name="unknown">`,
+	).Replace(testWebsites)
+
+	_, err := DecodeWebsites([]byte(data), "website.xml", nil)
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("expected validation errors, got %v", err)
+	}
+
+	expected := []string{"website.xml:6:", "website.xml:9:", "website.xml:14:"}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d:\n%s", len(expected), len(errs), errs)
+	}
+
+	for i, prefix := range expected {
+		if !strings.HasPrefix(errs[i].Error(), prefix) {
+			t.Errorf("error %d: expected prefix %q, got %q", i, prefix, errs[i].Error())
+		}
+	}
+}
+
+func TestChannelSettings(t *testing.T) {
+	data := strings.Replace(testWebsites, `<pool downloader="5"/>`, `<channel request="0" item="50"/>`, 1)
+	_, err := DecodeWebsites([]byte(data), "website.xml", nil)
+	errs, ok := err.(ValidationErrors)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	if message := errs[0].Error(); !strings.HasPrefix(message, "website.xml:2:") || !strings.Contains(message, "channel.request") {
+		t.Errorf("the error should name the file, line and key, got %q", message)
+	}
+
+	data = strings.Replace(testWebsites, `<pool downloader="5"/>`, `<channel item="50"/>`, 1)
+	websites, err := DecodeWebsites([]byte(data), "website.xml", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings := NewSettings()
+	settings.ApplyWebsites(websites, "website.xml")
+	arguments := settings.ChannelArguments()
+	if arguments.ItemChannelLength() != 50 || arguments.RequestChannelLength() != DEFAULT_REQUEST_CHANNEL_LENGTH {
+		t.Errorf("unexpected channel arguments: %s", arguments.String())
+	}
+}
+
+func TestSyntaxErrorLine(t *testing.T) {
+	_, err := DecodeWebsites([]byte("<websites>\n<site>\n</websites>"), "website.xml", nil)
+	errs, ok := err.(ValidationErrors)
+	if !ok || len(errs) != 1 || errs[0].Line != 3 {
+		t.Fatalf("expected a syntax error on line 3, got %v", err)
+	}
+}
+
+func TestFileSeeds(t *testing.T) {
+	mirror := strings.Replace(testWebsites, "<crawler>",
+		`<crawler><seeds><seed>file:///data/mirror/index.html</seed></seeds>`, 1)
+
+	_, err := DecodeWebsites([]byte(mirror), "website.xml", nil)
+	errs, ok := err.(ValidationErrors)
+	if !ok || len(errs) != 1 || !strings.Contains(errs[0].Message, "file网页下载器") {
+		t.Fatalf("expected the http downloader to be rejected for file seeds, got %v", err)
+	}
+
+	withFile := strings.Replace(mirror, "</crawler>", `<downloader name="file"/></crawler>`, 1)
+	if _, err := DecodeWebsites([]byte(withFile), "website.xml", nil); err != nil {
+		t.Fatalf("file seeds should be accepted by the file downloader: %v", err)
+	}
+
+	remote := strings.Replace(withFile, "file:///data", "file://fileserver/data", 1)
+	if _, err := DecodeWebsites([]byte(remote), "website.xml", nil); err == nil {
+		t.Error("expected remote file URLs to be rejected")
+	}
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	downloader "core/downloader"
+	registry "core/registry"
+	cookie "core/tool/cookie"
+	dns "core/tool/dns"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// 站点共用的网络环境。
+// 每个站点拥有独立的Cookie容器；指定了Cookie目录时，Cookie容器会被保存到该目录下，
+// 使登录等会话在重新启动之后仍然有效。所有站点共用一个域名解析器
+type Network struct {
+	cookies    cookie.MKCookiejarStore      // Cookie容器仓库，为nil时Cookie只保存在内存中
+	resolver   dns.MKResolver               // 域名解析器，为nil时使用共享的默认解析器
+	transports downloader.TransportProfiles // 站点配置文件中的传输配置
+}
+
+// 创建站点配置的网络环境。
+// 参数cookieDir为保存Cookie文件的目录，为空时不持久化Cookie；
+// 参数hosts为主机覆盖表（见配置项hosts），会覆盖站点配置文件中<resolver>的同名主机
+func (websites *Websites) NewNetwork(cookieDir string, hosts string) (*Network, error) {
+	network := &Network{transports: websites.Transports}
+	if cookieDir != "" {
+		network.cookies = cookie.NewCookiejarStore(cookieDir)
+	}
+
+	overrides, err := parseHosts(hosts)
+	if err != nil {
+		return nil, err
+	}
+
+	if websites.Resolver != nil || len(overrides) > 0 {
+		if network.resolver, err = dns.NewResolver(websites.Resolver); err != nil {
+			return nil, err
+		}
+
+		for _, override := range overrides {
+			if err := network.resolver.SetOverride(override.Host, override.Target); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return network, nil
+}
+
+// 获取域名解析器，没有单独配置时返回nil
+func (network *Network) Resolver() dns.MKResolver {
+	if network == nil {
+		return nil
+	}
+
+	return network.resolver
+}
+
+// 获取站点的Cookie容器
+func (network *Network) Jar(siteName string) (http.CookieJar, error) {
+	if network == nil || network.cookies == nil {
+		return cookie.NewCookiejar(), nil
+	}
+
+	return network.cookies.Jar(siteName)
+}
+
+// 保存所有站点的Cookie。没有指定Cookie目录时什么也不做
+func (network *Network) Save() error {
+	if network == nil || network.cookies == nil {
+		return nil
+	}
+
+	return network.cookies.SaveAll()
+}
+
+// 生成站点的网页下载器环境
+func (network *Network) env(site *Site) (*registry.DownloaderEnv, error) {
+	jar, err := network.Jar(site.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	crawler := site.EffectiveCrawler()
+	env := &registry.DownloaderEnv{
+		Jar:      jar,
+		Login:    crawler.Login,
+		Auth:     crawler.Auth,
+		Resolver: network.Resolver(),
+	}
+
+	var transports downloader.TransportProfiles
+	if network != nil {
+		transports = network.transports
+	}
+	if env.Profile, err = transports.Find(crawler.Transport); err != nil {
+		return nil, err
+	}
+
+	return env, nil
+}
+
+// 解析主机覆盖表，形如“a.com=10.0.0.1,b.com=10.0.0.2:8080”
+func parseHosts(value string) ([]dns.HostOverride, error) {
+	overrides := make([]dns.HostOverride, 0)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, errors.New(fmt.Sprintf("无效的主机覆盖项【%s】，应该形如host=ip[:port]！", entry))
+		}
+
+		overrides = append(overrides, dns.HostOverride{Host: strings.TrimSpace(parts[0]), Target: strings.TrimSpace(parts[1])})
+	}
+
+	arguments := &dns.ResolverArguments{Overrides: overrides}
+	if err := arguments.Check(); err != nil {
+		return nil, err
+	}
+
+	return overrides, nil
+}
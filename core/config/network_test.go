@@ -0,0 +1,222 @@
+package config
+
+import (
+	base "core/base"
+	downloader "core/downloader"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 用站点的网页下载器请求一个URL，返回响应体
+func downloadBody(t *testing.T, site *Site, network *Network, rawURL string) string {
+	pageDownloader, err := site.NewDownloader(network)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	httpRequest, _ := http.NewRequest("GET", rawURL, nil)
+	response, err := pageDownloader.Download(*base.NewRequest(httpRequest, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Response().Body.Close()
+
+	body, _ := io.ReadAll(response.Response().Body)
+	return string(body)
+}
+
+func TestNetworkPersistsCookies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("sid"); err == nil {
+			io.WriteString(w, c.Value)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "s1", Path: "/", MaxAge: 3600})
+	}))
+	defer server.Close()
+
+	websites, err := DecodeWebsites([]byte(testWebsites), "website.xml", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	site := &websites.Sites[0]
+
+	dir := t.TempDir()
+	network, _ := websites.NewNetwork(dir, "")
+	if body := downloadBody(t, site, network, server.URL); body != "" {
+		t.Fatalf("expected no cookie on the first request, got %q", body)
+	}
+	if err := network.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新启动之后仍然带着保存的Cookie
+	restarted, _ := websites.NewNetwork(dir, "")
+	if body := downloadBody(t, site, restarted, server.URL); body != "s1" {
+		t.Errorf("expected the saved cookie after a restart, got %q", body)
+	}
+
+	// 没有Cookie目录时不保存
+	if body := downloadBody(t, site, nil, server.URL); body != "" {
+		t.Errorf("expected an empty in-memory jar, got %q", body)
+	}
+}
+
+func TestSiteLogin(t *testing.T) {
+	login := `<login>
+				<url>http://blog.example.com/session</url>
+				<field name="user" value="alice"/>
+				<success><cookie>sid</cookie></success>
+			</login></crawler>`
+	data := strings.Replace(testWebsites, "</crawler>", login, 1)
+
+	websites, err := DecodeWebsites([]byte(data), "website.xml", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pageDownloader, err := websites.Sites[0].NewDownloader(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pageDownloader.(downloader.MKLoginPageDownloader); !ok {
+		t.Errorf("expected a login downloader, got %T", pageDownloader)
+	}
+
+	// file网页下载器不支持登录
+	withFile := strings.Replace(data, "</crawler>", `<downloader name="file"/></crawler>`, 1)
+	_, err = DecodeWebsites([]byte(withFile), "website.xml", nil)
+	if errs, ok := err.(ValidationErrors); !ok || len(errs) != 1 || !strings.Contains(errs[0].Message, "不支持登录") {
+		t.Errorf("expected the file downloader to be rejected for login, got %v", err)
+	}
+
+	invalid := strings.Replace(data, "<success><cookie>sid</cookie></success>", "", 1)
+	if _, err := DecodeWebsites([]byte(invalid), "website.xml", nil); err == nil {
+		t.Error("expected a login without success conditions to be rejected")
+	}
+}
+
+func TestSiteAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "reader" || password != "pw" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	auth := `<auth type="basic"><username>reader</username><password>pw</password></auth></crawler>`
+	data := strings.Replace(testWebsites, "</crawler>", auth, 1)
+
+	websites, err := DecodeWebsites([]byte(data), "website.xml", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := downloadBody(t, &websites.Sites[0], nil, server.URL); body != "ok" {
+		t.Errorf("expected the request to be authenticated, got %q", body)
+	}
+
+	withFile := strings.Replace(data, "</crawler>", `<downloader name="file"/></crawler>`, 1)
+	_, err = DecodeWebsites([]byte(withFile), "website.xml", nil)
+	if errs, ok := err.(ValidationErrors); !ok || len(errs) != 1 || !strings.Contains(errs[0].Message, "不支持认证") {
+		t.Errorf("expected the file downloader to be rejected for auth, got %v", err)
+	}
+
+	invalid := strings.Replace(data, `type="basic"`, `type="digest"`, 1)
+	if _, err := DecodeWebsites([]byte(invalid), "website.xml", nil); err == nil {
+		t.Error("expected an unknown auth type to be rejected")
+	}
+}
+
+func TestNetworkResolver(t *testing.T) {
+	newServer := func(body string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, body)
+		}))
+	}
+	mirror := newServer("mirror")
+	defer mirror.Close()
+	staging := newServer("staging")
+	defer staging.Close()
+
+	// blog.example.com被覆盖到本地的镜像服务器
+	resolver := `<resolver><ttl>1m</ttl><host host="blog.example.com" target="` + mirror.Listener.Addr().String() + `"/></resolver><site>`
+	data := strings.Replace(testWebsites, "<site>", resolver, 1)
+
+	websites, err := DecodeWebsites([]byte(data), "website.xml", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	site := &websites.Sites[0]
+
+	network, err := websites.NewNetwork("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := downloadBody(t, site, network, "http://blog.example.com/"); body != "mirror" {
+		t.Errorf("expected the resolver override to be used, got %q", body)
+	}
+
+	// 配置项hosts优先于<resolver>
+	network, err = websites.NewNetwork("", "Blog.Example.com="+staging.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := downloadBody(t, site, network, "http://blog.example.com/"); body != "staging" {
+		t.Errorf("expected the hosts setting to win, got %q", body)
+	}
+
+	for _, hosts := range []string{"blog.example.com", "=127.0.0.1", "blog.example.com=localhost"} {
+		if _, err := websites.NewNetwork("", hosts); err == nil {
+			t.Errorf("expected invalid hosts %q to be rejected", hosts)
+		}
+	}
+
+	invalid := strings.Replace(data, "<ttl>1m</ttl>", "<ttl>soon</ttl>", 1)
+	if _, err := DecodeWebsites([]byte(invalid), "website.xml", nil); err == nil {
+		t.Error("expected an invalid resolver TTL to be rejected")
+	}
+}
+
+func TestSiteTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	transports := `<transports>
+		<transport name="slow"><timeout>1m</timeout><maxConnsPerHost>2</maxConnsPerHost></transport>
+	</transports><site>`
+	data := strings.Replace(testWebsites, "<site>", transports, 1)
+	data = strings.Replace(data, "</crawler>", "<transport>slow</transport></crawler>", 1)
+
+	websites, err := DecodeWebsites([]byte(data), "website.xml", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	network, _ := websites.NewNetwork("", "")
+	env, err := network.env(&websites.Sites[0])
+	if err != nil || env.Profile == nil || env.Profile.Name != "slow" || env.Profile.MaxConnsPerHost != 2 {
+		t.Fatalf("expected the slow profile, got %v (%v)", env, err)
+	}
+	if body := downloadBody(t, &websites.Sites[0], network, server.URL); body != "ok" {
+		t.Errorf("expected the download to succeed, got %q", body)
+	}
+
+	cases := map[string]string{
+		"unknown profile":   strings.Replace(data, "<transport>slow</transport>", "<transport>fast</transport>", 1),
+		"invalid profile":   strings.Replace(data, "<timeout>1m</timeout>", "<timeout>soon</timeout>", 1),
+		"file downloader":   strings.Replace(data, "</crawler>", `<downloader name="file"/></crawler>`, 1),
+		"duplicate name":    strings.Replace(data, "</transports>", `<transport name="slow"/></transports>`, 1),
+		"unnamed transport": strings.Replace(data, "</transports>", `<transport/></transports>`, 1),
+	}
+	for name, invalid := range cases {
+		if _, err := DecodeWebsites([]byte(invalid), "website.xml", nil); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// 爬取范围的描述模板
+var scopeTemplate string = "{ max depth: %d, domains: %v, includes: %d, excludes: %d }"
+
+// 爬取范围。
+// 链接的主机必须是允许的域名或其子域名；指定了包含规则时，链接必须至少匹配其中一个；
+// 匹配任何一个排除规则的链接都会被丢弃
+type Scope struct {
+	MaxDepth    uint32   `xml:"maxDepth,attr"` // 最大爬取深度，0表示不限
+	Domains     []string `xml:"domain"`        // 允许的域名，为空时为种子URL的主机
+	Includes    []string `xml:"include"`       // 包含规则（正则表达式，匹配完整URL）
+	Excludes    []string `xml:"exclude"`       // 排除规则（正则表达式，匹配完整URL）
+	description string   // 描述
+}
+
+func (scope *Scope) Check() error {
+	if _, err := compilePatterns(scope.Includes); err != nil {
+		return errors.New(fmt.Sprintf("包含规则无效: %s", err))
+	}
+
+	if _, err := compilePatterns(scope.Excludes); err != nil {
+		return errors.New(fmt.Sprintf("排除规则无效: %s", err))
+	}
+
+	for _, domain := range scope.Domains {
+		if normalizeDomain(domain) == "" {
+			return errors.New("域名不能为空！")
+		}
+	}
+
+	return nil
+}
+
+func (scope *Scope) String() string {
+	if scope.description == "" {
+		scope.description =
+			fmt.Sprintf(scopeTemplate,
+				scope.MaxDepth,
+				scope.Domains,
+				len(scope.Includes),
+				len(scope.Excludes))
+	}
+
+	return scope.description
+}
+
+// 范围过滤器的接口类型
+type MKScopeFilter interface {
+	// 判断给定深度的链接是否在爬取范围之内
+	Allows(link *url.URL, depth uint32) bool
+
+	// 获得最大爬取深度，0表示不限
+	MaxDepth() uint32
+}
+
+// 创建范围过滤器。爬取范围中没有指定域名时，以种子URL的主机作为允许的域名
+func NewScopeFilter(scope *Scope, seeds []string) (MKScopeFilter, error) {
+	if scope == nil {
+		scope = &Scope{}
+	}
+
+	if err := scope.Check(); err != nil {
+		return nil, err
+	}
+
+	filter := &mk_scopeFilter{
+		maxDepth: scope.MaxDepth,
+		domains:  make([]string, 0),
+	}
+
+	for _, domain := range scope.Domains {
+		filter.domains = append(filter.domains, normalizeDomain(domain))
+	}
+
+	if len(filter.domains) == 0 {
+		for _, seed := range seeds {
+			seedURL, err := url.Parse(strings.TrimSpace(seed))
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("种子URL无效【%s】: %s", seed, err))
+			}
+			if host := normalizeDomain(seedURL.Hostname()); host != "" {
+				filter.domains = append(filter.domains, host)
+			}
+		}
+	}
+
+	filter.includes, _ = compilePatterns(scope.Includes)
+	filter.excludes, _ = compilePatterns(scope.Excludes)
+
+	return filter, nil
+}
+
+type mk_scopeFilter struct {
+	maxDepth uint32           // 最大爬取深度
+	domains  []string         // 允许的域名
+	includes []*regexp.Regexp // 包含规则
+	excludes []*regexp.Regexp // 排除规则
+}
+
+func (filter *mk_scopeFilter) Allows(link *url.URL, depth uint32) bool {
+	if link == nil {
+		return false
+	}
+
+	if filter.maxDepth > 0 && depth > filter.maxDepth {
+		return false
+	}
+
+	if len(filter.domains) > 0 {
+		host := normalizeDomain(link.Hostname())
+		allowed := false
+		for _, domain := range filter.domains {
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+
+	linkText := link.String()
+
+	if len(filter.includes) > 0 {
+		included := false
+		for _, pattern := range filter.includes {
+			if pattern.MatchString(linkText) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+
+	for _, pattern := range filter.excludes {
+		if pattern.MatchString(linkText) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (filter *mk_scopeFilter) MaxDepth() uint32 {
+	return filter.maxDepth
+}
+
+// 编译正则表达式列表
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(strings.TrimSpace(pattern))
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+
+	return compiled, nil
+}
+
+// 规范化域名：去掉两端的空白和点号，并转换为小写
+func normalizeDomain(domain string) string {
+	return strings.ToLower(strings.Trim(strings.TrimSpace(domain), "."))
+}
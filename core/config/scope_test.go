@@ -0,0 +1,68 @@
+package config
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestScopeFilter(t *testing.T) {
+	seeds := []string{"http://blog.devtang.com/"}
+
+	cases := []struct {
+		name    string
+		scope   *Scope
+		link    string
+		depth   uint32
+		allowed bool
+	}{
+		{"seed host", nil, "http://blog.devtang.com/2014/01/01/first/", 5, true},
+		{"seed subdomain", nil, "http://img.blog.devtang.com/a.png", 0, true},
+		{"other host", nil, "http://other.com/", 0, false},
+		{"suffix is not a subdomain", nil, "http://notblog.devtang.com/", 0, false},
+		{"within max depth", &Scope{MaxDepth: 2}, "http://blog.devtang.com/p/1", 2, true},
+		{"beyond max depth", &Scope{MaxDepth: 2}, "http://blog.devtang.com/p/1", 3, false},
+		{"declared domain", &Scope{Domains: []string{" .DevTang.com. "}}, "http://www.devtang.com/", 0, true},
+		{"declared domain replaces seeds", &Scope{Domains: []string{"other.com"}}, "http://blog.devtang.com/", 0, false},
+		{"include matches", &Scope{Includes: []string{`/\d{4}/\d{2}/`, `/archives/`}}, "http://blog.devtang.com/archives/2", 0, true},
+		{"include does not match", &Scope{Includes: []string{`/\d{4}/\d{2}/`}}, "http://blog.devtang.com/about/", 0, false},
+		{"exclude matches", &Scope{Excludes: []string{`\.(png|jpg)$`}}, "http://blog.devtang.com/a.png", 0, false},
+		{"exclude does not match", &Scope{Excludes: []string{`\.(png|jpg)$`}}, "http://blog.devtang.com/a.html", 0, true},
+		{"exclude wins over include", &Scope{Includes: []string{`/2014/`}, Excludes: []string{`/2014/01/`}}, "http://blog.devtang.com/2014/01/01/first/", 0, false},
+		{"include and not excluded", &Scope{Includes: []string{`/2014/`}, Excludes: []string{`/2014/01/`}}, "http://blog.devtang.com/2014/02/01/second/", 0, true},
+		{"patterns match the full url", &Scope{Includes: []string{`^https://`}}, "http://blog.devtang.com/", 0, false},
+		{"query is matched", &Scope{Excludes: []string{`[?&]replytocom=`}}, "http://blog.devtang.com/p/1?replytocom=3", 0, false},
+	}
+
+	for _, c := range cases {
+		filter, err := NewScopeFilter(c.scope, seeds)
+		if err != nil {
+			t.Fatalf("%s: %s", c.name, err)
+		}
+
+		link, _ := url.Parse(c.link)
+		if allowed := filter.Allows(link, c.depth); allowed != c.allowed {
+			t.Errorf("%s: expected %v for %s at depth %d, got %v", c.name, c.allowed, c.link, c.depth, allowed)
+		}
+	}
+}
+
+func TestScopeCheck(t *testing.T) {
+	invalid := []*Scope{
+		{Includes: []string{`(`}},
+		{Excludes: []string{`[a-`}},
+		{Domains: []string{" . "}},
+	}
+
+	for i, scope := range invalid {
+		if err := scope.Check(); err == nil {
+			t.Errorf("[%d] %s: expected an error", i, scope.String())
+		}
+		if _, err := NewScopeFilter(scope, nil); err == nil {
+			t.Errorf("[%d] %s: the filter should not be created", i, scope.String())
+		}
+	}
+
+	if _, err := NewScopeFilter(nil, []string{"http://[::1"}); err == nil {
+		t.Error("expected an error for an invalid seed")
+	}
+}
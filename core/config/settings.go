@@ -0,0 +1,121 @@
+package config
+
+import (
+	base "core/base"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 通道参数的默认值
+const (
+	DEFAULT_REQUEST_CHANNEL_LENGTH  uint = 10 // 请求通道的默认长度
+	DEFAULT_RESPONSE_CHANNEL_LENGTH uint = 10 // 响应通道的默认长度
+	DEFAULT_ITEM_CHANNEL_LENGTH     uint = 10 // 条目通道的默认长度
+	DEFAULT_ERROR_CHANNEL_LENGTH    uint = 10 // 错误通道的默认长度
+)
+
+// 池基本参数的默认值
+const (
+	DEFAULT_PAGE_DOWNLOADER_POOL_SIZE uint32 = 3 // 网页下载器池的默认尺寸
+	DEFAULT_ANALYZER_POOL_SIZE        uint32 = 3 // 分析器池的默认尺寸
+)
+
+// 通道参数的配置。未配置时使用默认值，配置的值不能为0
+type ChannelSettings struct {
+	RequestLength  *uint `xml:"request,attr"`  // 请求通道的长度
+	ResponseLength *uint `xml:"response,attr"` // 响应通道的长度
+	ItemLength     *uint `xml:"item,attr"`     // 条目通道的长度
+	ErrorLength    *uint `xml:"error,attr"`    // 错误通道的长度
+}
+
+func (settings *ChannelSettings) Check() error {
+	// 先检查配置的原始值，否则0会被默认值替换而无法发现
+	for _, length := range settings.lengths() {
+		if length.value != nil && *length.value == 0 {
+			return errors.New(fmt.Sprintf("配置项【%s】（来自站点配置文件）无效: 通道的长度不能为0！", length.key))
+		}
+	}
+
+	arguments := settings.Arguments()
+	return trimError(arguments.Check())
+}
+
+func (settings *ChannelSettings) String() string {
+	arguments := settings.Arguments()
+	return arguments.String()
+}
+
+// 生成通道参数的容器
+func (settings *ChannelSettings) Arguments() base.ChannelArguments {
+	return base.NewChannelArguments(
+		orDefault(settings.RequestLength, DEFAULT_REQUEST_CHANNEL_LENGTH),
+		orDefault(settings.ResponseLength, DEFAULT_RESPONSE_CHANNEL_LENGTH),
+		orDefault(settings.ItemLength, DEFAULT_ITEM_CHANNEL_LENGTH),
+		orDefault(settings.ErrorLength, DEFAULT_ERROR_CHANNEL_LENGTH))
+}
+
+// 通道长度的配置项
+type channelLength struct {
+	key   string // 对应的分层配置项的名称
+	value *uint  // 配置的值，为nil时表示未配置
+}
+
+// 获取各个通道长度的配置项
+func (settings *ChannelSettings) lengths() []channelLength {
+	return []channelLength{
+		{"channel.request", settings.RequestLength},
+		{"channel.response", settings.ResponseLength},
+		{"channel.item", settings.ItemLength},
+		{"channel.error", settings.ErrorLength},
+	}
+}
+
+// 池基本参数的配置。值为0（或未配置）时使用默认值
+type PoolSettings struct {
+	PageDownloaderPoolSize uint32 `xml:"downloader,attr"` // 网页下载器池的尺寸
+	AnalyzerPoolSize       uint32 `xml:"analyzer,attr"`   // 分析器池的尺寸
+}
+
+func (settings *PoolSettings) Check() error {
+	arguments := settings.Arguments()
+	return trimError(arguments.Check())
+}
+
+func (settings *PoolSettings) String() string {
+	arguments := settings.Arguments()
+	return arguments.String()
+}
+
+// 生成池基本参数的容器
+func (settings *PoolSettings) Arguments() base.PoolArguments {
+	pageDownloaderPoolSize := settings.PageDownloaderPoolSize
+	if pageDownloaderPoolSize == 0 {
+		pageDownloaderPoolSize = DEFAULT_PAGE_DOWNLOADER_POOL_SIZE
+	}
+
+	analyzerPoolSize := settings.AnalyzerPoolSize
+	if analyzerPoolSize == 0 {
+		analyzerPoolSize = DEFAULT_ANALYZER_POOL_SIZE
+	}
+
+	return base.NewPoolArguments(pageDownloaderPoolSize, analyzerPoolSize)
+}
+
+// 未配置时返回默认值
+func orDefault(value *uint, defaultValue uint) uint {
+	if value == nil {
+		return defaultValue
+	}
+
+	return *value
+}
+
+// 去掉错误信息末尾的换行符
+func trimError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return errors.New(strings.TrimRight(err.Error(), "\n"))
+}
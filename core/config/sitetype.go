@@ -0,0 +1,248 @@
+package config
+
+import (
+	analyzer "core/analyzer"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 内置站点类型的代码
+const (
+	SITE_TYPE_BLOG_ARCHIVE uint32 = 0 // 博客归档
+	SITE_TYPE_NEWS         uint32 = 1 // 新闻网站
+	SITE_TYPE_JSON_API     uint32 = 2 // JSON API
+	SITE_TYPE_FEED         uint32 = 3 // RSS/Atom订阅源
+)
+
+// 站点类型的描述模板
+var siteTypeTemplate string = "{ code: %d, name: %s, template: %s }"
+
+// 站点类型。
+// 站点类型提供爬虫定义的模板，站点只需要在<crawler>中声明与模板不同的部分
+type SiteType struct {
+	Code        uint32  // 类型代码，对应站点的<type>
+	Name        string  // 类型名称
+	Description string  // 说明
+	Template    Crawler // 爬虫定义的模板
+}
+
+func (siteType *SiteType) Check() error {
+	if strings.TrimSpace(siteType.Name) == "" {
+		return errors.New(fmt.Sprintf("站点类型[%d]的名称不能为空！", siteType.Code))
+	}
+
+	if err := siteType.Template.Check(); err != nil {
+		return errors.New(fmt.Sprintf("站点类型【%s】的模板无效: %s", siteType.Name, err))
+	}
+
+	return nil
+}
+
+func (siteType *SiteType) String() string {
+	return fmt.Sprintf(siteTypeTemplate, siteType.Code, siteType.Name, siteType.Template.String())
+}
+
+// 站点类型的注册表
+var siteTypeRegistry = struct {
+	types  map[uint32]*SiteType // 类型代码与站点类型的映射
+	rwlock sync.RWMutex         // 读写锁
+}{types: make(map[uint32]*SiteType)}
+
+// 注册站点类型。类型代码或名称与已注册的站点类型重复时返回错误
+func RegisterSiteType(siteType *SiteType) error {
+	if siteType == nil {
+		return errors.New("无效的站点类型！")
+	}
+
+	if err := siteType.Check(); err != nil {
+		return err
+	}
+
+	siteTypeRegistry.rwlock.Lock()
+	defer siteTypeRegistry.rwlock.Unlock()
+
+	if existing, ok := siteTypeRegistry.types[siteType.Code]; ok {
+		return errors.New(fmt.Sprintf("站点类型代码%d已被【%s】使用！", siteType.Code, existing.Name))
+	}
+
+	for _, existing := range siteTypeRegistry.types {
+		if existing.Name == siteType.Name {
+			return errors.New(fmt.Sprintf("站点类型名称【%s】已被类型代码%d使用！", siteType.Name, existing.Code))
+		}
+	}
+
+	siteTypeRegistry.types[siteType.Code] = siteType
+	return nil
+}
+
+// 按类型代码查找站点类型
+func LookupSiteType(code uint32) (*SiteType, bool) {
+	siteTypeRegistry.rwlock.RLock()
+	defer siteTypeRegistry.rwlock.RUnlock()
+
+	siteType, ok := siteTypeRegistry.types[code]
+	return siteType, ok
+}
+
+// 按名称查找站点类型
+func LookupSiteTypeByName(name string) (*SiteType, bool) {
+	siteTypeRegistry.rwlock.RLock()
+	defer siteTypeRegistry.rwlock.RUnlock()
+
+	for _, siteType := range siteTypeRegistry.types {
+		if siteType.Name == name {
+			return siteType, true
+		}
+	}
+
+	return nil, false
+}
+
+// 获取所有已注册的站点类型，按类型代码升序排列
+func SiteTypes() []*SiteType {
+	siteTypeRegistry.rwlock.RLock()
+	defer siteTypeRegistry.rwlock.RUnlock()
+
+	siteTypes := make([]*SiteType, 0, len(siteTypeRegistry.types))
+	for _, siteType := range siteTypeRegistry.types {
+		siteTypes = append(siteTypes, siteType)
+	}
+
+	sort.Slice(siteTypes, func(i, j int) bool {
+		return siteTypes[i].Code < siteTypes[j].Code
+	})
+
+	return siteTypes
+}
+
+// 获取已注册站点类型的列表，形如0(blog-archive)
+func knownSiteTypes() []string {
+	result := make([]string, 0)
+	for _, siteType := range SiteTypes() {
+		result = append(result, fmt.Sprintf("%d(%s)", siteType.Code, siteType.Name))
+	}
+
+	return result
+}
+
+// 以模板为基础合并爬虫定义。
+// 站点声明了的部分覆盖模板中的对应部分：
+// 爬取范围按字段覆盖；条目规则、正则规则、JSON规则和按名称引用的解析器作为一个整体覆盖，
+// 以免模板和站点的解析器重复产生条目；其余部分各自覆盖
+func mergeCrawler(template *Crawler, crawler *Crawler) Crawler {
+	merged := *template
+	merged.description = ""
+	merged.Scope.description = ""
+
+	merged.Seeds = crawler.Seeds
+
+	if crawler.Scope.MaxDepth > 0 {
+		merged.Scope.MaxDepth = crawler.Scope.MaxDepth
+	}
+	if len(crawler.Scope.Domains) > 0 {
+		merged.Scope.Domains = crawler.Scope.Domains
+	}
+	if len(crawler.Scope.Includes) > 0 {
+		merged.Scope.Includes = crawler.Scope.Includes
+	}
+	if len(crawler.Scope.Excludes) > 0 {
+		merged.Scope.Excludes = crawler.Scope.Excludes
+	}
+
+	if crawler.Links != nil {
+		merged.Links = crawler.Links
+	}
+
+	if len(crawler.Items) > 0 || len(crawler.Regexes) > 0 || len(crawler.JSON) > 0 || len(crawler.Named) > 0 {
+		merged.Items = crawler.Items
+		merged.Regexes = crawler.Regexes
+		merged.JSON = crawler.JSON
+		merged.Named = crawler.Named
+	}
+
+	if len(crawler.Pagination) > 0 {
+		merged.Pagination = crawler.Pagination
+	}
+
+	if len(crawler.Pipeline) > 0 {
+		merged.Pipeline = crawler.Pipeline
+	}
+	merged.FailFast = merged.FailFast || crawler.FailFast
+
+	if crawler.Downloader != nil {
+		merged.Downloader = crawler.Downloader
+	}
+
+	if crawler.Login != nil {
+		merged.Login = crawler.Login
+	}
+
+	if crawler.Auth != nil {
+		merged.Auth = crawler.Auth
+	}
+
+	if crawler.Transport != "" {
+		merged.Transport = crawler.Transport
+	}
+
+	return merged
+}
+
+// 注册内置的站点类型
+func init() {
+	builtinSiteTypes := []*SiteType{
+		{
+			Code:        SITE_TYPE_BLOG_ARCHIVE,
+			Name:        "blog-archive",
+			Description: "博客归档：从归档页跟随文章链接，用正文提取得到文章",
+			Template: Crawler{
+				Scope:      Scope{MaxDepth: 2},
+				Links:      &analyzer.LinkExtractorArguments{},
+				Named:      []Component{{Name: "readability"}},
+				Pagination: []analyzer.PaginationRule{{Text: "default", MaxPages: 50}},
+			},
+		},
+		{
+			Code:        SITE_TYPE_NEWS,
+			Name:        "news-site",
+			Description: "新闻网站：跟随站内链接，优先使用结构化数据，并用正文提取得到全文",
+			Template: Crawler{
+				Scope: Scope{MaxDepth: 3},
+				Links: &analyzer.LinkExtractorArguments{},
+				Named: []Component{
+					{Name: "structured-data"},
+					{Name: "readability"},
+				},
+			},
+		},
+		{
+			Code:        SITE_TYPE_JSON_API,
+			Name:        "json-api",
+			Description: "JSON API：按JSON规则提取条目并翻页，站点需要声明<items><json>规则",
+			Template: Crawler{
+				Scope: Scope{MaxDepth: 1},
+			},
+		},
+		{
+			Code:        SITE_TYPE_FEED,
+			Name:        "feed",
+			Description: "订阅源：解析RSS/Atom条目，并跟随条目链接用正文提取得到全文",
+			Template: Crawler{
+				Scope: Scope{MaxDepth: 1},
+				Named: []Component{
+					{Name: "feed", Params: []Param{{Name: "followLinks", Value: "true"}}},
+					{Name: "readability"},
+				},
+			},
+		},
+	}
+
+	for _, siteType := range builtinSiteTypes {
+		if err := RegisterSiteType(siteType); err != nil {
+			panic(err)
+		}
+	}
+}
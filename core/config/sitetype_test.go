@@ -0,0 +1,49 @@
+package config
+
+import (
+	analyzer "core/analyzer"
+	"testing"
+)
+
+func TestEffectiveCrawler(t *testing.T) {
+	site := &Site{
+		Name: "news",
+		URL:  "http://news.example.com/",
+		Type: SITE_TYPE_NEWS,
+		Crawler: Crawler{
+			Scope: Scope{Excludes: []string{`/video/`}},
+			Items: []analyzer.ItemRule{{Scope: "article"}},
+		},
+	}
+
+	crawler := site.EffectiveCrawler()
+	if crawler.Scope.MaxDepth != 3 || len(crawler.Scope.Excludes) != 1 {
+		t.Errorf("scope should be merged field by field: %s", crawler.Scope.String())
+	}
+
+	if crawler.Links == nil {
+		t.Error("links should come from the template")
+	}
+
+	if len(crawler.Items) != 1 || len(crawler.Named) != 0 {
+		t.Errorf("extraction rules should replace the template's parsers: %s", crawler.String())
+	}
+
+	if template, _ := LookupSiteType(SITE_TYPE_NEWS); len(template.Template.Named) != 2 {
+		t.Error("merging must not modify the template")
+	}
+}
+
+func TestRegisterSiteType(t *testing.T) {
+	if err := RegisterSiteType(&SiteType{Code: SITE_TYPE_FEED, Name: "another-feed"}); err == nil {
+		t.Error("expected an error for a duplicate code")
+	}
+
+	if err := RegisterSiteType(&SiteType{Code: 1000, Name: "feed"}); err == nil {
+		t.Error("expected an error for a duplicate name")
+	}
+
+	if siteType, ok := LookupSiteTypeByName("json-api"); !ok || siteType.Code != SITE_TYPE_JSON_API {
+		t.Error("json-api should be registered")
+	}
+}
@@ -0,0 +1,466 @@
+package config
+
+import (
+	analyzer "core/analyzer"
+	downloader "core/downloader"
+	itempipeline "core/itempipeline"
+	registry "core/registry"
+	dns "core/tool/dns"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// 站点配置的描述模板
+var websitesTemplate string = "{ channel: %s, pool: %s, sites: %v }"
+
+// 站点的描述模板
+var siteTemplate string = "{ name: %s, url: %s, type: %d, crawler: %s }"
+
+// 爬虫定义的描述模板
+var crawlerTemplate string = "{ seeds: %d, scope: %s, links: %v, items: %d, regexes: %d, json: %d, pagination: %d," +
+	" parsers: %s, pipeline: %s, downloader: %s, login: %v, auth: %v, transport: %s }"
+
+// 站点配置文件（config/website.xml）的根元素
+type Websites struct {
+	XMLName    xml.Name                     `xml:"websites"`
+	Channel    ChannelSettings              `xml:"channel"`              // 通道参数
+	Pool       PoolSettings                 `xml:"pool"`                 // 池基本参数
+	Resolver   *dns.ResolverArguments       `xml:"resolver"`             // 域名解析器参数，为nil时使用共享的默认解析器
+	Transports downloader.TransportProfiles `xml:"transports>transport"` // 传输配置，站点通过<transport>按名称引用
+	Sites      []Site                       `xml:"site"`                 // 站点列表
+}
+
+func (websites *Websites) String() string {
+	names := make([]string, 0, len(websites.Sites))
+	for _, site := range websites.Sites {
+		names = append(names, site.Name)
+	}
+
+	return fmt.Sprintf(websitesTemplate, websites.Channel.String(), websites.Pool.String(), names)
+}
+
+// 站点
+type Site struct {
+	Name        string  `xml:"name"`    // 站点名称
+	URL         string  `xml:"url"`     // 站点的入口URL，没有指定种子时作为唯一的种子
+	Type        uint32  `xml:"type"`    // 站点的类型代码
+	Crawler     Crawler `xml:"crawler"` // 爬虫定义
+	description string  // 描述
+}
+
+func (site *Site) Check() error {
+	if strings.TrimSpace(site.Name) == "" {
+		return errors.New("站点名称不能为空！")
+	}
+
+	if err := checkURL(site.URL); err != nil {
+		return errors.New(fmt.Sprintf("站点【%s】的URL无效: %s", site.Name, err))
+	}
+
+	if err := site.Crawler.Check(); err != nil {
+		return errors.New(fmt.Sprintf("站点【%s】: %s", site.Name, err))
+	}
+
+	return nil
+}
+
+func (site *Site) String() string {
+	if site.description == "" {
+		site.description = fmt.Sprintf(siteTemplate, site.Name, site.URL, site.Type, site.Crawler.String())
+	}
+
+	return site.description
+}
+
+// 获取站点的种子URL。爬虫定义中没有种子时使用站点的入口URL
+func (site *Site) Seeds() []string {
+	seeds := make([]string, 0, len(site.Crawler.Seeds))
+	for _, seed := range site.Crawler.Seeds {
+		if seed = strings.TrimSpace(seed); seed != "" {
+			seeds = append(seeds, seed)
+		}
+	}
+
+	if len(seeds) == 0 {
+		seeds = append(seeds, strings.TrimSpace(site.URL))
+	}
+
+	return seeds
+}
+
+// 获取站点实际使用的爬虫定义，即以站点类型的模板为基础、用站点的爬虫定义覆盖后的结果。
+// 站点类型未注册时直接使用站点的爬虫定义
+func (site *Site) EffectiveCrawler() Crawler {
+	siteType, ok := LookupSiteType(site.Type)
+	if !ok {
+		return site.Crawler
+	}
+
+	return mergeCrawler(&siteType.Template, &site.Crawler)
+}
+
+// 创建站点的范围过滤器，没有指定域名时以种子URL的主机为准
+func (site *Site) ScopeFilter() (MKScopeFilter, error) {
+	crawler := site.EffectiveCrawler()
+	return NewScopeFilter(&crawler.Scope, site.Seeds())
+}
+
+// 创建站点的解析器列表，顺序为：链接提取器、条目规则、正则规则、JSON规则、分页规则、按名称引用的解析器
+func (site *Site) Parsers() ([]analyzer.MKParseResponse, error) {
+	crawler := site.EffectiveCrawler()
+	return crawler.Parsers()
+}
+
+// 在网络环境中创建站点的网页下载器。参数network为nil时Cookie只保存在内存中
+func (site *Site) NewDownloader(network *Network) (downloader.MKPageDownloader, error) {
+	env, err := network.env(site)
+	if err != nil {
+		return nil, err
+	}
+
+	crawler := site.EffectiveCrawler()
+	return crawler.NewDownloader(env)
+}
+
+// 创建站点的条目处理器列表，以及其中的条目输出
+func (site *Site) Processors() ([]itempipeline.MKProcessItem, []itempipeline.MKItemSink, error) {
+	crawler := site.EffectiveCrawler()
+	return crawler.Processors()
+}
+
+// 爬虫定义，对应<site>中的<crawler>元素。
+// 新增站点时只需要在配置文件中描述种子、范围、链接规则、条目规则、分页规则和条目处理流程
+type Crawler struct {
+	Seeds       []string                         `xml:"seeds>seed"`        // 种子URL
+	Scope       Scope                            `xml:"scope"`             // 爬取范围
+	Links       *analyzer.LinkExtractorArguments `xml:"links"`             // 链接提取参数，为nil时不提取链接
+	Items       []analyzer.ItemRule              `xml:"items>item"`        // 条目规则
+	Regexes     []analyzer.RegexRule             `xml:"items>regex"`       // 正则表达式规则
+	JSON        []analyzer.JSONRule              `xml:"items>json"`        // JSON规则
+	Pagination  []analyzer.PaginationRule        `xml:"pagination"`        // 分页规则
+	Named       []Component                      `xml:"parsers>parser"`    // 按名称引用的解析器
+	Pipeline    []Component                      `xml:"pipeline>stage"`    // 条目处理流程的各个阶段
+	FailFast    bool                             `xml:"pipeline>failFast"` // 条目处理流程是否快速失败
+	Downloader  *Component                       `xml:"downloader"`        // 按名称引用的网页下载器，为nil时使用http
+	Login       *downloader.LoginArguments       `xml:"login"`             // 登录参数，为nil时不需要登录
+	Auth        *downloader.AuthArguments        `xml:"auth"`              // 认证参数，为nil时不需要认证
+	Transport   string                           `xml:"transport"`         // 引用的传输配置的名称，为空时使用名为default的配置
+	description string                           // 描述
+}
+
+func (crawler *Crawler) Check() error {
+	for i, seed := range crawler.Seeds {
+		if err := checkURL(seed); err != nil {
+			return errors.New(fmt.Sprintf("种子[%d]无效: %s", i, err))
+		}
+	}
+
+	if err := crawler.Scope.Check(); err != nil {
+		return err
+	}
+
+	if crawler.Links != nil {
+		if err := crawler.Links.Check(); err != nil {
+			return errors.New(fmt.Sprintf("链接规则无效: %s", err))
+		}
+	}
+
+	for i := range crawler.Items {
+		if err := crawler.Items[i].Check(); err != nil {
+			return errors.New(fmt.Sprintf("条目规则[%d]无效: %s", i, err))
+		}
+	}
+
+	for i := range crawler.Regexes {
+		if err := crawler.Regexes[i].Check(); err != nil {
+			return errors.New(fmt.Sprintf("正则表达式规则[%d]无效: %s", i, err))
+		}
+	}
+
+	for i := range crawler.JSON {
+		if err := crawler.JSON[i].Check(); err != nil {
+			return errors.New(fmt.Sprintf("JSON规则[%d]无效: %s", i, err))
+		}
+	}
+
+	for i := range crawler.Pagination {
+		if err := crawler.Pagination[i].Check(); err != nil {
+			return errors.New(fmt.Sprintf("分页规则[%d]无效: %s", i, err))
+		}
+	}
+
+	for i := range crawler.Named {
+		if err := crawler.Named[i].Check(); err != nil {
+			return errors.New(fmt.Sprintf("解析器[%d]无效: %s", i, err))
+		}
+	}
+
+	for i := range crawler.Pipeline {
+		if err := crawler.Pipeline[i].Check(); err != nil {
+			return errors.New(fmt.Sprintf("条目处理阶段[%d]无效: %s", i, err))
+		}
+	}
+
+	if crawler.Downloader != nil {
+		if err := crawler.Downloader.Check(); err != nil {
+			return errors.New(fmt.Sprintf("网页下载器无效: %s", err))
+		}
+	}
+
+	if crawler.Login != nil {
+		if err := crawler.Login.Check(); err != nil {
+			return errors.New(fmt.Sprintf("登录参数无效: %s", err))
+		}
+	}
+
+	if crawler.Auth != nil {
+		if err := crawler.Auth.Check(); err != nil {
+			return errors.New(fmt.Sprintf("认证参数无效: %s", err))
+		}
+	}
+
+	return nil
+}
+
+func (crawler *Crawler) String() string {
+	if crawler.description == "" {
+
+		crawler.description =
+			fmt.Sprintf(crawlerTemplate,
+				len(crawler.Seeds),
+				crawler.Scope.String(),
+				crawler.Links != nil,
+				len(crawler.Items),
+				len(crawler.Regexes),
+				len(crawler.JSON),
+				len(crawler.Pagination),
+				componentNames(crawler.Named),
+				componentNames(crawler.Pipeline),
+				crawler.downloaderName(),
+				crawler.Login != nil,
+				crawler.Auth != nil,
+				crawler.Transport)
+	}
+
+	return crawler.description
+}
+
+// 获取网页下载器的名称
+func (crawler *Crawler) downloaderName() string {
+	if crawler.Downloader == nil {
+		return registry.DEFAULT_DOWNLOADER
+	}
+
+	return crawler.Downloader.Name
+}
+
+// 获取需要网页下载器支持的站点设置：元素名称与设置名称
+func (crawler *Crawler) envSettings() [][2]string {
+	settings := make([][2]string, 0)
+	if crawler.Login != nil {
+		settings = append(settings, [2]string{"login", "登录"})
+	}
+	if crawler.Auth != nil {
+		settings = append(settings, [2]string{"auth", "认证"})
+	}
+	if crawler.Transport != "" && crawler.Transport != downloader.DefaultTransportProfileName {
+		settings = append(settings, [2]string{"transport", "传输配置"})
+	}
+
+	return settings
+}
+
+// 在给定的环境中创建网页下载器
+func (crawler *Crawler) NewDownloader(env *registry.DownloaderEnv) (downloader.MKPageDownloader, error) {
+	if crawler.Downloader == nil {
+		return registry.NewDownloader(registry.DEFAULT_DOWNLOADER, nil, env)
+	}
+
+	return newNamedDownloader(crawler.Downloader, env)
+}
+
+// 创建条目处理流程中的条目处理器，以及其中的条目输出。调用方负责在停止时关闭条目输出
+func (crawler *Crawler) Processors() ([]itempipeline.MKProcessItem, []itempipeline.MKItemSink, error) {
+	processors := make([]itempipeline.MKProcessItem, 0, len(crawler.Pipeline))
+	sinks := make([]itempipeline.MKItemSink, 0)
+	for i := range crawler.Pipeline {
+		processor, sink, err := newNamedStage(&crawler.Pipeline[i])
+		if err != nil {
+			for _, created := range sinks {
+				created.Close()
+			}
+			return nil, nil, err
+		}
+
+		processors = append(processors, processor)
+		if sink != nil {
+			sinks = append(sinks, sink)
+		}
+	}
+
+	return processors, sinks, nil
+}
+
+// 判断爬虫定义中是否声明了解析器
+func (crawler *Crawler) hasParsers() bool {
+	return crawler.Links != nil || len(crawler.Items) > 0 || len(crawler.Regexes) > 0 ||
+		len(crawler.JSON) > 0 || len(crawler.Pagination) > 0 || len(crawler.Named) > 0
+}
+
+// 创建爬虫定义中声明的解析器
+func (crawler *Crawler) Parsers() ([]analyzer.MKParseResponse, error) {
+	parsers := make([]analyzer.MKParseResponse, 0)
+
+	if crawler.Links != nil {
+		parser, err := analyzer.NewLinkExtractor(crawler.Links)
+		if err != nil {
+			return nil, err
+		}
+		parsers = append(parsers, parser)
+	}
+
+	for i := range crawler.Items {
+		parser, err := analyzer.NewItemExtractor(&crawler.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		parsers = append(parsers, parser)
+	}
+
+	for i := range crawler.Regexes {
+		parser, err := analyzer.NewRegexExtractor(&crawler.Regexes[i])
+		if err != nil {
+			return nil, err
+		}
+		parsers = append(parsers, parser)
+	}
+
+	for i := range crawler.JSON {
+		parser, err := analyzer.NewJSONParser(&crawler.JSON[i])
+		if err != nil {
+			return nil, err
+		}
+		parsers = append(parsers, parser)
+	}
+
+	for i := range crawler.Pagination {
+		parser, err := analyzer.NewPaginationFollower(&crawler.Pagination[i])
+		if err != nil {
+			return nil, err
+		}
+		parsers = append(parsers, parser)
+	}
+
+	for i := range crawler.Named {
+		parser, err := newNamedParser(&crawler.Named[i])
+		if err != nil {
+			return nil, err
+		}
+		parsers = append(parsers, parser)
+	}
+
+	return parsers, nil
+}
+
+// 组件引用，用于按名称引用解析器（<parser>）、条目处理器（<stage>）或网页下载器（<downloader>），参数以名称和值的形式给出
+type Component struct {
+	Name   string  `xml:"name,attr"` // 组件名称
+	Params []Param `xml:"param"`     // 参数
+}
+
+func (component *Component) Check() error {
+	if strings.TrimSpace(component.Name) == "" {
+		return errors.New("组件名称不能为空！")
+	}
+
+	names := make(map[string]bool)
+	for _, param := range component.Params {
+		if param.Name == "" {
+			return errors.New(fmt.Sprintf("组件【%s】的参数名称不能为空！", component.Name))
+		}
+
+		if names[param.Name] {
+			return errors.New(fmt.Sprintf("组件【%s】的参数重复【%s】！", component.Name, param.Name))
+		}
+		names[param.Name] = true
+	}
+
+	return nil
+}
+
+// 获取参数的映射
+func (component *Component) ParamMap() map[string]string {
+	params := make(map[string]string, len(component.Params))
+	for _, param := range component.Params {
+		params[param.Name] = strings.TrimSpace(param.Value)
+	}
+
+	return params
+}
+
+// 获取组件名称的列表
+func componentNames(components []Component) []string {
+	names := make([]string, 0, len(components))
+	for _, component := range components {
+		names = append(names, component.Name)
+	}
+
+	return names
+}
+
+// 参数
+type Param struct {
+	Name  string `xml:"name,attr"` // 参数名称
+	Value string `xml:",chardata"` // 参数值
+}
+
+// 读取站点配置
+func ReadWebsites(reader io.Reader) (*Websites, error) {
+	var websites Websites
+	if err := xml.NewDecoder(reader).Decode(&websites); err != nil {
+		return nil, errors.New(fmt.Sprintf("无法解析站点配置: %s", err))
+	}
+
+	return &websites, nil
+}
+
+// 检查URL是否为有效的HTTP(S) URL或本机的file URL（需要使用file网页下载器）
+func checkURL(rawURL string) error {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return errors.New("URL不能为空！")
+	}
+
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	switch parsedURL.Scheme {
+	case "http", "https":
+		if parsedURL.Host == "" {
+			return errors.New("URL中缺少主机！")
+		}
+	case "file":
+		if parsedURL.Host != "" && parsedURL.Host != "localhost" {
+			return errors.New(fmt.Sprintf("不支持访问远程主机上的文件【%s】！", parsedURL.Host))
+		}
+		if parsedURL.Path == "" {
+			return errors.New("URL中缺少文件路径！")
+		}
+	default:
+		return errors.New(fmt.Sprintf("不支持的协议【%s】！", parsedURL.Scheme))
+	}
+
+	return nil
+}
+
+// 判断URL是否为file URL
+func isFileURL(rawURL string) bool {
+	parsedURL, err := url.Parse(strings.TrimSpace(rawURL))
+	return err == nil && parsedURL.Scheme == "file"
+}
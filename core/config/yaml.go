@@ -0,0 +1,124 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 把YAML文档展开为配置项。
+// 只支持配置文件需要的子集：以缩进表示嵌套的映射、标量值（可以带引号）和#注释，不支持列表和多行字符串
+func flattenYAML(data []byte) (map[string]string, error) {
+	type level struct {
+		indent int    // 缩进
+		prefix string // 配置项名称的前缀
+	}
+
+	values := make(map[string]string)
+	stack := []level{{indent: 0}}
+	pendingKey := "" // 等待子映射的键
+	pendingIndent := 0
+
+	for number, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(stripYAMLComment(line), " \t\r")
+		if strings.TrimSpace(line) == "" || line == "---" {
+			continue
+		}
+
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if strings.Contains(line[:indent], "\t") {
+			return nil, errors.New(fmt.Sprintf("第%d行: 不能使用制表符缩进！", number+1))
+		}
+
+		content := strings.TrimSpace(line)
+
+		if strings.HasPrefix(content, "- ") || content == "-" {
+			return nil, errors.New(fmt.Sprintf("第%d行: 不支持列表！", number+1))
+		}
+
+		for len(stack) > 1 && indent < stack[len(stack)-1].indent {
+			stack = stack[:len(stack)-1]
+		}
+
+		if pendingKey != "" {
+			if indent > pendingIndent {
+				stack = append(stack, level{indent: indent, prefix: pendingKey})
+			} else {
+				// 没有子映射的键视为空值
+				values[pendingKey] = ""
+			}
+			pendingKey = ""
+		}
+
+		if indent != stack[len(stack)-1].indent {
+			return nil, errors.New(fmt.Sprintf("第%d行: 缩进不一致！", number+1))
+		}
+
+		colon := strings.Index(content, ":")
+		if colon <= 0 || (colon+1 < len(content) && content[colon+1] != ' ') {
+			return nil, errors.New(fmt.Sprintf("第%d行: 缺少“键: 值”！", number+1))
+		}
+
+		key := unquoteYAML(strings.TrimSpace(content[:colon]))
+		if prefix := stack[len(stack)-1].prefix; prefix != "" {
+			key = prefix + "." + key
+		}
+
+		value := strings.TrimSpace(content[colon+1:])
+		if value == "" {
+			pendingKey, pendingIndent = key, indent
+			continue
+		}
+
+		if strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{") ||
+			strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") {
+			return nil, errors.New(fmt.Sprintf("第%d行: 只支持标量值！", number+1))
+		}
+
+		values[key] = unquoteYAML(value)
+	}
+
+	if pendingKey != "" {
+		values[pendingKey] = ""
+	}
+
+	return values, nil
+}
+
+// 去掉行尾的注释，引号中的#不视为注释
+func stripYAMLComment(line string) string {
+	quote := byte(0)
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+
+	return line
+}
+
+// 去掉标量值两端的引号
+func unquoteYAML(value string) string {
+	if len(value) < 2 {
+		return value
+	}
+
+	switch {
+	case value[0] == '"' && value[len(value)-1] == '"':
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			return unquoted
+		}
+	case value[0] == '\'' && value[len(value)-1] == '\'':
+		return strings.Replace(value[1:len(value)-1], "''", "'", -1)
+	}
+
+	return value
+}
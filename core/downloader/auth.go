@@ -0,0 +1,425 @@
+package downloader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 认证类型常量
+const (
+	AUTH_TYPE_NONE   = ""       // 无认证
+	AUTH_TYPE_BASIC  = "basic"  // HTTP基本认证
+	AUTH_TYPE_BEARER = "bearer" // 固定的Bearer令牌
+	AUTH_TYPE_OAUTH2 = "oauth2" // OAuth2客户端凭证模式
+)
+
+// 令牌在过期前多久就需要刷新。有效期较短的令牌最多提前一半有效期刷新
+var tokenExpiryDelta = 30 * time.Second
+
+// 令牌端点未给出有效期时使用的默认有效期
+var defaultTokenLifetime = time.Hour
+
+// 访问令牌端点的默认超时时间，在HTTP客户端没有设置超时时间时使用
+var defaultTokenTimeout = 30 * time.Second
+
+// 认证参数的描述模板
+var authArgumentsTemplate string = "{ auth type: %s, token url: %s, scopes: %v }"
+
+// 认证参数的容器。
+// 凭证既可以直接给出，也可以通过对应的*Env字段从环境变量中读取
+type AuthArguments struct {
+	Type              string   `xml:"type,attr"`         // 认证类型
+	Username          string   `xml:"username"`          // 用户名（basic）
+	UsernameEnv       string   `xml:"usernameEnv"`       // 存放用户名的环境变量
+	Password          string   `xml:"password"`          // 密码（basic）
+	PasswordEnv       string   `xml:"passwordEnv"`       // 存放密码的环境变量
+	Token             string   `xml:"token"`             // 令牌（bearer）
+	TokenEnv          string   `xml:"tokenEnv"`          // 存放令牌的环境变量
+	TokenURL          string   `xml:"tokenUrl"`          // 令牌端点（oauth2）
+	ClientID          string   `xml:"clientId"`          // 客户端ID（oauth2）
+	ClientIDEnv       string   `xml:"clientIdEnv"`       // 存放客户端ID的环境变量
+	ClientSecret      string   `xml:"clientSecret"`      // 客户端密钥（oauth2）
+	ClientSecretEnv   string   `xml:"clientSecretEnv"`   // 存放客户端密钥的环境变量
+	Scopes            []string `xml:"scope"`             // 申请的权限范围（oauth2）
+	CredentialsInBody bool     `xml:"credentialsInBody"` // 是否在请求体中而不是基本认证头中发送客户端凭证
+	description       string   // 描述
+}
+
+func (arguments *AuthArguments) Check() error {
+	switch strings.ToLower(arguments.Type) {
+	case AUTH_TYPE_NONE:
+		return nil
+
+	case AUTH_TYPE_BASIC:
+		if arguments.Username == "" && arguments.UsernameEnv == "" {
+			return errors.New("基本认证需要指定用户名！\n")
+		}
+
+	case AUTH_TYPE_BEARER:
+		if arguments.Token == "" && arguments.TokenEnv == "" {
+			return errors.New("Bearer认证需要指定令牌！\n")
+		}
+
+	case AUTH_TYPE_OAUTH2:
+		if _, err := url.ParseRequestURI(arguments.TokenURL); err != nil {
+			return errors.New(fmt.Sprintf("无效的令牌端点【url = %s】！\n", arguments.TokenURL))
+		}
+
+		if arguments.ClientID == "" && arguments.ClientIDEnv == "" {
+			return errors.New("OAuth2认证需要指定客户端ID！\n")
+		}
+
+	default:
+		return errors.New(fmt.Sprintf("不支持的认证类型【type = %s】！\n", arguments.Type))
+	}
+
+	return nil
+}
+
+func (arguments *AuthArguments) String() string {
+	if arguments.description == "" {
+		arguments.description =
+			fmt.Sprintf(authArgumentsTemplate,
+				arguments.Type,
+				arguments.TokenURL,
+				arguments.Scopes)
+	}
+
+	return arguments.description
+}
+
+// 为HTTP客户端加上认证。
+// 返回的客户端是参数client的副本，其传输层会自动为请求附加认证信息
+func NewAuthClient(arguments *AuthArguments, client *http.Client) (*http.Client, error) {
+	if client == nil {
+		client = &http.Client{}
+	}
+
+	transport, err := newAuthTransport(arguments, client.Transport, client.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	authClient := *client
+	authClient.Transport = transport
+
+	return &authClient, nil
+}
+
+// 创建带认证的HTTP传输层。
+// 参数transport代表实际发送请求的传输层，为nil时使用http.DefaultTransport
+func NewAuthTransport(arguments *AuthArguments, transport http.RoundTripper) (http.RoundTripper, error) {
+	return newAuthTransport(arguments, transport, 0)
+}
+
+// 创建带认证的HTTP传输层。
+// 参数timeout是访问令牌端点的超时时间，为0时使用默认的超时时间
+func newAuthTransport(arguments *AuthArguments, transport http.RoundTripper, timeout time.Duration) (http.RoundTripper, error) {
+	if arguments == nil {
+		return nil, errors.New("认证参数无效！")
+	}
+
+	if err := arguments.Check(); err != nil {
+		return nil, err
+	}
+
+	// 凭证在创建时一次性读取，未设置的环境变量在这里就会报错，而不是在请求时发送空的凭证
+	credentials, err := loadCredentials(arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	if timeout <= 0 {
+		timeout = defaultTokenTimeout
+	}
+
+	authTransport := &mk_authTransport{
+		arguments:   arguments,
+		credentials: credentials,
+		transport:   transport,
+	}
+
+	if strings.ToLower(arguments.Type) == AUTH_TYPE_OAUTH2 {
+		authTransport.tokenSource = &mk_tokenSource{
+			arguments:   arguments,
+			credentials: credentials,
+			client:      &http.Client{Transport: transport, Timeout: timeout},
+		}
+	}
+
+	return authTransport, nil
+}
+
+// 带认证的HTTP传输层的实现类型
+type mk_authTransport struct {
+	arguments   *AuthArguments    // 认证参数
+	credentials *mk_credentials   // 已读取的凭证
+	transport   http.RoundTripper // 实际发送请求的传输层
+	tokenSource *mk_tokenSource   // OAuth2令牌源
+}
+
+func (transport *mk_authTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	authRequest, token, err := transport.authorize(request)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := transport.transport.RoundTrip(authRequest)
+	if err != nil || transport.tokenSource == nil || response.StatusCode != http.StatusUnauthorized {
+		return response, err
+	}
+
+	// 令牌可能已在服务端失效，作废缓存的令牌后重试一次
+	if request.Body != nil && request.Body != http.NoBody && request.GetBody == nil {
+		return response, nil
+	}
+
+	// 只作废被拒绝的那个令牌：并发的请求同时收到401时，只有第一个会重新获取令牌
+	transport.tokenSource.invalidate(token)
+
+	retryRequest, _, err := transport.authorize(request)
+	if err != nil {
+		return response, nil
+	}
+
+	if err := resetRequestBody(retryRequest); err != nil {
+		return response, nil
+	}
+
+	io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+
+	return transport.transport.RoundTrip(retryRequest)
+}
+
+// 复制请求并附加认证信息。使用OAuth2认证时同时返回所用的令牌
+func (transport *mk_authTransport) authorize(request *http.Request) (*http.Request, *mk_token, error) {
+	credentials := transport.credentials
+	authRequest := request.Clone(request.Context())
+
+	switch strings.ToLower(transport.arguments.Type) {
+	case AUTH_TYPE_BASIC:
+		authRequest.SetBasicAuth(credentials.username, credentials.password)
+
+	case AUTH_TYPE_BEARER:
+		authRequest.Header.Set("Authorization", "Bearer "+credentials.token)
+
+	case AUTH_TYPE_OAUTH2:
+		token, err := transport.tokenSource.token()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		authRequest.Header.Set("Authorization", token.authorization())
+		return authRequest, token, nil
+	}
+
+	return authRequest, nil, nil
+}
+
+// OAuth2令牌
+type mk_token struct {
+	AccessToken string        `json:"access_token"` // 访问令牌
+	TokenType   string        `json:"token_type"`   // 令牌类型
+	ExpiresIn   int64         `json:"expires_in"`   // 有效期，单位：秒
+	lifetime    time.Duration // 实际使用的有效期
+	expiry      time.Time     // 过期时间
+}
+
+// 判断令牌是否仍然可用（距过期时间还有足够的余量）
+func (token *mk_token) valid(now time.Time) bool {
+	if token == nil || token.AccessToken == "" {
+		return false
+	}
+
+	delta := tokenExpiryDelta
+	if half := token.lifetime / 2; half < delta {
+		delta = half
+	}
+
+	return now.Add(delta).Before(token.expiry)
+}
+
+// 生成Authorization请求头的值
+func (token *mk_token) authorization() string {
+	tokenType := token.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+
+	return tokenType + " " + token.AccessToken
+}
+
+// OAuth2客户端凭证模式的令牌源。
+// 令牌会被缓存，并在即将过期或被作废后重新获取
+type mk_tokenSource struct {
+	arguments   *AuthArguments  // 认证参数
+	credentials *mk_credentials // 已读取的凭证
+	client      *http.Client    // 访问令牌端点的客户端
+	current     *mk_token       // 当前缓存的令牌
+	mutex       sync.Mutex      // 互斥锁
+}
+
+// 获取可用的令牌
+func (source *mk_tokenSource) token() (*mk_token, error) {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+
+	if source.current.valid(time.Now()) {
+		return source.current, nil
+	}
+
+	token, err := source.fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	source.current = token
+
+	return token, nil
+}
+
+// 作废被拒绝的令牌。若缓存的令牌已经不是它（已被其他请求刷新），则保留缓存的令牌
+func (source *mk_tokenSource) invalidate(rejected *mk_token) {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+
+	if source.current == rejected {
+		source.current = nil
+	}
+}
+
+// 从令牌端点获取新令牌
+func (source *mk_tokenSource) fetch() (*mk_token, error) {
+	arguments := source.arguments
+	clientID := source.credentials.clientID
+	clientSecret := source.credentials.clientSecret
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if len(arguments.Scopes) > 0 {
+		form.Set("scope", strings.Join(arguments.Scopes, " "))
+	}
+
+	if arguments.CredentialsInBody {
+		form.Set("client_id", clientID)
+		form.Set("client_secret", clientSecret)
+	}
+
+	request, err := http.NewRequest("POST", arguments.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	if !arguments.CredentialsInBody {
+		request.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+	}
+
+	logger.Infof("获取OAuth2令牌【url = %s】\n", arguments.TokenURL)
+
+	response, err := source.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		errMsg := fmt.Sprintf("获取OAuth2令牌失败【status = %d】: %s", response.StatusCode, body)
+		return nil, errors.New(errMsg)
+	}
+
+	token := &mk_token{}
+	if err := json.Unmarshal(body, token); err != nil {
+		return nil, errors.New(fmt.Sprintf("无法解析OAuth2令牌: %s", err))
+	}
+
+	if token.AccessToken == "" {
+		return nil, errors.New("令牌端点没有返回access_token！")
+	}
+
+	lifetime := defaultTokenLifetime
+	if token.ExpiresIn > 0 {
+		lifetime = time.Duration(token.ExpiresIn) * time.Second
+	}
+	token.lifetime = lifetime
+	token.expiry = time.Now().Add(lifetime)
+
+	return token, nil
+}
+
+// 从认证参数和环境变量中读取的凭证
+type mk_credentials struct {
+	username     string // 用户名（basic）
+	password     string // 密码（basic）
+	token        string // 令牌（bearer）
+	clientID     string // 客户端ID（oauth2）
+	clientSecret string // 客户端密钥（oauth2）
+}
+
+// 读取认证类型所需的凭证
+func loadCredentials(arguments *AuthArguments) (*mk_credentials, error) {
+	credentials := &mk_credentials{}
+
+	var err error
+	switch strings.ToLower(arguments.Type) {
+	case AUTH_TYPE_BASIC:
+		if credentials.username, err = envValue(arguments.Username, arguments.UsernameEnv); err != nil {
+			return nil, err
+		}
+		if credentials.password, err = envValue(arguments.Password, arguments.PasswordEnv); err != nil {
+			return nil, err
+		}
+
+	case AUTH_TYPE_BEARER:
+		if credentials.token, err = envValue(arguments.Token, arguments.TokenEnv); err != nil {
+			return nil, err
+		}
+
+	case AUTH_TYPE_OAUTH2:
+		if credentials.clientID, err = envValue(arguments.ClientID, arguments.ClientIDEnv); err != nil {
+			return nil, err
+		}
+		if credentials.clientSecret, err = envValue(arguments.ClientSecret, arguments.ClientSecretEnv); err != nil {
+			return nil, err
+		}
+	}
+
+	return credentials, nil
+}
+
+// 获取凭证的值。若指定了环境变量，则优先从环境变量中读取；
+// 环境变量未设置且没有直接给出值时返回错误
+func envValue(value string, env string) (string, error) {
+	if env == "" {
+		return value, nil
+	}
+
+	if envValue, ok := os.LookupEnv(env); ok {
+		return envValue, nil
+	}
+
+	if value == "" {
+		return "", errors.New(fmt.Sprintf("认证所需的环境变量没有设置【env = %s】！\n", env))
+	}
+
+	return value, nil
+}
@@ -0,0 +1,304 @@
+package downloader
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 创建一个本地的令牌端点和受保护的API。
+// 每次获取令牌都会得到一个新令牌，API只接受最新的令牌
+func newOAuth2TestServer(t *testing.T, expiresIn int) (*httptest.Server, *int32) {
+	var issued int32
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "crawler" || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if r.FormValue("grant_type") != "client_credentials" || r.FormValue("scope") != "read" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		n := atomic.AddInt32(&issued, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":%d}`, n, expiresIn)
+	})
+
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		expected := fmt.Sprintf("Bearer token-%d", atomic.LoadInt32(&issued))
+		if r.Header.Get("Authorization") != expected {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		fmt.Fprint(w, "ok")
+	})
+
+	return httptest.NewServer(mux), &issued
+}
+
+func TestOAuth2TokenCached(t *testing.T) {
+	server, issued := newOAuth2TestServer(t, 3600)
+	defer server.Close()
+
+	client, err := NewAuthClient(&AuthArguments{
+		Type:         AUTH_TYPE_OAUTH2,
+		TokenURL:     server.URL + "/token",
+		ClientID:     "crawler",
+		ClientSecret: "s3cret",
+		Scopes:       []string{"read"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("创建客户端失败: %s", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		response, err := client.Get(server.URL + "/api")
+		if err != nil {
+			t.Fatalf("请求失败: %s", err)
+		}
+		response.Body.Close()
+
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("第%d次请求的状态码为%d", i, response.StatusCode)
+		}
+	}
+
+	if n := atomic.LoadInt32(issued); n != 1 {
+		t.Fatalf("令牌应该只获取一次，实际获取了%d次", n)
+	}
+}
+
+func TestOAuth2ShortLivedTokenCached(t *testing.T) {
+	// 有效期不超过刷新余量的令牌也应该被缓存
+	server, issued := newOAuth2TestServer(t, 20)
+	defer server.Close()
+
+	client, _ := NewAuthClient(&AuthArguments{
+		Type:         AUTH_TYPE_OAUTH2,
+		TokenURL:     server.URL + "/token",
+		ClientID:     "crawler",
+		ClientSecret: "s3cret",
+		Scopes:       []string{"read"},
+	}, nil)
+
+	for i := 0; i < 2; i++ {
+		response, err := client.Get(server.URL + "/api")
+		if err != nil {
+			t.Fatalf("请求失败: %s", err)
+		}
+		response.Body.Close()
+	}
+
+	if n := atomic.LoadInt32(issued); n != 1 {
+		t.Fatalf("短有效期的令牌应该被缓存，实际获取了%d次", n)
+	}
+}
+
+func TestTokenValid(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name     string
+		lifetime time.Duration
+		elapsed  time.Duration
+		valid    bool
+	}{
+		{"长有效期", time.Hour, 0, true},
+		{"长有效期即将过期", time.Hour, time.Hour - 20*time.Second, false},
+		{"短有效期", 20 * time.Second, 5 * time.Second, true},
+		{"短有效期过半", 20 * time.Second, 11 * time.Second, false},
+		{"已过期", time.Second, 2 * time.Second, false},
+	}
+
+	for _, c := range cases {
+		token := &mk_token{AccessToken: "token", lifetime: c.lifetime, expiry: now.Add(c.lifetime)}
+		if valid := token.valid(now.Add(c.elapsed)); valid != c.valid {
+			t.Errorf("%s: 期望%v，实际为%v", c.name, c.valid, valid)
+		}
+	}
+}
+
+func TestOAuth2TokenTimeout(t *testing.T) {
+	block := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer server.Close()
+	defer close(block)
+
+	client, _ := NewAuthClient(&AuthArguments{
+		Type:     AUTH_TYPE_OAUTH2,
+		TokenURL: server.URL + "/token",
+		ClientID: "crawler",
+	}, &http.Client{Timeout: 100 * time.Millisecond})
+
+	// 直接获取令牌，以免受到外层客户端超时时间的影响
+	source := client.Transport.(*mk_authTransport).tokenSource
+
+	start := time.Now()
+	if _, err := source.token(); err == nil {
+		t.Fatal("令牌端点没有响应时应该返回错误")
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("访问令牌端点应该受超时时间限制，实际等待了%s", elapsed)
+	}
+}
+
+func TestOAuth2RetryAfterUnauthorized(t *testing.T) {
+	server, issued := newOAuth2TestServer(t, 3600)
+	defer server.Close()
+
+	client, _ := NewAuthClient(&AuthArguments{
+		Type:         AUTH_TYPE_OAUTH2,
+		TokenURL:     server.URL + "/token",
+		ClientID:     "crawler",
+		ClientSecret: "s3cret",
+		Scopes:       []string{"read"},
+	}, nil)
+
+	response, _ := client.Get(server.URL + "/api")
+	response.Body.Close()
+
+	// 模拟令牌在服务端被吊销
+	atomic.AddInt32(issued, 1)
+
+	response, err := client.Get(server.URL + "/api")
+	if err != nil {
+		t.Fatalf("请求失败: %s", err)
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("收到401后应该重新获取令牌并重试，实际状态码为%d", response.StatusCode)
+	}
+}
+
+func TestOAuth2ConcurrentUnauthorized(t *testing.T) {
+	const concurrency = 8
+
+	var issued, rejected int32
+	allRejected := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&issued, 1)
+		fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":3600}`, n)
+	})
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == fmt.Sprintf("Bearer token-%d", atomic.LoadInt32(&issued)) {
+			fmt.Fprint(w, "ok")
+			return
+		}
+
+		// 等到所有并发请求都带着被吊销的令牌到达之后才返回401
+		if atomic.AddInt32(&rejected, 1) == concurrency {
+			close(allRejected)
+		}
+		select {
+		case <-allRejected:
+		case <-time.After(5 * time.Second):
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, _ := NewAuthClient(&AuthArguments{
+		Type:     AUTH_TYPE_OAUTH2,
+		TokenURL: server.URL + "/token",
+		ClientID: "crawler",
+	}, nil)
+
+	response, err := client.Get(server.URL + "/api")
+	if err != nil {
+		t.Fatalf("请求失败: %s", err)
+	}
+	response.Body.Close()
+
+	// 模拟令牌在服务端被吊销
+	atomic.AddInt32(&issued, 1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := client.Get(server.URL + "/api")
+			if err != nil {
+				t.Errorf("请求失败: %s", err)
+				return
+			}
+			response.Body.Close()
+
+			if response.StatusCode != http.StatusOK {
+				t.Errorf("重试之后的状态码为%d", response.StatusCode)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 第一次获取、人为吊销各占一次，并发的401只应该再获取一次
+	if n := atomic.LoadInt32(&issued); n != 3 {
+		t.Fatalf("并发收到401时应该只重新获取一次令牌，实际令牌序号为%d", n)
+	}
+}
+
+func TestBasicAuthFromEnv(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "reader" || password != "from-env" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("MKCRAWLER_TEST_PASSWORD", "from-env")
+
+	client, _ := NewAuthClient(&AuthArguments{
+		Type:        AUTH_TYPE_BASIC,
+		Username:    "reader",
+		PasswordEnv: "MKCRAWLER_TEST_PASSWORD",
+	}, nil)
+
+	response, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("请求失败: %s", err)
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("基本认证失败，状态码为%d", response.StatusCode)
+	}
+}
+
+func TestAuthMissingEnv(t *testing.T) {
+	cases := []struct {
+		arguments *AuthArguments
+		missing   bool
+	}{
+		{&AuthArguments{Type: AUTH_TYPE_BASIC, Username: "reader", PasswordEnv: "MKCRAWLER_TEST_UNSET"}, true},
+		{&AuthArguments{Type: AUTH_TYPE_BEARER, TokenEnv: "MKCRAWLER_TEST_UNSET"}, true},
+		{&AuthArguments{Type: AUTH_TYPE_OAUTH2, TokenURL: "http://localhost/token", ClientIDEnv: "MKCRAWLER_TEST_UNSET"}, true},
+		{&AuthArguments{Type: AUTH_TYPE_BEARER, Token: "fallback", TokenEnv: "MKCRAWLER_TEST_UNSET"}, false},
+	}
+
+	for i, c := range cases {
+		_, err := NewAuthClient(c.arguments, nil)
+		if c.missing && (err == nil || !strings.Contains(err.Error(), "MKCRAWLER_TEST_UNSET")) {
+			t.Errorf("[%d] %s: 应该返回包含环境变量名的错误，实际为%v", i, c.arguments.String(), err)
+		}
+		if !c.missing && err != nil {
+			t.Errorf("[%d] %s: 意外的错误: %s", i, c.arguments.String(), err)
+		}
+	}
+}
@@ -24,24 +24,26 @@ type MKPageDownloader interface {
 	Download(request base.MKRequest) (*base.MKResponse, error) // 根据请求下载网页并返回响应
 }
 
-// 创建网页下载器
-func NewPageDownloader(client *http.Client) MKPageDownload {
+// 创建网页下载器。
+// 参数client通常由站点的传输配置（TransportProfile）创建，并被该站点的所有网页下载器共享。
+// 参数client为nil时使用默认配置的客户端
+func NewPageDownloader(client *http.Client) MKPageDownloader {
 
 	id := generateDownloaderID()
 	if client == nil {
-		client = &http.Client()
+		client = &http.Client{Transport: NewTransport(nil)}
 	}
 
 	return &mk_PageDownloader{
 		id:         id,
-		httpClient: *client,
+		httpClient: client,
 	}
 }
 
 // 网页下载器实现类型
 type mk_PageDownloader struct {
-	id         uint32      // ID
-	httpClient http.Client // HTTP客户端
+	id         uint32       // ID
+	httpClient *http.Client // HTTP客户端
 }
 
 func (downloader *mk_PageDownloader) ID() uint32 {
@@ -0,0 +1,226 @@
+package downloader
+
+import (
+	base "core/base"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 目录请求所对应的默认文件名（与wget等工具镜像站点时的命名方式一致）
+var indexFileName = "index.html"
+
+// 本地文件不存在时合成的响应体
+var notFoundBody = "404 page not found"
+
+// 创建本地文件网页下载器。
+// 该下载器可以处理file://形式的URL，
+// 也可以按照主机名把HTTP(S)请求映射到本地镜像目录上。
+// 参数roots代表主机名与本地根目录之间的映射关系，
+// 例如：blog.devtang.com -> /data/mirror/blog.devtang.com。
+func NewFilePageDownloader(roots map[string]string) MKPageDownloader {
+	innerRoots := make(map[string]string)
+	for host, root := range roots {
+		innerRoots[strings.ToLower(host)] = filepath.Clean(root)
+	}
+
+	return &mk_filePageDownloader{
+		id:    generateDownloaderID(),
+		roots: innerRoots,
+	}
+}
+
+// 本地文件网页下载器实现类型
+type mk_filePageDownloader struct {
+	id    uint32            // ID
+	roots map[string]string // 主机名与本地根目录的映射关系
+}
+
+func (downloader *mk_filePageDownloader) ID() uint32 {
+	return downloader.id
+}
+
+func (downloader *mk_filePageDownloader) Download(request base.MKRequest) (*base.MKResponse, error) {
+	httpRequest := request.Request()
+	if httpRequest == nil || httpRequest.URL == nil {
+		return nil, errors.New("无效的请求！")
+	}
+
+	logger.Infof("读取本地文件【url = %s】\n", httpRequest.URL)
+
+	filePath, root, err := downloader.localPath(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	httpResponse, err := openLocalFile(httpRequest, filePath, root)
+	if err != nil {
+		return nil, err
+	}
+
+	return base.NewResponse(httpResponse, request.Depth()), nil
+}
+
+// 根据请求的URL计算对应的本地文件路径，同时返回文件所在的本地根目录（file URL没有根目录）
+func (downloader *mk_filePageDownloader) localPath(httpRequest *http.Request) (string, string, error) {
+	requestURL := httpRequest.URL
+
+	switch strings.ToLower(requestURL.Scheme) {
+	case "file":
+		if requestURL.Host != "" && requestURL.Host != "localhost" {
+			errMsg := fmt.Sprintf("不支持访问远程主机上的文件【url = %s】\n", requestURL)
+			return "", "", errors.New(errMsg)
+		}
+
+		return filepath.FromSlash(requestURL.Path), "", nil
+
+	case "http", "https":
+		root, ok := downloader.roots[strings.ToLower(requestURL.Hostname())]
+		if !ok {
+			errMsg := fmt.Sprintf("主机【%s】没有对应的本地目录\n", requestURL.Hostname())
+			return "", "", errors.New(errMsg)
+		}
+
+		// 先规范化路径，防止通过“..”访问根目录之外的文件。
+		// 指向根目录之外的符号链接在打开文件时检查
+		cleanPath := path.Clean("/" + requestURL.Path)
+		if strings.HasSuffix(requestURL.Path, "/") {
+			cleanPath = path.Join(cleanPath, indexFileName)
+		}
+
+		return filepath.Join(root, filepath.FromSlash(cleanPath)), root, nil
+
+	default:
+		errMsg := fmt.Sprintf("不支持的URL协议【scheme = %s】\n", requestURL.Scheme)
+		return "", "", errors.New(errMsg)
+	}
+}
+
+// 打开本地文件并合成HTTP响应。
+// 参数root不为空时，文件在解析符号链接之后必须仍位于该目录之内
+func openLocalFile(httpRequest *http.Request, filePath string, root string) (*http.Response, error) {
+	file, info, err := openFile(filePath, root)
+	if os.IsNotExist(err) && filepath.Ext(filePath) == "" {
+		// wget在使用--adjust-extension时会为网页补上.html后缀
+		file, info, err = openFile(filePath+".html", root)
+	}
+
+	if os.IsNotExist(err) {
+		return newLocalResponse(httpRequest, http.StatusNotFound,
+			"text/plain; charset=utf-8", int64(len(notFoundBody)),
+			io.NopCloser(strings.NewReader(notFoundBody))), nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	contentType, err := guessContentType(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	httpResponse := newLocalResponse(httpRequest, http.StatusOK, contentType, info.Size(), file)
+	httpResponse.Header.Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+
+	return httpResponse, nil
+}
+
+// 打开文件。若路径指向一个目录，则打开目录下的默认文件
+func openFile(filePath string, root string) (*os.File, os.FileInfo, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if info.IsDir() {
+		return openFile(filepath.Join(filePath, indexFileName), root)
+	}
+
+	if root != "" {
+		if err := checkWithinRoot(filePath, root); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return file, info, nil
+}
+
+// 检查文件在解析符号链接之后是否仍位于根目录之内，防止镜像中的符号链接指向根目录之外
+func checkWithinRoot(filePath string, root string) error {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+
+	realPath, err := filepath.EvalSymlinks(filePath)
+	if err != nil {
+		return err
+	}
+
+	relative, err := filepath.Rel(realRoot, realPath)
+	if err != nil || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		errMsg := fmt.Sprintf("文件位于本地目录之外【path = %s, root = %s】\n", filePath, root)
+		return errors.New(errMsg)
+	}
+
+	return nil
+}
+
+// 推测文件的内容类型。
+// 优先根据扩展名判断，无法判断时再根据文件开头的内容探测
+func guessContentType(file *os.File) (string, error) {
+	if contentType := mime.TypeByExtension(filepath.Ext(file.Name())); contentType != "" {
+		return contentType, nil
+	}
+
+	buffer := make([]byte, 512)
+	n, err := io.ReadFull(file, buffer)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return http.DetectContentType(buffer[:n]), nil
+}
+
+// 合成HTTP响应
+func newLocalResponse(
+	httpRequest *http.Request,
+	statusCode int,
+	contentType string,
+	contentLength int64,
+	body io.ReadCloser) *http.Response {
+
+	header := make(http.Header)
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Length", strconv.FormatInt(contentLength, 10))
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.0",
+		ProtoMajor:    1,
+		ProtoMinor:    0,
+		Header:        header,
+		Body:          body,
+		ContentLength: contentLength,
+		Request:       httpRequest,
+	}
+}
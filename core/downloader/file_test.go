@@ -0,0 +1,118 @@
+package downloader
+
+import (
+	base "core/base"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 创建一个镜像目录：
+//
+//	mirror/index.html
+//	mirror/posts/index.html
+//	mirror/about.html
+//	secret.txt（镜像目录之外）
+func newMirror(t *testing.T) (string, string) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "mirror")
+
+	files := map[string]string{
+		filepath.Join(root, "index.html"):          "<html>home</html>",
+		filepath.Join(root, "posts", "index.html"): "<html>posts</html>",
+		filepath.Join(root, "about.html"):          "<html>about</html>",
+		filepath.Join(dir, "secret.txt"):           "secret",
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir, root
+}
+
+// 用本地文件网页下载器下载URL，返回状态码和响应体
+func downloadFile(t *testing.T, pageDownloader MKPageDownloader, rawURL string) (int, string, error) {
+	httpRequest, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := pageDownloader.Download(*base.NewRequest(httpRequest, 0))
+	if err != nil {
+		return 0, "", err
+	}
+
+	httpResponse := response.Response()
+	defer httpResponse.Body.Close()
+
+	body, err := io.ReadAll(httpResponse.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return httpResponse.StatusCode, string(body), nil
+}
+
+func TestFilePageDownloaderMirror(t *testing.T) {
+	dir, root := newMirror(t)
+	pageDownloader := NewFilePageDownloader(map[string]string{"Blog.Devtang.com": root})
+
+	cases := []struct {
+		url    string
+		status int
+		body   string
+	}{
+		{"http://blog.devtang.com/", http.StatusOK, "<html>home</html>"},
+		{"http://blog.devtang.com/posts", http.StatusOK, "<html>posts</html>"},
+		{"http://blog.devtang.com/posts/", http.StatusOK, "<html>posts</html>"},
+		{"https://blog.devtang.com/about", http.StatusOK, "<html>about</html>"},
+		{"http://blog.devtang.com/missing.html", http.StatusNotFound, notFoundBody},
+		{"http://blog.devtang.com/../secret.txt", http.StatusNotFound, notFoundBody},
+		{"http://blog.devtang.com/posts/../../secret.txt", http.StatusNotFound, notFoundBody},
+		{"file://" + filepath.ToSlash(filepath.Join(dir, "secret.txt")), http.StatusOK, "secret"},
+	}
+
+	for _, c := range cases {
+		status, body, err := downloadFile(t, pageDownloader, c.url)
+		if err != nil {
+			t.Errorf("%s: 下载失败: %s", c.url, err)
+			continue
+		}
+
+		if status != c.status || body != c.body {
+			t.Errorf("%s: 期望%d %q，实际为%d %q", c.url, c.status, c.body, status, body)
+		}
+	}
+
+	if _, _, err := downloadFile(t, pageDownloader, "http://example.com/"); err == nil {
+		t.Error("没有本地目录的主机应该返回错误")
+	}
+}
+
+func TestFilePageDownloaderSymlinkOutsideRoot(t *testing.T) {
+	dir, root := newMirror(t)
+	if err := os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(root, "leak.txt")); err != nil {
+		t.Skipf("无法创建符号链接: %s", err)
+	}
+	if err := os.Symlink(filepath.Join(root, "about.html"), filepath.Join(root, "alias.html")); err != nil {
+		t.Fatal(err)
+	}
+
+	pageDownloader := NewFilePageDownloader(map[string]string{"blog.devtang.com": root})
+
+	if _, body, err := downloadFile(t, pageDownloader, "http://blog.devtang.com/leak.txt"); err == nil {
+		t.Errorf("指向根目录之外的符号链接应该被拒绝，实际读到了%q", body)
+	}
+
+	// 指向根目录之内的符号链接仍然可以读取
+	if status, body, err := downloadFile(t, pageDownloader, "http://blog.devtang.com/alias"); err != nil || status != http.StatusOK || body != "<html>about</html>" {
+		t.Errorf("根目录之内的符号链接应该可以读取: %d %q %v", status, body, err)
+	}
+}
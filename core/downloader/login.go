@@ -0,0 +1,494 @@
+package downloader
+
+import (
+	"bytes"
+	"code.google.com/p/go.net/html"
+	base "core/base"
+	cookie "core/tool/cookie"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// 登录参数的描述模板
+var loginArgumentsTemplate string = "{ login url: %s, method: %s, form url: %s, fields: %d," +
+	" success cookie: %s, success text: %s }"
+
+// 登录表单中的字段。
+// 字段值可以直接给出，也可以从环境变量中读取，以免把凭证写进配置文件
+type LoginField struct {
+	Name  string `xml:"name,attr"`  // 字段名称
+	Value string `xml:"value,attr"` // 字段值
+	Env   string `xml:"env,attr"`   // 存放字段值的环境变量名
+}
+
+// 登录参数的容器
+type LoginArguments struct {
+	URL           string       `xml:"url"`            // 登录表单的提交地址
+	Method        string       `xml:"method"`         // 提交方法，默认为POST
+	FormURL       string       `xml:"formUrl"`        // 登录表单所在的页面。若不为空，会先获取其中的隐藏字段
+	Fields        []LoginField `xml:"field"`          // 表单字段
+	SuccessCookie string       `xml:"success>cookie"` // 登录成功后应该存在的Cookie名称
+	SuccessText   string       `xml:"success>text"`   // 登录成功后响应中应该包含的文本
+	ExpiredStatus []int        `xml:"expired>status"` // 表示会话已过期的响应状态码，默认为401
+	ExpiredText   string       `xml:"expired>text"`   // 表示会话已过期的响应文本
+	description   string       // 描述
+}
+
+func (arguments *LoginArguments) Check() error {
+	if arguments.URL == "" {
+		return errors.New("登录地址不能为空！\n")
+	}
+
+	for _, rawURL := range []string{arguments.URL, arguments.FormURL} {
+		if rawURL == "" {
+			continue
+		}
+
+		if _, err := url.ParseRequestURI(rawURL); err != nil {
+			return errors.New(fmt.Sprintf("无效的登录地址【url = %s】！\n", rawURL))
+		}
+	}
+
+	method := strings.ToUpper(arguments.Method)
+	if method != "" && method != "GET" && method != "POST" {
+		return errors.New(fmt.Sprintf("不支持的登录方法【method = %s】！\n", arguments.Method))
+	}
+
+	for i, field := range arguments.Fields {
+		if field.Name == "" {
+			return errors.New(fmt.Sprintf("登录字段[%d]的名称不能为空！\n", i))
+		}
+
+		if field.Value != "" && field.Env != "" {
+			return errors.New(fmt.Sprintf("登录字段【%s】不能同时指定值和环境变量！\n", field.Name))
+		}
+	}
+
+	if arguments.SuccessCookie == "" && arguments.SuccessText == "" {
+		return errors.New("必须指定登录成功的检查条件（Cookie或文本）！\n")
+	}
+
+	return nil
+}
+
+func (arguments *LoginArguments) String() string {
+	if arguments.description == "" {
+		arguments.description =
+			fmt.Sprintf(loginArgumentsTemplate,
+				arguments.URL,
+				arguments.method(),
+				arguments.FormURL,
+				len(arguments.Fields),
+				arguments.SuccessCookie,
+				arguments.SuccessText)
+	}
+
+	return arguments.description
+}
+
+// 获取提交方法
+func (arguments *LoginArguments) method() string {
+	if arguments.Method == "" {
+		return "POST"
+	}
+
+	return strings.ToUpper(arguments.Method)
+}
+
+// 登录会话的接口。
+// 同一个站点的多个网页下载器共享一个登录会话
+type MKLoginSession interface {
+	// 执行登录。应该在放入种子请求之前调用
+	Login() error
+
+	// 判断响应是否表示会话已过期
+	Expired(response *http.Response) bool
+
+	// 获取会话所使用的HTTP客户端
+	Client() *http.Client
+
+	// 获取当前的登录代数，每次成功登录都会使其加一
+	Generation() uint64
+
+	// 在登录代数仍为给定值时重新登录。
+	// 若其他下载器已经完成了重新登录，则直接返回
+	Relogin(generation uint64) error
+}
+
+// 创建登录会话。
+// 若客户端没有设置Cookie容器，则会为其创建一个
+func NewLoginSession(arguments *LoginArguments, client *http.Client) (MKLoginSession, error) {
+	if arguments == nil {
+		return nil, errors.New("登录参数无效！")
+	}
+
+	if err := arguments.Check(); err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		client = &http.Client{}
+	}
+
+	if client.Jar == nil {
+		client.Jar = cookie.NewCookiejar()
+	}
+
+	return &mk_loginSession{arguments: arguments, client: client}, nil
+}
+
+// 登录会话的实现类型
+type mk_loginSession struct {
+	arguments  *LoginArguments // 登录参数
+	client     *http.Client    // HTTP客户端
+	generation uint64          // 登录代数
+	mutex      sync.Mutex      // 互斥锁
+}
+
+func (session *mk_loginSession) Login() error {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	return session.login()
+}
+
+func (session *mk_loginSession) Relogin(generation uint64) error {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	if session.generation != generation {
+		return nil
+	}
+
+	logger.Infof("会话已过期，重新登录【url = %s】\n", session.arguments.URL)
+
+	return session.login()
+}
+
+func (session *mk_loginSession) Generation() uint64 {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	return session.generation
+}
+
+func (session *mk_loginSession) Client() *http.Client {
+	return session.client
+}
+
+func (session *mk_loginSession) Expired(response *http.Response) bool {
+	if response == nil {
+		return false
+	}
+
+	arguments := session.arguments
+	expiredStatus := arguments.ExpiredStatus
+	if len(expiredStatus) == 0 {
+		expiredStatus = []int{http.StatusUnauthorized}
+	}
+
+	for _, status := range expiredStatus {
+		if response.StatusCode == status {
+			return true
+		}
+	}
+
+	// 被重定向到了登录页面
+	if response.Request != nil && response.Request.URL != nil {
+		finalURL := stripQuery(response.Request.URL)
+		if finalURL == stripURLQuery(arguments.URL) || finalURL == stripURLQuery(arguments.FormURL) {
+			return true
+		}
+	}
+
+	if arguments.ExpiredText != "" && response.Body != nil {
+		body, err := readAndRestoreBody(response)
+		if err == nil && bytes.Contains(body, []byte(arguments.ExpiredText)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 执行登录，调用方需持有锁
+func (session *mk_loginSession) login() error {
+	arguments := session.arguments
+	form := url.Values{}
+
+	if arguments.FormURL != "" {
+		hiddenFields, err := session.hiddenFields(arguments.FormURL)
+		if err != nil {
+			return err
+		}
+
+		for name, value := range hiddenFields {
+			form.Set(name, value)
+		}
+	}
+
+	for _, field := range arguments.Fields {
+		value := field.Value
+		if field.Env != "" {
+			var ok bool
+			if value, ok = os.LookupEnv(field.Env); !ok {
+				errMsg := fmt.Sprintf("登录字段【%s】所需的环境变量%s未设置！", field.Name, field.Env)
+				return errors.New(errMsg)
+			}
+		}
+
+		form.Set(field.Name, value)
+	}
+
+	var httpRequest *http.Request
+	var err error
+	if arguments.method() == "GET" {
+		// 表单字段并入登录URL中已有的查询参数，同名的参数以表单字段为准
+		loginURL, _ := url.Parse(arguments.URL)
+		query := loginURL.Query()
+		for name, values := range form {
+			query[name] = values
+		}
+		loginURL.RawQuery = query.Encode()
+		httpRequest, err = http.NewRequest("GET", loginURL.String(), nil)
+	} else {
+		httpRequest, err = http.NewRequest("POST", arguments.URL, strings.NewReader(form.Encode()))
+		if err == nil {
+			httpRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	logger.Infof("登录【url = %s】\n", arguments.URL)
+
+	httpResponse, err := session.client.Do(httpRequest)
+	if err != nil {
+		return err
+	}
+	defer httpResponse.Body.Close()
+
+	if err := session.checkSuccess(httpResponse); err != nil {
+		return err
+	}
+
+	session.generation++
+
+	return nil
+}
+
+// 检查登录是否成功
+func (session *mk_loginSession) checkSuccess(httpResponse *http.Response) error {
+	arguments := session.arguments
+
+	if arguments.SuccessCookie != "" {
+		found := false
+		for _, c := range session.client.Jar.Cookies(httpResponse.Request.URL) {
+			if c.Name == arguments.SuccessCookie {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			errMsg := fmt.Sprintf("登录失败：未获得Cookie【%s】（status = %d）", arguments.SuccessCookie, httpResponse.StatusCode)
+			return errors.New(errMsg)
+		}
+	}
+
+	if arguments.SuccessText != "" {
+		body, err := io.ReadAll(httpResponse.Body)
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Contains(body, []byte(arguments.SuccessText)) {
+			errMsg := fmt.Sprintf("登录失败：响应中不包含文本【%s】（status = %d）", arguments.SuccessText, httpResponse.StatusCode)
+			return errors.New(errMsg)
+		}
+	}
+
+	return nil
+}
+
+// 获取登录表单页面中的隐藏字段（例如CSRF令牌）
+func (session *mk_loginSession) hiddenFields(formURL string) (map[string]string, error) {
+	httpResponse, err := session.client.Get(formURL)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResponse.Body.Close()
+
+	fields := make(map[string]string)
+	tokenizer := html.NewTokenizer(httpResponse.Body)
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			if tokenizer.Err() == io.EOF {
+				return fields, nil
+			}
+
+			return nil, tokenizer.Err()
+		}
+
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+
+		token := tokenizer.Token()
+		if token.Data != "input" {
+			continue
+		}
+
+		var name, value, inputType string
+		for _, attr := range token.Attr {
+			switch attr.Key {
+			case "name":
+				name = attr.Val
+			case "value":
+				value = attr.Val
+			case "type":
+				inputType = strings.ToLower(attr.Val)
+			}
+		}
+
+		if inputType == "hidden" && name != "" {
+			fields[name] = value
+		}
+	}
+}
+
+// 需要登录的网页下载器的接口
+type MKLoginPageDownloader interface {
+	MKPageDownloader
+
+	// 获取下载器使用的登录会话，以便在放入种子请求之前登录
+	Session() MKLoginSession
+}
+
+// 创建需要登录的网页下载器。
+// 下载器在会话过期时会重新登录并重试一次请求
+func NewLoginPageDownloader(session MKLoginSession) MKLoginPageDownloader {
+	return &mk_loginPageDownloader{
+		id:      generateDownloaderID(),
+		session: session,
+	}
+}
+
+// 需要登录的网页下载器的实现类型
+type mk_loginPageDownloader struct {
+	id      uint32         // ID
+	session MKLoginSession // 登录会话
+}
+
+func (downloader *mk_loginPageDownloader) ID() uint32 {
+	return downloader.id
+}
+
+func (downloader *mk_loginPageDownloader) Session() MKLoginSession {
+	return downloader.session
+}
+
+func (downloader *mk_loginPageDownloader) Download(request base.MKRequest) (*base.MKResponse, error) {
+	httpRequest := request.Request()
+	logger.Infof("请求【url = %s】\n", httpRequest.URL)
+
+	generation := downloader.session.Generation()
+	if generation == 0 {
+		// 尚未登录过
+		if err := downloader.session.Relogin(generation); err != nil {
+			return nil, err
+		}
+		generation = downloader.session.Generation()
+	}
+
+	// 在发送之前复制请求，因为发送时客户端会把Cookie容器中的Cookie写进请求头
+	retryRequest := httpRequest.Clone(httpRequest.Context())
+
+	httpResponse, err := downloader.session.Client().Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	if !downloader.session.Expired(httpResponse) {
+		return base.NewResponse(httpResponse, request.Depth()), nil
+	}
+
+	httpResponse.Body.Close()
+
+	if err := downloader.session.Relogin(generation); err != nil {
+		return nil, err
+	}
+
+	if err := resetRequestBody(retryRequest); err != nil {
+		return nil, err
+	}
+
+	httpResponse, err = downloader.session.Client().Do(retryRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	return base.NewResponse(httpResponse, request.Depth()), nil
+}
+
+// 为重试的请求重新获取请求体
+func resetRequestBody(httpRequest *http.Request) error {
+	if httpRequest.Body == nil || httpRequest.Body == http.NoBody {
+		return nil
+	}
+
+	if httpRequest.GetBody == nil {
+		errMsg := fmt.Sprintf("无法重试请求【url = %s】：请求体不可重复读取", httpRequest.URL)
+		return errors.New(errMsg)
+	}
+
+	body, err := httpRequest.GetBody()
+	if err != nil {
+		return err
+	}
+
+	httpRequest.Body = body
+
+	return nil
+}
+
+// 读取响应体，并把它替换为可以再次读取的副本
+func readAndRestoreBody(response *http.Response) ([]byte, error) {
+	body, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	response.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, err
+}
+
+// 去掉URL中的查询串和片段
+func stripQuery(u *url.URL) string {
+	stripped := *u
+	stripped.RawQuery = ""
+	stripped.Fragment = ""
+
+	return stripped.String()
+}
+
+// 去掉URL字符串中的查询串和片段
+func stripURLQuery(rawURL string) string {
+	if rawURL == "" {
+		return ""
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	return stripQuery(u)
+}
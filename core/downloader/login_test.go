@@ -0,0 +1,188 @@
+package downloader
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+// 模拟需要登录的站点：
+//
+//	/login    登录表单，包含隐藏的CSRF令牌
+//	/session  登录表单的提交地址
+//	/private  需要登录才能访问的页面，会话无效时返回401
+type loginSite struct {
+	logins  int    // 成功登录的次数
+	session string // 当前有效的会话ID
+	mutex   sync.Mutex
+}
+
+func (site *loginSite) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	site.mutex.Lock()
+	defer site.mutex.Unlock()
+
+	switch r.URL.Path {
+	case "/login":
+		io.WriteString(w, `<form action="/session" method="post">
+			<input type="hidden" name="_csrf" value="csrf-token">
+			<input type="text" name="user">
+			<input type="password" name="password">
+		</form>`)
+	case "/session":
+		if r.PostFormValue("_csrf") != "csrf-token" ||
+			r.PostFormValue("user") != "alice" || r.PostFormValue("password") != "secret" {
+			http.Error(w, "登录失败", http.StatusForbidden)
+			return
+		}
+
+		site.logins++
+		site.session = fmt.Sprintf("s%d", site.logins)
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: site.session, Path: "/"})
+		io.WriteString(w, "欢迎")
+	case "/private":
+		if c, err := r.Cookie("sid"); err != nil || c.Value != site.session {
+			http.Error(w, "请先登录", http.StatusUnauthorized)
+			return
+		}
+		io.WriteString(w, "private")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// 使会话过期
+func (site *loginSite) expire() {
+	site.mutex.Lock()
+	defer site.mutex.Unlock()
+
+	site.session = ""
+}
+
+func (site *loginSite) loginCount() int {
+	site.mutex.Lock()
+	defer site.mutex.Unlock()
+
+	return site.logins
+}
+
+func newLoginArguments(serverURL string) *LoginArguments {
+	return &LoginArguments{
+		URL:     serverURL + "/session",
+		FormURL: serverURL + "/login",
+		Fields: []LoginField{
+			{Name: "user", Value: "alice"},
+			{Name: "password", Env: "MK_TEST_PASSWORD"},
+		},
+		SuccessCookie: "sid",
+	}
+}
+
+func TestLoginPageDownloader(t *testing.T) {
+	site := &loginSite{}
+	server := httptest.NewServer(site)
+	defer server.Close()
+
+	t.Setenv("MK_TEST_PASSWORD", "secret")
+	session, err := NewLoginSession(newLoginArguments(server.URL), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pageDownloader := NewLoginPageDownloader(session)
+
+	// 先登录，隐藏的CSRF令牌要随表单一起提交
+	if err := pageDownloader.Session().Login(); err != nil {
+		t.Fatalf("登录失败: %s", err)
+	}
+	if status, body, err := downloadFile(t, pageDownloader, server.URL+"/private"); err != nil || status != http.StatusOK || body != "private" {
+		t.Fatalf("登录之后应该可以访问: %d %q %v", status, body, err)
+	}
+	if logins := site.loginCount(); logins != 1 {
+		t.Fatalf("应该登录1次，实际为%d次", logins)
+	}
+
+	// 会话过期后重新登录并重试请求
+	site.expire()
+	if status, body, err := downloadFile(t, pageDownloader, server.URL+"/private"); err != nil || status != http.StatusOK || body != "private" {
+		t.Fatalf("会话过期后应该重新登录: %d %q %v", status, body, err)
+	}
+	if logins := site.loginCount(); logins != 2 {
+		t.Fatalf("应该登录2次，实际为%d次", logins)
+	}
+
+	// 多个请求同时发现会话过期时只重新登录一次
+	site.expire()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if status, _, err := downloadFile(t, pageDownloader, server.URL+"/private"); err != nil || status != http.StatusOK {
+				t.Errorf("并发请求失败: %d %v", status, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if logins := site.loginCount(); logins != 3 {
+		t.Errorf("应该只重新登录1次，实际共登录%d次", logins)
+	}
+}
+
+func TestLoginSessionFailure(t *testing.T) {
+	site := &loginSite{}
+	server := httptest.NewServer(site)
+	defer server.Close()
+
+	t.Setenv("MK_TEST_PASSWORD", "secret")
+
+	// 不获取登录表单就拿不到CSRF令牌
+	arguments := newLoginArguments(server.URL)
+	arguments.FormURL = ""
+	session, err := NewLoginSession(arguments, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Login(); err == nil {
+		t.Error("缺少CSRF令牌时登录应该失败")
+	}
+
+	t.Setenv("MK_TEST_PASSWORD", "wrong")
+	session, _ = NewLoginSession(newLoginArguments(server.URL), nil)
+	if err := session.Login(); err == nil {
+		t.Error("密码错误时登录应该失败")
+	}
+
+	if logins := site.loginCount(); logins != 0 {
+		t.Errorf("不应该有成功的登录，实际为%d次", logins)
+	}
+}
+
+func TestLoginSessionGetKeepsQuery(t *testing.T) {
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		io.WriteString(w, "欢迎")
+	}))
+	defer server.Close()
+
+	arguments := &LoginArguments{
+		URL:         server.URL + "/session?next=%2Fprivate&user=bob",
+		Method:      "get",
+		Fields:      []LoginField{{Name: "user", Value: "alice"}, {Name: "token", Value: "t"}},
+		SuccessText: "欢迎",
+	}
+	session, err := NewLoginSession(arguments, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Login(); err != nil {
+		t.Fatal(err)
+	}
+
+	if query.Get("next") != "/private" || query.Get("user") != "alice" || query.Get("token") != "t" || len(query["user"]) != 1 {
+		t.Errorf("表单字段应该并入登录URL已有的查询参数，实际为%v", query)
+	}
+}
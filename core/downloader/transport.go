@@ -0,0 +1,293 @@
+package downloader
+
+import (
+	"context"
+	dns "core/tool/dns"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// 默认传输配置的名称
+var DefaultTransportProfileName = "default"
+
+// TLS最低版本的名称与取值的映射关系
+var tlsVersionMap = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// 传输配置的描述模板
+var transportProfileTemplate string = "{ name: %s, connect timeout: %s, read timeout: %s, timeout: %s," +
+	" max idle conns per host: %d, max conns per host: %d, http2: %v, tls min version: %s," +
+	" ca bundle: %s, client cert: %s, insecure skip verify: %v }"
+
+// 创建使用给定域名解析器的HTTP传输层。
+// 参数resolver为nil时使用共享的默认解析器，
+// 这样所有网页下载器都会共用同一份DNS缓存和主机覆盖表
+func NewTransport(resolver dns.MKResolver) *http.Transport {
+	if resolver == nil {
+		resolver = dns.DefaultResolver()
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = resolver.DialContext
+
+	return transport
+}
+
+// 传输配置。
+// 时间类的字段使用time.ParseDuration可以解析的格式，例如“10s”，为空时表示不限制
+type TransportProfile struct {
+	Name                string `xml:"name,attr"`           // 配置名称
+	ConnectTimeout      string `xml:"connectTimeout"`      // 建立连接的超时时间
+	ReadTimeout         string `xml:"readTimeout"`         // 发出请求后等待响应头的超时时间
+	Timeout             string `xml:"timeout"`             // 整个请求（包括读取响应体）的超时时间
+	IdleConnTimeout     string `xml:"idleConnTimeout"`     // 空闲连接的保留时间
+	MaxIdleConnsPerHost int    `xml:"maxIdleConnsPerHost"` // 每个主机的最大空闲连接数
+	MaxConnsPerHost     int    `xml:"maxConnsPerHost"`     // 每个主机的最大连接数，0表示不限制
+	DisableHTTP2        bool   `xml:"disableHttp2"`        // 是否禁用HTTP/2
+	TLSMinVersion       string `xml:"tlsMinVersion"`       // TLS最低版本，可选1.0、1.1、1.2、1.3
+	CABundle            string `xml:"caBundle"`            // 自定义CA证书文件（PEM）
+	ClientCert          string `xml:"clientCert"`          // 客户端证书文件（PEM）
+	ClientKey           string `xml:"clientKey"`           // 客户端私钥文件（PEM）
+	InsecureSkipVerify  bool   `xml:"insecureSkipVerify"`  // 是否跳过证书校验，仅用于测试环境
+	description         string // 描述
+}
+
+func (profile *TransportProfile) Check() error {
+	durations := map[string]string{
+		"connectTimeout":  profile.ConnectTimeout,
+		"readTimeout":     profile.ReadTimeout,
+		"timeout":         profile.Timeout,
+		"idleConnTimeout": profile.IdleConnTimeout,
+	}
+
+	for name, value := range durations {
+		if _, err := parseDuration(value); err != nil {
+			errMsg := fmt.Sprintf("传输配置【%s】的%s无效【%s】！\n", profile.Name, name, value)
+			return errors.New(errMsg)
+		}
+	}
+
+	if profile.MaxIdleConnsPerHost < 0 || profile.MaxConnsPerHost < 0 {
+		return errors.New(fmt.Sprintf("传输配置【%s】的连接数不能为负数！\n", profile.Name))
+	}
+
+	if profile.TLSMinVersion != "" {
+		if _, ok := tlsVersionMap[profile.TLSMinVersion]; !ok {
+			errMsg := fmt.Sprintf("传输配置【%s】的TLS最低版本无效【%s】！\n", profile.Name, profile.TLSMinVersion)
+			return errors.New(errMsg)
+		}
+	}
+
+	if (profile.ClientCert == "") != (profile.ClientKey == "") {
+		return errors.New(fmt.Sprintf("传输配置【%s】的客户端证书和私钥必须同时指定！\n", profile.Name))
+	}
+
+	return nil
+}
+
+func (profile *TransportProfile) String() string {
+	if profile.description == "" {
+		profile.description =
+			fmt.Sprintf(transportProfileTemplate,
+				profile.Name,
+				profile.ConnectTimeout,
+				profile.ReadTimeout,
+				profile.Timeout,
+				profile.MaxIdleConnsPerHost,
+				profile.MaxConnsPerHost,
+				!profile.DisableHTTP2,
+				profile.TLSMinVersion,
+				profile.CABundle,
+				profile.ClientCert,
+				profile.InsecureSkipVerify)
+	}
+
+	return profile.description
+}
+
+// 复制传输配置，以便在其基础上修改而不影响原配置
+func (profile *TransportProfile) Copy() *TransportProfile {
+	copied := *profile
+	copied.description = ""
+
+	return &copied
+}
+
+// 根据传输配置创建HTTP客户端。
+// 同一个配置创建出的客户端应该被该站点的所有网页下载器共享，以便复用连接。
+// 参数resolver为nil时使用共享的默认解析器
+func (profile *TransportProfile) NewClient(resolver dns.MKResolver) (*http.Client, error) {
+	if err := profile.Check(); err != nil {
+		return nil, err
+	}
+
+	transport, err := profile.newTransport(resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout, _ := parseDuration(profile.Timeout)
+
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+// 根据传输配置创建HTTP传输层
+func (profile *TransportProfile) newTransport(resolver dns.MKResolver) (*http.Transport, error) {
+	transport := NewTransport(resolver)
+	dial := transport.DialContext
+
+	connectTimeout, _ := parseDuration(profile.ConnectTimeout)
+	readTimeout, _ := parseDuration(profile.ReadTimeout)
+	idleConnTimeout, _ := parseDuration(profile.IdleConnTimeout)
+
+	transport.DialContext = func(ctx context.Context, network string, address string) (net.Conn, error) {
+		if connectTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, connectTimeout)
+			defer cancel()
+		}
+
+		return dial(ctx, network, address)
+	}
+
+	if connectTimeout > 0 {
+		transport.TLSHandshakeTimeout = connectTimeout
+	}
+
+	if readTimeout > 0 {
+		transport.ResponseHeaderTimeout = readTimeout
+	}
+
+	if idleConnTimeout > 0 {
+		transport.IdleConnTimeout = idleConnTimeout
+	}
+
+	if profile.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = profile.MaxIdleConnsPerHost
+	}
+
+	transport.MaxConnsPerHost = profile.MaxConnsPerHost
+
+	if profile.DisableHTTP2 {
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+
+	tlsConfig, err := profile.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	return transport, nil
+}
+
+// 根据传输配置生成TLS配置
+func (profile *TransportProfile) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tlsVersionMap[profile.TLSMinVersion],
+		InsecureSkipVerify: profile.InsecureSkipVerify,
+	}
+
+	if profile.CABundle != "" {
+		pem, err := os.ReadFile(profile.CABundle)
+		if err != nil {
+			return nil, err
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			errMsg := fmt.Sprintf("CA证书文件中没有有效的证书【path = %s】", profile.CABundle)
+			return nil, errors.New(errMsg)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if profile.ClientCert != "" {
+		certificate, err := tls.LoadX509KeyPair(profile.ClientCert, profile.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	if profile.InsecureSkipVerify {
+		logger.Warnf("传输配置【%s】跳过了证书校验，请勿在生产环境中使用！\n", profile.Name)
+	}
+
+	return tlsConfig, nil
+}
+
+// 传输配置的集合
+type TransportProfiles []*TransportProfile
+
+// 检查集合中的每一个配置，并确保名称不重复
+func (profiles TransportProfiles) Check() error {
+	names := make(map[string]bool)
+	for _, profile := range profiles {
+		if profile.Name == "" {
+			return errors.New("传输配置的名称不能为空！\n")
+		}
+
+		if names[profile.Name] {
+			return errors.New(fmt.Sprintf("传输配置的名称重复【%s】！\n", profile.Name))
+		}
+		names[profile.Name] = true
+
+		if err := profile.Check(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 按名称查找传输配置。
+// 名称为空时查找默认配置，找不到默认配置时返回一个空配置
+func (profiles TransportProfiles) Find(name string) (*TransportProfile, error) {
+	if name == "" {
+		name = DefaultTransportProfileName
+	}
+
+	for _, profile := range profiles {
+		if profile.Name == name {
+			return profile, nil
+		}
+	}
+
+	if name == DefaultTransportProfileName {
+		return &TransportProfile{Name: DefaultTransportProfileName}, nil
+	}
+
+	return nil, errors.New(fmt.Sprintf("找不到传输配置【%s】", name))
+}
+
+// 解析时间长度，空字符串表示0
+func parseDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err == nil && duration < 0 {
+		err = errors.New("时间长度不能为负数")
+	}
+
+	return duration, err
+}
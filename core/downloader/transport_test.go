@@ -0,0 +1,223 @@
+package downloader
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 把PEM块写入文件
+func writePEM(t *testing.T, path string, blockType string, bytes []byte) {
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: bytes}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 生成客户端证书：先生成一个自签名的CA，再由它签发客户端证书。
+// 返回CA证书，以及客户端证书和私钥的PEM文件路径
+func newClientCertificate(t *testing.T, dir string) (*x509.Certificate, string, string) {
+	newKey := func() *ecdsa.PrivateKey {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+
+	caKey := newKey()
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "mkcrawler test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	clientKey := newKey()
+	clientTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "mkcrawler"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, clientTemplate, ca, &clientKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(clientKey)
+
+	certPath := filepath.Join(dir, "client.pem")
+	keyPath := filepath.Join(dir, "client.key")
+	writePEM(t, certPath, "CERTIFICATE", clientDER)
+	writePEM(t, keyPath, "EC PRIVATE KEY", keyDER)
+
+	return ca, certPath, keyPath
+}
+
+func TestTransportProfileCheck(t *testing.T) {
+	cases := []struct {
+		profile TransportProfile
+		valid   bool
+	}{
+		{TransportProfile{Name: "default"}, true},
+		{TransportProfile{Name: "api", ConnectTimeout: "5s", Timeout: "1m", MaxConnsPerHost: 4, TLSMinVersion: "1.2"}, true},
+		{TransportProfile{Name: "api", ClientCert: "client.pem", ClientKey: "client.key"}, true},
+		{TransportProfile{Name: "api", ReadTimeout: "soon"}, false},
+		{TransportProfile{Name: "api", IdleConnTimeout: "-1s"}, false},
+		{TransportProfile{Name: "api", MaxIdleConnsPerHost: -1}, false},
+		{TransportProfile{Name: "api", TLSMinVersion: "1.4"}, false},
+		{TransportProfile{Name: "api", ClientCert: "client.pem"}, false},
+	}
+
+	for i, c := range cases {
+		if err := c.profile.Check(); (err == nil) != c.valid {
+			t.Errorf("[%d] %s: 期望有效为%v，实际错误为%v", i, c.profile.String(), c.valid, err)
+		}
+	}
+
+	profiles := TransportProfiles{{Name: "api"}, {Name: "api"}}
+	if err := profiles.Check(); err == nil {
+		t.Error("重复的传输配置名称应该被拒绝")
+	}
+	if err := (TransportProfiles{{}}).Check(); err == nil {
+		t.Error("传输配置的名称不能为空")
+	}
+}
+
+func TestTransportProfileTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	_, certPath, keyPath := newClientCertificate(t, dir)
+
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	caPath := filepath.Join(dir, "ca.pem")
+	writePEM(t, caPath, "CERTIFICATE", server.Certificate().Raw)
+
+	emptyPath := filepath.Join(dir, "empty.pem")
+	os.WriteFile(emptyPath, []byte("not a certificate"), 0600)
+
+	tlsConfig, err := (&TransportProfile{Name: "api", TLSMinVersion: "1.3", CABundle: caPath, ClientCert: certPath, ClientKey: keyPath}).tlsConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.MinVersion != tls.VersionTLS13 {
+		t.Errorf("TLS最低版本应该为1.3，实际为%x", tlsConfig.MinVersion)
+	}
+	if tlsConfig.RootCAs == nil || len(tlsConfig.Certificates) != 1 {
+		t.Errorf("应该加载CA证书和客户端证书: %v %d", tlsConfig.RootCAs, len(tlsConfig.Certificates))
+	}
+
+	if tlsConfig, _ := (&TransportProfile{Name: "default"}).tlsConfig(); tlsConfig.MinVersion != 0 || tlsConfig.RootCAs != nil {
+		t.Errorf("默认配置应该使用Go的默认值: %x %v", tlsConfig.MinVersion, tlsConfig.RootCAs)
+	}
+
+	invalid := []*TransportProfile{
+		{Name: "api", CABundle: filepath.Join(dir, "missing.pem")},
+		{Name: "api", CABundle: emptyPath},
+		{Name: "api", ClientCert: certPath, ClientKey: filepath.Join(dir, "missing.key")},
+		{Name: "api", ClientCert: caPath, ClientKey: keyPath},
+	}
+	for i, profile := range invalid {
+		if _, err := profile.tlsConfig(); err == nil {
+			t.Errorf("[%d] 无效的证书文件应该返回错误", i)
+		}
+	}
+}
+
+func TestTransportProfileClient(t *testing.T) {
+	dir := t.TempDir()
+	ca, certPath, keyPath := newClientCertificate(t, dir)
+
+	// 要求客户端证书，并支持HTTP/2的服务器
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	caPath := filepath.Join(dir, "ca.pem")
+	writePEM(t, caPath, "CERTIFICATE", server.Certificate().Raw)
+
+	get := func(profile *TransportProfile) (string, error) {
+		client, err := profile.NewClient(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		response, err := client.Get(server.URL)
+		if err != nil {
+			return "", err
+		}
+		defer response.Body.Close()
+
+		body, _ := io.ReadAll(response.Body)
+		return string(body), nil
+	}
+
+	profile := &TransportProfile{Name: "api", CABundle: caPath, ClientCert: certPath, ClientKey: keyPath, MaxConnsPerHost: 2}
+	if proto, err := get(profile); err != nil || proto != "HTTP/2.0" {
+		t.Errorf("应该通过HTTP/2完成双向TLS认证: %q %v", proto, err)
+	}
+
+	http1 := profile.Copy()
+	http1.DisableHTTP2 = true
+	if proto, err := get(http1); err != nil || proto != "HTTP/1.1" {
+		t.Errorf("禁用HTTP/2之后应该使用HTTP/1.1: %q %v", proto, err)
+	}
+
+	if _, err := get(&TransportProfile{Name: "api", CABundle: caPath}); err == nil {
+		t.Error("没有客户端证书时应该被服务器拒绝")
+	}
+	if _, err := get(&TransportProfile{Name: "api", ClientCert: certPath, ClientKey: keyPath}); err == nil {
+		t.Error("没有CA证书时应该无法校验服务器证书")
+	}
+
+	transport, _ := profile.newTransport(nil)
+	if transport.MaxConnsPerHost != 2 || transport.TLSClientConfig.RootCAs == nil {
+		t.Errorf("传输层应该使用配置中的连接数和CA证书: %d", transport.MaxConnsPerHost)
+	}
+}
+
+func TestTransportProfilesFind(t *testing.T) {
+	profiles := TransportProfiles{{Name: "api", Timeout: "5s"}}
+
+	if profile, err := profiles.Find(""); err != nil || profile.Name != DefaultTransportProfileName {
+		t.Errorf("没有默认配置时应该返回空的默认配置: %v %v", profile, err)
+	}
+	if profile, err := profiles.Find("api"); err != nil || profile.Timeout != "5s" {
+		t.Errorf("应该找到api配置: %v %v", profile, err)
+	}
+	if _, err := profiles.Find("missing"); err == nil {
+		t.Error("不存在的配置应该返回错误")
+	}
+
+	withDefault := append(profiles, &TransportProfile{Name: DefaultTransportProfileName, MaxConnsPerHost: 8})
+	if profile, _ := withDefault.Find(""); profile.MaxConnsPerHost != 8 {
+		t.Errorf("应该返回声明的默认配置: %s", profile.String())
+	}
+}
@@ -0,0 +1,476 @@
+package expression
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+func (e *literalExpr) eval(item map[string]interface{}) (interface{}, error) {
+	return e.value, nil
+}
+
+func (e *listExpr) eval(item map[string]interface{}) (interface{}, error) {
+	list := make([]interface{}, 0, len(e.elements))
+	for _, element := range e.elements {
+		value, err := element.eval(item)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+	}
+
+	return list, nil
+}
+
+func (e *fieldExpr) eval(item map[string]interface{}) (interface{}, error) {
+	return normalize(item[e.name]), nil
+}
+
+func (e *memberExpr) eval(item map[string]interface{}) (interface{}, error) {
+	target, err := e.target.eval(item)
+	if err != nil {
+		return nil, err
+	}
+
+	if object, ok := target.(map[string]interface{}); ok {
+		return normalize(object[e.name]), nil
+	}
+
+	return nil, nil
+}
+
+func (e *indexExpr) eval(item map[string]interface{}) (interface{}, error) {
+	target, err := e.target.eval(item)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := e.index.eval(item)
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := target.(type) {
+	case map[string]interface{}:
+		return normalize(t[toString(index)]), nil
+	case []interface{}:
+		number, ok := index.(float64)
+		if !ok || number != math.Trunc(number) {
+			return nil, newEvaluationError("列表的下标应为整数: %s", toString(index))
+		}
+		// 负数表示从末尾开始
+		i := int(number)
+		if i < 0 {
+			i += len(t)
+		}
+		if i < 0 || i >= len(t) {
+			return nil, nil
+		}
+		return normalize(t[i]), nil
+	case string:
+		return nil, newEvaluationError("不能对字符串使用下标，请使用substr")
+	}
+
+	return nil, nil
+}
+
+func (e *unaryExpr) eval(item map[string]interface{}) (interface{}, error) {
+	value, err := e.operand.eval(item)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.op == "not" {
+		return !toBoolean(value), nil
+	}
+
+	number, ok := toNumber(value)
+	if !ok {
+		return nil, newEvaluationError("不能对%s取负", describe(value))
+	}
+
+	return -number, nil
+}
+
+func (e *logicalExpr) eval(item map[string]interface{}) (interface{}, error) {
+	left, err := e.left.eval(item)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.op == "and" && !toBoolean(left) {
+		return false, nil
+	}
+	if e.op == "or" && toBoolean(left) {
+		return true, nil
+	}
+
+	right, err := e.right.eval(item)
+	if err != nil {
+		return nil, err
+	}
+
+	return toBoolean(right), nil
+}
+
+func (e *matchExpr) eval(item map[string]interface{}) (interface{}, error) {
+	left, err := e.left.eval(item)
+	if err != nil {
+		return nil, err
+	}
+
+	pattern, err := e.pattern.eval(item)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := compilePattern(toString(pattern))
+	if err != nil {
+		return nil, newEvaluationError("无效的正则表达式【%s】: %s", toString(pattern), err)
+	}
+
+	// null不匹配任何正则表达式
+	matched := left != nil && re.MatchString(toString(left))
+	return matched != e.negate, nil
+}
+
+func (e *binaryExpr) eval(item map[string]interface{}) (interface{}, error) {
+	left, err := e.left.eval(item)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := e.right.eval(item)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "<", "<=", ">", ">=":
+		return compare(e.op, left, right)
+	case "+":
+		return add(left, right)
+	}
+
+	return arithmetic(e.op, left, right)
+}
+
+func (e *callExpr) eval(item map[string]interface{}) (interface{}, error) {
+	args := make([]interface{}, 0, len(e.args))
+	for _, arg := range e.args {
+		value, err := arg.eval(item)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, value)
+	}
+
+	value, err := e.fn.call(item, args)
+	if err != nil {
+		return nil, newEvaluationError("函数【%s】: %s", e.name, err)
+	}
+
+	return value, nil
+}
+
+// 把条目中的值转换为表达式使用的类型：
+// 整数转换为float64，[]string等切片转换为[]interface{}，条目等映射转换为map[string]interface{}
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, bool, float64, string, time.Time, []interface{}, map[string]interface{}:
+		return v
+	case json.Number:
+		if number, err := v.Float64(); err == nil {
+			return number
+		}
+		return v.String()
+	case []string:
+		list := make([]interface{}, 0, len(v))
+		for _, s := range v {
+			list = append(list, s)
+		}
+		return list
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.Float32:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	case reflect.Slice, reflect.Array:
+		list := make([]interface{}, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			list = append(list, normalize(rv.Index(i).Interface()))
+		}
+		return list
+	case reflect.Map:
+		if rv.Type().Key().Kind() == reflect.String {
+			object := make(map[string]interface{}, rv.Len())
+			for _, key := range rv.MapKeys() {
+				object[key.String()] = rv.MapIndex(key).Interface()
+			}
+			return object
+		}
+	}
+
+	return value
+}
+
+// 判断两个值是否相等。
+// 数字与可以解析为数字的字符串按数字比较，时间按时刻比较，其他不同类型的值不相等
+func equal(left interface{}, right interface{}) bool {
+	if l, r, ok := numberPair(left, right); ok {
+		return l == r
+	}
+
+	switch l := left.(type) {
+	case time.Time:
+		r, ok := right.(time.Time)
+		return ok && l.Equal(r)
+	case []interface{}, map[string]interface{}:
+		return reflect.DeepEqual(left, right)
+	}
+
+	return left == right
+}
+
+// 比较两个值的大小。
+// 任一个值为null时结果为false；数字、字符串和时间之外的值不能比较大小
+func compare(op string, left interface{}, right interface{}) (interface{}, error) {
+	if left == nil || right == nil {
+		return false, nil
+	}
+
+	var order int
+	if l, r, ok := numberPair(left, right); ok {
+		order = compareOrder(l < r, l > r)
+	} else if l, ok := left.(string); ok {
+		r, ok := right.(string)
+		if !ok {
+			return nil, newEvaluationError("不能比较%s和%s", describe(left), describe(right))
+		}
+		order = strings.Compare(l, r)
+	} else if l, ok := left.(time.Time); ok {
+		r, ok := right.(time.Time)
+		if !ok {
+			return nil, newEvaluationError("不能比较%s和%s，请使用date函数转换", describe(left), describe(right))
+		}
+		order = compareOrder(l.Before(r), l.After(r))
+	} else {
+		return nil, newEvaluationError("不能比较%s和%s", describe(left), describe(right))
+	}
+
+	switch op {
+	case "<":
+		return order < 0, nil
+	case "<=":
+		return order <= 0, nil
+	case ">":
+		return order > 0, nil
+	}
+
+	return order >= 0, nil
+}
+
+// 把比较结果转换为-1、0或1
+func compareOrder(less bool, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+
+	return 0
+}
+
+// 加法。两个值都是数字时相加，列表与列表相连，其他情况作为字符串连接（null作为空字符串）
+func add(left interface{}, right interface{}) (interface{}, error) {
+	l, lok := left.(float64)
+	r, rok := right.(float64)
+	if lok && rok {
+		return l + r, nil
+	}
+
+	if l, ok := left.([]interface{}); ok {
+		if r, ok := right.([]interface{}); ok {
+			list := make([]interface{}, 0, len(l)+len(r))
+			return append(append(list, l...), r...), nil
+		}
+	}
+
+	return toString(left) + toString(right), nil
+}
+
+// 减法、乘法、除法和取余
+func arithmetic(op string, left interface{}, right interface{}) (interface{}, error) {
+	l, lok := toNumber(left)
+	r, rok := toNumber(right)
+	if !lok || !rok {
+		return nil, newEvaluationError("运算符%s的操作数应为数字: %s, %s", op, describe(left), describe(right))
+	}
+
+	switch op {
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, newEvaluationError("除数为0")
+		}
+		return l / r, nil
+	}
+
+	if r == 0 {
+		return nil, newEvaluationError("除数为0")
+	}
+	return math.Mod(l, r), nil
+}
+
+// 若两个值都是数字，或一个是数字而另一个是可以解析为数字的字符串，返回它们的数值
+func numberPair(left interface{}, right interface{}) (float64, float64, bool) {
+	_, lnumber := left.(float64)
+	_, rnumber := right.(float64)
+	if !lnumber && !rnumber {
+		return 0, 0, false
+	}
+
+	l, lok := toNumber(left)
+	r, rok := toNumber(right)
+	return l, r, lok && rok
+}
+
+// 把值转换为数字。只有数字、布尔值和可以解析为数字的字符串可以转换
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		number, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return number, err == nil
+	}
+
+	return 0, false
+}
+
+// 把值转换为字符串。
+// null为空字符串，整数不带小数点，时间为RFC 3339格式，列表和映射为JSON文本
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+
+	return string(data)
+}
+
+// 把值转换为布尔值。
+// null、false、0、空字符串、空列表、空映射和零时间为false，其他为true
+func toBoolean(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case string:
+		return v != ""
+	case time.Time:
+		return !v.IsZero()
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+
+	return true
+}
+
+// 描述值的类型，用于错误提示
+func describe(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "布尔值"
+	case float64:
+		return "数字"
+	case string:
+		return "字符串"
+	case time.Time:
+		return "时间"
+	case []interface{}:
+		return "列表"
+	case map[string]interface{}:
+		return "映射"
+	}
+
+	return reflect.TypeOf(value).String()
+}
+
+// 缓存的正则表达式的最多数量。非字面量的正则表达式可能各不相同，超过此数量后不再缓存
+const maxCachedPatterns = 1024
+
+// 已编译的正则表达式的缓存
+var patternCache = struct {
+	patterns map[string]*regexp.Regexp
+	rwlock   sync.RWMutex
+}{patterns: make(map[string]*regexp.Regexp)}
+
+// 编译正则表达式，同一个正则表达式只编译一次
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	patternCache.rwlock.RLock()
+	re, ok := patternCache.patterns[pattern]
+	patternCache.rwlock.RUnlock()
+	if ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	patternCache.rwlock.Lock()
+	if len(patternCache.patterns) < maxCachedPatterns {
+		patternCache.patterns[pattern] = re
+	}
+	patternCache.rwlock.Unlock()
+
+	return re, nil
+}
@@ -0,0 +1,95 @@
+package expression
+
+import (
+	base "core/base"
+	"fmt"
+	"strings"
+)
+
+// 条目表达式接口
+type MKExpression interface {
+	// 以给定条目为上下文对表达式求值。
+	// 结果的类型为nil、bool、float64、string、time.Time、[]interface{}或map[string]interface{}之一
+	Evaluate(item base.MKItem) (interface{}, error)
+
+	// 以给定条目为上下文对表达式求值，并把结果转换为布尔值
+	Test(item base.MKItem) (bool, error)
+
+	// 获取表达式的字符串表现形式
+	String() string
+}
+
+// 编译条目表达式。
+// 支持的语法：
+//   - 字面量：字符串'a'或"a"、数字（如12、1.5、1e3、2.5E-2）、true、false、null，以及列表[1, 'a']
+//   - 字段：title、author.name、tags[0]，名称不是标识符的字段用field('og:title')
+//   - 运算符（按优先级从低到高）：or（||）、and（&&）、
+//     比较==、!=、<、<=、>、>=以及正则匹配=~、!~、
+//     +、-、*、/、%、一元的not（!）和-
+//   - 函数：见functions
+//
+// 条目中不存在的字段为null。null参与大小比较时结果总为false。
+// 在XML配置文件中，&和<需要转义，因此建议使用and、or、not
+func Compile(expression string) (MKExpression, error) {
+	if strings.TrimSpace(expression) == "" {
+		return nil, newSyntaxError(expression, 0, "表达式为空")
+	}
+
+	root, err := parse(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mk_expression{source: expression, root: root}, nil
+}
+
+// 编译条目表达式，失败时引发运行时恐慌。仅适用于常量表达式
+func MustCompile(expression string) MKExpression {
+	compiled, err := Compile(expression)
+	if err != nil {
+		panic(err)
+	}
+
+	return compiled
+}
+
+// 条目表达式的实现类型
+type mk_expression struct {
+	source string // 表达式源文本
+	root   expr   // 语法树
+}
+
+func (expression *mk_expression) Evaluate(item base.MKItem) (interface{}, error) {
+	return expression.root.eval(item)
+}
+
+func (expression *mk_expression) Test(item base.MKItem) (bool, error) {
+	value, err := expression.Evaluate(item)
+	if err != nil {
+		return false, err
+	}
+
+	return toBoolean(value), nil
+}
+
+func (expression *mk_expression) String() string {
+	return expression.source
+}
+
+// 把求值结果转换为字符串
+func String(value interface{}) string {
+	return toString(value)
+}
+
+// 创建语法错误
+func newSyntaxError(expression string, pos int, format string, args ...interface{}) error {
+	message := fmt.Sprintf("无效的条目表达式【%s】（位置%d）: %s",
+		expression, pos, fmt.Sprintf(format, args...))
+	return base.NewError(base.ERR_DOMAIN_ITEM_PROCESSOR, base.ERR_CODE_INVALID_EXPRESSION, message)
+}
+
+// 创建求值错误
+func newEvaluationError(format string, args ...interface{}) error {
+	message := "条目表达式求值失败: " + fmt.Sprintf(format, args...)
+	return base.NewError(base.ERR_DOMAIN_ITEM_PROCESSOR, base.ERR_CODE_EVALUATION, message)
+}
@@ -0,0 +1,102 @@
+package expression
+
+import (
+	base "core/base"
+	"testing"
+	"time"
+)
+
+var testItem = base.MKItem{
+	"title":     "  Go Concurrency Patterns ",
+	"author":    map[string]interface{}{"name": "Rob Pike"},
+	"tags":      []string{"go", "concurrency"},
+	"views":     1024,
+	"price":     "12.5",
+	"published": "2012-07-02",
+	"og:title":  "Go Concurrency",
+}
+
+func TestEvaluate(t *testing.T) {
+	cases := []struct {
+		expression string
+		expected   interface{}
+	}{
+		{`trim(title)`, "Go Concurrency Patterns"},
+		{`author.name + " / " + upper(tags[0])`, "Rob Pike / GO"},
+		{`tags[-1]`, "concurrency"},
+		{`views / 2 + 1`, float64(513)},
+		{`price > 10 and views >= 1024`, true},
+		{`missing > 10 or missing == null`, true},
+		{`not empty(title) && len(tags) == 2`, true},
+		{`title =~ '(?i)concurrency' and title !~ '^\d+$'`, true},
+		{`extract(published, '^(\d{4})-')`, "2012"},
+		{`replaceRegex(published, '(\d+)-(\d+)-(\d+)', '$3/$2/$1')`, "02/07/2012"},
+		{`date(published) < date('2013-01-01')`, true},
+		{`formatDate(published, '2006年1月2日')`, "2012年7月2日"},
+		{`field('og:title')`, "Go Concurrency"},
+		{`coalesce(missing, '', author.name)`, "Rob Pike"},
+		{`contains(tags, 'go') and contains(['a', 'b'], 'c') == false`, true},
+		{`substr(trim(title), -8)`, "Patterns"},
+		{`join(split('a, b,,c', ','), '|')`, "a|b|c"},
+		{`1e3 + 1.5E-1 + 2e+1`, float64(1020.15)},
+		{`views < 1.1e3 and .5e1 == 5`, true},
+	}
+
+	for _, c := range cases {
+		value, err := MustCompile(c.expression).Evaluate(testItem)
+		if err != nil {
+			t.Errorf("%s: %s", c.expression, err)
+			continue
+		}
+		if value != c.expected {
+			t.Errorf("%s: expected %#v, got %#v", c.expression, c.expected, value)
+		}
+	}
+
+	value, err := MustCompile(`date(published)`).Evaluate(testItem)
+	if expected := time.Date(2012, 7, 2, 0, 0, 0, 0, time.UTC); err != nil || !value.(time.Time).Equal(expected) {
+		t.Errorf("date(published): expected %s, got %v (%v)", expected, value, err)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	invalid := []string{
+		``,
+		`title ==`,
+		`(title`,
+		`a < b < c`,
+		`unknown(title)`,
+		`lower()`,
+		`title =~ '['`,
+		`matches(title, '(')`,
+		`'unterminated`,
+		`title # 1`,
+		`1e`,
+		`1e+`,
+	}
+
+	for _, source := range invalid {
+		_, err := Compile(source)
+		mkErr, ok := err.(base.MKError)
+		if !ok || mkErr.Domain() != base.ERR_DOMAIN_ITEM_PROCESSOR || mkErr.Code() != base.ERR_CODE_INVALID_EXPRESSION {
+			t.Errorf("%q: expected a syntax error, got %v", source, err)
+		}
+	}
+}
+
+func TestEvaluationErrors(t *testing.T) {
+	invalid := []string{
+		`views / 0`,
+		`tags < 1`,
+		`date('yesterday')`,
+		`-title`,
+	}
+
+	for _, source := range invalid {
+		_, err := MustCompile(source).Evaluate(testItem)
+		mkErr, ok := err.(base.MKError)
+		if !ok || mkErr.Code() != base.ERR_CODE_EVALUATION {
+			t.Errorf("%q: expected an evaluation error, got %v", source, err)
+		}
+	}
+}
@@ -0,0 +1,405 @@
+package expression
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 内置函数
+type function struct {
+	minArgs    int                                                                        // 最少参数个数
+	maxArgs    int                                                                        // 最多参数个数，-1表示不限
+	patternArg int                                                                        // 正则表达式参数的下标，-1表示没有
+	call       func(item map[string]interface{}, args []interface{}) (interface{}, error) // 实现
+}
+
+// 内置函数库
+var functions map[string]*function
+
+func init() {
+	functions = map[string]*function{
+		// 字段函数
+		"field":    {1, 1, -1, fnField},
+		"coalesce": {1, -1, -1, fnCoalesce},
+		"empty":    {1, 1, -1, fnEmpty},
+
+		// 类型转换函数
+		"string": {1, 1, -1, fnString},
+		"number": {1, 1, -1, fnNumber},
+
+		// 字符串和列表函数
+		"len":        {1, 1, -1, fnLen},
+		"lower":      {1, 1, -1, fnLower},
+		"upper":      {1, 1, -1, fnUpper},
+		"trim":       {1, 1, -1, fnTrim},
+		"concat":     {1, -1, -1, fnConcat},
+		"contains":   {2, 2, -1, fnContains},
+		"startsWith": {2, 2, -1, fnStartsWith},
+		"endsWith":   {2, 2, -1, fnEndsWith},
+		"replace":    {3, 3, -1, fnReplace},
+		"substr":     {2, 3, -1, fnSubstr},
+		"split":      {2, 2, -1, fnSplit},
+		"join":       {2, 2, -1, fnJoin},
+
+		// 正则表达式函数
+		"matches":      {2, 2, 1, fnMatches},
+		"extract":      {2, 3, 1, fnExtract},
+		"replaceRegex": {3, 3, 1, fnReplaceRegex},
+
+		// 日期函数
+		"date":       {1, 2, -1, fnDate},
+		"formatDate": {1, 2, -1, fnFormatDate},
+		"now":        {0, 0, -1, fnNow},
+	}
+}
+
+// 未指定格式时date函数依次尝试的日期格式
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+	"2006年1月2日 15:04",
+	"2006年1月2日",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC850,
+	time.RFC822Z,
+	time.RFC822,
+	time.ANSIC,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"2 Jan 2006",
+}
+
+// field(name)：按名称获取条目的字段，用于名称不是标识符的字段
+func fnField(item map[string]interface{}, args []interface{}) (interface{}, error) {
+	return normalize(item[toString(args[0])]), nil
+}
+
+// coalesce(a, b, ...)：第一个非空的值，都为空时返回null
+func fnCoalesce(item map[string]interface{}, args []interface{}) (interface{}, error) {
+	for _, arg := range args {
+		if !isEmpty(arg) {
+			return arg, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// empty(x)：是否为null、空字符串（只含空白也视为空）、空列表或空映射
+func fnEmpty(item map[string]interface{}, args []interface{}) (interface{}, error) {
+	return isEmpty(args[0]), nil
+}
+
+// string(x)：转换为字符串
+func fnString(item map[string]interface{}, args []interface{}) (interface{}, error) {
+	return toString(args[0]), nil
+}
+
+// number(x)：转换为数字，不能转换时返回null
+func fnNumber(item map[string]interface{}, args []interface{}) (interface{}, error) {
+	if t, ok := args[0].(time.Time); ok {
+		return float64(t.Unix()), nil
+	}
+
+	if number, ok := toNumber(args[0]); ok {
+		return number, nil
+	}
+
+	return nil, nil
+}
+
+// len(x)：字符串的字符数、列表的元素数或映射的成员数，null为0
+func fnLen(item map[string]interface{}, args []interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case []interface{}:
+		return float64(len(v)), nil
+	case map[string]interface{}:
+		return float64(len(v)), nil
+	}
+
+	return float64(utf8.RuneCountInString(toString(args[0]))), nil
+}
+
+// lower(s)：转换为小写
+func fnLower(item map[string]interface{}, args []interface{}) (interface{}, error) {
+	return strings.ToLower(toString(args[0])), nil
+}
+
+// upper(s)：转换为大写
+func fnUpper(item map[string]interface{}, args []interface{}) (interface{}, error) {
+	return strings.ToUpper(toString(args[0])), nil
+}
+
+// trim(s)：去掉两端的空白
+func fnTrim(item map[string]interface{}, args []interface{}) (interface{}, error) {
+	return strings.TrimSpace(toString(args[0])), nil
+}
+
+// concat(a, b, ...)：把所有参数作为字符串连接
+func fnConcat(item map[string]interface{}, args []interface{}) (interface{}, error) {
+	var buffer strings.Builder
+	for _, arg := range args {
+		buffer.WriteString(toString(arg))
+	}
+
+	return buffer.String(), nil
+}
+
+// contains(s, sub)：字符串是否包含子串；第一个参数为列表时，判断列表是否包含元素
+func fnContains(item map[string]interface{}, args []interface{}) (interface{}, error) {
+	if list, ok := args[0].([]interface{}); ok {
+		for _, element := range list {
+			if equal(element, args[1]) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	return strings.Contains(toString(args[0]), toString(args[1])), nil
+}
+
+// startsWith(s, prefix)：字符串是否以前缀开始
+func fnStartsWith(item map[string]interface{}, args []interface{}) (interface{}, error) {
+	return strings.HasPrefix(toString(args[0]), toString(args[1])), nil
+}
+
+// endsWith(s, suffix)：字符串是否以后缀结束
+func fnEndsWith(item map[string]interface{}, args []interface{}) (interface{}, error) {
+	return strings.HasSuffix(toString(args[0]), toString(args[1])), nil
+}
+
+// replace(s, old, new)：替换所有的子串
+func fnReplace(item map[string]interface{}, args []interface{}) (interface{}, error) {
+	return strings.Replace(toString(args[0]), toString(args[1]), toString(args[2]), -1), nil
+}
+
+// substr(s, start[, length])：按字符截取子串，start从0开始，负数表示从末尾开始
+func fnSubstr(item map[string]interface{}, args []interface{}) (interface{}, error) {
+	runes := []rune(toString(args[0]))
+
+	start, err := intArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if start < 0 {
+		start += len(runes)
+	}
+	start = clamp(start, 0, len(runes))
+
+	end := len(runes)
+	if len(args) > 2 {
+		length, err := intArg(args, 2)
+		if err != nil {
+			return nil, err
+		}
+		if length < 0 {
+			return nil, errors.New("长度不能为负数")
+		}
+		end = clamp(start+length, start, len(runes))
+	}
+
+	return string(runes[start:end]), nil
+}
+
+// split(s, sep)：按分隔符切分字符串，去掉每个部分两端的空白，并忽略空的部分
+func fnSplit(item map[string]interface{}, args []interface{}) (interface{}, error) {
+	list := make([]interface{}, 0)
+	for _, part := range strings.Split(toString(args[0]), toString(args[1])) {
+		if part = strings.TrimSpace(part); part != "" {
+			list = append(list, part)
+		}
+	}
+
+	return list, nil
+}
+
+// join(list, sep)：用分隔符连接列表的元素
+func fnJoin(item map[string]interface{}, args []interface{}) (interface{}, error) {
+	list, ok := args[0].([]interface{})
+	if !ok {
+		if args[0] == nil {
+			return "", nil
+		}
+		return toString(args[0]), nil
+	}
+
+	parts := make([]string, 0, len(list))
+	for _, element := range list {
+		parts = append(parts, toString(element))
+	}
+
+	return strings.Join(parts, toString(args[1])), nil
+}
+
+// matches(s, pattern)：字符串是否匹配正则表达式，null不匹配
+func fnMatches(item map[string]interface{}, args []interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return false, nil
+	}
+
+	re, err := compilePattern(toString(args[1]))
+	if err != nil {
+		return nil, err
+	}
+
+	return re.MatchString(toString(args[0])), nil
+}
+
+// extract(s, pattern[, group])：正则表达式第一个匹配中给定分组的文本。
+// 未指定分组时，有分组则取第一个分组，否则取整个匹配；没有匹配时返回null
+func fnExtract(item map[string]interface{}, args []interface{}) (interface{}, error) {
+	re, err := compilePattern(toString(args[1]))
+	if err != nil {
+		return nil, err
+	}
+
+	group := 0
+	if re.NumSubexp() > 0 {
+		group = 1
+	}
+	if len(args) > 2 {
+		if group, err = intArg(args, 2); err != nil {
+			return nil, err
+		}
+		if group < 0 || group > re.NumSubexp() {
+			return nil, errors.New(fmt.Sprintf("分组%d不存在", group))
+		}
+	}
+
+	match := re.FindStringSubmatch(toString(args[0]))
+	if match == nil {
+		return nil, nil
+	}
+
+	return match[group], nil
+}
+
+// replaceRegex(s, pattern, replacement)：替换正则表达式的所有匹配，替换文本中可以用$1引用分组
+func fnReplaceRegex(item map[string]interface{}, args []interface{}) (interface{}, error) {
+	re, err := compilePattern(toString(args[1]))
+	if err != nil {
+		return nil, err
+	}
+
+	return re.ReplaceAllString(toString(args[0]), toString(args[2])), nil
+}
+
+// date(x[, layout])：把字符串解析为时间，数字视为Unix时间戳（秒）。
+// layout为Go的时间格式，如2006-01-02；未指定时依次尝试dateLayouts中的格式。
+// null和空字符串返回null，无法解析时返回错误
+func fnDate(item map[string]interface{}, args []interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case nil:
+		return nil, nil
+	case time.Time:
+		return v, nil
+	case float64:
+		seconds, fraction := math.Modf(v)
+		return time.Unix(int64(seconds), int64(fraction*1e9)).UTC(), nil
+	}
+
+	text := strings.TrimSpace(toString(args[0]))
+	if text == "" {
+		return nil, nil
+	}
+
+	if len(args) > 1 {
+		t, err := time.Parse(toString(args[1]), text)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("无法按格式【%s】解析日期【%s】", toString(args[1]), text))
+		}
+		return t, nil
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t, nil
+		}
+	}
+
+	return nil, errors.New(fmt.Sprintf("无法解析日期【%s】", text))
+}
+
+// formatDate(t[, layout])：按Go的时间格式格式化时间，默认为RFC 3339格式。字符串会先用date解析
+func fnFormatDate(item map[string]interface{}, args []interface{}) (interface{}, error) {
+	value, err := fnDate(item, args[:1])
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	layout := time.RFC3339
+	if len(args) > 1 {
+		layout = toString(args[1])
+	}
+
+	return value.(time.Time).Format(layout), nil
+}
+
+// 按date函数的规则把值解析为时间，供表达式之外需要相同日期格式的地方使用。
+// 值为null或空字符串时第二个返回值为false
+func ParseDate(value interface{}) (time.Time, bool, error) {
+	result, err := fnDate(nil, []interface{}{value})
+	if err != nil || result == nil {
+		return time.Time{}, false, err
+	}
+
+	return result.(time.Time), true, nil
+}
+
+// now()：当前时间
+func fnNow(item map[string]interface{}, args []interface{}) (interface{}, error) {
+	return time.Now(), nil
+}
+
+// 判断值是否为空
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+
+	return false
+}
+
+// 获取整数参数
+func intArg(args []interface{}, i int) (int, error) {
+	number, ok := toNumber(args[i])
+	if !ok || number != math.Trunc(number) {
+		return 0, errors.New(fmt.Sprintf("第%d个参数应为整数: %s", i+1, toString(args[i])))
+	}
+
+	return int(number), nil
+}
+
+// 把整数限制在给定的范围之内
+func clamp(value int, min int, max int) int {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+
+	return value
+}
@@ -0,0 +1,187 @@
+package expression
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 记号类型
+type tokenKind uint8
+
+const (
+	tokenEOF          tokenKind = iota // 结束
+	tokenName                          // 名称（字段名、函数名以及and、or、not、true、false、null）
+	tokenNumber                        // 数字
+	tokenString                        // 字符串字面量
+	tokenOperator                      // 运算符
+	tokenLeftParen                     // (
+	tokenRightParen                    // )
+	tokenLeftBracket                   // [
+	tokenRightBracket                  // ]
+	tokenComma                         // ,
+	tokenDot                           // .
+)
+
+// 记号
+type token struct {
+	kind tokenKind // 类型
+	text string    // 文本
+	pos  int       // 在表达式中的位置
+}
+
+// 由两个字符组成的运算符
+var twoCharOperators = []string{"||", "&&", "==", "!=", "<=", ">=", "=~", "!~"}
+
+// 把表达式切分为记号
+func tokenize(expression string) ([]token, error) {
+	tokens := make([]token, 0)
+	pos := 0
+
+	for {
+		for pos < len(expression) && strings.IndexByte(" \t\r\n", expression[pos]) >= 0 {
+			pos++
+		}
+
+		if pos >= len(expression) {
+			tokens = append(tokens, token{kind: tokenEOF, pos: pos})
+			return tokens, nil
+		}
+
+		start := pos
+		ch := expression[pos]
+		next := byte(0)
+		if pos+1 < len(expression) {
+			next = expression[pos+1]
+		}
+
+		var t token
+		switch {
+		case ch == '(':
+			t, pos = token{kind: tokenLeftParen, text: "("}, pos+1
+		case ch == ')':
+			t, pos = token{kind: tokenRightParen, text: ")"}, pos+1
+		case ch == '[':
+			t, pos = token{kind: tokenLeftBracket, text: "["}, pos+1
+		case ch == ']':
+			t, pos = token{kind: tokenRightBracket, text: "]"}, pos+1
+		case ch == ',':
+			t, pos = token{kind: tokenComma, text: ","}, pos+1
+		case ch == '.' && !isDigit(next):
+			t, pos = token{kind: tokenDot, text: "."}, pos+1
+		case isTwoCharOperator(expression[pos:]):
+			t, pos = token{kind: tokenOperator, text: expression[pos : pos+2]}, pos+2
+		case strings.IndexByte("<>!+-*/%", ch) >= 0:
+			t, pos = token{kind: tokenOperator, text: string(ch)}, pos+1
+		case ch == '"' || ch == '\'':
+			text, end, ok := scanString(expression, pos)
+			if !ok {
+				return nil, newSyntaxError(expression, pos, "字符串没有结束")
+			}
+			t, pos = token{kind: tokenString, text: text}, end
+		case isDigit(ch) || ch == '.':
+			for pos < len(expression) && isDigit(expression[pos]) {
+				pos++
+			}
+			if pos < len(expression) && expression[pos] == '.' {
+				pos++
+				for pos < len(expression) && isDigit(expression[pos]) {
+					pos++
+				}
+			}
+			pos = scanExponent(expression, pos)
+			t = token{kind: tokenNumber, text: expression[start:pos]}
+		default:
+			r, _ := utf8.DecodeRuneInString(expression[pos:])
+			if !isNameStart(r) {
+				return nil, newSyntaxError(expression, pos, "意外的字符%q", r)
+			}
+
+			pos = scanName(expression, pos)
+			t = token{kind: tokenName, text: expression[start:pos]}
+		}
+
+		t.pos = start
+		tokens = append(tokens, t)
+	}
+}
+
+// 扫描数字的指数部分，如e3、E-2，返回指数之后的位置。
+// e之后没有数字时不作为指数，返回原来的位置
+func scanExponent(expression string, pos int) int {
+	if pos >= len(expression) || (expression[pos] != 'e' && expression[pos] != 'E') {
+		return pos
+	}
+
+	end := pos + 1
+	if end < len(expression) && (expression[end] == '+' || expression[end] == '-') {
+		end++
+	}
+	if end >= len(expression) || !isDigit(expression[end]) {
+		return pos
+	}
+
+	for end < len(expression) && isDigit(expression[end]) {
+		end++
+	}
+
+	return end
+}
+
+// 判断文本是否以两个字符组成的运算符开始
+func isTwoCharOperator(text string) bool {
+	for _, operator := range twoCharOperators {
+		if strings.HasPrefix(text, operator) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 扫描字符串字面量，返回字符串的值和字面量之后的位置。
+// 反斜杠只用于转义引号和反斜杠本身，其他的反斜杠原样保留，以便书写正则表达式（如'\d+'）
+func scanString(expression string, pos int) (string, int, bool) {
+	quote := expression[pos]
+	var buffer strings.Builder
+
+	for i := pos + 1; i < len(expression); i++ {
+		ch := expression[i]
+		switch {
+		case ch == quote:
+			return buffer.String(), i + 1, true
+		case ch == '\\' && i+1 < len(expression) && (expression[i+1] == quote || expression[i+1] == '\\'):
+			buffer.WriteByte(expression[i+1])
+			i++
+		default:
+			buffer.WriteByte(ch)
+		}
+	}
+
+	return "", 0, false
+}
+
+// 扫描名称，返回名称之后的位置
+func scanName(expression string, pos int) int {
+	for pos < len(expression) {
+		r, size := utf8.DecodeRuneInString(expression[pos:])
+		if !isNameChar(r) {
+			break
+		}
+		pos += size
+	}
+
+	return pos
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isNameStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isNameChar(r rune) bool {
+	return isNameStart(r) || unicode.IsDigit(r)
+}
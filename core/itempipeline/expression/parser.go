@@ -0,0 +1,450 @@
+package expression
+
+import (
+	"strconv"
+)
+
+// 表达式语法树的节点
+type expr interface {
+	eval(item map[string]interface{}) (interface{}, error)
+}
+
+// 字面量
+type literalExpr struct {
+	value interface{}
+}
+
+// 列表字面量
+type listExpr struct {
+	elements []expr
+}
+
+// 条目的字段
+type fieldExpr struct {
+	name string
+}
+
+// 成员访问，如author.name
+type memberExpr struct {
+	target expr
+	name   string
+}
+
+// 下标访问，如tags[0]、author['first-name']
+type indexExpr struct {
+	target expr
+	index  expr
+}
+
+// 一元运算（not和取负）
+type unaryExpr struct {
+	op      string
+	operand expr
+}
+
+// 二元运算（比较和算术）
+type binaryExpr struct {
+	op    string
+	left  expr
+	right expr
+}
+
+// 逻辑运算（and和or），右操作数按需求值
+type logicalExpr struct {
+	op    string
+	left  expr
+	right expr
+}
+
+// 正则匹配（=~和!~）
+type matchExpr struct {
+	negate  bool
+	left    expr
+	pattern expr
+}
+
+// 函数调用
+type callExpr struct {
+	name string
+	fn   *function
+	args []expr
+}
+
+// 运算符的别名
+var operatorAliases = map[string]string{
+	"||": "or",
+	"&&": "and",
+	"!":  "not",
+}
+
+// 比较运算符
+var comparisonOperators = map[string]bool{
+	"==": true,
+	"!=": true,
+	"<":  true,
+	"<=": true,
+	">":  true,
+	">=": true,
+	"=~": true,
+	"!~": true,
+}
+
+// 语法分析器
+type parser struct {
+	source string  // 表达式源文本
+	tokens []token // 记号列表
+	pos    int     // 当前记号的下标
+}
+
+// 解析表达式
+func parse(expression string) (expr, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{source: expression, tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.errorAt(t, "意外的记号【%s】", t.text)
+	}
+
+	return root, nil
+}
+
+// 获取当前记号
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// 获取当前记号并前进
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+// 获取当前记号的运算符（包括and、or、not），不是运算符时返回空字符串
+func (p *parser) operator() string {
+	t := p.peek()
+	switch t.kind {
+	case tokenOperator:
+		if alias, ok := operatorAliases[t.text]; ok {
+			return alias
+		}
+		return t.text
+	case tokenName:
+		switch t.text {
+		case "and", "or", "not":
+			return t.text
+		}
+	}
+
+	return ""
+}
+
+// 要求当前记号为给定类型，并前进
+func (p *parser) expect(kind tokenKind, text string) error {
+	t := p.next()
+	if t.kind != kind {
+		if t.kind == tokenEOF {
+			return p.errorAt(t, "缺少【%s】", text)
+		}
+		return p.errorAt(t, "应为【%s】，实为【%s】", text, t.text)
+	}
+
+	return nil
+}
+
+// 创建给定记号处的语法错误
+func (p *parser) errorAt(t token, format string, args ...interface{}) error {
+	return newSyntaxError(p.source, t.pos, format, args...)
+}
+
+// OrExpr ::= AndExpr ('or' AndExpr)*
+func (p *parser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.operator() == "or" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpr{op: "or", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+// AndExpr ::= ComparisonExpr ('and' ComparisonExpr)*
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.operator() == "and" {
+		p.next()
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpr{op: "and", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+// ComparisonExpr ::= AdditiveExpr (ComparisonOperator AdditiveExpr)?
+// 比较运算符不能连用，如a < b < c
+func (p *parser) parseComparison() (expr, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	op := p.operator()
+	if !comparisonOperators[op] {
+		return left, nil
+	}
+
+	p.next()
+	patternToken := p.peek()
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); comparisonOperators[p.operator()] {
+		return nil, p.errorAt(t, "比较运算符不能连用")
+	}
+
+	if op == "=~" || op == "!~" {
+		if err := p.checkPattern(patternToken, right); err != nil {
+			return nil, err
+		}
+		return &matchExpr{negate: op == "!~", left: left, pattern: right}, nil
+	}
+
+	return &binaryExpr{op: op, left: left, right: right}, nil
+}
+
+// AdditiveExpr ::= MultiplicativeExpr (('+' | '-') MultiplicativeExpr)*
+func (p *parser) parseAdditive() (expr, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+
+	for op := p.operator(); op == "+" || op == "-"; op = p.operator() {
+		p.next()
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+// MultiplicativeExpr ::= UnaryExpr (('*' | '/' | '%') UnaryExpr)*
+func (p *parser) parseMultiplicative() (expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for op := p.operator(); op == "*" || op == "/" || op == "%"; op = p.operator() {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+// UnaryExpr ::= ('not' | '-') UnaryExpr | PostfixExpr
+func (p *parser) parseUnary() (expr, error) {
+	if op := p.operator(); op == "not" || op == "-" {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: op, operand: operand}, nil
+	}
+
+	return p.parsePostfix()
+}
+
+// PostfixExpr ::= PrimaryExpr ('.' Name | '[' OrExpr ']')*
+func (p *parser) parsePostfix() (expr, error) {
+	target, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch p.peek().kind {
+		case tokenDot:
+			p.next()
+			t := p.next()
+			if t.kind != tokenName {
+				return nil, p.errorAt(t, "成员访问缺少名称")
+			}
+			target = &memberExpr{target: target, name: t.text}
+		case tokenLeftBracket:
+			p.next()
+			index, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(tokenRightBracket, "]"); err != nil {
+				return nil, err
+			}
+			target = &indexExpr{target: target, index: index}
+		default:
+			return target, nil
+		}
+	}
+}
+
+// PrimaryExpr ::= Literal | '[' List ']' | '(' OrExpr ')' | FunctionCall | Name
+func (p *parser) parsePrimary() (expr, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokenNumber:
+		value, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorAt(t, "无效的数字【%s】", t.text)
+		}
+		return &literalExpr{value: value}, nil
+	case tokenString:
+		return &literalExpr{value: t.text}, nil
+	case tokenLeftParen:
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenRightParen, ")"); err != nil {
+			return nil, err
+		}
+		return e, nil
+	case tokenLeftBracket:
+		elements, err := p.parseList(tokenRightBracket, "]")
+		if err != nil {
+			return nil, err
+		}
+		return &listExpr{elements: elements}, nil
+	case tokenName:
+		switch t.text {
+		case "true":
+			return &literalExpr{value: true}, nil
+		case "false":
+			return &literalExpr{value: false}, nil
+		case "null":
+			return &literalExpr{value: nil}, nil
+		case "and", "or", "not":
+			return nil, p.errorAt(t, "意外的运算符【%s】", t.text)
+		}
+
+		if p.peek().kind == tokenLeftParen {
+			p.next()
+			return p.parseCall(t)
+		}
+		return &fieldExpr{name: t.text}, nil
+	case tokenEOF:
+		return nil, p.errorAt(t, "表达式不完整")
+	}
+
+	return nil, p.errorAt(t, "意外的记号【%s】", t.text)
+}
+
+// 解析以逗号分隔的表达式列表，直到给定的结束记号
+func (p *parser) parseList(end tokenKind, endText string) ([]expr, error) {
+	elements := make([]expr, 0)
+	if p.peek().kind == end {
+		p.next()
+		return elements, nil
+	}
+
+	for {
+		element, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+
+		if p.peek().kind != tokenComma {
+			break
+		}
+		p.next()
+	}
+
+	if err := p.expect(end, endText); err != nil {
+		return nil, err
+	}
+
+	return elements, nil
+}
+
+// FunctionCall ::= Name '(' (OrExpr (',' OrExpr)*)? ')'
+func (p *parser) parseCall(name token) (expr, error) {
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, p.errorAt(name, "未知的函数【%s】", name.text)
+	}
+
+	args, err := p.parseList(tokenRightParen, ")")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, p.errorAt(name, "函数【%s】的参数个数不正确: %d", name.text, len(args))
+	}
+
+	// 字面量的正则表达式在编译时检查
+	if fn.patternArg >= 0 && fn.patternArg < len(args) {
+		if err := p.checkPattern(name, args[fn.patternArg]); err != nil {
+			return nil, err
+		}
+	}
+
+	return &callExpr{name: name.text, fn: fn, args: args}, nil
+}
+
+// 检查字面量的正则表达式。非字面量的正则表达式在求值时检查
+func (p *parser) checkPattern(t token, pattern expr) error {
+	literal, ok := pattern.(*literalExpr)
+	if !ok {
+		return nil
+	}
+
+	text, ok := literal.value.(string)
+	if !ok {
+		return p.errorAt(t, "正则表达式应为字符串")
+	}
+
+	if _, err := compilePattern(text); err != nil {
+		return p.errorAt(t, "无效的正则表达式【%s】: %s", text, err)
+	}
+
+	return nil
+}
@@ -27,6 +27,9 @@ type MKItemPipeline interface {
 	// 获取正在被处理的条目的数量
 	ProcessingNumber() uint64
 
+	// 获取被条目处理器丢弃的条目的数量
+	Dropped() uint64
+
 	// 获取摘要信息
 	Summary() string
 }
@@ -56,6 +59,7 @@ type mk_itemPipeline struct {
 	sent             uint64          // 已被发送的条目数量
 	accepted         uint64          // 已被接受的条目数量
 	processed        uint64          // 已被处理的条目数量
+	dropped          uint64          // 已被丢弃的条目数量
 	processingNumber uint64          // 正在被处理的条目的数量
 }
 
@@ -75,6 +79,10 @@ func (pipeline *mk_itemPipeline) Send(item base.MKItem) []error {
 	var currentItem base.MKItem = item
 	for _, itemProcessor := range pipeline.itemProcessors {
 		processedItem, err := itemProcessor(currentItem)
+		if IsDropError(err) {
+			atomic.AddUint64(&pipeline.dropped, 1)
+			break
+		}
 		if err != nil {
 			errs = append(errs, err)
 			if pipeline.failFast {
@@ -112,8 +120,12 @@ func (pipeline *mk_itemPipeline) ProcessingNumber() uint64 {
 	return atomic.LoadUint64(&pipeline.processingNumber)
 }
 
+func (pipeline *mk_itemPipeline) Dropped() uint64 {
+	return atomic.LoadUint64(&pipeline.dropped)
+}
+
 var summaryTemplate = "failFast: %v, processorNumber: %d, " +
-	" send: %d, accepted: %d, processed: %d, processingNumber: %d"
+	" send: %d, accepted: %d, processed: %d, dropped: %d, processingNumber: %d"
 
 func (pipeline *mk_itemPipeline) Summary() string {
 	counts := pipeline.Count()
@@ -123,6 +135,7 @@ func (pipeline *mk_itemPipeline) Summary() string {
 		counts[0],
 		counts[1],
 		counts[2],
+		pipeline.Dropped(),
 		pipeline.ProcessingNumber())
 
 	return summary
@@ -4,5 +4,17 @@ import (
 	base "core/base"
 )
 
+// 创建表示丢弃条目的错误。
+// 条目处理器返回此错误时，条目处理管道会忽略后续的所有处理步骤，且不把它作为错误报告
+func NewDropError(reason string) error {
+	return base.NewError(base.ERR_DOMAIN_ITEM_PROCESSOR, base.ERR_CODE_ITEM_DROPPED, reason)
+}
+
+// 判断错误是否表示丢弃条目
+func IsDropError(err error) bool {
+	mkErr, ok := err.(base.MKError)
+	return ok && mkErr.Code() == base.ERR_CODE_ITEM_DROPPED
+}
+
 // 被用来处理条目的函数类型
 type MKProcessItem func(item base.MKItem) (result base.Item, err error)
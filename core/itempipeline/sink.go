@@ -0,0 +1,30 @@
+package itempipeline
+
+import (
+	base "core/base"
+)
+
+// 条目输出的接口类型。
+// 条目输出把条目写入文件等外部存储，通常位于条目处理流程的最后。
+// 条目输出一般带有缓冲，停止爬取时必须调用Close以刷新缓冲并释放资源
+type MKItemSink interface {
+	// 写入条目
+	Write(item base.MKItem) error
+
+	// 把缓冲中的条目写入存储
+	Flush() error
+
+	// 刷新缓冲并关闭条目输出。关闭之后不能再写入
+	Close() error
+}
+
+// 把条目输出包装为条目处理器。条目处理器不会修改条目。
+// 条目处理器和条目处理管道都没有停止时的回调，因此不会关闭条目输出：
+// 调用方必须自己保留条目输出，并在条目处理管道不再使用之后调用其Close，
+// 否则缓冲中的条目会丢失（registry.NewStage和config.Crawler.Processors都会为此返回条目输出）。
+// 条目输出关闭之后，条目处理器写入条目时会返回错误
+func NewSinkProcessor(sink MKItemSink) MKProcessItem {
+	return func(item base.MKItem) (base.MKItem, error) {
+		return nil, sink.Write(item)
+	}
+}
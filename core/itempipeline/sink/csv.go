@@ -0,0 +1,543 @@
+package sink
+
+import (
+	"bytes"
+	base "core/base"
+	itempipeline "core/itempipeline"
+	expression "core/itempipeline/expression"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 嵌套值（列表和映射）的默认格式
+const (
+	CSV_NESTED_FLATTEN = "flatten" // 展开为文本：列表元素以分隔符连接，映射展开为“键=值”并以分隔符连接
+	CSV_NESTED_JSON    = "json"    // 编码为JSON
+)
+
+// 列表元素的默认分隔符
+const CSV_DEFAULT_SEPARATOR = "; "
+
+// CSV参数的描述模板
+var csvArgumentsTemplate string = "{ columns: %s, delimiter: %q, skip header: %v, nested: %s, separator: %q }"
+
+// CSV文件的一列
+type CSVColumn struct {
+	Field  string // 字段名称。字段不存在时可以用“.”访问嵌套的值，如author.name、tags.0
+	Header string // 表头，为空时使用字段名称
+	Format string // 格式，见newFormatter，为空时按嵌套值的默认格式输出
+}
+
+func (column CSVColumn) String() string {
+	text := column.Field
+	if column.Header != "" {
+		text += "=" + column.Header
+	}
+	if column.Format != "" {
+		text += "|" + column.Format
+	}
+
+	return text
+}
+
+// CSV输出的参数
+type CSVArguments struct {
+	Columns     []CSVColumn // 列，按顺序输出
+	Delimiter   rune        // 字段分隔符，0表示逗号
+	SkipHeader  bool        // 是否不写入表头
+	Nested      string      // 嵌套值的默认格式，CSV_NESTED_FLATTEN或CSV_NESTED_JSON，为空时为CSV_NESTED_FLATTEN
+	Separator   string      // 展开列表和映射时使用的分隔符，为空时为CSV_DEFAULT_SEPARATOR
+	description string      // 描述
+}
+
+func (arguments *CSVArguments) Check() error {
+	if len(arguments.Columns) == 0 {
+		return errors.New("至少需要一列！")
+	}
+
+	for i, column := range arguments.Columns {
+		if strings.TrimSpace(column.Field) == "" {
+			return errors.New(fmt.Sprintf("第%d列的字段名称为空！", i+1))
+		}
+
+		if _, err := newFormatter(column.Format); err != nil {
+			return errors.New(fmt.Sprintf("列【%s】: %s", column.Field, err))
+		}
+	}
+
+	if arguments.Delimiter != 0 &&
+		(arguments.Delimiter == '"' || arguments.Delimiter == '\r' || arguments.Delimiter == '\n' ||
+			arguments.Delimiter == utf8.RuneError) {
+		return errors.New(fmt.Sprintf("无效的字段分隔符【%q】！", arguments.Delimiter))
+	}
+
+	switch arguments.Nested {
+	case "", CSV_NESTED_FLATTEN, CSV_NESTED_JSON:
+	default:
+		return errors.New(fmt.Sprintf("无效的嵌套值格式【%s】，应为%s或%s！",
+			arguments.Nested, CSV_NESTED_FLATTEN, CSV_NESTED_JSON))
+	}
+
+	return nil
+}
+
+func (arguments *CSVArguments) String() string {
+	if arguments.description == "" {
+		columns := make([]string, 0, len(arguments.Columns))
+		for _, column := range arguments.Columns {
+			columns = append(columns, column.String())
+		}
+
+		arguments.description =
+			fmt.Sprintf(csvArgumentsTemplate,
+				strings.Join(columns, ", "),
+				arguments.Delimiter,
+				arguments.SkipHeader,
+				arguments.Nested,
+				arguments.Separator)
+	}
+
+	return arguments.description
+}
+
+// 解析列的声明。每行声明一列，格式为“字段[=表头][|格式]”，空行和以#开头的行被忽略，例如：
+//
+//	title=标题
+//	author.name=作者
+//	published=发布日期|date:2006-01-02
+//	tags|join:/
+func ParseCSVColumns(spec string) ([]CSVColumn, error) {
+	columns := make([]CSVColumn, 0)
+	for i, line := range strings.Split(spec, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var column CSVColumn
+		if index := strings.Index(line, "|"); index >= 0 {
+			column.Format = strings.TrimSpace(line[index+1:])
+			line = line[:index]
+		}
+		if index := strings.Index(line, "="); index >= 0 {
+			column.Header = strings.TrimSpace(line[index+1:])
+			line = line[:index]
+		}
+		column.Field = strings.TrimSpace(line)
+
+		if column.Field == "" {
+			return nil, errors.New(fmt.Sprintf("第%d行的列声明缺少字段名称！", i+1))
+		}
+
+		if _, err := newFormatter(column.Format); err != nil {
+			return nil, errors.New(fmt.Sprintf("第%d行的列【%s】: %s", i+1, column.Field, err))
+		}
+
+		columns = append(columns, column)
+	}
+
+	return columns, nil
+}
+
+// 创建CSV条目输出。每个条目按声明的列输出为一行，缺少的字段输出为空单元格。
+// 无法格式化的值也输出为空单元格，该行照常写入，Write再返回这些单元格的错误。
+// 表头写在每个新文件的开头，向已有内容的文件追加时不再重复写入。
+// 追加时不会检查已有的表头，即使列的声明已经改变也是如此，因此改变列之后应该输出到新的文件
+func NewCSVSink(arguments *RotationArguments, csvArguments *CSVArguments) (itempipeline.MKItemSink, error) {
+	if csvArguments == nil {
+		return nil, errors.New("CSV参数无效！")
+	}
+
+	if err := csvArguments.Check(); err != nil {
+		return nil, err
+	}
+
+	sink := &mk_csvSink{arguments: csvArguments}
+	sink.formatters = make([]formatter, 0, len(csvArguments.Columns))
+	for _, column := range csvArguments.Columns {
+		format, _ := newFormatter(column.Format)
+		sink.formatters = append(sink.formatters, format)
+	}
+
+	var header func(w io.Writer) error
+	if !csvArguments.SkipHeader {
+		header = sink.writeHeader
+	}
+
+	file, err := newRotatingFile(arguments, header)
+	if err != nil {
+		return nil, err
+	}
+	sink.file = file
+
+	return sink, nil
+}
+
+// CSV条目输出的实现类型
+type mk_csvSink struct {
+	arguments  *CSVArguments // CSV参数
+	formatters []formatter   // 各列的格式化函数
+	file       *rotatingFile // 输出文件
+	closed     bool          // 是否已关闭
+	mutex      sync.Mutex    // 互斥锁
+}
+
+func (sink *mk_csvSink) Write(item base.MKItem) error {
+	// 一个单元格出错不影响整行，出错的单元格留空，写入之后再报告错误
+	row := make([]string, len(sink.arguments.Columns))
+	cellErrors := make([]string, 0)
+	for i, column := range sink.arguments.Columns {
+		value, ok := lookupField(map[string]interface{}(item), column.Field)
+		if !ok || value == nil {
+			continue
+		}
+
+		cell, err := sink.formatters[i](value, sink.arguments)
+		if err != nil {
+			cellErrors = append(cellErrors, fmt.Sprintf("列【%s】: %s", column.Field, err))
+			continue
+		}
+		row[i] = cell
+	}
+
+	record, err := sink.encode(row)
+	if err != nil {
+		return err
+	}
+
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	if sink.closed {
+		return errors.New("条目输出已关闭！")
+	}
+
+	if err := sink.file.write(record); err != nil {
+		return err
+	}
+
+	if len(cellErrors) > 0 {
+		return errors.New(strings.Join(cellErrors, "; "))
+	}
+
+	return nil
+}
+
+func (sink *mk_csvSink) Flush() error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	return sink.file.flush()
+}
+
+func (sink *mk_csvSink) Close() error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	if sink.closed {
+		return nil
+	}
+
+	sink.closed = true
+	return sink.file.close()
+}
+
+// 写入表头
+func (sink *mk_csvSink) writeHeader(w io.Writer) error {
+	headers := make([]string, 0, len(sink.arguments.Columns))
+	for _, column := range sink.arguments.Columns {
+		if column.Header != "" {
+			headers = append(headers, column.Header)
+		} else {
+			headers = append(headers, column.Field)
+		}
+	}
+
+	record, err := sink.encode(headers)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(record)
+	return err
+}
+
+// 把一行编码为CSV记录
+func (sink *mk_csvSink) encode(row []string) ([]byte, error) {
+	var record bytes.Buffer
+	writer := csv.NewWriter(&record)
+	if sink.arguments.Delimiter != 0 {
+		writer.Comma = sink.arguments.Delimiter
+	}
+
+	if err := writer.Write(row); err != nil {
+		return nil, err
+	}
+	writer.Flush()
+
+	return record.Bytes(), writer.Error()
+}
+
+// 查找字段的值。先按完整的名称查找，找不到时把名称按“.”拆分后逐级访问映射的键或列表的下标
+func lookupField(item map[string]interface{}, field string) (interface{}, bool) {
+	if value, ok := item[field]; ok {
+		return value, true
+	}
+
+	if !strings.Contains(field, ".") {
+		return nil, false
+	}
+
+	var value interface{} = item
+	for _, name := range strings.Split(field, ".") {
+		rv := reflect.ValueOf(value)
+		switch rv.Kind() {
+		case reflect.Map:
+			if rv.Type().Key().Kind() != reflect.String {
+				return nil, false
+			}
+			element := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()))
+			if !element.IsValid() {
+				return nil, false
+			}
+			value = element.Interface()
+		case reflect.Slice, reflect.Array:
+			index, err := strconv.Atoi(name)
+			if err != nil || index < 0 || index >= rv.Len() {
+				return nil, false
+			}
+			value = rv.Index(index).Interface()
+		default:
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// 格式化函数，把非nil的值转换为单元格的文本
+type formatter func(value interface{}, arguments *CSVArguments) (string, error)
+
+// 按格式的声明创建格式化函数。格式为“名称[:参数]”，可用的格式有：
+//
+//	text           默认格式，嵌套值按CSVArguments.Nested输出
+//	json           编码为JSON
+//	join[:分隔符]  把列表的元素以分隔符连接，默认为CSVArguments.Separator
+//	date[:布局]    按date函数的规则解析为时间，再按Go的时间布局输出，默认为RFC 3339格式
+//	number[:位数]  输出为保留给定小数位数的数字，默认为最短表示
+func newFormatter(format string) (formatter, error) {
+	name, parameter, hasParameter := format, "", false
+	if index := strings.Index(format, ":"); index >= 0 {
+		name, parameter, hasParameter = strings.TrimSpace(format[:index]), format[index+1:], true
+	}
+
+	switch name {
+	case "", "text":
+		return formatText, nil
+	case "json":
+		return func(value interface{}, arguments *CSVArguments) (string, error) {
+			return encodeJSON(value)
+		}, nil
+	case "join":
+		return func(value interface{}, arguments *CSVArguments) (string, error) {
+			separator := arguments.separator()
+			if hasParameter {
+				separator = parameter
+			}
+			return flatten(value, separator)
+		}, nil
+	case "date":
+		layout := time.RFC3339
+		if strings.TrimSpace(parameter) != "" {
+			layout = strings.TrimSpace(parameter)
+		}
+		return func(value interface{}, arguments *CSVArguments) (string, error) {
+			t, ok, err := expression.ParseDate(value)
+			if err != nil || !ok {
+				return "", err
+			}
+			return t.Format(layout), nil
+		}, nil
+	case "number":
+		precision := -1
+		if strings.TrimSpace(parameter) != "" {
+			p, err := strconv.Atoi(strings.TrimSpace(parameter))
+			if err != nil || p < 0 {
+				return nil, errors.New(fmt.Sprintf("无效的小数位数【%s】", parameter))
+			}
+			precision = p
+		}
+		return func(value interface{}, arguments *CSVArguments) (string, error) {
+			number, err := toNumber(value)
+			if err != nil {
+				return "", err
+			}
+			return strconv.FormatFloat(number, 'f', precision, 64), nil
+		}, nil
+	}
+
+	return nil, errors.New(fmt.Sprintf("未知的格式【%s】，可用的格式: text, json, join, date, number", name))
+}
+
+// 默认格式：标量直接输出，嵌套值按CSVArguments.Nested展开或编码为JSON
+func formatText(value interface{}, arguments *CSVArguments) (string, error) {
+	if text, ok := formatScalar(value); ok {
+		return text, nil
+	}
+
+	if arguments.Nested == CSV_NESTED_JSON {
+		return encodeJSON(value)
+	}
+
+	return flatten(value, arguments.separator())
+}
+
+// 把列表展开为以分隔符连接的元素，把映射展开为以分隔符连接的“键=值”，
+// 嵌套在映射中的映射以“.”连接键，如author.name=Tang
+func flatten(value interface{}, separator string) (string, error) {
+	if text, ok := formatScalar(value); ok {
+		return text, nil
+	}
+
+	parts := make([]string, 0)
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			part, err := flatten(rv.Index(i).Interface(), separator)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, part)
+		}
+	case reflect.Map:
+		if err := flattenMap(rv, "", &parts); err != nil {
+			return "", err
+		}
+	default:
+		return encodeJSON(value)
+	}
+
+	return strings.Join(parts, separator), nil
+}
+
+// 把映射展开为“键=值”，键按名称排序。映射中的列表编码为JSON
+func flattenMap(rv reflect.Value, prefix string, parts *[]string) error {
+	keys := make([]string, 0, rv.Len())
+	values := make(map[string]interface{}, rv.Len())
+	for _, key := range rv.MapKeys() {
+		name := fmt.Sprint(key.Interface())
+		keys = append(keys, name)
+		values[name] = rv.MapIndex(key).Interface()
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := values[key]
+		if nested := reflect.ValueOf(value); nested.Kind() == reflect.Map {
+			if err := flattenMap(nested, prefix+key+".", parts); err != nil {
+				return err
+			}
+			continue
+		}
+
+		text, ok := formatScalar(value)
+		if !ok {
+			var err error
+			if text, err = encodeJSON(value); err != nil {
+				return err
+			}
+		}
+		*parts = append(*parts, prefix+key+"="+text)
+	}
+
+	return nil
+}
+
+// 输出标量值。值为列表或映射时第二个返回值为false
+func formatScalar(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", true
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), true
+	case time.Time:
+		return v.Format(time.RFC3339), true
+	case json.Number:
+		return v.String(), true
+	case fmt.Stringer:
+		return v.String(), true
+	}
+
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct, reflect.Ptr:
+		return "", false
+	}
+
+	return fmt.Sprint(value), true
+}
+
+// 把值编码为JSON，不转义HTML字符
+func encodeJSON(value interface{}) (string, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(buffer.String(), "\n"), nil
+}
+
+// 把值转换为数字，字符串会被解析
+func toNumber(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case string:
+		number, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, errors.New(fmt.Sprintf("无法把【%s】转换为数字", v))
+		}
+		return number, nil
+	case json.Number:
+		return v.Float64()
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	}
+
+	return 0, errors.New(fmt.Sprintf("无法把%T类型的值转换为数字", value))
+}
+
+// 展开列表和映射时使用的分隔符
+func (arguments *CSVArguments) separator() string {
+	if arguments.Separator == "" {
+		return CSV_DEFAULT_SEPARATOR
+	}
+
+	return arguments.Separator
+}
@@ -0,0 +1,64 @@
+package sink
+
+import (
+	"bytes"
+	base "core/base"
+	itempipeline "core/itempipeline"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// 创建JSON Lines条目输出。每个条目编码为一行JSON，字段按名称排序
+func NewJSONLSink(arguments *RotationArguments) (itempipeline.MKItemSink, error) {
+	file, err := newRotatingFile(arguments, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mk_jsonlSink{file: file}, nil
+}
+
+// JSON Lines条目输出的实现类型
+type mk_jsonlSink struct {
+	file   *rotatingFile // 输出文件
+	closed bool          // 是否已关闭
+	mutex  sync.Mutex    // 互斥锁
+}
+
+func (sink *mk_jsonlSink) Write(item base.MKItem) error {
+	var record bytes.Buffer
+	encoder := json.NewEncoder(&record)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(item); err != nil {
+		return err
+	}
+
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	if sink.closed {
+		return errors.New("条目输出已关闭！")
+	}
+
+	return sink.file.write(record.Bytes())
+}
+
+func (sink *mk_jsonlSink) Flush() error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	return sink.file.flush()
+}
+
+func (sink *mk_jsonlSink) Close() error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	if sink.closed {
+		return nil
+	}
+
+	sink.closed = true
+	return sink.file.close()
+}
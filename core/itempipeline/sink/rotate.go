@@ -0,0 +1,236 @@
+package sink
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 轮转参数的描述模板
+var rotationArgumentsTemplate string = "{ path: %s, max bytes: %d, max items: %d, interval: %s, gzip: %v }"
+
+// 轮转文件名中时间戳的格式
+const rotationTimeLayout = "20060102T150405"
+
+// 文件输出的轮转参数。
+// 任一个轮转条件满足时，关闭当前文件并打开新文件。轮转条件在写入时检查，
+// 所以按时间轮转时，没有新条目写入的文件会保持打开直到下一次写入或关闭
+type RotationArguments struct {
+	Path        string        // 输出文件的路径，如data/items.jsonl
+	MaxBytes    uint64        // 单个文件的最大字节数（压缩之前），0表示不限
+	MaxItems    uint64        // 单个文件的最多条目数，0表示不限
+	Interval    time.Duration // 轮转的时间间隔，0表示不按时间轮转
+	Gzip        bool          // 是否用gzip压缩，压缩后的文件名以.gz结尾
+	description string        // 描述
+}
+
+func (arguments *RotationArguments) Check() error {
+	if strings.TrimSpace(arguments.Path) == "" {
+		return errors.New("输出文件的路径不能为空！")
+	}
+
+	if strings.HasSuffix(arguments.Path, string(filepath.Separator)) {
+		return errors.New(fmt.Sprintf("输出文件的路径不能是目录【%s】！", arguments.Path))
+	}
+
+	if arguments.Interval < 0 {
+		return errors.New(fmt.Sprintf("轮转的时间间隔不能为负数【%s】！", arguments.Interval))
+	}
+
+	return nil
+}
+
+func (arguments *RotationArguments) String() string {
+	if arguments.description == "" {
+		arguments.description =
+			fmt.Sprintf(rotationArgumentsTemplate,
+				arguments.Path,
+				arguments.MaxBytes,
+				arguments.MaxItems,
+				arguments.Interval,
+				arguments.Gzip)
+	}
+
+	return arguments.description
+}
+
+// 是否需要轮转
+func (arguments *RotationArguments) rotates() bool {
+	return arguments.MaxBytes > 0 || arguments.MaxItems > 0 || arguments.Interval > 0
+}
+
+// 可轮转的输出文件。
+// 不轮转时始终追加到Path；轮转时每个文件的名称为“名称-时间戳-序号.扩展名”，
+// 如items-20140315T120000-0001.jsonl。压缩时每次打开文件都会开始一个新的gzip成员，
+// 多个成员连接而成的文件仍然可以被gzip正常解压。
+// 不是并发安全的，由使用者加锁
+type rotatingFile struct {
+	arguments *RotationArguments      // 轮转参数
+	header    func(w io.Writer) error // 在每个新文件（空文件）的开头写入表头，可以为nil
+	now       func() time.Time        // 获取当前时间，便于测试
+	file      *os.File                // 当前文件
+	compress  *gzip.Writer            // 压缩器，不压缩时为nil
+	buffer    *bufio.Writer           // 缓冲
+	bytes     uint64                  // 当前文件已写入的字节数（压缩之前）
+	items     uint64                  // 当前文件已写入的条目数
+	opened    time.Time               // 当前文件的打开时间
+	sequence  uint32                  // 已打开的文件的数量
+}
+
+// 创建可轮转的输出文件。文件在第一次写入时才会被创建
+func newRotatingFile(arguments *RotationArguments, header func(w io.Writer) error) (*rotatingFile, error) {
+	if arguments == nil {
+		return nil, errors.New("轮转参数无效！")
+	}
+
+	if err := arguments.Check(); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(arguments.Path), 0755); err != nil {
+		return nil, err
+	}
+
+	return &rotatingFile{arguments: arguments, header: header, now: time.Now}, nil
+}
+
+// 写入一条记录（一个条目），必要时先轮转
+func (f *rotatingFile) write(record []byte) error {
+	if f.file != nil && f.shouldRotate(len(record)) {
+		if err := f.close(); err != nil {
+			return err
+		}
+	}
+
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return err
+		}
+	}
+
+	n, err := f.buffer.Write(record)
+	f.bytes += uint64(n)
+	if err != nil {
+		return err
+	}
+
+	f.items++
+	return nil
+}
+
+// 判断写入给定长度的记录之前是否需要轮转。
+// 单条记录超过最大字节数时仍然写入，且独占一个文件
+func (f *rotatingFile) shouldRotate(length int) bool {
+	arguments := f.arguments
+	switch {
+	case arguments.MaxItems > 0 && f.items >= arguments.MaxItems:
+		return true
+	case arguments.MaxBytes > 0 && f.items > 0 && f.bytes+uint64(length) > arguments.MaxBytes:
+		return true
+	case arguments.Interval > 0 && f.now().Sub(f.opened) >= arguments.Interval:
+		return true
+	}
+
+	return false
+}
+
+// 打开新文件，新文件为空时写入表头
+func (f *rotatingFile) open() error {
+	f.opened = f.now()
+	f.sequence++
+
+	path := f.currentPath()
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	var w io.Writer = file
+	if f.arguments.Gzip {
+		f.compress = gzip.NewWriter(file)
+		w = f.compress
+	}
+
+	f.file, f.buffer, f.bytes, f.items = file, bufio.NewWriter(w), 0, 0
+
+	if info.Size() == 0 && f.header != nil {
+		if err := f.header(f.buffer); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 获取当前文件的路径
+func (f *rotatingFile) currentPath() string {
+	path := f.arguments.Path
+	if f.arguments.rotates() {
+		ext := filepath.Ext(path)
+		path = fmt.Sprintf("%s-%s-%04d%s",
+			strings.TrimSuffix(path, ext), f.opened.Format(rotationTimeLayout), f.sequence, ext)
+	}
+
+	if f.arguments.Gzip && !strings.HasSuffix(path, ".gz") {
+		path += ".gz"
+	}
+
+	return path
+}
+
+// 把缓冲写入文件。压缩时同时刷新压缩器，使已写入的条目可以被解压读出
+func (f *rotatingFile) flush() error {
+	if f.file == nil {
+		return nil
+	}
+
+	if err := f.buffer.Flush(); err != nil {
+		return err
+	}
+
+	if f.compress != nil {
+		return f.compress.Flush()
+	}
+
+	return nil
+}
+
+// 刷新缓冲，结束压缩，把文件同步到磁盘并关闭
+func (f *rotatingFile) close() error {
+	if f.file == nil {
+		return nil
+	}
+
+	file := f.file
+	f.file = nil
+
+	err := f.buffer.Flush()
+	if f.compress != nil {
+		if closeErr := f.compress.Close(); err == nil {
+			err = closeErr
+		}
+		f.compress = nil
+	}
+
+	if syncErr := file.Sync(); err == nil {
+		err = syncErr
+	}
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
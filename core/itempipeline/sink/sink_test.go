@@ -0,0 +1,245 @@
+package sink
+
+import (
+	"bufio"
+	"compress/gzip"
+	base "core/base"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 读取目录中的所有输出文件，返回文件名与各行内容的映射
+func readOutput(t *testing.T, dir string) map[string][]string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := make(map[string][]string)
+	for _, entry := range entries {
+		file, err := os.Open(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		if filepath.Ext(entry.Name()) == ".gz" {
+			reader, err := gzip.NewReader(file)
+			if err != nil {
+				t.Fatalf("%s: %s", entry.Name(), err)
+			}
+			scanner = bufio.NewScanner(reader)
+		}
+
+		lines := make([]string, 0)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			t.Fatalf("%s: %s", entry.Name(), err)
+		}
+		output[entry.Name()] = lines
+	}
+
+	return output
+}
+
+func sortedNames(output map[string][]string) []string {
+	names := make([]string, 0, len(output))
+	for name := range output {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestJSONLSinkRotateByItems(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewJSONLSink(&RotationArguments{Path: filepath.Join(dir, "items.jsonl"), MaxItems: 2, Gzip: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := sink.Write(base.MKItem{"id": i, "title": "<a>"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write(base.MKItem{}); err == nil {
+		t.Error("expected an error after close")
+	}
+
+	output := readOutput(t, dir)
+	names := sortedNames(output)
+	if len(names) != 3 {
+		t.Fatalf("expected 3 files, got %v", names)
+	}
+
+	last := output[names[2]]
+	if filepath.Ext(names[2]) != ".gz" || len(last) != 1 || last[0] != `{"id":4,"title":"<a>"}` {
+		t.Errorf("unexpected last file %s: %v", names[2], last)
+	}
+
+	var item map[string]interface{}
+	if err := json.Unmarshal([]byte(output[names[0]][1]), &item); err != nil || item["id"] != float64(1) {
+		t.Errorf("unexpected item: %v (%v)", item, err)
+	}
+}
+
+func TestRotateByBytesAndInterval(t *testing.T) {
+	dir := t.TempDir()
+	arguments := &RotationArguments{Path: filepath.Join(dir, "items.csv"), MaxBytes: 10, Interval: time.Hour}
+	headers := 0
+	file, err := newRotatingFile(arguments, func(w io.Writer) error {
+		headers++
+		_, err := io.WriteString(w, "h\n")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2014, 3, 15, 12, 0, 0, 0, time.UTC)
+	file.now = func() time.Time { return now }
+
+	// 第1个文件：表头和两条记录，第三条记录会超过10字节
+	for _, record := range []string{"aaa\n", "bbb\n", "ccc\n"} {
+		if err := file.write([]byte(record)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 第2个文件已有一条记录，一小时后轮转出第3个文件
+	now = now.Add(time.Hour)
+	if err := file.write([]byte("ddd\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	output := readOutput(t, dir)
+	names := sortedNames(output)
+	expected := []string{"items-20140315T120000-0001.csv", "items-20140315T120000-0002.csv", "items-20140315T130000-0003.csv"}
+	if len(names) != len(expected) || headers != 3 {
+		t.Fatalf("expected files %v with 3 headers, got %v with %d headers", expected, names, headers)
+	}
+
+	for i, name := range expected {
+		if names[i] != name {
+			t.Errorf("file %d: expected %s, got %s", i, name, names[i])
+		}
+	}
+
+	if lines := output[names[0]]; len(lines) != 3 || lines[0] != "h" || lines[2] != "bbb" {
+		t.Errorf("unexpected first file: %v", lines)
+	}
+}
+
+func TestCSVSink(t *testing.T) {
+	columns, err := ParseCSVColumns(`
+		title=标题
+		author.name=作者
+		published=日期|date:2006-01-02
+		tags|join:/
+		meta
+		score|number:1
+		missing`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	sink, err := NewCSVSink(&RotationArguments{Path: filepath.Join(dir, "items.csv")}, &CSVArguments{Columns: columns})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items := []base.MKItem{
+		{
+			"title":     "a, \"b\"",
+			"author":    map[string]interface{}{"name": "Tang"},
+			"published": "2014-03-15T12:00:00Z",
+			"tags":      []string{"go", "ios"},
+			"meta":      map[string]interface{}{"lang": "zh", "source": map[string]interface{}{"host": "devtang.com"}},
+			"score":     3,
+		},
+		{"title": "only title"},
+	}
+	for _, item := range items {
+		if err := sink.Write(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := readOutput(t, dir)["items.csv"]
+	expected := []string{
+		"标题,作者,日期,tags,meta,score,missing",
+		`"a, ""b""",Tang,2014-03-15,go/ios,lang=zh; source.host=devtang.com,3.0,`,
+		"only title,,,,,,",
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %v", len(expected), lines)
+	}
+	for i, line := range expected {
+		if lines[i] != line {
+			t.Errorf("line %d: expected %s, got %s", i, line, lines[i])
+		}
+	}
+
+	if _, err := ParseCSVColumns("title|upper"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestCSVSinkCellErrors(t *testing.T) {
+	columns, _ := ParseCSVColumns("title\nscore|number\npublished|date")
+	path := filepath.Join(t.TempDir(), "items.csv")
+
+	sink, err := NewCSVSink(&RotationArguments{Path: path}, &CSVArguments{Columns: columns})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sink.Write(base.MKItem{"title": "bad cells", "score": "high", "published": "someday"})
+	if err == nil || !strings.Contains(err.Error(), "score") || !strings.Contains(err.Error(), "published") {
+		t.Errorf("expected errors for both bad cells, got %v", err)
+	}
+	if err := sink.Write(base.MKItem{"title": "good", "score": "1.5", "published": "2014-03-15"}); err != nil {
+		t.Error(err)
+	}
+	sink.Close()
+
+	// appending to an existing file skips the header even though the columns changed
+	columns, _ = ParseCSVColumns("title\nauthor")
+	sink, err = NewCSVSink(&RotationArguments{Path: path}, &CSVArguments{Columns: columns})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.Write(base.MKItem{"title": "appended", "author": "Tang"})
+	sink.Close()
+
+	lines := readOutput(t, filepath.Dir(path))["items.csv"]
+	expected := []string{
+		"title,score,published",
+		"bad cells,,",
+		"good,1.5,2014-03-15T00:00:00Z",
+		"appended,Tang",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected %v, got %v", expected, lines)
+	}
+}
@@ -0,0 +1,256 @@
+package registry
+
+import (
+	analyzer "core/analyzer"
+	base "core/base"
+	itempipeline "core/itempipeline"
+	sink "core/itempipeline/sink"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 内置的解析器
+var builtinParsers = []*ParserSpec{
+	{
+		Name:        "link-extractor",
+		Description: "从HTML文档中提取链接并生成请求",
+		Options: Schema{
+			{Name: "tags", Type: OPTION_LIST, Usage: "提取链接的标签，默认为a和area"},
+			{Name: "followNofollow", Type: OPTION_BOOL, Usage: "是否跟随带有rel=\"nofollow\"的链接"},
+		},
+		New: func(options Options) (analyzer.MKParseResponse, error) {
+			return analyzer.NewLinkExtractor(&analyzer.LinkExtractorArguments{
+				Tags:           options.List("tags"),
+				FollowNofollow: options.Bool("followNofollow"),
+			})
+		},
+	},
+	{
+		Name:        "readability",
+		Description: "提取文章页面的正文、标题、作者和发布时间",
+		Options: Schema{
+			{Name: "minLength", Type: OPTION_UINT, Usage: "正文的最少字符数，默认为140"},
+		},
+		New: func(options Options) (analyzer.MKParseResponse, error) {
+			return analyzer.NewReadabilityParser(&analyzer.ReadabilityArguments{MinLength: uint32(options.Uint("minLength"))})
+		},
+	},
+	{
+		Name:        "feed",
+		Description: "解析RSS 2.0和Atom订阅源",
+		Options: Schema{
+			{Name: "followLinks", Type: OPTION_BOOL, Usage: "是否为每个条目的链接生成请求"},
+		},
+		New: func(options Options) (analyzer.MKParseResponse, error) {
+			return analyzer.NewFeedParser(&analyzer.FeedArguments{FollowLinks: options.Bool("followLinks")})
+		},
+	},
+	{
+		Name:        "structured-data",
+		Description: "提取JSON-LD、微数据、OpenGraph和Twitter卡片",
+		Options: Schema{
+			{Name: "sources", Type: OPTION_LIST, Usage: "使用的来源：jsonld、microdata、opengraph、twitter，默认为全部"},
+		},
+		New: func(options Options) (analyzer.MKParseResponse, error) {
+			return analyzer.NewStructuredDataParser(&analyzer.StructuredDataArguments{Sources: options.List("sources")})
+		},
+	},
+}
+
+// 内置的条目处理器
+var builtinProcessors = []*ProcessorSpec{
+	{
+		Name:        "trim-fields",
+		Description: "去掉字符串字段两端的空白",
+		Options: Schema{
+			{Name: "fields", Type: OPTION_LIST, Usage: "要处理的字段，默认为所有字段"},
+		},
+		New: func(options Options) (itempipeline.MKProcessItem, error) {
+			fields := options.List("fields")
+			return func(item base.MKItem) (base.MKItem, error) {
+				result := make(base.MKItem, len(item))
+				for key, value := range item {
+					if text, ok := value.(string); ok && (len(fields) == 0 || containsField(fields, key)) {
+						value = strings.TrimSpace(text)
+					}
+					result[key] = value
+				}
+				return result, nil
+			}, nil
+		},
+	},
+	{
+		Name:        "filter",
+		Description: "只保留使表达式为真的条目，丢弃其他条目",
+		Options: Schema{
+			{Name: "expr", Type: OPTION_EXPR, Required: true, Usage: "条目表达式，如not empty(title) and len(body) > 100"},
+		},
+		New: func(options Options) (itempipeline.MKProcessItem, error) {
+			test := options.Expr("expr")
+			return func(item base.MKItem) (base.MKItem, error) {
+				ok, err := test.Test(item)
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					return nil, itempipeline.NewDropError(fmt.Sprintf("不满足条件【%s】", test))
+				}
+				return nil, nil
+			}, nil
+		},
+	},
+	{
+		Name:        "map",
+		Description: "把表达式的值赋给字段，值为null时删除字段",
+		Options: Schema{
+			{Name: "field", Type: OPTION_STRING, Required: true, Usage: "赋值的字段，可以是新字段"},
+			{Name: "expr", Type: OPTION_EXPR, Required: true, Usage: "条目表达式，如concat(author, ' - ', title)"},
+		},
+		New: func(options Options) (itempipeline.MKProcessItem, error) {
+			field, value := options.String("field"), options.Expr("expr")
+			return func(item base.MKItem) (base.MKItem, error) {
+				evaluated, err := value.Evaluate(item)
+				if err != nil {
+					return nil, err
+				}
+
+				result := make(base.MKItem, len(item)+1)
+				for key, v := range item {
+					result[key] = v
+				}
+				if evaluated == nil {
+					delete(result, field)
+				} else {
+					result[field] = evaluated
+				}
+				return result, nil
+			}, nil
+		},
+	},
+	{
+		Name:        "jsonl-writer",
+		Description: "把条目写入JSON Lines文件，可以按大小、条目数或时间轮转并压缩",
+		Options:     rotationOptions,
+		NewSink: func(options Options) (itempipeline.MKItemSink, error) {
+			return sink.NewJSONLSink(rotationArguments(options))
+		},
+	},
+	{
+		Name:        "csv-writer",
+		Description: "按声明的列把条目写入CSV文件，可以按大小、条目数或时间轮转并压缩",
+		Options: withRotationOptions(
+			Option{Name: "columns", Type: OPTION_STRING, Required: true,
+				Usage: "列的声明，每行一列，格式为“字段[=表头][|格式]”，格式为text、json、join[:分隔符]、date[:布局]或number[:位数]"},
+			Option{Name: "delimiter", Type: OPTION_STRING, Default: ",", Usage: "字段分隔符，tab表示制表符"},
+			Option{Name: "header", Type: OPTION_BOOL, Default: "true", Usage: "是否在每个新文件的开头写入表头"},
+			Option{Name: "nested", Type: OPTION_STRING, Default: sink.CSV_NESTED_FLATTEN, Usage: "列表和映射的默认格式，flatten或json"},
+			Option{Name: "separator", Type: OPTION_STRING, Usage: "展开列表和映射时使用的分隔符，默认为“; ”"},
+		),
+		NewSink: func(options Options) (itempipeline.MKItemSink, error) {
+			columns, err := sink.ParseCSVColumns(options.String("columns"))
+			if err != nil {
+				return nil, err
+			}
+
+			delimiter, err := parseDelimiter(options.String("delimiter"))
+			if err != nil {
+				return nil, err
+			}
+
+			return sink.NewCSVSink(rotationArguments(options), &sink.CSVArguments{
+				Columns:    columns,
+				Delimiter:  delimiter,
+				SkipHeader: !options.Bool("header"),
+				Nested:     options.String("nested"),
+				Separator:  options.String("separator"),
+			})
+		},
+	},
+	{
+		Name:        "select-fields",
+		Description: "只保留给定的字段",
+		Options: Schema{
+			{Name: "fields", Type: OPTION_LIST, Required: true, Usage: "保留的字段"},
+		},
+		New: func(options Options) (itempipeline.MKProcessItem, error) {
+			fields := options.List("fields")
+			return func(item base.MKItem) (base.MKItem, error) {
+				result := make(base.MKItem, len(fields))
+				for _, field := range fields {
+					if value, ok := item[field]; ok {
+						result[field] = value
+					}
+				}
+				return result, nil
+			}, nil
+		},
+	},
+}
+
+// 文件输出共用的轮转选项
+var rotationOptions = Schema{
+	{Name: "path", Type: OPTION_STRING, Required: true, Usage: "输出文件的路径，轮转时作为文件名的模板"},
+	{Name: "maxBytes", Type: OPTION_UINT, Usage: "单个文件的最大字节数（压缩之前），0表示不限"},
+	{Name: "maxItems", Type: OPTION_UINT, Usage: "单个文件的最多条目数，0表示不限"},
+	{Name: "interval", Type: OPTION_DURATION, Usage: "按时间轮转的间隔，如1h，0表示不按时间轮转"},
+	{Name: "gzip", Type: OPTION_BOOL, Usage: "是否用gzip压缩"},
+}
+
+// 在轮转选项之后追加其他选项
+func withRotationOptions(options ...Option) Schema {
+	schema := make(Schema, 0, len(rotationOptions)+len(options))
+	schema = append(schema, rotationOptions...)
+	return append(schema, options...)
+}
+
+// 按轮转选项创建轮转参数
+func rotationArguments(options Options) *sink.RotationArguments {
+	return &sink.RotationArguments{
+		Path:     options.String("path"),
+		MaxBytes: options.Uint("maxBytes"),
+		MaxItems: options.Uint("maxItems"),
+		Interval: options.Duration("interval"),
+		Gzip:     options.Bool("gzip"),
+	}
+}
+
+// 解析CSV的字段分隔符，只能是一个字符
+func parseDelimiter(text string) (rune, error) {
+	if text == "tab" || text == "\\t" {
+		return '\t', nil
+	}
+
+	runes := []rune(text)
+	if len(runes) != 1 {
+		return 0, errors.New(fmt.Sprintf("字段分隔符只能是一个字符【%s】", text))
+	}
+
+	return runes[0], nil
+}
+
+// 判断字段列表中是否包含给定的字段
+func containsField(fields []string, field string) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 注册内置的解析器和条目处理器
+func init() {
+	for _, spec := range builtinParsers {
+		if err := RegisterParser(spec); err != nil {
+			panic(err)
+		}
+	}
+
+	for _, spec := range builtinProcessors {
+		if err := RegisterProcessor(spec); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package registry
+
+import (
+	downloader "core/downloader"
+	dns "core/tool/dns"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 默认的网页下载器的名称
+const DEFAULT_DOWNLOADER = "http"
+
+// 网页下载器的规格。
+// New和NewInEnv二者必须有一个：需要使用站点的Cookie容器等环境的网页下载器（如http）使用NewInEnv
+type DownloaderSpec struct {
+	Name        string                                                                         // 名称，如http
+	Description string                                                                         // 说明
+	Options     Schema                                                                         // 选项模式
+	New         func(options Options) (downloader.MKPageDownloader, error)                     // 创建网页下载器
+	NewInEnv    func(options Options, env *DownloaderEnv) (downloader.MKPageDownloader, error) // 在站点的环境中创建网页下载器
+}
+
+// 创建网页下载器时由站点提供的环境。
+// 同一个站点的环境在多次创建之间共享，例如Cookie容器在爬取结束时由站点保存
+type DownloaderEnv struct {
+	Jar      http.CookieJar               // 站点的Cookie容器，为nil时不保存Cookie
+	Login    *downloader.LoginArguments   // 站点的登录参数，为nil时不需要登录
+	Auth     *downloader.AuthArguments    // 站点的认证参数，为nil时不需要认证
+	Resolver dns.MKResolver               // 域名解析器，为nil时使用共享的默认解析器
+	Profile  *downloader.TransportProfile // 站点的传输配置，为nil时使用空的默认配置
+}
+
+// 获取环境中已指定的站点设置的名称。不使用环境的网页下载器不能支持这些设置
+func (env *DownloaderEnv) settings() []string {
+	settings := make([]string, 0)
+	if env.Login != nil {
+		settings = append(settings, "登录")
+	}
+	if env.Auth != nil {
+		settings = append(settings, "认证")
+	}
+	if env.Profile != nil && env.Profile.Name != downloader.DefaultTransportProfileName {
+		settings = append(settings, "传输配置")
+	}
+
+	return settings
+}
+
+// 网页下载器的注册表
+var downloaders = struct {
+	specs  map[string]*DownloaderSpec // 名称与网页下载器规格的映射
+	rwlock sync.RWMutex               // 读写锁
+}{
+	specs: make(map[string]*DownloaderSpec),
+}
+
+// 注册网页下载器。名称已被注册时返回错误
+func RegisterDownloader(spec *DownloaderSpec) error {
+	if spec == nil {
+		return errors.New("无效的网页下载器规格！")
+	}
+
+	if err := checkSpec("网页下载器", spec.Name, spec.Options, spec.New != nil || spec.NewInEnv != nil); err != nil {
+		return err
+	}
+
+	if spec.New != nil && spec.NewInEnv != nil {
+		return errors.New(fmt.Sprintf("网页下载器【%s】不能同时提供New和NewInEnv！", spec.Name))
+	}
+
+	downloaders.rwlock.Lock()
+	defer downloaders.rwlock.Unlock()
+
+	if _, ok := downloaders.specs[spec.Name]; ok {
+		return errors.New(fmt.Sprintf("网页下载器【%s】已被注册！", spec.Name))
+	}
+
+	// 加载插件时先暂存，插件注册成功之后再加入注册表
+	if pending := currentStage(); pending != nil {
+		return pending.addDownloader(spec)
+	}
+
+	downloaders.specs[spec.Name] = spec
+	return nil
+}
+
+// 按名称查找网页下载器规格
+func LookupDownloader(name string) (*DownloaderSpec, bool) {
+	downloaders.rwlock.RLock()
+	defer downloaders.rwlock.RUnlock()
+
+	spec, ok := downloaders.specs[name]
+	return spec, ok
+}
+
+// 获取所有已注册的网页下载器规格，按名称排序
+func Downloaders() []*DownloaderSpec {
+	downloaders.rwlock.RLock()
+	defer downloaders.rwlock.RUnlock()
+
+	specs := make([]*DownloaderSpec, 0, len(downloaders.specs))
+	for _, spec := range downloaders.specs {
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+
+	return specs
+}
+
+// 判断网页下载器是否使用站点的环境。不使用环境的网页下载器不支持站点的登录、认证等设置
+func DownloaderUsesEnv(name string) bool {
+	spec, ok := LookupDownloader(name)
+	return ok && spec.NewInEnv != nil
+}
+
+// 检查网页下载器是否存在以及参数是否符合其选项模式，不会创建网页下载器
+func CheckDownloader(name string, params map[string]string) error {
+	spec, ok := LookupDownloader(name)
+	if !ok {
+		return errors.New(fmt.Sprintf("未知的网页下载器【%s】！", name))
+	}
+
+	if _, err := spec.Options.Parse(params); err != nil {
+		return errors.New(fmt.Sprintf("网页下载器【%s】: %s", name, err))
+	}
+
+	return nil
+}
+
+// 按名称和参数在站点的环境中创建网页下载器。参数env为nil时使用空的环境
+func NewDownloader(name string, params map[string]string, env *DownloaderEnv) (downloader.MKPageDownloader, error) {
+	spec, ok := LookupDownloader(name)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("未知的网页下载器【%s】！", name))
+	}
+
+	options, err := spec.Options.Parse(params)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("网页下载器【%s】: %s", name, err))
+	}
+
+	if env == nil {
+		env = &DownloaderEnv{}
+	}
+
+	var pageDownloader downloader.MKPageDownloader
+	if spec.NewInEnv != nil {
+		pageDownloader, err = spec.NewInEnv(options, env)
+	} else if settings := env.settings(); len(settings) > 0 {
+		err = errors.New(fmt.Sprintf("不支持站点的%s设置！", strings.Join(settings, "、")))
+	} else {
+		pageDownloader, err = spec.New(options)
+	}
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("网页下载器【%s】: %s", name, err))
+	}
+
+	return pageDownloader, nil
+}
+
+// 内置的网页下载器
+var builtinDownloaders = []*DownloaderSpec{
+	{
+		Name:        DEFAULT_DOWNLOADER,
+		Description: "通过HTTP(S)下载网页",
+		Options: Schema{
+			{Name: "connectTimeout", Type: OPTION_DURATION, Usage: "建立连接的超时时间"},
+			{Name: "readTimeout", Type: OPTION_DURATION, Usage: "发出请求后等待响应头的超时时间"},
+			{Name: "timeout", Type: OPTION_DURATION, Usage: "整个请求的超时时间"},
+			{Name: "insecureSkipVerify", Type: OPTION_BOOL, Usage: "是否跳过证书校验，仅用于测试环境"},
+		},
+		NewInEnv: func(options Options, env *DownloaderEnv) (downloader.MKPageDownloader, error) {
+			// 以站点的传输配置为基础，网页下载器的参数优先
+			profile := &downloader.TransportProfile{Name: downloader.DefaultTransportProfileName}
+			if env.Profile != nil {
+				profile = env.Profile.Copy()
+			}
+			overrideDuration(&profile.ConnectTimeout, options, "connectTimeout")
+			overrideDuration(&profile.ReadTimeout, options, "readTimeout")
+			overrideDuration(&profile.Timeout, options, "timeout")
+			profile.InsecureSkipVerify = profile.InsecureSkipVerify || options.Bool("insecureSkipVerify")
+
+			client, err := profile.NewClient(env.Resolver)
+			if err != nil {
+				return nil, err
+			}
+			client.Jar = env.Jar
+
+			if env.Auth != nil {
+				if client, err = downloader.NewAuthClient(env.Auth, client); err != nil {
+					return nil, err
+				}
+			}
+
+			if env.Login != nil {
+				session, err := downloader.NewLoginSession(env.Login, client)
+				if err != nil {
+					return nil, err
+				}
+				return downloader.NewLoginPageDownloader(session), nil
+			}
+
+			return downloader.NewPageDownloader(client), nil
+		},
+	},
+	{
+		Name:        "file",
+		Description: "读取file://形式的URL，或按主机名把HTTP(S)请求映射到本地镜像目录",
+		Options: Schema{
+			{Name: "roots", Type: OPTION_LIST, Usage: "主机名与本地根目录的映射，如blog.devtang.com=/data/mirror/devtang"},
+		},
+		New: func(options Options) (downloader.MKPageDownloader, error) {
+			roots := make(map[string]string)
+			for _, entry := range options.List("roots") {
+				parts := strings.SplitN(entry, "=", 2)
+				if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+					return nil, errors.New(fmt.Sprintf("无效的目录映射【%s】，应为主机名=目录", entry))
+				}
+				roots[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+			}
+
+			return downloader.NewFilePageDownloader(roots), nil
+		},
+	},
+}
+
+// 把时间长度选项转换为传输配置使用的格式，0表示不限制
+func formatDuration(options Options, name string) string {
+	if duration := options.Duration(name); duration > 0 {
+		return duration.String()
+	}
+
+	return ""
+}
+
+// 选项给出了时间长度时，用它覆盖传输配置中的对应字段
+func overrideDuration(field *string, options Options, name string) {
+	if duration := formatDuration(options, name); duration != "" {
+		*field = duration
+	}
+}
+
+func init() {
+	for _, spec := range builtinDownloaders {
+		if err := RegisterDownloader(spec); err != nil {
+			panic(err)
+		}
+	}
+}
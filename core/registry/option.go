@@ -0,0 +1,242 @@
+package registry
+
+import (
+	expression "core/itempipeline/expression"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 选项的类型
+type OptionType string
+
+// 选项类型常量
+const (
+	OPTION_STRING   OptionType = "string"   // 字符串
+	OPTION_BOOL     OptionType = "bool"     // 布尔值
+	OPTION_INT      OptionType = "int"      // 整数
+	OPTION_UINT     OptionType = "uint"     // 非负整数
+	OPTION_FLOAT    OptionType = "float"    // 浮点数
+	OPTION_DURATION OptionType = "duration" // 时间长度，time.ParseDuration可以解析的格式，如10s
+	OPTION_LIST     OptionType = "list"     // 以逗号分隔的字符串列表
+	OPTION_EXPR     OptionType = "expr"     // 条目表达式，见expression.Compile
+)
+
+// 所有选项类型的集合
+var optionTypes = map[OptionType]bool{
+	OPTION_STRING:   true,
+	OPTION_BOOL:     true,
+	OPTION_INT:      true,
+	OPTION_UINT:     true,
+	OPTION_FLOAT:    true,
+	OPTION_DURATION: true,
+	OPTION_LIST:     true,
+	OPTION_EXPR:     true,
+}
+
+// 选项的定义
+type Option struct {
+	Name     string     // 选项名称
+	Type     OptionType // 选项类型
+	Required bool       // 是否必须指定
+	Default  string     // 未指定时使用的默认值
+	Usage    string     // 说明
+}
+
+// 选项模式，即组件接受的所有选项的定义
+type Schema []Option
+
+// 检查选项模式本身是否有效
+func (schema Schema) Check() error {
+	names := make(map[string]bool)
+	for _, option := range schema {
+		if option.Name == "" {
+			return errors.New("选项名称不能为空！")
+		}
+
+		if names[option.Name] {
+			return errors.New(fmt.Sprintf("选项名称重复【%s】！", option.Name))
+		}
+		names[option.Name] = true
+
+		if !optionTypes[option.Type] {
+			return errors.New(fmt.Sprintf("选项【%s】的类型未知【%s】！", option.Name, option.Type))
+		}
+
+		if option.Default != "" {
+			if _, err := parseOption(option.Type, option.Default); err != nil {
+				return errors.New(fmt.Sprintf("选项【%s】的默认值无效: %s", option.Name, err))
+			}
+		}
+	}
+
+	return nil
+}
+
+// 按选项模式解析参数。
+// 参数中不能有未定义的选项，必须指定的选项不能缺少；未指定的选项使用默认值（没有默认值时为类型的零值）
+func (schema Schema) Parse(params map[string]string) (Options, error) {
+	defined := make(map[string]bool, len(schema))
+	for _, option := range schema {
+		defined[option.Name] = true
+	}
+
+	unknown := make([]string, 0)
+	for name := range params {
+		if !defined[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, errors.New(fmt.Sprintf("未知的选项【%s】，可用的选项: %s",
+			strings.Join(unknown, ", "), strings.Join(schema.names(), ", ")))
+	}
+
+	options := make(Options, len(schema))
+	for _, option := range schema {
+		raw, ok := params[option.Name]
+		raw = strings.TrimSpace(raw)
+		if !ok || raw == "" {
+			if option.Required {
+				return nil, errors.New(fmt.Sprintf("缺少必须指定的选项【%s】！", option.Name))
+			}
+			raw = option.Default
+		}
+
+		value, err := parseOption(option.Type, raw)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("选项【%s】%s", option.Name, err))
+		}
+		options[option.Name] = value
+	}
+
+	return options, nil
+}
+
+// 获取所有选项的名称
+func (schema Schema) names() []string {
+	names := make([]string, 0, len(schema))
+	for _, option := range schema {
+		names = append(names, option.Name)
+	}
+
+	return names
+}
+
+// 按类型解析选项的值。空字符串解析为类型的零值
+func parseOption(optionType OptionType, raw string) (interface{}, error) {
+	switch optionType {
+	case OPTION_STRING:
+		return raw, nil
+	case OPTION_LIST:
+		list := make([]string, 0)
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				list = append(list, value)
+			}
+		}
+		return list, nil
+	}
+
+	if raw == "" {
+		switch optionType {
+		case OPTION_EXPR:
+			return nil, nil
+		case OPTION_BOOL:
+			return false, nil
+		case OPTION_INT:
+			return int64(0), nil
+		case OPTION_UINT:
+			return uint64(0), nil
+		case OPTION_FLOAT:
+			return float64(0), nil
+		case OPTION_DURATION:
+			return time.Duration(0), nil
+		}
+	}
+
+	var value interface{}
+	var err error
+	switch optionType {
+	case OPTION_BOOL:
+		value, err = strconv.ParseBool(raw)
+	case OPTION_INT:
+		value, err = strconv.ParseInt(raw, 10, 64)
+	case OPTION_UINT:
+		value, err = strconv.ParseUint(raw, 10, 64)
+	case OPTION_FLOAT:
+		value, err = strconv.ParseFloat(raw, 64)
+	case OPTION_DURATION:
+		value, err = time.ParseDuration(raw)
+	case OPTION_EXPR:
+		// 表达式的错误信息已包含出错的位置，直接返回
+		compiled, err := expression.Compile(raw)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("无效: %s", strings.TrimSpace(err.Error())))
+		}
+		return compiled, nil
+	default:
+		return nil, errors.New(fmt.Sprintf("的类型未知【%s】！", optionType))
+	}
+
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("的值无效，应为%s: %s", optionType, raw))
+	}
+
+	return value, nil
+}
+
+// 按选项模式解析后的选项值
+type Options map[string]interface{}
+
+// 获取字符串选项的值
+func (options Options) String(name string) string {
+	value, _ := options[name].(string)
+	return value
+}
+
+// 获取布尔选项的值
+func (options Options) Bool(name string) bool {
+	value, _ := options[name].(bool)
+	return value
+}
+
+// 获取整数选项的值
+func (options Options) Int(name string) int64 {
+	value, _ := options[name].(int64)
+	return value
+}
+
+// 获取非负整数选项的值
+func (options Options) Uint(name string) uint64 {
+	value, _ := options[name].(uint64)
+	return value
+}
+
+// 获取浮点数选项的值
+func (options Options) Float(name string) float64 {
+	value, _ := options[name].(float64)
+	return value
+}
+
+// 获取时间长度选项的值
+func (options Options) Duration(name string) time.Duration {
+	value, _ := options[name].(time.Duration)
+	return value
+}
+
+// 获取表达式选项的值，未指定时为nil
+func (options Options) Expr(name string) expression.MKExpression {
+	value, _ := options[name].(expression.MKExpression)
+	return value
+}
+
+// 获取列表选项的值
+func (options Options) List(name string) []string {
+	value, _ := options[name].([]string)
+	return value
+}
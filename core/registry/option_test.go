@@ -0,0 +1,63 @@
+package registry
+
+import (
+	"testing"
+	"time"
+)
+
+var testSchema = Schema{
+	{Name: "fields", Type: OPTION_LIST, Required: true},
+	{Name: "limit", Type: OPTION_UINT, Default: "10"},
+	{Name: "interval", Type: OPTION_DURATION},
+	{Name: "gzip", Type: OPTION_BOOL},
+}
+
+func TestSchemaParse(t *testing.T) {
+	options, err := testSchema.Parse(map[string]string{
+		"fields":   "title, url,",
+		"interval": "5s",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fields := options.List("fields"); len(fields) != 2 || fields[1] != "url" {
+		t.Errorf("unexpected fields: %v", fields)
+	}
+	if options.Uint("limit") != 10 {
+		t.Errorf("expected default limit 10, got %d", options.Uint("limit"))
+	}
+	if options.Duration("interval") != 5*time.Second || options.Bool("gzip") {
+		t.Errorf("unexpected options: %v", options)
+	}
+}
+
+func TestSchemaParseErrors(t *testing.T) {
+	invalid := []map[string]string{
+		{},
+		{"fields": "title", "limit": "-1"},
+		{"fields": "title", "unknown": "1"},
+	}
+
+	for _, params := range invalid {
+		if _, err := testSchema.Parse(params); err == nil {
+			t.Errorf("expected an error for %v", params)
+		}
+	}
+}
+
+func TestExprOption(t *testing.T) {
+	schema := Schema{{Name: "expr", Type: OPTION_EXPR, Required: true}}
+
+	options, err := schema.Parse(map[string]string{"expr": "len(title) > 3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := options.Expr("expr").Test(map[string]interface{}{"title": "abcd"}); err != nil || !ok {
+		t.Errorf("expected true, got %v (%v)", ok, err)
+	}
+
+	if _, err := schema.Parse(map[string]string{"expr": "len(title >"}); err == nil {
+		t.Error("expected a syntax error")
+	}
+}
@@ -0,0 +1,314 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 插件接口的版本，格式为“主版本.次版本”。
+// 主版本相同且插件的次版本不高于此版本的插件才能被加载。
+// 注册表中的规格类型或注册函数发生不兼容的变化时增加主版本，新增功能时增加次版本
+const PLUGIN_API_VERSION = "1.2"
+
+// 插件中必须导出的变量的名称，其类型为PluginInfo
+const PLUGIN_SYMBOL = "MKPlugin"
+
+// 插件文件的扩展名
+const PLUGIN_EXT = ".so"
+
+// 插件的信息。
+// 插件是以-buildmode=plugin编译的main包，需要导出名为MKPlugin的PluginInfo变量，例如：
+//
+//	var MKPlugin = registry.PluginInfo{
+//		Name:       "acme",
+//		Version:    "1.2.0",
+//		APIVersion: registry.PLUGIN_API_VERSION,
+//		Register:   register,
+//	}
+//
+// 其中register通过RegisterParser、RegisterProcessor或RegisterDownloader注册组件。
+// register返回错误时，插件已注册的组件都不会生效。
+// 插件必须与mkcrawler使用同一版本的Go和同一份core源码编译，否则无法打开
+type PluginInfo struct {
+	Name       string       // 插件名称
+	Version    string       // 插件自身的版本
+	APIVersion string       // 编译插件时的插件接口版本，应为PLUGIN_API_VERSION
+	Register   func() error // 注册插件提供的组件
+}
+
+// 已加载的插件
+type LoadedPlugin struct {
+	Path       string // 插件文件的路径
+	Name       string // 插件名称
+	Version    string // 插件自身的版本
+	APIVersion string // 插件接口版本
+}
+
+// 已加载的插件列表
+var plugins = struct {
+	loaded []*LoadedPlugin // 按加载顺序排列
+	mutex  sync.Mutex      // 互斥锁
+}{}
+
+// 加载目录中的所有插件（*.so），按文件名的顺序依次加载并注册其组件。
+// 参数dir为空时不加载任何插件。任一个插件加载失败时停止加载并返回错误
+func LoadPlugins(dir string) error {
+	if dir == "" {
+		return nil
+	}
+
+	paths, err := pluginFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		info, err := openPlugin(path)
+		if err != nil {
+			return err
+		}
+
+		if err := registerPlugin(path, info); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 获取已加载的插件，按加载顺序排列
+func Plugins() []*LoadedPlugin {
+	plugins.mutex.Lock()
+	defer plugins.mutex.Unlock()
+
+	loaded := make([]*LoadedPlugin, len(plugins.loaded))
+	copy(loaded, plugins.loaded)
+	return loaded
+}
+
+// 获取目录中的插件文件，按文件名排序
+func pluginFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("无法读取插件目录【%s】: %s", dir, err))
+	}
+
+	paths := make([]string, 0)
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), PLUGIN_EXT) {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// 检查插件的信息和接口版本，然后注册插件的组件
+func registerPlugin(path string, info *PluginInfo) error {
+	if info == nil || strings.TrimSpace(info.Name) == "" {
+		return errors.New(fmt.Sprintf("插件【%s】的名称为空！", path))
+	}
+
+	if info.Register == nil {
+		return errors.New(fmt.Sprintf("插件【%s】（%s）缺少注册函数！", info.Name, path))
+	}
+
+	if err := checkAPIVersion(info.APIVersion); err != nil {
+		return errors.New(fmt.Sprintf("插件【%s】（%s）与当前版本不兼容: %s", info.Name, path, err))
+	}
+
+	plugins.mutex.Lock()
+	defer plugins.mutex.Unlock()
+
+	for _, loaded := range plugins.loaded {
+		if loaded.Name == info.Name {
+			return errors.New(fmt.Sprintf("插件【%s】已从%s加载，不能重复加载%s！", info.Name, loaded.Path, path))
+		}
+	}
+
+	// 组件先放入暂存区，注册函数成功返回之后才一并加入注册表
+	pending := newComponentStage()
+	if err := pending.run(info.Register); err != nil {
+		return errors.New(fmt.Sprintf("插件【%s】（%s）注册组件失败: %s", info.Name, path, err))
+	}
+
+	if err := pending.commit(); err != nil {
+		return errors.New(fmt.Sprintf("插件【%s】（%s）注册组件失败: %s", info.Name, path, err))
+	}
+
+	plugins.loaded = append(plugins.loaded, &LoadedPlugin{
+		Path:       path,
+		Name:       info.Name,
+		Version:    info.Version,
+		APIVersion: info.APIVersion,
+	})
+	return nil
+}
+
+// 插件注册组件时的暂存区。
+// 插件的注册函数执行期间，注册的组件先放入暂存区，注册函数成功返回之后才一并加入注册表；
+// 注册函数返回错误时丢弃暂存区，使注册到一半失败的插件不会留下任何组件
+type componentStage struct {
+	parsers     map[string]*ParserSpec     // 暂存的解析器规格
+	processors  map[string]*ProcessorSpec  // 暂存的条目处理器规格
+	downloaders map[string]*DownloaderSpec // 暂存的网页下载器规格
+}
+
+// 当前使用的暂存区，为nil时组件直接加入注册表
+var stage = struct {
+	current *componentStage // 暂存区
+	mutex   sync.Mutex      // 互斥锁
+}{}
+
+// 创建暂存区
+func newComponentStage() *componentStage {
+	return &componentStage{
+		parsers:     make(map[string]*ParserSpec),
+		processors:  make(map[string]*ProcessorSpec),
+		downloaders: make(map[string]*DownloaderSpec),
+	}
+}
+
+// 获取当前使用的暂存区
+func currentStage() *componentStage {
+	stage.mutex.Lock()
+	defer stage.mutex.Unlock()
+
+	return stage.current
+}
+
+// 在使用暂存区的情况下执行注册函数。注册函数返回（包括panic）之后不再使用暂存区
+func (pending *componentStage) run(register func() error) error {
+	stage.mutex.Lock()
+	stage.current = pending
+	stage.mutex.Unlock()
+
+	defer func() {
+		stage.mutex.Lock()
+		stage.current = nil
+		stage.mutex.Unlock()
+	}()
+
+	return register()
+}
+
+// 暂存解析器规格。名称已被暂存时返回错误
+func (pending *componentStage) addParser(spec *ParserSpec) error {
+	stage.mutex.Lock()
+	defer stage.mutex.Unlock()
+
+	if _, ok := pending.parsers[spec.Name]; ok {
+		return errors.New(fmt.Sprintf("解析器【%s】已被注册！", spec.Name))
+	}
+
+	pending.parsers[spec.Name] = spec
+	return nil
+}
+
+// 暂存条目处理器规格。名称已被暂存时返回错误
+func (pending *componentStage) addProcessor(spec *ProcessorSpec) error {
+	stage.mutex.Lock()
+	defer stage.mutex.Unlock()
+
+	if _, ok := pending.processors[spec.Name]; ok {
+		return errors.New(fmt.Sprintf("条目处理器【%s】已被注册！", spec.Name))
+	}
+
+	pending.processors[spec.Name] = spec
+	return nil
+}
+
+// 暂存网页下载器规格。名称已被暂存时返回错误
+func (pending *componentStage) addDownloader(spec *DownloaderSpec) error {
+	stage.mutex.Lock()
+	defer stage.mutex.Unlock()
+
+	if _, ok := pending.downloaders[spec.Name]; ok {
+		return errors.New(fmt.Sprintf("网页下载器【%s】已被注册！", spec.Name))
+	}
+
+	pending.downloaders[spec.Name] = spec
+	return nil
+}
+
+// 把暂存的组件加入注册表。任一个名称已被注册时不加入任何组件
+func (pending *componentStage) commit() error {
+	registry.rwlock.Lock()
+	defer registry.rwlock.Unlock()
+
+	downloaders.rwlock.Lock()
+	defer downloaders.rwlock.Unlock()
+
+	for name := range pending.parsers {
+		if _, ok := registry.parsers[name]; ok {
+			return errors.New(fmt.Sprintf("解析器【%s】已被注册！", name))
+		}
+	}
+
+	for name := range pending.processors {
+		if _, ok := registry.processors[name]; ok {
+			return errors.New(fmt.Sprintf("条目处理器【%s】已被注册！", name))
+		}
+	}
+
+	for name := range pending.downloaders {
+		if _, ok := downloaders.specs[name]; ok {
+			return errors.New(fmt.Sprintf("网页下载器【%s】已被注册！", name))
+		}
+	}
+
+	for name, spec := range pending.parsers {
+		registry.parsers[name] = spec
+	}
+	for name, spec := range pending.processors {
+		registry.processors[name] = spec
+	}
+	for name, spec := range pending.downloaders {
+		downloaders.specs[name] = spec
+	}
+
+	return nil
+}
+
+// 检查插件接口版本是否与PLUGIN_API_VERSION兼容
+func checkAPIVersion(version string) error {
+	major, minor, err := parseAPIVersion(version)
+	if err != nil {
+		return err
+	}
+
+	hostMajor, hostMinor, _ := parseAPIVersion(PLUGIN_API_VERSION)
+	if major != hostMajor || minor > hostMinor {
+		return errors.New(fmt.Sprintf("插件接口版本为%s，当前支持的版本为%d.0至%s",
+			version, hostMajor, PLUGIN_API_VERSION))
+	}
+
+	return nil
+}
+
+// 解析“主版本.次版本”格式的插件接口版本
+func parseAPIVersion(version string) (uint64, uint64, error) {
+	parts := strings.Split(strings.TrimSpace(version), ".")
+	if len(parts) != 2 {
+		return 0, 0, errors.New(fmt.Sprintf("无效的插件接口版本【%s】", version))
+	}
+
+	major, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return 0, 0, errors.New(fmt.Sprintf("无效的插件接口版本【%s】", version))
+	}
+
+	minor, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return 0, 0, errors.New(fmt.Sprintf("无效的插件接口版本【%s】", version))
+	}
+
+	return major, minor, nil
+}
@@ -0,0 +1,29 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"plugin"
+)
+
+// 打开插件文件并获取其导出的插件信息
+func openPlugin(path string) (*PluginInfo, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		// 插件与mkcrawler使用的Go版本或core源码不一致时也会在这里出错
+		return nil, errors.New(fmt.Sprintf("无法打开插件【%s】: %s", path, err))
+	}
+
+	symbol, err := p.Lookup(PLUGIN_SYMBOL)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("插件【%s】没有导出%s变量！", path, PLUGIN_SYMBOL))
+	}
+
+	info, ok := symbol.(*PluginInfo)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("插件【%s】导出的%s不是registry.PluginInfo类型，而是%T！",
+			path, PLUGIN_SYMBOL, symbol))
+	}
+
+	return info, nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package registry
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 当前平台不支持Go插件，插件目录中有插件文件时返回错误
+func openPlugin(path string) (*PluginInfo, error) {
+	return nil, errors.New(fmt.Sprintf("当前平台不支持Go插件，无法加载【%s】！", path))
+}
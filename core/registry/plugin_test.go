@@ -0,0 +1,179 @@
+package registry
+
+import (
+	analyzer "core/analyzer"
+	downloader "core/downloader"
+	itempipeline "core/itempipeline"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckAPIVersion(t *testing.T) {
+	for _, version := range []string{PLUGIN_API_VERSION, "1.0"} {
+		if err := checkAPIVersion(version); err != nil {
+			t.Errorf("%s: %s", version, err)
+		}
+	}
+
+	for _, version := range []string{"", "1", "0.9", "2.0", "1.99", "1.x"} {
+		if err := checkAPIVersion(version); err == nil {
+			t.Errorf("%s: expected an incompatible version", version)
+		}
+	}
+}
+
+func TestRegisterPlugin(t *testing.T) {
+	registered := 0
+	info := &PluginInfo{
+		Name:       "test-plugin",
+		Version:    "0.1.0",
+		APIVersion: PLUGIN_API_VERSION,
+		Register: func() error {
+			registered++
+			return nil
+		},
+	}
+
+	if err := registerPlugin("a.so", info); err != nil {
+		t.Fatal(err)
+	}
+	if err := registerPlugin("b.so", info); err == nil {
+		t.Error("expected an error for a duplicate plugin")
+	}
+	if registered != 1 {
+		t.Errorf("expected Register to be called once, got %d", registered)
+	}
+
+	loaded := Plugins()
+	if len(loaded) != 1 || loaded[0].Path != "a.so" || loaded[0].Version != "0.1.0" {
+		t.Errorf("unexpected loaded plugins: %v", loaded)
+	}
+}
+
+func TestRegisterPluginRollsBack(t *testing.T) {
+	newParser := func(name string) *ParserSpec {
+		return &ParserSpec{Name: name, New: func(options Options) (analyzer.MKParseResponse, error) { return nil, nil }}
+	}
+	newProcessor := func(name string) *ProcessorSpec {
+		return &ProcessorSpec{Name: name, New: func(options Options) (itempipeline.MKProcessItem, error) { return nil, nil }}
+	}
+	newDownloader := func(name string) *DownloaderSpec {
+		return &DownloaderSpec{Name: name, New: func(options Options) (downloader.MKPageDownloader, error) { return nil, nil }}
+	}
+
+	cases := []struct {
+		name     string
+		register func() error
+	}{
+		{"failing-plugin", func() error {
+			RegisterParser(newParser("failing-parser"))
+			RegisterProcessor(newProcessor("failing-processor"))
+			RegisterDownloader(newDownloader("failing-downloader"))
+			return errors.New("missing configuration")
+		}},
+		{"duplicate-builtin-plugin", func() error {
+			if err := RegisterParser(newParser("failing-parser")); err != nil {
+				return err
+			}
+			return RegisterProcessor(newProcessor("jsonl-writer"))
+		}},
+		{"duplicate-in-plugin", func() error {
+			if err := RegisterDownloader(newDownloader("failing-downloader")); err != nil {
+				return err
+			}
+			return RegisterDownloader(newDownloader("failing-downloader"))
+		}},
+		{"panicking-plugin", func() error {
+			RegisterParser(newParser("failing-parser"))
+			panic("boom")
+		}},
+	}
+
+	for _, c := range cases {
+		info := &PluginInfo{Name: c.name, APIVersion: PLUGIN_API_VERSION, Register: c.register}
+		func() {
+			defer func() { recover() }()
+			if err := registerPlugin(c.name+".so", info); err == nil {
+				t.Errorf("%s: expected an error", c.name)
+			}
+		}()
+
+		if _, ok := LookupParser("failing-parser"); ok {
+			t.Errorf("%s: the parser should not be registered", c.name)
+		}
+		if _, ok := LookupProcessor("failing-processor"); ok {
+			t.Errorf("%s: the processor should not be registered", c.name)
+		}
+		if _, ok := LookupDownloader("failing-downloader"); ok {
+			t.Errorf("%s: the downloader should not be registered", c.name)
+		}
+		if currentStage() != nil {
+			t.Errorf("%s: the stage should be cleared", c.name)
+		}
+	}
+
+	for _, loaded := range Plugins() {
+		if loaded.Name != "test-plugin" {
+			t.Errorf("failed plugin %s should not be listed", loaded.Name)
+		}
+	}
+
+	// all components take effect once Register returns
+	info := &PluginInfo{Name: "staged-plugin", APIVersion: PLUGIN_API_VERSION, Register: func() error {
+		if err := RegisterParser(newParser("staged-parser")); err != nil {
+			return err
+		}
+		if _, ok := LookupParser("staged-parser"); ok {
+			return errors.New("the parser should be staged until Register returns")
+		}
+		if err := RegisterProcessor(newProcessor("staged-processor")); err != nil {
+			return err
+		}
+		return RegisterDownloader(newDownloader("staged-downloader"))
+	}}
+	if err := registerPlugin("staged.so", info); err != nil {
+		t.Fatal(err)
+	}
+
+	_, parser := LookupParser("staged-parser")
+	_, processor := LookupProcessor("staged-processor")
+	_, pageDownloader := LookupDownloader("staged-downloader")
+	if !parser || !processor || !pageDownloader {
+		t.Errorf("components should be registered: %v %v %v", parser, processor, pageDownloader)
+	}
+	if err := RegisterParser(newParser("staged-parser")); err == nil {
+		t.Error("expected an error for a duplicate parser")
+	}
+}
+
+func TestLoadPlugins(t *testing.T) {
+	if err := LoadPlugins(""); err != nil {
+		t.Errorf("an empty directory setting should load nothing: %s", err)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "README.txt"), []byte("not a plugin"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadPlugins(dir); err != nil {
+		t.Errorf("files without the plugin extension should be ignored: %s", err)
+	}
+
+	if err := LoadPlugins(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "broken"+PLUGIN_EXT), []byte("not a shared object"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadPlugins(dir); err == nil {
+		t.Error("expected an error for an invalid plugin file")
+	}
+
+	paths, err := pluginFiles(dir)
+	if err != nil || len(paths) != 1 || filepath.Base(paths[0]) != "broken"+PLUGIN_EXT {
+		t.Errorf("unexpected plugin files: %v %v", paths, err)
+	}
+}
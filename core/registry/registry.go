@@ -0,0 +1,247 @@
+package registry
+
+import (
+	analyzer "core/analyzer"
+	itempipeline "core/itempipeline"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 解析器的规格。
+// 配置文件通过名称引用解析器，并以参数的形式给出选项，选项按选项模式检查和转换后传给New
+type ParserSpec struct {
+	Name        string                                                  // 名称，如link-extractor
+	Description string                                                  // 说明
+	Options     Schema                                                  // 选项模式
+	New         func(options Options) (analyzer.MKParseResponse, error) // 创建解析器
+}
+
+// 条目处理器的规格。
+// New和NewSink二者必须有一个：条目输出（如jsonl-writer）使用NewSink，以便在停止时关闭
+type ProcessorSpec struct {
+	Name        string                                                    // 名称，如jsonl-writer
+	Description string                                                    // 说明
+	Options     Schema                                                    // 选项模式
+	New         func(options Options) (itempipeline.MKProcessItem, error) // 创建条目处理器
+	NewSink     func(options Options) (itempipeline.MKItemSink, error)    // 创建条目输出
+}
+
+// 解析器和条目处理器的注册表
+var registry = struct {
+	parsers    map[string]*ParserSpec    // 名称与解析器规格的映射
+	processors map[string]*ProcessorSpec // 名称与条目处理器规格的映射
+	rwlock     sync.RWMutex              // 读写锁
+}{
+	parsers:    make(map[string]*ParserSpec),
+	processors: make(map[string]*ProcessorSpec),
+}
+
+// 检查组件的名称和选项模式
+func checkSpec(kind string, name string, options Schema, hasNew bool) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New(fmt.Sprintf("%s的名称不能为空！", kind))
+	}
+
+	if !hasNew {
+		return errors.New(fmt.Sprintf("%s【%s】缺少创建函数！", kind, name))
+	}
+
+	if err := options.Check(); err != nil {
+		return errors.New(fmt.Sprintf("%s【%s】的选项模式无效: %s", kind, name, err))
+	}
+
+	return nil
+}
+
+// 注册解析器。名称已被注册时返回错误
+func RegisterParser(spec *ParserSpec) error {
+	if spec == nil {
+		return errors.New("无效的解析器规格！")
+	}
+
+	if err := checkSpec("解析器", spec.Name, spec.Options, spec.New != nil); err != nil {
+		return err
+	}
+
+	registry.rwlock.Lock()
+	defer registry.rwlock.Unlock()
+
+	if _, ok := registry.parsers[spec.Name]; ok {
+		return errors.New(fmt.Sprintf("解析器【%s】已被注册！", spec.Name))
+	}
+
+	// 加载插件时先暂存，插件注册成功之后再加入注册表
+	if pending := currentStage(); pending != nil {
+		return pending.addParser(spec)
+	}
+
+	registry.parsers[spec.Name] = spec
+	return nil
+}
+
+// 注册条目处理器。名称已被注册时返回错误
+func RegisterProcessor(spec *ProcessorSpec) error {
+	if spec == nil {
+		return errors.New("无效的条目处理器规格！")
+	}
+
+	if err := checkSpec("条目处理器", spec.Name, spec.Options, spec.New != nil || spec.NewSink != nil); err != nil {
+		return err
+	}
+
+	if spec.New != nil && spec.NewSink != nil {
+		return errors.New(fmt.Sprintf("条目处理器【%s】不能同时提供New和NewSink！", spec.Name))
+	}
+
+	registry.rwlock.Lock()
+	defer registry.rwlock.Unlock()
+
+	if _, ok := registry.processors[spec.Name]; ok {
+		return errors.New(fmt.Sprintf("条目处理器【%s】已被注册！", spec.Name))
+	}
+
+	// 加载插件时先暂存，插件注册成功之后再加入注册表
+	if pending := currentStage(); pending != nil {
+		return pending.addProcessor(spec)
+	}
+
+	registry.processors[spec.Name] = spec
+	return nil
+}
+
+// 按名称查找解析器规格
+func LookupParser(name string) (*ParserSpec, bool) {
+	registry.rwlock.RLock()
+	defer registry.rwlock.RUnlock()
+
+	spec, ok := registry.parsers[name]
+	return spec, ok
+}
+
+// 按名称查找条目处理器规格
+func LookupProcessor(name string) (*ProcessorSpec, bool) {
+	registry.rwlock.RLock()
+	defer registry.rwlock.RUnlock()
+
+	spec, ok := registry.processors[name]
+	return spec, ok
+}
+
+// 获取所有已注册的解析器规格，按名称排序
+func Parsers() []*ParserSpec {
+	registry.rwlock.RLock()
+	defer registry.rwlock.RUnlock()
+
+	specs := make([]*ParserSpec, 0, len(registry.parsers))
+	for _, spec := range registry.parsers {
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+
+	return specs
+}
+
+// 获取所有已注册的条目处理器规格，按名称排序
+func Processors() []*ProcessorSpec {
+	registry.rwlock.RLock()
+	defer registry.rwlock.RUnlock()
+
+	specs := make([]*ProcessorSpec, 0, len(registry.processors))
+	for _, spec := range registry.processors {
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+
+	return specs
+}
+
+// 检查解析器是否存在以及参数是否符合其选项模式，不会创建解析器
+func CheckParser(name string, params map[string]string) error {
+	spec, ok := LookupParser(name)
+	if !ok {
+		return errors.New(fmt.Sprintf("未知的解析器【%s】！", name))
+	}
+
+	if _, err := spec.Options.Parse(params); err != nil {
+		return errors.New(fmt.Sprintf("解析器【%s】: %s", name, err))
+	}
+
+	return nil
+}
+
+// 检查条目处理器是否存在以及参数是否符合其选项模式，不会创建条目处理器
+func CheckProcessor(name string, params map[string]string) error {
+	spec, ok := LookupProcessor(name)
+	if !ok {
+		return errors.New(fmt.Sprintf("未知的条目处理器【%s】！", name))
+	}
+
+	if _, err := spec.Options.Parse(params); err != nil {
+		return errors.New(fmt.Sprintf("条目处理器【%s】: %s", name, err))
+	}
+
+	return nil
+}
+
+// 按名称和参数创建解析器
+func NewParser(name string, params map[string]string) (analyzer.MKParseResponse, error) {
+	spec, ok := LookupParser(name)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("未知的解析器【%s】！", name))
+	}
+
+	options, err := spec.Options.Parse(params)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("解析器【%s】: %s", name, err))
+	}
+
+	parser, err := spec.New(options)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("解析器【%s】: %s", name, err))
+	}
+
+	return parser, nil
+}
+
+// 按名称和参数创建条目处理器。
+// 条目输出需要在停止时关闭，不能用此函数创建，请使用NewStage
+func NewProcessor(name string, params map[string]string) (itempipeline.MKProcessItem, error) {
+	if spec, ok := LookupProcessor(name); ok && spec.NewSink != nil {
+		return nil, errors.New(fmt.Sprintf("条目处理器【%s】是条目输出，请使用NewStage创建！", name))
+	}
+
+	processor, _, err := NewStage(name, params)
+	return processor, err
+}
+
+// 按名称和参数创建条目处理流程的一个阶段。
+// 对于条目输出，同时返回条目输出本身，调用方负责在停止时关闭它；对于其他条目处理器，条目输出为nil
+func NewStage(name string, params map[string]string) (itempipeline.MKProcessItem, itempipeline.MKItemSink, error) {
+	spec, ok := LookupProcessor(name)
+	if !ok {
+		return nil, nil, errors.New(fmt.Sprintf("未知的条目处理器【%s】！", name))
+	}
+
+	options, err := spec.Options.Parse(params)
+	if err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("条目处理器【%s】: %s", name, err))
+	}
+
+	if spec.NewSink != nil {
+		sink, err := spec.NewSink(options)
+		if err != nil {
+			return nil, nil, errors.New(fmt.Sprintf("条目处理器【%s】: %s", name, err))
+		}
+		return itempipeline.NewSinkProcessor(sink), sink, nil
+	}
+
+	processor, err := spec.New(options)
+	if err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("条目处理器【%s】: %s", name, err))
+	}
+
+	return processor, nil, nil
+}
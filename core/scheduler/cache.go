@@ -52,17 +52,17 @@ type mk_requestCache struct {
 
 func (cache *mk_requestCache) put(request *base.MKRequest) bool {
 
-	if reqeust == nil {
-		return false
-	}
-
-	if request.status == 1 {
+	if request == nil {
 		return false
 	}
 
 	cache.mutex.Lock()
 	defer cache.mutex.Unlock()
 
+	if cache.status == 1 {
+		return false
+	}
+
 	cache.cache = append(cache.cache, request)
 
 	return true
@@ -70,17 +70,13 @@ func (cache *mk_requestCache) put(request *base.MKRequest) bool {
 
 func (cache *mk_requestCache) get() *base.MKRequest {
 
-	if cache.length() == 0 {
-		return nil
-	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
 
-	if cache.status == 1 {
+	if len(cache.cache) == 0 || cache.status == 1 {
 		return nil
 	}
 
-	cache.mutex.Lock()
-	defer cache.mutex.Unlock()
-
 	request := cache.cache[0]
 	cache.cache = cache.cache[1:]
 
@@ -88,17 +84,22 @@ func (cache *mk_requestCache) get() *base.MKRequest {
 }
 
 func (cache *mk_requestCache) capacity() int {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
 	return cap(cache.cache)
 }
 
 func (cache *mk_requestCache) length() int {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
 	return len(cache.cache)
 }
 
 func (cache *mk_requestCache) close() {
-	if cache.status == 1 {
-		return
-	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
 
 	cache.status = 1
 }
@@ -108,8 +109,12 @@ var summaryTemplate = "status: %s, " + "length: %d, " + "capacity: %d"
 
 func (cache *mk_requestCache) summary() string {
 
+	cache.mutex.Lock()
+	status := cache.status
+	cache.mutex.Unlock()
+
 	summary := fmt.Sprintf(summaryTemplate,
-		statusMap[cache.status],
+		statusMap[status],
 		cache.length(),
 		cache.capacity())
 
@@ -0,0 +1,404 @@
+package scheduler
+
+import (
+	"context"
+	analyzer "core/analyzer"
+	base "core/base"
+	downloader "core/downloader"
+	itempipeline "core/itempipeline"
+	middleware "core/middleware"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 调度器的状态
+const (
+	SCHEDULER_STATUS_UNSTARTED uint32 = 0 // 未开启
+	SCHEDULER_STATUS_RUNNING   uint32 = 1 // 正在运行
+	SCHEDULER_STATUS_STOPPED   uint32 = 2 // 已停止
+)
+
+// 请求缓存为空时，再次检查请求缓存的间隔时间
+var scheduleInterval = 10 * time.Millisecond
+
+// 调度器的接口
+type MKScheduler interface {
+
+	// 开启调度器。
+	// 参数channelArguments代表通道参数的容器，
+	// 参数poolArguments代表池基本参数的容器，
+	// 参数generateDownloader用于生成网页下载器池中的网页下载器，
+	// 参数parsers代表分析器使用的响应解析器列表，
+	// 参数pipeline代表处理条目的条目处理管道
+	Start(channelArguments base.ChannelArguments,
+		poolArguments base.PoolArguments,
+		generateDownloader downloader.GeneratePageDownloader,
+		parsers []analyzer.MKParseResponse,
+		pipeline itempipeline.MKItemPipeline) error
+
+	// 发送请求。请求先放入请求缓存，再由调度器依次放入请求通道。
+	// 每个被接受的请求无论成败都会在结果通道中产生一个结果
+	Send(request *base.MKRequest) bool
+
+	// 停止调度器。调用方应该在收到所有请求的结果之后再停止调度器，
+	// 停止时会等待条目通道中的条目都处理完毕
+	Stop() bool
+
+	// 判断调度器是否正在运行
+	Running() bool
+
+	// 获得结果通道。调用方必须接收其中的结果
+	ResultChan() <-chan *MKResult
+
+	// 获得错误通道。调用方必须接收其中的错误，调度器停止后该通道会被关闭
+	ErrorChan() <-chan error
+
+	// 等待已放入条目通道的条目都处理完毕
+	WaitItems()
+
+	// 获得处理成功的条目的数量（不包括被丢弃和处理出错的条目）
+	Items() uint64
+
+	// 判断所有处理模块是否都处于空闲状态
+	Idle() bool
+
+	// 获取摘要信息
+	Summary(prefix string) SchedulerSummary
+}
+
+// 请求的处理结果。
+// 分析得到的条目已经放入条目通道，结果中只包含分析得到的新请求
+type MKResult struct {
+	request  *base.MKRequest   // 发送的请求
+	requests []*base.MKRequest // 分析得到的新请求
+}
+
+// 创建请求的处理结果
+func NewResult(request *base.MKRequest, requests []*base.MKRequest) *MKResult {
+	return &MKResult{
+		request:  request,
+		requests: requests,
+	}
+}
+
+// 获取发送的请求，即调用Send时传入的请求
+func (result *MKResult) Request() *base.MKRequest {
+	return result.request
+}
+
+// 获取分析得到的新请求
+func (result *MKResult) Requests() []*base.MKRequest {
+	return result.requests
+}
+
+// 在HTTP请求的上下文中保存发送的请求时使用的键。
+// 重定向之后的请求会继承上下文，因此总能找到最初发送的请求
+type originKey struct{}
+
+// 创建调度器
+func NewScheduler() MKScheduler {
+	return &mk_scheduler{}
+}
+
+// 调度器的实现类型
+type mk_scheduler struct {
+	channelArguments base.ChannelArguments           // 通道参数的容器
+	poolArguments    base.PoolArguments              // 池基本参数的容器
+	parsers          []analyzer.MKParseResponse      // 响应解析器列表
+	pipeline         itempipeline.MKItemPipeline     // 条目处理管道
+	channelManager   middleware.MKChannelManager     // 通道管理器
+	downloaderPool   downloader.MKPageDownloaderPool // 网页下载器池
+	analyzerPool     analyzer.MKAnalyzerPool         // 分析器池
+	cache            requestCache                    // 请求缓存
+	resultChannel    chan *MKResult                  // 结果通道
+	errorChannel     chan error                      // 错误通道
+	stopChannel      chan struct{}                   // 停止调度的通知通道
+	scheduling       sync.WaitGroup                  // 调度请求的goroutine
+	workers          sync.WaitGroup                  // 下载、分析和处理条目的goroutine
+	pending          int64                           // 已发送但还没有产生结果的请求的数量
+	pendingItems     int64                           // 已放入条目通道但还没有处理完毕的条目的数量
+	items            uint64                          // 处理成功的条目的数量
+	status           uint32                          // 状态
+}
+
+func (sched *mk_scheduler) Start(
+	channelArguments base.ChannelArguments,
+	poolArguments base.PoolArguments,
+	generateDownloader downloader.GeneratePageDownloader,
+	parsers []analyzer.MKParseResponse,
+	pipeline itempipeline.MKItemPipeline) (err error) {
+
+	if !atomic.CompareAndSwapUint32(&sched.status, SCHEDULER_STATUS_UNSTARTED, SCHEDULER_STATUS_RUNNING) {
+		return errors.New("调度器已经开启过！")
+	}
+
+	// 开启失败时恢复为未开启状态
+	defer func() {
+		if err != nil {
+			atomic.StoreUint32(&sched.status, SCHEDULER_STATUS_UNSTARTED)
+		}
+	}()
+
+	if err := channelArguments.Check(); err != nil {
+		return err
+	}
+
+	if err := poolArguments.Check(); err != nil {
+		return err
+	}
+
+	if generateDownloader == nil {
+		return errors.New("无效的网页下载器生成函数！")
+	}
+
+	if parsers == nil {
+		return errors.New("无效的响应解析器列表！")
+	}
+
+	if pipeline == nil {
+		return errors.New("无效的条目处理管道！")
+	}
+
+	downloaderPool, err := downloader.NewPageDownloaderPool(poolArguments.PageDownloaderPoolSize(), generateDownloader)
+	if err != nil {
+		return errors.New(fmt.Sprintf("无法创建网页下载器池: %s", err))
+	}
+
+	analyzerPool, err := analyzer.NewAnalyzerPool(poolArguments.AnalyzerPoolSize(), analyzer.NewAnalyzer)
+	if err != nil {
+		return errors.New(fmt.Sprintf("无法创建分析器池: %s", err))
+	}
+
+	sched.channelArguments = channelArguments
+	sched.poolArguments = poolArguments
+	sched.parsers = parsers
+	sched.pipeline = pipeline
+	sched.downloaderPool = downloaderPool
+	sched.analyzerPool = analyzerPool
+	sched.channelManager = middleware.NewChannelManager(channelArguments)
+	sched.cache = newRequestCache()
+	sched.resultChannel = make(chan *MKResult)
+	sched.stopChannel = make(chan struct{})
+
+	requestChannel, _ := sched.channelManager.RequestChannel()
+	responseChannel, _ := sched.channelManager.ResponseChannel()
+	itemChannel, _ := sched.channelManager.ItemChannel()
+	sched.errorChannel, _ = sched.channelManager.ErrorChannel()
+
+	// 每个下载goroutine和分析goroutine都从池中取出一个实体，在停止时归还
+	for i := uint32(0); i < downloaderPool.Total(); i++ {
+		pageDownloader, err := downloaderPool.Take()
+		if err != nil {
+			return err
+		}
+
+		sched.workers.Add(1)
+		go sched.download(pageDownloader, requestChannel, responseChannel)
+	}
+
+	for i := uint32(0); i < analyzerPool.Total(); i++ {
+		analyzer, err := analyzerPool.Take()
+		if err != nil {
+			return err
+		}
+
+		sched.workers.Add(1)
+		go sched.analyze(analyzer, responseChannel, itemChannel)
+	}
+
+	sched.workers.Add(1)
+	go sched.processItems(itemChannel)
+
+	sched.scheduling.Add(1)
+	go sched.schedule(requestChannel)
+
+	return nil
+}
+
+func (sched *mk_scheduler) Send(request *base.MKRequest) bool {
+	if !sched.Running() || request == nil || !request.Valid() {
+		return false
+	}
+
+	httpRequest := request.Request()
+	ctx := context.WithValue(httpRequest.Context(), originKey{}, request)
+
+	atomic.AddInt64(&sched.pending, 1)
+	if !sched.cache.put(base.NewRequest(httpRequest.WithContext(ctx), request.Depth())) {
+		atomic.AddInt64(&sched.pending, -1)
+		return false
+	}
+
+	return true
+}
+
+func (sched *mk_scheduler) Stop() bool {
+	if !atomic.CompareAndSwapUint32(&sched.status, SCHEDULER_STATUS_RUNNING, SCHEDULER_STATUS_STOPPED) {
+		return false
+	}
+
+	// 先停止调度，使请求通道不再有新的请求，再关闭所有通道
+	close(sched.stopChannel)
+	sched.scheduling.Wait()
+	sched.cache.close()
+
+	sched.WaitItems()
+	sched.channelManager.Close()
+	sched.workers.Wait()
+	close(sched.resultChannel)
+
+	return true
+}
+
+func (sched *mk_scheduler) Running() bool {
+	return atomic.LoadUint32(&sched.status) == SCHEDULER_STATUS_RUNNING
+}
+
+func (sched *mk_scheduler) ResultChan() <-chan *MKResult {
+	return sched.resultChannel
+}
+
+func (sched *mk_scheduler) ErrorChan() <-chan error {
+	return sched.errorChannel
+}
+
+func (sched *mk_scheduler) WaitItems() {
+	for atomic.LoadInt64(&sched.pendingItems) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (sched *mk_scheduler) Items() uint64 {
+	return atomic.LoadUint64(&sched.items)
+}
+
+func (sched *mk_scheduler) Idle() bool {
+	return atomic.LoadInt64(&sched.pending) == 0 && atomic.LoadInt64(&sched.pendingItems) == 0
+}
+
+func (sched *mk_scheduler) Summary(prefix string) SchedulerSummary {
+	return newSchedulerSummary(sched, prefix)
+}
+
+// 把请求缓存中的请求依次放入请求通道，直到调度器停止
+func (sched *mk_scheduler) schedule(requestChannel chan<- base.MKRequest) {
+	defer sched.scheduling.Done()
+
+	for {
+		request := sched.cache.get()
+		if request == nil {
+			select {
+			case <-sched.stopChannel:
+				return
+			case <-time.After(scheduleInterval):
+			}
+			continue
+		}
+
+		select {
+		case requestChannel <- *request:
+		case <-sched.stopChannel:
+			return
+		}
+	}
+}
+
+// 用一个网页下载器依次下载请求通道中的请求，并把响应放入响应通道
+func (sched *mk_scheduler) download(
+	pageDownloader downloader.MKPageDownloader,
+	requestChannel <-chan base.MKRequest,
+	responseChannel chan<- base.MKResponse) {
+
+	defer sched.workers.Done()
+	defer sched.downloaderPool.Return(pageDownloader)
+
+	for request := range requestChannel {
+		httpRequest := request.Request()
+		response, err := pageDownloader.Download(request)
+		if err != nil {
+			sched.sendError(errors.New(fmt.Sprintf("下载失败【url = %s】: %s", httpRequest.URL, err)))
+			sched.sendResult(origin(httpRequest, request.Depth()), nil)
+			continue
+		}
+
+		responseChannel <- *response
+	}
+}
+
+// 用一个分析器依次分析响应通道中的响应，把条目放入条目通道，并产生请求的结果
+func (sched *mk_scheduler) analyze(
+	analyzer analyzer.MKAnalyzer,
+	responseChannel <-chan base.MKResponse,
+	itemChannel chan<- base.MKItem) {
+
+	defer sched.workers.Done()
+	defer sched.analyzerPool.Return(analyzer)
+
+	for response := range responseChannel {
+		httpResponse := response.Response()
+		request := origin(httpResponse.Request, response.Depth())
+
+		dataList, errorList := analyzer.Analyze(sched.parsers, response)
+		for _, err := range errorList {
+			sched.sendError(errors.New(fmt.Sprintf("分析失败【url = %s】: %s", httpResponse.Request.URL, err)))
+		}
+
+		requests := make([]*base.MKRequest, 0)
+		for _, data := range dataList {
+			switch value := data.(type) {
+			case base.MKItem:
+				// 先放入条目，再产生结果，使调用方收到结果之后可以等待这些条目处理完毕
+				atomic.AddInt64(&sched.pendingItems, 1)
+				itemChannel <- value
+			case *base.MKRequest:
+				requests = append(requests, value)
+			}
+		}
+
+		sched.sendResult(request, requests)
+	}
+}
+
+// 依次把条目通道中的条目发送到条目处理管道。
+// 条目只在这里依次发送，因此可以由丢弃计数的变化判断条目是否被丢弃
+func (sched *mk_scheduler) processItems(itemChannel <-chan base.MKItem) {
+	defer sched.workers.Done()
+
+	for item := range itemChannel {
+		dropped := sched.pipeline.Dropped()
+		errs := sched.pipeline.Send(item)
+		for _, err := range errs {
+			sched.sendError(errors.New(fmt.Sprintf("条目处理失败: %s", err)))
+		}
+
+		if len(errs) == 0 && sched.pipeline.Dropped() == dropped {
+			atomic.AddUint64(&sched.items, 1)
+		}
+
+		atomic.AddInt64(&sched.pendingItems, -1)
+	}
+}
+
+// 产生请求的结果
+func (sched *mk_scheduler) sendResult(request *base.MKRequest, requests []*base.MKRequest) {
+	sched.resultChannel <- NewResult(request, requests)
+	atomic.AddInt64(&sched.pending, -1)
+}
+
+// 把错误放入错误通道
+func (sched *mk_scheduler) sendError(err error) {
+	sched.errorChannel <- err
+}
+
+// 获取最初发送的请求。上下文中没有保存时，以给定的HTTP请求和深度创建请求
+func origin(httpRequest *http.Request, depth uint32) *base.MKRequest {
+	if request, ok := httpRequest.Context().Value(originKey{}).(*base.MKRequest); ok {
+		return request
+	}
+
+	return base.NewRequest(httpRequest, depth)
+}
@@ -0,0 +1,182 @@
+package scheduler
+
+import (
+	analyzer "core/analyzer"
+	base "core/base"
+	downloader "core/downloader"
+	itempipeline "core/itempipeline"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 测试用的响应解析器：响应体的每一行是“item:标题”或“link:路径”
+func parseLines(httpResponse *http.Response, depth uint32) ([]base.MKData, []error) {
+	body, err := io.ReadAll(httpResponse.Body)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	dataList := make([]base.MKData, 0)
+	for _, line := range strings.Split(string(body), "\n") {
+		switch {
+		case strings.HasPrefix(line, "item:"):
+			dataList = append(dataList, base.MKItem{"title": strings.TrimPrefix(line, "item:")})
+		case strings.HasPrefix(line, "link:"):
+			linkURL, _ := httpResponse.Request.URL.Parse(strings.TrimPrefix(line, "link:"))
+			httpRequest, _ := http.NewRequest("GET", linkURL.String(), nil)
+			dataList = append(dataList, base.NewRequest(httpRequest, depth))
+		}
+	}
+
+	return dataList, nil
+}
+
+func newTestRequest(t *testing.T, rawURL string) *base.MKRequest {
+	httpRequest, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return base.NewRequest(httpRequest, 0)
+}
+
+func TestScheduler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "item:ok\nitem:drop\nitem:error\nlink:/next\n")
+	})
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "item:moved\n")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	processors := []itempipeline.MKProcessItem{
+		func(item base.MKItem) (base.MKItem, error) {
+			switch item["title"] {
+			case "drop":
+				return nil, itempipeline.NewDropError("重复的条目")
+			case "error":
+				return nil, errors.New("无法处理条目")
+			}
+			return item, nil
+		},
+	}
+
+	sched := NewScheduler()
+	err := sched.Start(
+		base.NewChannelArguments(1, 1, 1, 10),
+		base.NewPoolArguments(2, 2),
+		func() downloader.MKPageDownloader { return downloader.NewPageDownloader(nil) },
+		[]analyzer.MKParseResponse{parseLines},
+		itempipeline.NewItemPipeline(processors))
+	if err != nil {
+		t.Fatalf("无法开启调度器: %s", err)
+	}
+	if !sched.Running() {
+		t.Fatal("调度器应该正在运行")
+	}
+
+	errs := make([]string, 0)
+	errorsDone := make(chan struct{})
+	go func() {
+		defer close(errorsDone)
+		for err := range sched.ErrorChan() {
+			errs = append(errs, err.Error())
+		}
+	}()
+
+	requests := []*base.MKRequest{
+		newTestRequest(t, server.URL+"/"),
+		newTestRequest(t, server.URL+"/old"),
+		newTestRequest(t, "http://127.0.0.1:1/"),
+	}
+	for _, request := range requests {
+		if !sched.Send(request) {
+			t.Fatalf("请求没有被接受: %s", request.Request().URL)
+		}
+	}
+
+	// 每个请求都应该产生一个结果，结果中是最初发送的请求，即使发生了重定向
+	links := make(map[*base.MKRequest][]string)
+	for range requests {
+		select {
+		case result := <-sched.ResultChan():
+			urls := make([]string, 0)
+			for _, request := range result.Requests() {
+				urls = append(urls, request.Request().URL.String())
+			}
+			links[result.Request()] = urls
+		case <-time.After(5 * time.Second):
+			t.Fatal("等待结果超时")
+		}
+	}
+
+	for _, request := range requests {
+		if _, ok := links[request]; !ok {
+			t.Errorf("没有请求%s的结果", request.Request().URL)
+		}
+	}
+	if urls := links[requests[0]]; len(urls) != 1 || urls[0] != server.URL+"/next" {
+		t.Errorf("分析得到的新请求不正确: %v", urls)
+	}
+
+	sched.WaitItems()
+	if !sched.Idle() {
+		t.Error("所有请求都有了结果之后调度器应该空闲")
+	}
+	if items := sched.Items(); items != 2 {
+		t.Errorf("只应该计入成功处理的条目，期望2个，实际为%d个", items)
+	}
+
+	if !sched.Stop() || sched.Running() {
+		t.Fatal("调度器应该已经停止")
+	}
+	if sched.Stop() {
+		t.Error("重复停止应该返回false")
+	}
+	<-errorsDone
+
+	sort.Strings(errs)
+	if len(errs) != 2 || !strings.Contains(errs[0], "127.0.0.1:1") || !strings.Contains(errs[1], "无法处理条目") {
+		t.Errorf("应该报告下载和条目处理的错误，实际为%v", errs)
+	}
+
+	if sched.Send(newTestRequest(t, server.URL+"/")) {
+		t.Error("停止之后不应该接受请求")
+	}
+}
+
+func TestSchedulerStartArguments(t *testing.T) {
+	generate := func() downloader.MKPageDownloader { return downloader.NewPageDownloader(nil) }
+	parsers := []analyzer.MKParseResponse{parseLines}
+	pipeline := itempipeline.NewItemPipeline([]itempipeline.MKProcessItem{})
+
+	sched := NewScheduler()
+	if err := sched.Start(base.NewChannelArguments(0, 1, 1, 1), base.NewPoolArguments(1, 1), generate, parsers, pipeline); err == nil {
+		t.Error("通道长度为0时不应该开启")
+	}
+	if err := sched.Start(base.NewChannelArguments(1, 1, 1, 1), base.NewPoolArguments(1, 0), generate, parsers, pipeline); err == nil {
+		t.Error("分析器池尺寸为0时不应该开启")
+	}
+	if sched.Running() {
+		t.Fatal("开启失败之后调度器不应该在运行")
+	}
+
+	if err := sched.Start(base.NewChannelArguments(1, 1, 1, 1), base.NewPoolArguments(1, 1), generate, parsers, pipeline); err != nil {
+		t.Fatalf("无法开启调度器: %s", err)
+	}
+	if err := sched.Start(base.NewChannelArguments(1, 1, 1, 1), base.NewPoolArguments(1, 1), generate, parsers, pipeline); err == nil {
+		t.Error("调度器不应该被开启两次")
+	}
+	sched.Stop()
+}
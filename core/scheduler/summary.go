@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"bytes"
+	"fmt"
+	"sync/atomic"
+)
+
+// 调度器状态的名称
+var schedulerStatusMap = map[uint32]string{
+	SCHEDULER_STATUS_UNSTARTED: "unstarted",
+	SCHEDULER_STATUS_RUNNING:   "running",
+	SCHEDULER_STATUS_STOPPED:   "stopped",
+}
+
+// 调度器摘要信息的接口
+type SchedulerSummary interface {
+	String() string                   // 获得摘要信息的一般表示
+	Detail() string                   // 获得摘要信息的详细表示
+	Same(other SchedulerSummary) bool // 判断是否与另一份摘要信息相同
+}
+
+// 创建调度器的摘要信息
+func newSchedulerSummary(sched *mk_scheduler, prefix string) SchedulerSummary {
+	if sched == nil {
+		return nil
+	}
+
+	summary := &mk_schedulerSummary{
+		prefix:       prefix,
+		status:       atomic.LoadUint32(&sched.status),
+		pending:      atomic.LoadInt64(&sched.pending),
+		pendingItems: atomic.LoadInt64(&sched.pendingItems),
+		items:        atomic.LoadUint64(&sched.items),
+	}
+
+	if summary.status == SCHEDULER_STATUS_UNSTARTED {
+		return summary
+	}
+
+	summary.channelArguments = sched.channelArguments.String()
+	summary.poolArguments = sched.poolArguments.String()
+	summary.channelManager = sched.channelManager.Summary()
+	summary.requestCache = sched.cache.summary()
+	summary.downloaderPoolUsed = sched.downloaderPool.Used()
+	summary.downloaderPoolTotal = sched.downloaderPool.Total()
+	summary.analyzerPoolUsed = sched.analyzerPool.Used()
+	summary.analyzerPoolTotal = sched.analyzerPool.Total()
+	summary.itemPipeline = sched.pipeline.Summary()
+
+	return summary
+}
+
+// 调度器摘要信息的实现类型
+type mk_schedulerSummary struct {
+	prefix              string // 前缀
+	status              uint32 // 状态
+	channelArguments    string // 通道参数的描述
+	poolArguments       string // 池基本参数的描述
+	channelManager      string // 通道管理器的摘要信息
+	requestCache        string // 请求缓存的摘要信息
+	downloaderPoolUsed  uint32 // 网页下载器池中被使用的网页下载器的数量
+	downloaderPoolTotal uint32 // 网页下载器池的总容量
+	analyzerPoolUsed    uint32 // 分析器池中被使用的分析器的数量
+	analyzerPoolTotal   uint32 // 分析器池的总容量
+	itemPipeline        string // 条目处理管道的摘要信息
+	pending             int64  // 还没有产生结果的请求的数量
+	pendingItems        int64  // 还没有处理完毕的条目的数量
+	items               uint64 // 处理成功的条目的数量
+}
+
+func (summary *mk_schedulerSummary) String() string {
+	return summary.getSummary(false)
+}
+
+func (summary *mk_schedulerSummary) Detail() string {
+	return summary.getSummary(true)
+}
+
+func (summary *mk_schedulerSummary) Same(other SchedulerSummary) bool {
+	if other == nil {
+		return false
+	}
+
+	otherSummary, ok := other.(*mk_schedulerSummary)
+	if !ok {
+		return false
+	}
+
+	return *summary == *otherSummary
+}
+
+// 生成摘要信息。参数detail表示是否包含通道参数和池基本参数
+func (summary *mk_schedulerSummary) getSummary(detail bool) string {
+	prefix := summary.prefix
+
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("%sStatus: %s\n", prefix, schedulerStatusMap[summary.status]))
+	if detail {
+		buffer.WriteString(fmt.Sprintf("%sChannel arguments: %s\n", prefix, summary.channelArguments))
+		buffer.WriteString(fmt.Sprintf("%sPool arguments: %s\n", prefix, summary.poolArguments))
+	}
+	buffer.WriteString(fmt.Sprintf("%sChannel manager: %s\n", prefix, summary.channelManager))
+	buffer.WriteString(fmt.Sprintf("%sRequest cache: %s\n", prefix, summary.requestCache))
+	buffer.WriteString(fmt.Sprintf("%sDownloader pool: %d/%d\n", prefix, summary.downloaderPoolUsed, summary.downloaderPoolTotal))
+	buffer.WriteString(fmt.Sprintf("%sAnalyzer pool: %d/%d\n", prefix, summary.analyzerPoolUsed, summary.analyzerPoolTotal))
+	buffer.WriteString(fmt.Sprintf("%sItem pipeline: %s\n", prefix, summary.itemPipeline))
+	buffer.WriteString(fmt.Sprintf("%sPending requests: %d, pending items: %d, processed items: %d\n",
+		prefix, summary.pending, summary.pendingItems, summary.items))
+
+	return buffer.String()
+}
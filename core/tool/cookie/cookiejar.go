@@ -8,18 +8,21 @@ import (
 
 // 创建http.CookieJar类型的值
 func NewCookiejar() http.CookieJar {
-	options := &cookiejar.Options{PublicSuffixList: &mk_publicSuffixList{}}
-
-	jar, _ := cookiejar.New(options)
+	jar, _ := cookiejar.New(newCookiejarOptions())
 
 	return jar
 }
 
+// 创建Cookie容器的选项
+func newCookiejarOptions() *cookiejar.Options {
+	return &cookiejar.Options{PublicSuffixList: &mk_publicSuffixList{}}
+}
+
 // cookiejar.PublicSuffixList接口实现类型
 type mk_publicSuffixList struct{}
 
 func (list *mk_publicSuffixList) PublicSuffix(domain string) string {
-	suffix, _ := list.PublicSuffix(domain)
+	suffix, _ := publicsuffix.PublicSuffix(domain)
 	return suffix
 }
 
@@ -0,0 +1,293 @@
+package cookie
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 可持久化的Cookie容器接口
+type MKCookiejar interface {
+	http.CookieJar
+
+	// 把容器中仍然有效的Cookie保存到文件
+	Save() error
+
+	// 从文件加载Cookie。文件不存在时不会报错
+	Load() error
+
+	// 清除容器中的所有Cookie
+	Clear()
+
+	// 获取保存Cookie的文件路径
+	FilePath() string
+}
+
+// 创建可持久化的Cookie容器。
+// 参数filePath代表保存Cookie的文件路径。若该文件已存在，则会立即加载其中的Cookie。
+func NewPersistentCookiejar(filePath string) (MKCookiejar, error) {
+	if filePath == "" {
+		return nil, errors.New("Cookie文件路径不能为空！")
+	}
+
+	jar := &mk_persistentCookiejar{
+		filePath: filePath,
+	}
+	jar.reset()
+
+	if err := jar.Load(); err != nil {
+		return nil, err
+	}
+
+	return jar, nil
+}
+
+// 被持久化的Cookie
+type storedCookie struct {
+	URL      string    `json:"url"`               // 设置该Cookie的URL
+	Name     string    `json:"name"`              // 名称
+	Value    string    `json:"value"`             // 值
+	Domain   string    `json:"domain,omitempty"`  // 域属性，为空时表示仅限主机
+	Path     string    `json:"path,omitempty"`    // 路径属性
+	Expires  time.Time `json:"expires,omitempty"` // 过期时间，零值表示会话Cookie
+	Secure   bool      `json:"secure,omitempty"`  // 是否仅限HTTPS
+	HttpOnly bool      `json:"httpOnly,omitempty"`
+}
+
+// 判断Cookie在给定时间是否已过期
+func (cookie *storedCookie) expired(now time.Time) bool {
+	return !cookie.Expires.IsZero() && !cookie.Expires.After(now)
+}
+
+// 可持久化的Cookie容器实现类型。
+// 标准库的cookiejar.Jar不会暴露其中的Cookie，
+// 所以这里另外记录每一次设置的Cookie，以便保存和重新加载。
+type mk_persistentCookiejar struct {
+	filePath string                   // 保存Cookie的文件路径
+	jar      *cookiejar.Jar           // 实际的Cookie容器
+	cookies  map[string]*storedCookie // 被记录的Cookie，键由域、路径和名称组成
+	mutex    sync.Mutex               // 互斥锁
+}
+
+func (jar *mk_persistentCookiejar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	jar.mutex.Lock()
+	defer jar.mutex.Unlock()
+
+	jar.jar.SetCookies(u, cookies)
+
+	now := time.Now()
+	for _, cookie := range cookies {
+		stored := newStoredCookie(u, cookie, now)
+		key := stored.key(u)
+
+		if cookie.MaxAge < 0 || stored.expired(now) {
+			delete(jar.cookies, key)
+			continue
+		}
+
+		// 只记录被实际的容器接受了的Cookie（例如域属性与URL不符的Cookie会被拒绝）
+		if !jar.accepted(u, stored) {
+			continue
+		}
+
+		jar.cookies[key] = stored
+	}
+}
+
+// 判断Cookie是否已被实际的容器接受：用Cookie自己的域和路径查询容器，看是否能取回同名同值的Cookie
+func (jar *mk_persistentCookiejar) accepted(u *url.URL, stored *storedCookie) bool {
+	query := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: stored.Path}
+	if stored.Domain != "" {
+		query.Host = stored.Domain
+	}
+	if stored.Secure {
+		query.Scheme = "https"
+	}
+
+	for _, cookie := range jar.jar.Cookies(query) {
+		if cookie.Name == stored.Name && cookie.Value == stored.Value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (jar *mk_persistentCookiejar) Cookies(u *url.URL) []*http.Cookie {
+	return jar.jar.Cookies(u)
+}
+
+func (jar *mk_persistentCookiejar) Save() error {
+	jar.mutex.Lock()
+	defer jar.mutex.Unlock()
+
+	now := time.Now()
+	cookies := make([]*storedCookie, 0, len(jar.cookies))
+	for key, cookie := range jar.cookies {
+		if cookie.expired(now) {
+			delete(jar.cookies, key)
+			continue
+		}
+
+		cookies = append(cookies, cookie)
+	}
+
+	data, err := json.MarshalIndent(cookies, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(jar.filePath), 0700); err != nil {
+		return err
+	}
+
+	// 先写入临时文件并同步到磁盘再重命名，避免保存中途失败或断电时破坏原有文件
+	tempPath := jar.filePath + ".tmp"
+	if err := writeFileSync(tempPath, data, 0600); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	return os.Rename(tempPath, jar.filePath)
+}
+
+// 写入文件并同步到磁盘
+func writeFileSync(path string, data []byte, perm os.FileMode) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func (jar *mk_persistentCookiejar) Load() error {
+	data, err := os.ReadFile(jar.filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var cookies []*storedCookie
+	if err := json.Unmarshal(data, &cookies); err != nil {
+		errMsg := fmt.Sprintf("无法解析Cookie文件【path = %s】: %s\n", jar.filePath, err)
+		return errors.New(errMsg)
+	}
+
+	now := time.Now()
+	for _, cookie := range cookies {
+		if cookie.expired(now) {
+			continue
+		}
+
+		u, err := url.Parse(cookie.URL)
+		if err != nil {
+			continue
+		}
+
+		jar.SetCookies(u, []*http.Cookie{cookie.httpCookie()})
+	}
+
+	return nil
+}
+
+func (jar *mk_persistentCookiejar) Clear() {
+	jar.mutex.Lock()
+	defer jar.mutex.Unlock()
+
+	jar.reset()
+}
+
+func (jar *mk_persistentCookiejar) FilePath() string {
+	return jar.filePath
+}
+
+// 重置容器
+func (jar *mk_persistentCookiejar) reset() {
+	jar.jar, _ = cookiejar.New(newCookiejarOptions())
+	jar.cookies = make(map[string]*storedCookie)
+}
+
+// 根据响应中的Cookie生成被持久化的Cookie
+func newStoredCookie(u *url.URL, cookie *http.Cookie, now time.Time) *storedCookie {
+	expires := cookie.Expires
+	if cookie.MaxAge > 0 {
+		expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+	}
+
+	origin := url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}
+
+	path := cookie.Path
+	if !strings.HasPrefix(path, "/") {
+		path = defaultPath(u.Path)
+	}
+
+	return &storedCookie{
+		URL:      origin.String(),
+		Name:     cookie.Name,
+		Value:    cookie.Value,
+		Domain:   strings.TrimPrefix(strings.ToLower(cookie.Domain), "."),
+		Path:     path,
+		Expires:  expires,
+		Secure:   cookie.Secure,
+		HttpOnly: cookie.HttpOnly,
+	}
+}
+
+// 获取Cookie的键，由域（仅限主机的Cookie为URL的主机）、路径和名称组成，与容器区分Cookie的方式一致
+func (cookie *storedCookie) key(u *url.URL) string {
+	domain := cookie.Domain
+	if domain == "" {
+		domain = strings.ToLower(u.Hostname())
+	}
+
+	return domain + ";" + cookie.Path + ";" + cookie.Name
+}
+
+// 按RFC 6265第5.1.4节计算URL路径的默认Cookie路径，即最后一个“/”之前的部分
+func defaultPath(urlPath string) string {
+	if urlPath == "" || urlPath[0] != '/' {
+		return "/"
+	}
+
+	i := strings.LastIndex(urlPath, "/")
+	if i == 0 {
+		return "/"
+	}
+
+	return urlPath[:i]
+}
+
+// 还原为http.Cookie
+func (cookie *storedCookie) httpCookie() *http.Cookie {
+	return &http.Cookie{
+		Name:     cookie.Name,
+		Value:    cookie.Value,
+		Domain:   cookie.Domain,
+		Path:     cookie.Path,
+		Expires:  cookie.Expires,
+		Secure:   cookie.Secure,
+		HttpOnly: cookie.HttpOnly,
+	}
+}
@@ -0,0 +1,144 @@
+package cookie
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func mustParseURL(t *testing.T, rawURL string) *url.URL {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u
+}
+
+// 获取容器中给定名称的Cookie的值
+func cookieValue(jar http.CookieJar, u *url.URL, name string) string {
+	for _, cookie := range jar.Cookies(u) {
+		if cookie.Name == name {
+			return cookie.Value
+		}
+	}
+
+	return ""
+}
+
+func TestPersistentCookiejarSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "site", "blog.cookies.json")
+	jar, err := NewPersistentCookiejar(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	login := mustParseURL(t, "https://blog.devtang.com/account/login")
+	jar.SetCookies(login, []*http.Cookie{
+		{Name: "sid", Value: "s1", Expires: time.Now().Add(time.Hour)},
+		{Name: "theme", Value: "dark", Domain: "devtang.com", Path: "/", MaxAge: 3600},
+		{Name: "session", Value: "only-this-run"},
+		// 域属性与URL不符，会被容器拒绝
+		{Name: "evil", Value: "x", Domain: "example.com", Path: "/", MaxAge: 3600},
+	})
+
+	if err := jar.Save(); err != nil {
+		t.Fatalf("保存失败: %s", err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("临时文件应该已被重命名: %v", err)
+	}
+
+	reloaded, err := NewPersistentCookiejar(path)
+	if err != nil {
+		t.Fatalf("加载失败: %s", err)
+	}
+
+	// 没有Path属性的Cookie的路径为/account
+	if value := cookieValue(reloaded, mustParseURL(t, "https://blog.devtang.com/account/profile"), "sid"); value != "s1" {
+		t.Errorf("sid应该被恢复，实际为%q", value)
+	}
+	if value := cookieValue(reloaded, mustParseURL(t, "https://blog.devtang.com/"), "sid"); value != "" {
+		t.Errorf("sid只应该发送到/account之下，实际为%q", value)
+	}
+	if value := cookieValue(reloaded, mustParseURL(t, "http://www.devtang.com/"), "theme"); value != "dark" {
+		t.Errorf("theme应该被恢复到整个域，实际为%q", value)
+	}
+	if value := cookieValue(reloaded, mustParseURL(t, "http://example.com/"), "evil"); value != "" {
+		t.Errorf("被容器拒绝的Cookie不应该被保存，实际为%q", value)
+	}
+
+	stored := reloaded.(*mk_persistentCookiejar).cookies
+	if len(stored) != 3 {
+		t.Errorf("应该保存3个Cookie，实际为%d个: %v", len(stored), stored)
+	}
+}
+
+func TestPersistentCookiejarDefaultPath(t *testing.T) {
+	jar, err := NewPersistentCookiejar(filepath.Join(t.TempDir(), "cookies.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 两个没有Path属性的同名Cookie来自不同的路径，在容器中是两个Cookie，不能互相覆盖
+	jar.SetCookies(mustParseURL(t, "http://example.com/a/login"), []*http.Cookie{{Name: "sid", Value: "a"}})
+	jar.SetCookies(mustParseURL(t, "http://example.com/b/login"), []*http.Cookie{{Name: "sid", Value: "b"}})
+
+	stored := jar.(*mk_persistentCookiejar).cookies
+	if len(stored) != 2 || stored["example.com;/a;sid"] == nil || stored["example.com;/b;sid"] == nil {
+		t.Fatalf("应该按默认路径区分Cookie，实际为%v", stored)
+	}
+
+	// 删除其中一个
+	jar.SetCookies(mustParseURL(t, "http://example.com/a/logout"), []*http.Cookie{{Name: "sid", MaxAge: -1}})
+	if len(stored) != 1 || stored["example.com;/b;sid"] == nil {
+		t.Errorf("删除的Cookie不应该再被保存，实际为%v", stored)
+	}
+}
+
+func TestDefaultPath(t *testing.T) {
+	cases := map[string]string{
+		"":          "/",
+		"x":         "/",
+		"/":         "/",
+		"/login":    "/",
+		"/a/login":  "/a",
+		"/a/b/":     "/a/b",
+		"/a/b/c.do": "/a/b",
+	}
+
+	for urlPath, expected := range cases {
+		if path := defaultPath(urlPath); path != expected {
+			t.Errorf("%q: 期望%q，实际为%q", urlPath, expected, path)
+		}
+	}
+}
+
+func TestCookiejarStoreSaveAll(t *testing.T) {
+	dir := t.TempDir()
+	store := NewCookiejarStore(dir)
+
+	jar, err := store.Jar("blog/devtang")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := store.Jar("blog/devtang"); again != jar {
+		t.Error("同一个键应该得到同一个容器")
+	}
+
+	jar.SetCookies(mustParseURL(t, "http://blog.devtang.com/"), []*http.Cookie{{Name: "sid", Value: "s1", MaxAge: 3600}})
+	if err := store.SaveAll(); err != nil {
+		t.Fatalf("保存失败: %s", err)
+	}
+
+	reloaded, err := NewCookiejarStore(dir).Jar("blog/devtang")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value := cookieValue(reloaded, mustParseURL(t, "http://blog.devtang.com/"), "sid"); value != "s1" {
+		t.Errorf("重新打开的仓库应该恢复Cookie，实际为%q", value)
+	}
+}
@@ -0,0 +1,82 @@
+package cookie
+
+import (
+	"errors"
+	"net/url"
+	"path/filepath"
+	"sync"
+)
+
+// Cookie文件的扩展名
+var cookieFileExt = ".cookies.json"
+
+// Cookie容器仓库的接口。
+// 每个站点（或会话键）都拥有一个独立的、可持久化的Cookie容器。
+type MKCookiejarStore interface {
+	// 获取与给定键对应的Cookie容器，不存在时会创建并从文件加载
+	Jar(key string) (MKCookiejar, error)
+
+	// 保存所有已打开的Cookie容器
+	SaveAll() error
+
+	// 获取存放Cookie文件的目录
+	Dir() string
+}
+
+// 创建Cookie容器仓库。
+// 参数dir代表存放Cookie文件的目录，每个键对应其中的一个文件。
+func NewCookiejarStore(dir string) MKCookiejarStore {
+	return &mk_cookiejarStore{
+		dir:  dir,
+		jars: make(map[string]MKCookiejar),
+	}
+}
+
+// Cookie容器仓库的实现类型
+type mk_cookiejarStore struct {
+	dir   string                 // 存放Cookie文件的目录
+	jars  map[string]MKCookiejar // 已打开的Cookie容器
+	mutex sync.Mutex             // 互斥锁
+}
+
+func (store *mk_cookiejarStore) Jar(key string) (MKCookiejar, error) {
+	if key == "" {
+		return nil, errors.New("Cookie容器的键不能为空！")
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if jar, ok := store.jars[key]; ok {
+		return jar, nil
+	}
+
+	// 对键进行转义，使其可以安全地作为文件名
+	fileName := url.QueryEscape(key) + cookieFileExt
+	jar, err := NewPersistentCookiejar(filepath.Join(store.dir, fileName))
+	if err != nil {
+		return nil, err
+	}
+
+	store.jars[key] = jar
+
+	return jar, nil
+}
+
+func (store *mk_cookiejarStore) SaveAll() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	var firstErr error
+	for _, jar := range store.jars {
+		if err := jar.Save(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+func (store *mk_cookiejarStore) Dir() string {
+	return store.dir
+}
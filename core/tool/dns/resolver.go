@@ -0,0 +1,281 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 默认的DNS缓存有效期
+var defaultTTL = 5 * time.Minute
+
+// 默认的连接超时时间
+var defaultDialTimeout = 30 * time.Second
+
+// 解析器参数的描述模板
+var resolverArgumentsTemplate string = "{ ttl: %s, overrides: %d }"
+
+// 主机覆盖项，把主机名映射到指定的IP（及端口）上
+type HostOverride struct {
+	Host   string `xml:"host,attr"`   // 主机名
+	Target string `xml:"target,attr"` // 目标地址，形如IP或IP:port
+}
+
+// 解析器参数的容器
+type ResolverArguments struct {
+	TTL         string         `xml:"ttl"`  // 缓存有效期，例如“5m”。为空时使用默认值
+	Overrides   []HostOverride `xml:"host"` // 主机覆盖表
+	description string         // 描述
+}
+
+func (arguments *ResolverArguments) Check() error {
+	if arguments.TTL != "" {
+		if ttl, err := time.ParseDuration(arguments.TTL); err != nil || ttl < 0 {
+			return errors.New(fmt.Sprintf("无效的DNS缓存有效期【ttl = %s】！\n", arguments.TTL))
+		}
+	}
+
+	for _, override := range arguments.Overrides {
+		if override.Host == "" {
+			return errors.New("主机覆盖项的主机名不能为空！\n")
+		}
+
+		if _, _, err := splitTarget(override.Target); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (arguments *ResolverArguments) String() string {
+	if arguments.description == "" {
+		arguments.description =
+			fmt.Sprintf(resolverArgumentsTemplate,
+				arguments.ttl(),
+				len(arguments.Overrides))
+	}
+
+	return arguments.description
+}
+
+// 获取缓存有效期
+func (arguments *ResolverArguments) ttl() time.Duration {
+	ttl, err := time.ParseDuration(arguments.TTL)
+	if arguments.TTL == "" || err != nil {
+		return defaultTTL
+	}
+
+	return ttl
+}
+
+// 带缓存的域名解析器接口
+type MKResolver interface {
+	// 解析主机名，返回IP地址列表
+	Resolve(ctx context.Context, host string) ([]string, error)
+
+	// 建立网络连接。可以作为http.Transport的DialContext使用
+	DialContext(ctx context.Context, network string, address string) (net.Conn, error)
+
+	// 设置主机覆盖项。参数target为空时表示删除覆盖项
+	SetOverride(host string, target string) error
+
+	// 清空缓存
+	Flush()
+
+	// 获取摘要信息
+	Summary() string
+}
+
+// 创建域名解析器
+func NewResolver(arguments *ResolverArguments) (MKResolver, error) {
+	if arguments == nil {
+		arguments = &ResolverArguments{}
+	}
+
+	if err := arguments.Check(); err != nil {
+		return nil, err
+	}
+
+	resolver := &mk_resolver{
+		ttl:       arguments.ttl(),
+		lookup:    net.DefaultResolver.LookupHost,
+		now:       time.Now,
+		dialer:    &net.Dialer{Timeout: defaultDialTimeout, KeepAlive: 30 * time.Second},
+		cache:     make(map[string]*cacheEntry),
+		overrides: make(map[string]string),
+	}
+
+	for _, override := range arguments.Overrides {
+		resolver.overrides[normalizeHost(override.Host)] = override.Target
+	}
+
+	return resolver, nil
+}
+
+// 共享的默认解析器
+var defaultResolver MKResolver
+var defaultResolverOnce sync.Once
+
+// 获取进程内共享的默认解析器
+func DefaultResolver() MKResolver {
+	defaultResolverOnce.Do(func() {
+		defaultResolver, _ = NewResolver(nil)
+	})
+
+	return defaultResolver
+}
+
+// 缓存项
+type cacheEntry struct {
+	addresses []string  // IP地址列表
+	expires   time.Time // 过期时间
+}
+
+// 域名解析器的实现类型
+type mk_resolver struct {
+	ttl       time.Duration                                            // 缓存有效期
+	lookup    func(ctx context.Context, host string) ([]string, error) // 实际的解析函数
+	now       func() time.Time                                         // 获取当前时间，测试时可以替换
+	dialer    *net.Dialer                                              // 连接器
+	cache     map[string]*cacheEntry                                   // 缓存
+	overrides map[string]string                                        // 主机覆盖表
+	hits      uint64                                                   // 命中缓存的次数
+	misses    uint64                                                   // 未命中缓存的次数
+	mutex     sync.RWMutex                                             // 读写锁
+}
+
+func (resolver *mk_resolver) Resolve(ctx context.Context, host string) ([]string, error) {
+	host = normalizeHost(host)
+
+	if ip := net.ParseIP(host); ip != nil {
+		return []string{host}, nil
+	}
+
+	now := resolver.now()
+	resolver.mutex.Lock()
+	if entry, ok := resolver.cache[host]; ok && now.Before(entry.expires) {
+		resolver.hits++
+		resolver.mutex.Unlock()
+		return entry.addresses, nil
+	}
+	resolver.misses++
+	resolver.mutex.Unlock()
+
+	addresses, err := resolver.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	if resolver.ttl > 0 {
+		resolver.mutex.Lock()
+		resolver.cache[host] = &cacheEntry{addresses: addresses, expires: now.Add(resolver.ttl)}
+		resolver.mutex.Unlock()
+	}
+
+	return addresses, nil
+}
+
+func (resolver *mk_resolver) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	resolver.mutex.RLock()
+	target, overridden := resolver.overrides[normalizeHost(host)]
+	resolver.mutex.RUnlock()
+
+	if overridden {
+		overrideHost, overridePort, _ := splitTarget(target)
+		if overridePort != "" {
+			port = overridePort
+		}
+
+		return resolver.dialer.DialContext(ctx, network, net.JoinHostPort(overrideHost, port))
+	}
+
+	addresses, err := resolver.Resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	// 依次尝试每个地址，直到连接成功
+	var lastErr error
+	for _, ip := range addresses {
+		conn, err := resolver.dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+
+		lastErr = err
+	}
+
+	if lastErr == nil {
+		lastErr = errors.New(fmt.Sprintf("主机【%s】没有可用的地址", host))
+	}
+
+	return nil, lastErr
+}
+
+func (resolver *mk_resolver) SetOverride(host string, target string) error {
+	resolver.mutex.Lock()
+	defer resolver.mutex.Unlock()
+
+	if target == "" {
+		delete(resolver.overrides, normalizeHost(host))
+		return nil
+	}
+
+	if _, _, err := splitTarget(target); err != nil {
+		return err
+	}
+
+	resolver.overrides[normalizeHost(host)] = target
+
+	return nil
+}
+
+func (resolver *mk_resolver) Flush() {
+	resolver.mutex.Lock()
+	defer resolver.mutex.Unlock()
+
+	resolver.cache = make(map[string]*cacheEntry)
+}
+
+var summaryTemplate = "ttl: %s, cached: %d, hits: %d, misses: %d, overrides: %d"
+
+func (resolver *mk_resolver) Summary() string {
+	resolver.mutex.RLock()
+	defer resolver.mutex.RUnlock()
+
+	return fmt.Sprintf(summaryTemplate,
+		resolver.ttl,
+		len(resolver.cache),
+		resolver.hits,
+		resolver.misses,
+		len(resolver.overrides))
+}
+
+// 规范化主机名
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// 拆分覆盖项的目标地址。端口可以省略
+func splitTarget(target string) (string, string, error) {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		host, port = strings.Trim(target, "[]"), ""
+	}
+
+	if net.ParseIP(host) == nil {
+		return "", "", errors.New(fmt.Sprintf("无效的覆盖地址【target = %s】！\n", target))
+	}
+
+	return host, port, nil
+}
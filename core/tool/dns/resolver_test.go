@@ -0,0 +1,160 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// 创建不访问网络的解析器。主机名按hosts表解析，返回的计数器记录实际解析的次数
+func newTestResolver(t *testing.T, arguments *ResolverArguments, hosts map[string][]string) (*mk_resolver, *int, *time.Time) {
+	created, err := NewResolver(arguments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolver := created.(*mk_resolver)
+	lookups := 0
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	resolver.lookup = func(ctx context.Context, host string) ([]string, error) {
+		lookups++
+		if addresses, ok := hosts[host]; ok {
+			return addresses, nil
+		}
+		return nil, errors.New("no such host: " + host)
+	}
+	resolver.now = func() time.Time { return now }
+
+	return resolver, &lookups, &now
+}
+
+// 在本地监听一个端口，接受连接后立即写入标记并关闭
+func listen(t *testing.T, mark string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte(mark))
+			conn.Close()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+// 建立连接并读取对方写入的标记
+func dialMark(t *testing.T, resolver MKResolver, address string) string {
+	conn, err := resolver.DialContext(context.Background(), "tcp", address)
+	if err != nil {
+		t.Fatalf("连接%s失败: %s", address, err)
+	}
+	defer conn.Close()
+
+	buffer := make([]byte, 16)
+	n, _ := conn.Read(buffer)
+
+	return string(buffer[:n])
+}
+
+func TestResolverArgumentsCheck(t *testing.T) {
+	cases := []struct {
+		arguments ResolverArguments
+		valid     bool
+	}{
+		{ResolverArguments{}, true},
+		{ResolverArguments{TTL: "30s", Overrides: []HostOverride{{"a.test", "10.0.0.1"}, {"b.test", "[::1]:8080"}}}, true},
+		{ResolverArguments{TTL: "soon"}, false},
+		{ResolverArguments{TTL: "-1s"}, false},
+		{ResolverArguments{Overrides: []HostOverride{{"", "10.0.0.1"}}}, false},
+		{ResolverArguments{Overrides: []HostOverride{{"a.test", "b.test"}}}, false},
+	}
+
+	for i, c := range cases {
+		if err := c.arguments.Check(); (err == nil) != c.valid {
+			t.Errorf("[%d] %s: 期望有效为%v，实际错误为%v", i, c.arguments.String(), c.valid, err)
+		}
+	}
+}
+
+func TestResolverCacheExpiry(t *testing.T) {
+	resolver, lookups, now := newTestResolver(t, &ResolverArguments{TTL: "1m"},
+		map[string][]string{"blog.devtang.com": {"10.0.0.1"}})
+
+	for i := 0; i < 3; i++ {
+		addresses, err := resolver.Resolve(context.Background(), "Blog.Devtang.com.")
+		if err != nil || len(addresses) != 1 || addresses[0] != "10.0.0.1" {
+			t.Fatalf("解析失败: %v %v", addresses, err)
+		}
+	}
+	if *lookups != 1 {
+		t.Fatalf("有效期内应该只解析一次，实际解析了%d次", *lookups)
+	}
+
+	*now = now.Add(time.Minute)
+	resolver.Resolve(context.Background(), "blog.devtang.com")
+	if *lookups != 2 {
+		t.Fatalf("缓存过期后应该重新解析，实际解析了%d次", *lookups)
+	}
+
+	resolver.Flush()
+	resolver.Resolve(context.Background(), "blog.devtang.com")
+	if *lookups != 3 {
+		t.Fatalf("清空缓存后应该重新解析，实际解析了%d次", *lookups)
+	}
+
+	// IP地址不需要解析
+	if addresses, _ := resolver.Resolve(context.Background(), "10.0.0.2"); len(addresses) != 1 || *lookups != 3 {
+		t.Errorf("IP地址不应该被解析: %v", addresses)
+	}
+}
+
+func TestResolverDialContext(t *testing.T) {
+	resolved := listen(t, "resolved")
+	overridden := listen(t, "overridden")
+	_, resolvedPort, _ := net.SplitHostPort(resolved)
+
+	resolver, lookups, _ := newTestResolver(t, &ResolverArguments{
+		Overrides: []HostOverride{{Host: "mirror.test", Target: overridden}},
+	}, map[string][]string{"blog.test": {"127.0.0.1"}})
+
+	if mark := dialMark(t, resolver, net.JoinHostPort("blog.test", resolvedPort)); mark != "resolved" {
+		t.Errorf("应该连接到解析得到的地址，实际为%q", mark)
+	}
+
+	// 覆盖项带端口时使用覆盖项的端口，并且不经过解析
+	if mark := dialMark(t, resolver, "mirror.test:80"); mark != "overridden" || *lookups != 1 {
+		t.Errorf("应该连接到覆盖的地址，实际为%q（解析了%d次）", mark, *lookups)
+	}
+
+	// 运行时改写覆盖项
+	if err := resolver.SetOverride("blog.test", overridden); err != nil {
+		t.Fatal(err)
+	}
+	if mark := dialMark(t, resolver, net.JoinHostPort("blog.test", resolvedPort)); mark != "overridden" {
+		t.Errorf("设置覆盖项之后应该连接到覆盖的地址，实际为%q", mark)
+	}
+
+	// 删除覆盖项之后恢复解析
+	resolver.SetOverride("blog.test", "")
+	if mark := dialMark(t, resolver, net.JoinHostPort("blog.test", resolvedPort)); mark != "resolved" {
+		t.Errorf("删除覆盖项之后应该恢复解析，实际为%q", mark)
+	}
+
+	if err := resolver.SetOverride("blog.test", "not-an-ip"); err == nil {
+		t.Error("无效的覆盖地址应该被拒绝")
+	}
+
+	if _, err := resolver.DialContext(context.Background(), "tcp", "unknown.test:80"); err == nil {
+		t.Error("无法解析的主机应该返回错误")
+	}
+}
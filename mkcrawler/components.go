@@ -0,0 +1,50 @@
+package main
+
+import (
+	config "core/config"
+	registry "core/registry"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+// 列出已注册的解析器、条目处理器和网页下载器及其选项，以及已加载的插件
+func runComponents(settings *config.Settings, args []string) error {
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(table, "PARSER\tOPTION\tTYPE\tDEFAULT\tDESCRIPTION")
+	for _, spec := range registry.Parsers() {
+		printComponent(table, spec.Name, spec.Description, spec.Options)
+	}
+
+	fmt.Fprintln(table, "\nPROCESSOR\tOPTION\tTYPE\tDEFAULT\tDESCRIPTION")
+	for _, spec := range registry.Processors() {
+		printComponent(table, spec.Name, spec.Description, spec.Options)
+	}
+
+	fmt.Fprintln(table, "\nDOWNLOADER\tOPTION\tTYPE\tDEFAULT\tDESCRIPTION")
+	for _, spec := range registry.Downloaders() {
+		printComponent(table, spec.Name, spec.Description, spec.Options)
+	}
+
+	if loaded := registry.Plugins(); len(loaded) > 0 {
+		fmt.Fprintln(table, "\nPLUGIN\tVERSION\tAPI\tPATH\t")
+		for _, plugin := range loaded {
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\t\n", plugin.Name, plugin.Version, plugin.APIVersion, plugin.Path)
+		}
+	}
+
+	return table.Flush()
+}
+
+// 输出一个组件及其选项，每个选项占一行
+func printComponent(table *tabwriter.Writer, name string, description string, options registry.Schema) {
+	fmt.Fprintf(table, "%s\t\t\t\t%s\n", name, description)
+	for _, option := range options {
+		usage := option.Usage
+		if option.Required {
+			usage = "（必须）" + usage
+		}
+		fmt.Fprintf(table, "\t%s\t%s\t%s\t%s\n", option.Name, option.Type, option.Default, usage)
+	}
+}
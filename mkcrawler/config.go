@@ -0,0 +1,21 @@
+package main
+
+import (
+	config "core/config"
+	"os"
+)
+
+// 输出生效的配置以及每个值的来源。
+// 站点配置文件存在时，其中的通道参数和池基本参数也会计入
+func runConfig(settings *config.Settings, args []string) error {
+	path := settings.Get("websites")
+	if _, err := os.Stat(path); err == nil {
+		websites, err := config.LoadWebsites(path, nil)
+		if err != nil {
+			return err
+		}
+		settings.ApplyWebsites(websites, path)
+	}
+
+	return settings.Print(os.Stdout)
+}
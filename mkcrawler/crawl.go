@@ -0,0 +1,344 @@
+package main
+
+import (
+	base "core/base"
+	config "core/config"
+	downloader "core/downloader"
+	itempipeline "core/itempipeline"
+	scheduler "core/scheduler"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+)
+
+// 每完成多少个请求保存一次检查点
+const checkpointInterval = 100
+
+// crawl命令的参数
+var crawlOptions struct {
+	site        string // 站点名称，为空时爬取所有站点
+	maxRequests uint64 // 每个站点最多的请求数量，0表示不限
+	checkpoint  string // 检查点文件的路径
+	output      string // 条目的输出文件
+}
+
+// 注册crawl命令的参数
+func crawlFlags(flags *flag.FlagSet) {
+	flags.StringVar(&crawlOptions.site, "site", "", "只爬取给定名称的站点，默认爬取所有站点")
+	flags.Uint64Var(&crawlOptions.maxRequests, "max-requests", 0, "每个站点最多的请求数量，0表示不限")
+	flags.StringVar(&crawlOptions.checkpoint, "checkpoint", "", "检查点文件。文件存在时从中恢复爬取边界，中断或结束时保存爬取边界")
+	flags.StringVar(&crawlOptions.output, "output", "",
+		"条目的输出文件（JSON Lines）。站点的条目处理流程中没有条目输出且未指定此参数时，条目输出到标准输出")
+}
+
+// 按站点配置爬取站点
+func runCrawl(settings *config.Settings, args []string) error {
+	websites, err := loadWebsites(settings)
+	if err != nil {
+		return err
+	}
+
+	sites, err := selectSites(websites, crawlOptions.site)
+	if err != nil {
+		return err
+	}
+
+	channelArguments := settings.ChannelArguments()
+	if err := channelArguments.Check(); err != nil {
+		return err
+	}
+
+	poolArguments := settings.PoolArguments()
+	if err := poolArguments.Check(); err != nil {
+		return err
+	}
+
+	network, err := websites.NewNetwork(settings.Get("cookies"), settings.Get("hosts"))
+	if err != nil {
+		return err
+	}
+
+	state := &checkpoint{}
+	if crawlOptions.checkpoint != "" {
+		if _, err := os.Stat(crawlOptions.checkpoint); err == nil {
+			if state, err = loadCheckpoint(crawlOptions.checkpoint); err != nil {
+				return err
+			}
+		}
+	}
+
+	var output io.Writer = os.Stdout
+	if crawlOptions.output != "" {
+		file, err := os.OpenFile(crawlOptions.output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		output = file
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	crawler := &siteCrawler{
+		channelArguments: channelArguments,
+		poolArguments:    poolArguments,
+		network:          network,
+		encoder:          json.NewEncoder(output),
+		state:            state,
+		interrupt:        interrupt,
+	}
+	crawler.encoder.SetEscapeHTML(false)
+
+	for _, site := range sites {
+		if err := crawler.crawl(site); err != nil {
+			return err
+		}
+		if crawler.stopped {
+			return errors.New("爬取被中断。")
+		}
+	}
+
+	return nil
+}
+
+// 站点爬取器
+type siteCrawler struct {
+	channelArguments base.ChannelArguments     // 调度器的通道参数
+	poolArguments    base.PoolArguments        // 调度器的池基本参数
+	network          *config.Network           // 站点共用的网络环境
+	sinks            []itempipeline.MKItemSink // 当前站点的条目输出
+	encoder          *json.Encoder             // 条目的编码器
+	state            *checkpoint               // 检查点
+	interrupt        chan os.Signal            // 中断信号
+	stopped          bool                      // 是否已被中断
+}
+
+// 爬取一个站点，直到没有待爬取的URL、达到最多的请求数量或被中断
+func (crawler *siteCrawler) crawl(site *config.Site) (err error) {
+	parsers, err := site.Parsers()
+	if err != nil {
+		return err
+	}
+
+	filter, err := site.ScopeFilter()
+	if err != nil {
+		return err
+	}
+
+	siteDownloader, err := site.NewDownloader(crawler.network)
+	if err != nil {
+		return err
+	}
+
+	// 需要登录的站点先登录，再放入种子请求
+	if loginDownloader, ok := siteDownloader.(downloader.MKLoginPageDownloader); ok {
+		fmt.Fprintf(os.Stderr, "登录站点【%s】\n", site.Name)
+		if err := loginDownloader.Session().Login(); err != nil {
+			return errors.New(fmt.Sprintf("站点【%s】登录失败: %s", site.Name, err))
+		}
+	}
+
+	// 经过站点的条目处理流程之后，再按需要输出条目
+	processors, sinks, err := site.Processors()
+	if err != nil {
+		return err
+	}
+	if crawlOptions.output != "" || len(sinks) == 0 {
+		processors = append(processors, crawler.writeItem)
+	}
+
+	// 无论如何结束，都要刷新并关闭条目输出
+	crawler.sinks = sinks
+	defer func() {
+		if closeErr := crawler.closeSinks(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	pipeline := itempipeline.NewItemPipeline(processors)
+	effective := site.EffectiveCrawler()
+	pipeline.SetFailFast(effective.FailFast)
+
+	f := crawler.state.frontier(site.Name)
+	if f == nil {
+		f = newFrontier(site.Name)
+		crawler.state.Frontiers = append(crawler.state.Frontiers, f)
+		for _, seed := range site.Seeds() {
+			f.push(seed, 0)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "开始爬取站点【%s】，待爬取%d个URL\n", site.Name, len(f.Pending))
+
+	sched := scheduler.NewScheduler()
+	err = sched.Start(crawler.channelArguments, crawler.poolArguments,
+		sharedDownloaders(siteDownloader), parsers, pipeline)
+	if err != nil {
+		return err
+	}
+
+	// 错误通道在调度器停止后关闭
+	errorsDone := make(chan struct{})
+	go func() {
+		defer close(errorsDone)
+		for err := range sched.ErrorChan() {
+			fmt.Fprintf(os.Stderr, "错误: %s\n", err)
+		}
+	}()
+
+	// 请求通道和所有网页下载器都占满时不再放入请求，其余的URL留在爬取边界中
+	limit := int(crawler.poolArguments.PageDownloaderPoolSize() + uint32(crawler.channelArguments.RequestChannelLength()))
+	items := f.Items // 之前的爬取中成功处理的条目数量
+	sent := make(map[*base.MKRequest]frontierEntry)
+	started, completed := uint64(0), 0
+	for {
+		for !crawler.stopped && len(sent) < limit &&
+			(crawlOptions.maxRequests == 0 || started < crawlOptions.maxRequests) {
+			entry, ok := f.pop()
+			if !ok {
+				break
+			}
+
+			started++
+			httpRequest, err := http.NewRequest("GET", entry.URL, nil)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "错误: %s: %s\n", entry.URL, err)
+				f.done(entry)
+				f.Requests++
+				continue
+			}
+
+			request := base.NewRequest(httpRequest, entry.Depth)
+			if sched.Send(request) {
+				sent[request] = entry
+			}
+		}
+
+		if len(sent) == 0 {
+			break
+		}
+
+		select {
+		case result := <-sched.ResultChan():
+			entry := sent[result.Request()]
+			delete(sent, result.Request())
+			crawler.handle(f, filter, entry, result)
+
+			completed++
+			if completed%checkpointInterval == 0 {
+				sched.WaitItems()
+				f.Items = items + sched.Items()
+				if err := crawler.save(); err != nil {
+					crawler.stop(sched, sent, errorsDone)
+					return err
+				}
+			}
+		case <-crawler.interrupt:
+			fmt.Fprintln(os.Stderr, "收到中断信号，等待进行中的请求完成……")
+			crawler.stopped = true
+		}
+	}
+
+	sched.Stop()
+	<-errorsDone
+	f.Items = items + sched.Items()
+
+	fmt.Fprintf(os.Stderr, "站点【%s】: 请求%d个，成功处理条目%d个（本次丢弃%d个），待爬取%d个\n",
+		site.Name, f.Requests, f.Items, pipeline.Dropped(), len(f.Pending))
+
+	return crawler.save()
+}
+
+// 出错时停止调度器：先接收进行中的请求的结果，使调度器可以停止
+func (crawler *siteCrawler) stop(sched scheduler.MKScheduler, sent map[*base.MKRequest]frontierEntry, errorsDone <-chan struct{}) {
+	for range sent {
+		<-sched.ResultChan()
+	}
+
+	sched.Stop()
+	<-errorsDone
+}
+
+// 处理请求的结果：标记请求已完成，并把范围之内的新请求加入爬取边界。
+// 分析得到的条目已由调度器发送到条目处理管道
+func (crawler *siteCrawler) handle(
+	f *frontier,
+	filter config.MKScopeFilter,
+	entry frontierEntry,
+	result *scheduler.MKResult) {
+
+	f.done(entry)
+	f.Requests++
+
+	for _, request := range result.Requests() {
+		if !request.Valid() || !filter.Allows(request.Request().URL, request.Depth()) {
+			continue
+		}
+		f.push(request.Request().URL.String(), request.Depth())
+	}
+}
+
+// 池中的网页下载器。
+// 同一个站点的网页下载器应该共享HTTP客户端、Cookie和登录会话，
+// 因此池中的网页下载器都使用站点的同一个网页下载器，只是ID各不相同
+type pooledDownloader struct {
+	downloader.MKPageDownloader
+	id uint32 // ID
+}
+
+func (pooled *pooledDownloader) ID() uint32 {
+	return pooled.id
+}
+
+// 生成共享站点网页下载器的网页下载器
+func sharedDownloaders(siteDownloader downloader.MKPageDownloader) downloader.GeneratePageDownloader {
+	var id uint32
+	return func() downloader.MKPageDownloader {
+		id++
+		return &pooledDownloader{MKPageDownloader: siteDownloader, id: id}
+	}
+}
+
+// 以JSON Lines的格式输出条目，作为条目处理流程的最后一步
+func (crawler *siteCrawler) writeItem(item base.MKItem) (base.MKItem, error) {
+	return nil, crawler.encoder.Encode(item)
+}
+
+// 刷新条目输出，保存Cookie和检查点。先刷新条目输出，使检查点不会超前于已写入的条目
+func (crawler *siteCrawler) save() error {
+	for _, sink := range crawler.sinks {
+		if err := sink.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if err := crawler.network.Save(); err != nil {
+		return err
+	}
+
+	if crawlOptions.checkpoint == "" {
+		return nil
+	}
+
+	return crawler.state.save(crawlOptions.checkpoint)
+}
+
+// 关闭当前站点的所有条目输出，返回第一个错误
+func (crawler *siteCrawler) closeSinks() error {
+	var err error
+	for _, sink := range crawler.sinks {
+		if closeErr := sink.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	crawler.sinks = nil
+
+	return err
+}
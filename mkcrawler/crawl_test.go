@@ -0,0 +1,67 @@
+package main
+
+import (
+	base "core/base"
+	config "core/config"
+	downloader "core/downloader"
+	scheduler "core/scheduler"
+	"net/http"
+	"testing"
+)
+
+func TestCrawlerHandlesResult(t *testing.T) {
+	filter, err := config.NewScopeFilter(&config.Scope{MaxDepth: 1}, []string{"http://blog.devtang.com/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newRequest := func(rawURL string, depth uint32) *base.MKRequest {
+		httpRequest, _ := http.NewRequest("GET", rawURL, nil)
+		return base.NewRequest(httpRequest, depth)
+	}
+
+	f := newFrontier("blog")
+	f.push("http://blog.devtang.com/", 0)
+	entry, _ := f.pop()
+
+	result := scheduler.NewResult(newRequest(entry.URL, entry.Depth), []*base.MKRequest{
+		newRequest("http://blog.devtang.com/p/1", 1),
+		newRequest("http://blog.devtang.com/p/2", 2),
+		newRequest("http://other.com/", 1),
+		newRequest("http://blog.devtang.com/", 1),
+	})
+
+	crawler := &siteCrawler{}
+	crawler.handle(f, filter, entry, result)
+
+	if f.Requests != 1 || len(f.inflight) != 0 {
+		t.Errorf("完成的请求应该离开进行中的列表: %d %v", f.Requests, f.inflight)
+	}
+	if len(f.Pending) != 1 || f.Pending[0].URL != "http://blog.devtang.com/p/1" {
+		t.Errorf("只有范围之内且没有见过的请求应该加入爬取边界，实际为%v", f.Pending)
+	}
+}
+
+func TestSharedDownloaders(t *testing.T) {
+	siteDownloader := downloader.NewPageDownloader(nil)
+	pool, err := downloader.NewPageDownloaderPool(3, sharedDownloaders(siteDownloader))
+	if err != nil {
+		t.Fatalf("无法创建网页下载器池: %s", err)
+	}
+
+	ids := make(map[uint32]bool)
+	for i := 0; i < 3; i++ {
+		pageDownloader, err := pool.Take()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pooled, ok := pageDownloader.(*pooledDownloader); !ok || pooled.MKPageDownloader != siteDownloader {
+			t.Errorf("池中的网页下载器应该使用站点的网页下载器，实际为%v", pageDownloader)
+		}
+		ids[pageDownloader.ID()] = true
+	}
+
+	if len(ids) != 3 {
+		t.Errorf("池中的网页下载器的ID应该各不相同，实际为%v", ids)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	base "core/base"
+	config "core/config"
+	downloader "core/downloader"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+)
+
+// fetch命令的参数
+var fetchOptions struct {
+	headers bool // 是否输出所有响应头
+	body    bool // 是否输出响应体
+}
+
+// 注册fetch命令的参数
+func fetchFlags(flags *flag.FlagSet) {
+	flags.BoolVar(&fetchOptions.headers, "headers", false, "输出所有响应头")
+	flags.BoolVar(&fetchOptions.body, "body", false, "输出响应体")
+}
+
+// 下载一个URL并输出响应的摘要
+func runFetch(settings *config.Settings, args []string) error {
+	if len(args) != 1 {
+		return errors.New("用法: mkcrawler fetch [-headers] [-body] <URL>")
+	}
+
+	httpRequest, err := http.NewRequest("GET", args[0], nil)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	response, err := downloader.NewPageDownloader(nil).Download(*base.NewRequest(httpRequest, 0))
+	if err != nil {
+		return err
+	}
+
+	httpResponse := response.Response()
+	defer httpResponse.Body.Close()
+
+	body, err := io.ReadAll(httpResponse.Body)
+	if err != nil {
+		return err
+	}
+	elapsed := time.Since(start)
+
+	fmt.Printf("URL:            %s\n", httpResponse.Request.URL)
+	fmt.Printf("Status:         %s\n", httpResponse.Status)
+	fmt.Printf("Protocol:       %s\n", httpResponse.Proto)
+	fmt.Printf("Content-Type:   %s\n", httpResponse.Header.Get("Content-Type"))
+	fmt.Printf("Content-Length: %d\n", len(body))
+	fmt.Printf("Elapsed:        %s\n", elapsed.Round(time.Millisecond))
+
+	if fetchOptions.headers {
+		fmt.Println()
+		names := make([]string, 0, len(httpResponse.Header))
+		for name := range httpResponse.Header {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			for _, value := range httpResponse.Header[name] {
+				fmt.Printf("%s: %s\n", name, value)
+			}
+		}
+	}
+
+	if fetchOptions.body {
+		fmt.Println()
+		os.Stdout.Write(body)
+	}
+
+	return nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	config "core/config"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// 待爬取的URL
+type frontierEntry struct {
+	URL   string `json:"url"`   // URL
+	Depth uint32 `json:"depth"` // 深度
+}
+
+// 一个站点的爬取边界（待爬取的URL和已见过的URL）
+type frontier struct {
+	Site     string          `json:"site"`     // 站点名称
+	Pending  []frontierEntry `json:"pending"`  // 待爬取的URL，按先进先出的顺序
+	Seen     []string        `json:"seen"`     // 已见过（已爬取或待爬取）的URL
+	Requests uint64          `json:"requests"` // 已完成的请求数量
+	Items    uint64          `json:"items"`    // 成功处理的条目数量（不包括被丢弃和处理出错的条目）
+	seen     map[string]bool // 已见过的URL的集合
+	inflight []frontierEntry // 已取出但还没有完成的URL，按取出的顺序
+}
+
+// 检查点文件的内容
+type checkpoint struct {
+	Saved     string      `json:"saved"`     // 保存时间（RFC 3339）
+	Frontiers []*frontier `json:"frontiers"` // 各个站点的爬取边界
+}
+
+// 创建爬取边界
+func newFrontier(site string) *frontier {
+	return &frontier{Site: site, seen: make(map[string]bool)}
+}
+
+// 加入待爬取的URL。已见过的URL会被忽略，此时返回false
+func (f *frontier) push(rawURL string, depth uint32) bool {
+	if f.seen[rawURL] {
+		return false
+	}
+
+	f.seen[rawURL] = true
+	f.Seen = append(f.Seen, rawURL)
+	f.Pending = append(f.Pending, frontierEntry{URL: rawURL, Depth: depth})
+	return true
+}
+
+// 取出最早加入的待爬取URL
+func (f *frontier) pop() (frontierEntry, bool) {
+	if len(f.Pending) == 0 {
+		return frontierEntry{}, false
+	}
+
+	entry := f.Pending[0]
+	f.Pending = f.Pending[1:]
+	f.inflight = append(f.inflight, entry)
+	return entry, true
+}
+
+// 标记取出的URL已经完成
+func (f *frontier) done(entry frontierEntry) {
+	for i, inflight := range f.inflight {
+		if inflight == entry {
+			f.inflight = append(f.inflight[:i], f.inflight[i+1:]...)
+			return
+		}
+	}
+}
+
+// 获取用于保存的爬取边界。
+// 进行中的URL放回待爬取URL的最前面，使从检查点恢复时不会丢失它们
+func (f *frontier) snapshot() *frontier {
+	pending := make([]frontierEntry, 0, len(f.inflight)+len(f.Pending))
+	pending = append(pending, f.inflight...)
+	pending = append(pending, f.Pending...)
+
+	return &frontier{
+		Site:     f.Site,
+		Pending:  pending,
+		Seen:     f.Seen,
+		Requests: f.Requests,
+		Items:    f.Items,
+	}
+}
+
+// 读取检查点文件
+func loadCheckpoint(path string) (*checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var result checkpoint
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, errors.New(fmt.Sprintf("无法解析检查点文件【%s】: %s", path, err))
+	}
+
+	for _, f := range result.Frontiers {
+		f.seen = make(map[string]bool, len(f.Seen))
+		for _, seen := range f.Seen {
+			f.seen[seen] = true
+		}
+	}
+
+	return &result, nil
+}
+
+// 查找站点的爬取边界
+func (c *checkpoint) frontier(site string) *frontier {
+	for _, f := range c.Frontiers {
+		if f.Site == site {
+			return f
+		}
+	}
+
+	return nil
+}
+
+// 保存检查点文件，其中包括进行中的URL。先写入临时文件再重命名，避免中断时留下不完整的文件
+func (c *checkpoint) save(path string) error {
+	c.Saved = time.Now().Format(time.RFC3339)
+
+	snapshot := &checkpoint{Saved: c.Saved, Frontiers: make([]*frontier, 0, len(c.Frontiers))}
+	for _, f := range c.Frontiers {
+		snapshot.Frontiers = append(snapshot.Frontiers, f.snapshot())
+	}
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return err
+	}
+
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return err
+	}
+
+	if err := temp.Close(); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+
+	return os.Rename(temp.Name(), path)
+}
+
+// frontier命令的参数
+var frontierOptions struct {
+	list int // 列出的待爬取URL的数量
+}
+
+// 注册frontier命令的参数
+func frontierFlags(flags *flag.FlagSet) {
+	flags.IntVar(&frontierOptions.list, "list", 10, "每个站点列出的待爬取URL的数量，-1表示全部")
+}
+
+// 查看检查点文件中保存的爬取边界
+func runFrontier(settings *config.Settings, args []string) error {
+	if len(args) != 1 {
+		return errors.New("用法: mkcrawler frontier [-list N] <检查点文件>")
+	}
+
+	result, err := loadCheckpoint(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("检查点: %s（保存于%s）\n", args[0], result.Saved)
+	for _, f := range result.Frontiers {
+		fmt.Println()
+		fmt.Printf("站点:     %s\n", f.Site)
+		fmt.Printf("已请求:   %d\n", f.Requests)
+		fmt.Printf("条目:     %d\n", f.Items)
+		fmt.Printf("已见过:   %d\n", len(f.Seen))
+		fmt.Printf("待爬取:   %d\n", len(f.Pending))
+
+		hosts := make(map[string]int)
+		depths := make(map[uint32]int)
+		for _, entry := range f.Pending {
+			host := entry.URL
+			if entryURL, err := url.Parse(entry.URL); err == nil {
+				host = entryURL.Host
+			}
+			hosts[host]++
+			depths[entry.Depth]++
+		}
+
+		if len(hosts) > 0 {
+			fmt.Println("按主机:")
+			for _, host := range sortedKeys(hosts) {
+				fmt.Printf("  %-30s %d\n", host, hosts[host])
+			}
+
+			levels := make([]int, 0, len(depths))
+			for depth := range depths {
+				levels = append(levels, int(depth))
+			}
+			sort.Ints(levels)
+
+			fmt.Println("按深度:")
+			for _, depth := range levels {
+				fmt.Printf("  %-30d %d\n", depth, depths[uint32(depth)])
+			}
+		}
+
+		limit := frontierOptions.list
+		if limit < 0 || limit > len(f.Pending) {
+			limit = len(f.Pending)
+		}
+		if limit > 0 {
+			fmt.Println("待爬取的URL:")
+			for _, entry := range f.Pending[:limit] {
+				fmt.Printf("  [%d] %s\n", entry.Depth, entry.URL)
+			}
+			if limit < len(f.Pending) {
+				fmt.Printf("  ……还有%d个\n", len(f.Pending)-limit)
+			}
+		}
+	}
+
+	return nil
+}
+
+// 按数量降序（数量相同时按名称升序）获取映射的键
+func sortedKeys(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	return keys
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheckpointKeepsInflightEntries(t *testing.T) {
+	f := newFrontier("blog")
+	for _, rawURL := range []string{"http://blog.devtang.com/", "http://blog.devtang.com/p/1", "http://blog.devtang.com/p/2"} {
+		f.push(rawURL, 1)
+	}
+	if f.push("http://blog.devtang.com/p/1", 2) {
+		t.Error("已见过的URL不应该再次加入")
+	}
+
+	first, _ := f.pop()
+	second, _ := f.pop()
+	f.done(first)
+	f.Requests++
+
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	state := &checkpoint{Frontiers: []*frontier{f}}
+	if err := state.save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// 保存不应该改变内存中的爬取边界
+	if len(f.Pending) != 1 || len(f.inflight) != 1 {
+		t.Errorf("保存之后待爬取%d个，进行中%d个", len(f.Pending), len(f.inflight))
+	}
+
+	loaded, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := loaded.frontier("blog")
+	if restored == nil {
+		t.Fatal("应该恢复站点的爬取边界")
+	}
+
+	expected := []frontierEntry{second, {URL: "http://blog.devtang.com/p/2", Depth: 1}}
+	if !reflect.DeepEqual(restored.Pending, expected) {
+		t.Errorf("进行中的URL应该排在待爬取URL的最前面，期望%v，实际为%v", expected, restored.Pending)
+	}
+	if restored.Requests != 1 || len(restored.Seen) != 3 {
+		t.Errorf("请求数量和已见过的URL不正确: %d %v", restored.Requests, restored.Seen)
+	}
+	if restored.push("http://blog.devtang.com/", 0) {
+		t.Error("恢复之后已见过的URL不应该再次加入")
+	}
+}
@@ -0,0 +1,108 @@
+// mkcrawler是爬虫的命令行工具。
+//
+// 用法：
+//
+//	mkcrawler <命令> [参数]
+//
+// 每个命令都接受-config参数以及所有配置项对应的命令行参数，
+// 配置项的值依次由默认值、配置文件、MKCRAWLER_*环境变量和命令行参数决定。
+package main
+
+import (
+	config "core/config"
+	registry "core/registry"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// 子命令
+type command struct {
+	name  string                                               // 命令名称
+	usage string                                               // 说明
+	run   func(settings *config.Settings, args []string) error // 执行命令，参数args为选项之后的参数
+	flags func(flags *flag.FlagSet)                            // 注册命令特有的命令行参数，可以为nil
+}
+
+// 所有子命令
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{name: "crawl", usage: "按站点配置爬取站点", run: runCrawl, flags: crawlFlags},
+		{name: "validate", usage: "校验站点配置文件", run: runValidate},
+		{name: "fetch", usage: "下载一个URL并输出响应的摘要", run: runFetch, flags: fetchFlags},
+		{name: "parse", usage: "用站点的规则解析一个URL或本地文件并输出条目", run: runParse, flags: parseFlags},
+		{name: "frontier", usage: "查看检查点文件中保存的爬取边界", run: runFrontier, flags: frontierFlags},
+		{name: "types", usage: "列出已注册的站点类型", run: runTypes},
+		{name: "components", usage: "列出已注册的解析器、条目处理器、网页下载器以及已加载的插件", run: runComponents},
+		{name: "config", usage: "输出生效的配置以及每个值的来源", run: runConfig},
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		printUsage()
+		return
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == name {
+			os.Exit(execute(cmd, os.Args[2:]))
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "未知的命令【%s】\n\n", name)
+	printUsage()
+	os.Exit(2)
+}
+
+// 执行子命令，返回进程的退出码
+func execute(cmd *command, args []string) int {
+	flags := flag.NewFlagSet("mkcrawler "+cmd.name, flag.ContinueOnError)
+	settings := config.NewSettings()
+	settings.RegisterFlags(flags)
+	if cmd.flags != nil {
+		cmd.flags(flags)
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if err := config.ResolveSettings(settings, flags, os.Environ()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	// 插件中的组件可能被站点配置引用，所以在执行任何命令之前加载插件
+	if err := registry.LoadPlugins(settings.Get("plugins")); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if err := cmd.run(settings, flags.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// 输出用法
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "用法: mkcrawler <命令> [参数]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "命令:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "使用“mkcrawler <命令> -h”查看命令的参数。")
+}
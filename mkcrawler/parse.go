@@ -0,0 +1,168 @@
+package main
+
+import (
+	analyzer "core/analyzer"
+	base "core/base"
+	config "core/config"
+	downloader "core/downloader"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// parse命令的参数
+var parseOptions struct {
+	site        string // 站点名称
+	baseURL     string // 解析本地文件时使用的页面URL
+	contentType string // 解析本地文件时使用的内容类型
+	depth       uint   // 页面的深度
+}
+
+// 注册parse命令的参数
+func parseFlags(flags *flag.FlagSet) {
+	flags.StringVar(&parseOptions.site, "site", "", "站点名称（必需）")
+	flags.StringVar(&parseOptions.baseURL, "url", "", "解析本地文件时使用的页面URL，默认为站点的URL")
+	flags.StringVar(&parseOptions.contentType, "content-type", "", "解析本地文件时使用的内容类型，默认根据扩展名和内容判断")
+	flags.UintVar(&parseOptions.depth, "depth", 0, "页面的深度")
+}
+
+// 用站点的规则解析一个URL或本地文件，把条目以JSON Lines的格式输出到标准输出，
+// 生成的请求和解析错误输出到标准错误
+func runParse(settings *config.Settings, args []string) error {
+	if len(args) != 1 || parseOptions.site == "" {
+		return errors.New("用法: mkcrawler parse -site <站点名称> [-url <页面URL>] [-content-type <类型>] <URL|文件>")
+	}
+
+	websites, err := loadWebsites(settings)
+	if err != nil {
+		return err
+	}
+
+	sites, err := selectSites(websites, parseOptions.site)
+	if err != nil {
+		return err
+	}
+	site := sites[0]
+
+	parsers, err := site.Parsers()
+	if err != nil {
+		return err
+	}
+
+	depth := uint32(parseOptions.depth)
+	var httpResponse *http.Response
+	if target, err := url.Parse(args[0]); err == nil && (target.Scheme == "http" || target.Scheme == "https") {
+		network, err := websites.NewNetwork(settings.Get("cookies"), settings.Get("hosts"))
+		if err != nil {
+			return err
+		}
+
+		httpResponse, err = download(site, network, target.String(), depth)
+		if err != nil {
+			return err
+		}
+
+		if err := network.Save(); err != nil {
+			return err
+		}
+	} else {
+		pageURL := parseOptions.baseURL
+		if pageURL == "" {
+			pageURL = site.URL
+		}
+		httpResponse, err = fileResponse(args[0], pageURL, parseOptions.contentType)
+		if err != nil {
+			return err
+		}
+	}
+
+	defer httpResponse.Body.Close()
+
+	dataList, errorList := analyzer.NewAnalyzer().Analyze(parsers, *base.NewResponse(httpResponse, depth))
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	items, requests := 0, 0
+	for _, data := range dataList {
+		switch value := data.(type) {
+		case base.MKItem:
+			if err := encoder.Encode(value); err != nil {
+				return err
+			}
+			items++
+		case *base.MKRequest:
+			fmt.Fprintf(os.Stderr, "请求 depth=%d %s\n", value.Depth(), value.Request().URL)
+			requests++
+		}
+	}
+
+	for _, err := range errorList {
+		fmt.Fprintf(os.Stderr, "错误 %s\n", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "条目: %d，请求: %d，错误: %d\n", items, requests, len(errorList))
+	return nil
+}
+
+// 用站点的网页下载器下载网页
+func download(site *config.Site, network *config.Network, rawURL string, depth uint32) (*http.Response, error) {
+	httpRequest, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	pageDownloader, err := site.NewDownloader(network)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := pageDownloader.Download(*base.NewRequest(httpRequest, depth))
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Response(), nil
+}
+
+// 用本地文件网页下载器读取文件，并以页面URL作为响应对应的请求，使相对链接按页面URL解析。
+// 参数contentType不为空时覆盖下载器推测的内容类型
+func fileResponse(path string, pageURL string, contentType string) (*http.Response, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fileURL := &url.URL{Scheme: "file", Path: filepath.ToSlash(absPath)}
+	fileRequest, err := http.NewRequest("GET", fileURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	pageRequest, err := http.NewRequest("GET", pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := downloader.NewFilePageDownloader(nil).Download(*base.NewRequest(fileRequest, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	httpResponse := response.Response()
+	if httpResponse.StatusCode != http.StatusOK {
+		httpResponse.Body.Close()
+		return nil, errors.New(fmt.Sprintf("无法读取文件【%s】: %s", path, httpResponse.Status))
+	}
+
+	httpResponse.Request = pageRequest
+	if contentType != "" {
+		httpResponse.Header.Set("Content-Type", contentType)
+	}
+
+	return httpResponse, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileResponse(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "archive.html")
+	if err := os.WriteFile(path, []byte("<html><body>归档</body></html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := fileResponse(path, "http://blog.devtang.com/archives/", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	if response.Request.URL.String() != "http://blog.devtang.com/archives/" {
+		t.Errorf("响应应该对应页面URL，实际为%s", response.Request.URL)
+	}
+	if contentType := response.Header.Get("Content-Type"); contentType != "text/html; charset=utf-8" {
+		t.Errorf("应该根据扩展名推测内容类型，实际为%s", contentType)
+	}
+	if body, _ := io.ReadAll(response.Body); string(body) != "<html><body>归档</body></html>" {
+		t.Errorf("响应体不正确: %s", body)
+	}
+
+	if response, err := fileResponse(path, "http://blog.devtang.com/", "application/xhtml+xml"); err != nil ||
+		response.Header.Get("Content-Type") != "application/xhtml+xml" {
+		t.Errorf("指定的内容类型应该覆盖推测的类型: %v", err)
+	}
+
+	if _, err := fileResponse(filepath.Join(dir, "missing.html"), "http://blog.devtang.com/", ""); err == nil {
+		t.Error("文件不存在时应该返回错误")
+	}
+	if _, err := fileResponse(path, "://", ""); err == nil {
+		t.Error("页面URL无效时应该返回错误")
+	}
+}
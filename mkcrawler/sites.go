@@ -0,0 +1,35 @@
+package main
+
+import (
+	config "core/config"
+	"errors"
+	"fmt"
+)
+
+// 加载配置项websites指定的站点配置文件，并把其中的通道参数和池基本参数计入分层配置
+func loadWebsites(settings *config.Settings) (*config.Websites, error) {
+	path := settings.Get("websites")
+	websites, err := config.LoadWebsites(path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	settings.ApplyWebsites(websites, path)
+	return websites, nil
+}
+
+// 选出站点。参数name为空时选出所有站点
+func selectSites(websites *config.Websites, name string) ([]*config.Site, error) {
+	sites := make([]*config.Site, 0, len(websites.Sites))
+	for i := range websites.Sites {
+		if name == "" || websites.Sites[i].Name == name {
+			sites = append(sites, &websites.Sites[i])
+		}
+	}
+
+	if len(sites) == 0 {
+		return nil, errors.New(fmt.Sprintf("没有名为【%s】的站点！", name))
+	}
+
+	return sites, nil
+}
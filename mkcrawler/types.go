@@ -0,0 +1,19 @@
+package main
+
+import (
+	config "core/config"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+// 列出已注册的站点类型
+func runTypes(settings *config.Settings, args []string) error {
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "CODE\tNAME\tDESCRIPTION")
+	for _, siteType := range config.SiteTypes() {
+		fmt.Fprintf(table, "%d\t%s\t%s\n", siteType.Code, siteType.Name, siteType.Description)
+	}
+
+	return table.Flush()
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	config "core/config"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// 校验站点配置文件。没有给出文件时校验配置项websites指定的文件
+func runValidate(settings *config.Settings, args []string) error {
+	paths := args
+	if len(paths) == 0 {
+		paths = []string{settings.Get("websites")}
+	}
+
+	failed := 0
+	for _, path := range paths {
+		websites, err := config.LoadWebsites(path, nil)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed++
+			continue
+		}
+
+		fmt.Printf("%s: OK（%d个站点）\n", path, len(websites.Sites))
+	}
+
+	if failed > 0 {
+		return errors.New(fmt.Sprintf("%d个配置文件未通过校验。", failed))
+	}
+
+	return nil
+}